@@ -0,0 +1,45 @@
+// Command admin runs maintenance tasks against the database.
+//
+//	admin recompute-usage   rebuild every user's storage usage from files
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/config"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: admin recompute-usage")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(flag.NewFlagSet("admin "+os.Args[1], flag.ExitOnError), os.Args[2:])
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	db := config.NewPostgresPool(cfg.DB)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "recompute-usage":
+		n, err := usecase.NewUsageUsecase(repository.NewUsageRepository(db)).Recompute(ctx)
+		if err != nil {
+			log.Fatalf("recompute usage: %v", err)
+		}
+		log.Printf("corrected usage for %d users", n)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		os.Exit(2)
+	}
+}
@@ -1,57 +1,122 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
 	router "github.com/1sh-repalto/e2ee-file-sharing-platform/internal/routes"
-	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/worker"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/config"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env found.")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
 	}
 
-	db := config.NewPostgresPool()
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if cfg.Production() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	db := config.NewPostgresPool(cfg.DB)
 	defer db.Close()
+	checkSchema(db, cfg.DB.RequireCurrentSchema)
 
-	minioStorage, err := storage.NewMinioStorage(
-		"localhost:9000",
-		os.Getenv("MINIO_ROOT_USER"),
-		os.Getenv("MINIO_ROOT_PASSWORD"),
-		false,
-	)
+	metadataMode, err := usecase.ParseMetadataMode(cfg.Files.MetadataMode)
 	if err != nil {
-		log.Fatalf("failed to init minio: %v", err)
+		log.Fatalf("invalid configuration: %v", err)
 	}
+	objectStorage := config.NewStorage(cfg.Storage)
+	tokenSigner := config.NewTokenSigner(cfg.Auth)
 
 	userRepo := repository.NewUserRepository(db)
 	fileRepo := repository.NewFileRepository(db)
 	shareRepo := repository.NewShareRepository(db)
+	uploadSessionRepo := repository.NewUploadSessionRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	keyLogRepo := repository.NewKeyLogRepository(db)
+	userKeyRepo := repository.NewUserKeyRepository(db)
+	linkShareRepo := repository.NewLinkShareRepository(db)
+	groupRepo := repository.NewGroupRepository(db)
+	folderRepo := repository.NewFolderRepository(db)
+	usageRepo := repository.NewUsageRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
 
-	userUsecase := usecase.NewUserUsecase(userRepo)
-	fileUsecase := usecase.NewFileUsecase(fileRepo, shareRepo, minioStorage)
+	keyLogUsecase := usecase.NewKeyLogUsecase(keyLogRepo, config.LoadKeyLogSigningKey(cfg.KeyLog))
+	userUsecase := usecase.NewUserUsecase(userRepo, keyLogUsecase)
+	fileUsecase := usecase.NewFileUsecase(fileRepo, shareRepo, groupRepo, folderRepo, objectStorage, metadataMode)
 	shareUsecase := usecase.NewShareUsecase(shareRepo, fileRepo)
+	uploadUsecase := usecase.NewUploadUsecase(uploadSessionRepo, objectStorage, metadataMode)
+	sessionUsecase := usecase.NewSessionUsecase(sessionRepo, userRepo, tokenSigner)
+	keyUsecase := usecase.NewKeyUsecase(userRepo, userKeyRepo, fileRepo, shareRepo, keyLogUsecase)
+	linkUsecase := usecase.NewLinkUsecase(linkShareRepo, fileRepo, objectStorage)
+	groupUsecase := usecase.NewGroupUsecase(groupRepo, fileRepo)
+	folderUsecase := usecase.NewFolderUsecase(folderRepo, fileRepo)
+	usageUsecase := usecase.NewUsageUsecase(usageRepo)
+	reconcileUsecase := usecase.NewReconcileUsecase(fileRepo, outboxRepo, objectStorage)
 
-	userHandler := handler.NewUserHandler(userUsecase)
+	userHandler := handler.NewUserHandler(userUsecase, sessionUsecase)
 	fileHandler := handler.NewFileHandler(fileUsecase)
 	shareHandler := handler.NewShareHandler(shareUsecase)
+	uploadHandler := handler.NewUploadHandler(uploadUsecase)
+	sessionHandler := handler.NewSessionHandler(sessionUsecase)
+	keyLogHandler := handler.NewKeyLogHandler(keyLogUsecase)
+	keyHandler := handler.NewKeyHandler(keyUsecase)
+	linkHandler := handler.NewLinkHandler(linkUsecase)
+	groupHandler := handler.NewGroupHandler(groupUsecase)
+	folderHandler := handler.NewFolderHandler(folderUsecase)
+	usageHandler := handler.NewUsageHandler(usageUsecase)
 
-	r := gin.Default()
-	router.SetupRouter(r, userHandler, fileHandler, shareHandler)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go worker.NewUploadSweeper(uploadUsecase, 15*time.Minute).Run(ctx)
+	go worker.NewShareReaper(shareUsecase, 5*time.Minute).Run(ctx)
+	go worker.NewSessionReaper(sessionUsecase, time.Hour).Run(ctx)
+	go worker.NewLinkAttemptSweeper(linkUsecase, 15*time.Minute).Run(ctx)
+	go worker.NewTrashPurger(fileUsecase, time.Duration(cfg.Files.TrashRetention), time.Hour).Run(ctx)
+	go worker.NewReconciler(reconcileUsecase, time.Hour, time.Minute).Run(ctx)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3000"
+	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
 	}
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server at : %v", port)
+	router.SetupRouter(r, router.Handlers{
+		User:    userHandler,
+		File:    fileHandler,
+		Share:   shareHandler,
+		Upload:  uploadHandler,
+		Session: sessionHandler,
+		KeyLog:  keyLogHandler,
+		Key:     keyHandler,
+		Link:    linkHandler,
+		Group:   groupHandler,
+		Folder:  folderHandler,
+		Usage:   usageHandler,
+	}, tokenSigner, sessionUsecase)
+
+	if err := r.Run(":" + strconv.Itoa(cfg.Port)); err != nil {
+		log.Fatalf("Failed to start server at : %v", cfg.Port)
 	}
 }
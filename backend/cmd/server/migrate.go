@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/migrations"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/config"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/migrate"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = `usage: server migrate [flags] <command>
+
+commands:
+  up              apply every pending migration
+  down [n]        roll back the newest n migrations (default 1)
+  to <version>    migrate up or down to version; 0 rolls back everything
+  status          list migrations and when they were applied
+
+flags:`
+
+func newMigrator(db *pgxpool.Pool) *migrate.Migrator {
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("Unable to load migrations: %v", err)
+	}
+	return m
+}
+
+// checkSchema warns about pending migrations at startup, or refuses to
+// start when the configuration requires a current schema.
+func checkSchema(db *pgxpool.Pool, require bool) {
+	err := newMigrator(db).Check(context.Background())
+	if err == nil {
+		return
+	}
+	if require {
+		log.Fatalf("%v; run `server migrate up`", err)
+	}
+	log.Printf("%v; run `server migrate up`", err)
+}
+
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("server migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	var run func(*migrate.Migrator) error
+	switch {
+	case cmd == "up" && len(rest) == 0:
+		run = func(m *migrate.Migrator) error { return m.Up(ctx) }
+	case cmd == "down" && len(rest) <= 1:
+		steps := 1
+		if len(rest) == 1 {
+			if steps, err = strconv.Atoi(rest[0]); err != nil {
+				log.Fatalf("invalid step count %q", rest[0])
+			}
+		}
+		run = func(m *migrate.Migrator) error { return m.Down(ctx, steps) }
+	case cmd == "to" && len(rest) == 1:
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			log.Fatalf("invalid version %q", rest[0])
+		}
+		run = func(m *migrate.Migrator) error { return m.To(ctx, version) }
+	case cmd == "status" && len(rest) == 0:
+		run = func(m *migrate.Migrator) error { return printStatus(ctx, m) }
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db := config.NewPostgresPool(cfg.DB)
+	defer db.Close()
+
+	if err := run(newMigrator(db)); err != nil {
+		log.Fatalf("migrate %s: %v", cmd, err)
+	}
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
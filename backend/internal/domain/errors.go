@@ -0,0 +1,77 @@
+package domain
+
+import "strings"
+
+// Kind classifies an Error by what the caller can do about it. The HTTP
+// layer derives the response status from the kind alone.
+type Kind int
+
+const (
+	KindValidation Kind = iota + 1
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindGone
+	KindTooLarge
+	KindQuotaExceeded
+	KindTooManyRequests
+	KindUnprocessable
+)
+
+// Error is a failure that is safe to report to the client. Code is a stable
+// snake_case identifier clients can branch on; Message is human readable and
+// may change.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches another Error with the same code, or a bare kind sentinel such
+// as ErrNotFound, which matches every error of its kind.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && (t.Code == "" || t.Code == e.Code)
+}
+
+// Kind sentinels, for errors.Is checks that only care about the kind.
+var (
+	ErrValidation = &Error{Kind: KindValidation, Message: "validation failed"}
+	ErrForbidden  = &Error{Kind: KindForbidden, Message: "forbidden"}
+	ErrNotFound   = &Error{Kind: KindNotFound, Message: "not found"}
+	ErrConflict   = &Error{Kind: KindConflict, Message: "conflict"}
+)
+
+// NotFound reports that resource, e.g. "file" or "share", does not exist or
+// is not visible to the caller. Its code is "<resource>_not_found".
+func NotFound(resource string) error {
+	return &Error{
+		Kind:    KindNotFound,
+		Code:    strings.ReplaceAll(resource, " ", "_") + "_not_found",
+		Message: resource + " not found",
+	}
+}
+
+func Invalid(code, message string) error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func Conflict(code, message string) error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+// Unprocessable reports a well-formed request that asks for something the
+// caller's own grants do not allow, such as passing on a permission they
+// lack.
+func Unprocessable(code, message string) error {
+	return &Error{Kind: KindUnprocessable, Code: code, Message: message}
+}
@@ -6,13 +6,88 @@ import (
 	"github.com/google/uuid"
 )
 
+// File and version states. Rows are written as pending before their object
+// is uploaded and committed after, so a crash in between leaves a record the
+// reconciler can finish or roll back.
+const (
+	FileStatePending   = "pending"
+	FileStateCommitted = "committed"
+)
+
+// Content formats. FormatLegacy is a single AES-GCM ciphertext whose nonce
+// is stored in IV; FormatChunkedV1 is a pkg/chunkcrypt container that
+// carries its own nonce prefix in the header.
+const (
+	FormatLegacy    = 0
+	FormatChunkedV1 = 1
+)
+
+// File.Filename and File.MimeType are only populated for files uploaded
+// before metadata encryption; newer files carry them, along with the
+// original size and any custom attributes, in EncryptedMetadata sealed under
+// the file key.
 type File struct {
-	ID           uuid.UUID `db:"id"`
-	OwnerID      uuid.UUID `db:"owner_id"`
-	Filename     string    `db:"filename"`
-	MimeType     string    `db:"mime_type"`
-	Size         int64     `db:"size"`
-	IV           []byte    `db:"iv"`
-	EncryptedKey []byte    `db:"encrypted_key"`
-	CreatedAt    time.Time `db:"created_at"`
+	ID            uuid.UUID `db:"id"`
+	OwnerID       uuid.UUID `db:"owner_id"`
+	Filename      string    `db:"filename"`
+	MimeType      string    `db:"mime_type"`
+	Size          int64     `db:"size"`
+	IV            []byte    `db:"iv"`
+	EncryptedKey  []byte    `db:"encrypted_key"`
+	FormatVersion int       `db:"format_version"`
+	ChunkSize     int       `db:"chunk_size"`
+	KeyVersion    int       `db:"key_version"`
+	CreatedAt     time.Time `db:"created_at"`
+
+	EncryptedMetadata []byte `db:"encrypted_metadata"`
+	// Version, VersionKey and ObjectName describe the current version; the
+	// content fields above (Size, IV, FormatVersion, ChunkSize) mirror it.
+	Version    int    `db:"version"`
+	VersionKey []byte `db:"version_key"`
+	ObjectName string `db:"object_name"`
+	// FolderWrappedKey is the file key wrapped under the key of FolderID,
+	// which lets folder share recipients reach it.
+	FolderID         *uuid.UUID `db:"folder_id"`
+	FolderWrappedKey []byte     `db:"folder_wrapped_key"`
+
+	// DeletedAt is set while the file sits in its owner's trash.
+	DeletedAt *time.Time `db:"deleted_at"`
+	State     string     `db:"state"`
+}
+
+// ErrFileUnavailable is returned for files in the trash.
+var ErrFileUnavailable = &Error{Kind: KindGone, Code: "file_unavailable", Message: "file is unavailable"}
+
+func (f File) Trashed() bool {
+	return f.DeletedAt != nil
+}
+
+// FileVersion is one stored ciphertext of a file. VersionKey is the
+// version's content key wrapped under the file key; it is empty when the
+// content is encrypted directly under the file key, as it is for every
+// file's first version. Either way, everyone who can unwrap the file key
+// can read every version, so shares survive new uploads.
+type FileVersion struct {
+	FileID        uuid.UUID `db:"file_id"`
+	Version       int       `db:"version"`
+	ObjectName    string    `db:"object_name"`
+	Size          int64     `db:"size"`
+	IV            []byte    `db:"iv"`
+	VersionKey    []byte    `db:"version_key"`
+	FormatVersion int       `db:"format_version"`
+	ChunkSize     int       `db:"chunk_size"`
+	State         string    `db:"state"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// AtVersion returns f with its content fields replaced by those of v.
+func (f File) AtVersion(v FileVersion) File {
+	f.Version = v.Version
+	f.ObjectName = v.ObjectName
+	f.Size = v.Size
+	f.IV = v.IV
+	f.VersionKey = v.VersionKey
+	f.FormatVersion = v.FormatVersion
+	f.ChunkSize = v.ChunkSize
+	return f
 }
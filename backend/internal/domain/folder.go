@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Folder is a node in a user's folder tree. Each folder has its own
+// symmetric key: WrappedKey is that key wrapped under the parent folder's
+// key, or under the owner's keypair version KeyVersion for top-level
+// folders. EncryptedName is sealed with the folder's own key, so the server
+// only ever learns the shape of the tree.
+type Folder struct {
+	ID            uuid.UUID  `db:"id"`
+	OwnerID       uuid.UUID  `db:"owner_id"`
+	ParentID      *uuid.UUID `db:"parent_id"`
+	EncryptedName []byte     `db:"encrypted_name"`
+	WrappedKey    []byte     `db:"wrapped_key"`
+	KeyVersion    int        `db:"key_version"`
+	CreatedAt     time.Time  `db:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+}
+
+// FolderShare grants a recipient access to a folder and everything below
+// it. WrappedKey is the folder key wrapped for the recipient's keypair
+// version KeyVersion.
+type FolderShare struct {
+	ID          uuid.UUID       `db:"id"`
+	FolderID    uuid.UUID       `db:"folder_id"`
+	RecipientID uuid.UUID       `db:"recipient_id"`
+	WrappedKey  []byte          `db:"wrapped_key"`
+	KeyVersion  int             `db:"key_version"`
+	Permissions SharePermission `db:"permissions"`
+	GrantedBy   uuid.UUID       `db:"granted_by"`
+	CreatedAt   time.Time       `db:"created_at"`
+	ExpiresAt   *time.Time      `db:"expires_at"`
+}
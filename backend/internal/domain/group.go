@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGroupMembershipMismatch = &Error{Kind: KindValidation, Code: "group_membership_mismatch", Message: "group keys must be wrapped for exactly the group's members"}
+	ErrGroupSharesMismatch     = &Error{Kind: KindValidation, Code: "group_shares_mismatch", Message: "file keys must be rewrapped for exactly the group's shares"}
+	ErrGroupKeyVersionConflict = &Error{Kind: KindConflict, Code: "group_key_version_conflict", Message: "group key version conflict"}
+)
+
+// Group is a set of users sharing a keypair. Files shared with a group wrap
+// their key once for the group's public key; the group private key is in
+// turn wrapped for every member. PublicKey and KeyVersion mirror the
+// current group key.
+type Group struct {
+	ID         uuid.UUID `db:"id"`
+	Name       string    `db:"name"`
+	OwnerID    uuid.UUID `db:"owner_id"`
+	KeyVersion int       `db:"key_version"`
+	PublicKey  string    `db:"public_key"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type GroupMember struct {
+	GroupID  uuid.UUID `db:"group_id"`
+	UserID   uuid.UUID `db:"user_id"`
+	Username string    `db:"username"`
+	JoinedAt time.Time `db:"joined_at"`
+}
+
+// GroupMemberKey is version Version of a group's private key wrapped for a
+// member's keypair version UserKeyVersion.
+type GroupMemberKey struct {
+	GroupID           uuid.UUID `db:"group_id"`
+	Version           int       `db:"version"`
+	UserID            uuid.UUID `db:"user_id"`
+	WrappedPrivateKey []byte    `db:"wrapped_private_key"`
+	UserKeyVersion    int       `db:"user_key_version"`
+}
+
+// GroupShare grants every member of a group access to a file. WrappedKey is
+// the file key wrapped for the group keypair KeyVersion.
+type GroupShare struct {
+	ID          uuid.UUID       `db:"id"`
+	FileID      uuid.UUID       `db:"file_id"`
+	GroupID     uuid.UUID       `db:"group_id"`
+	WrappedKey  []byte          `db:"wrapped_key"`
+	KeyVersion  int             `db:"key_version"`
+	Permissions SharePermission `db:"permissions"`
+	GrantedBy   uuid.UUID       `db:"granted_by"`
+	CreatedAt   time.Time       `db:"created_at"`
+	ExpiresAt   *time.Time      `db:"expires_at"`
+}
+
+func (s GroupShare) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// GroupRotation replaces a group's keypair with version Version, applying
+// the membership changes in Add and Remove at the same time. MemberKeys must
+// hold the new private key wrapped for every resulting member, and Shares
+// every group share's file key rewrapped for the new public key, so that
+// members added now can open files shared before they joined.
+type GroupRotation struct {
+	GroupID    uuid.UUID
+	Version    int
+	PublicKey  string
+	Add        []uuid.UUID
+	Remove     []uuid.UUID
+	MemberKeys []GroupMemberKey
+	Shares     []GroupShareRewrap
+	CreatedAt  time.Time
+}
+
+// GroupShareRewrap is a group share's file key wrapped for a new group
+// keypair.
+type GroupShareRewrap struct {
+	ShareID    uuid.UUID
+	WrappedKey []byte
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrKeyLogIndexTaken = &Error{Kind: KindConflict, Code: "key_log_index_taken", Message: "key log index already taken"}
+
+type KeyLogEntry struct {
+	Index     int64     `db:"idx"`
+	UserID    uuid.UUID `db:"user_id"`
+	PublicKey []byte    `db:"public_key"`
+	LeafData  []byte    `db:"leaf_data"`
+	LeafHash  []byte    `db:"leaf_hash"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// KeyLogNode is the stored root of a complete subtree of the key log, see
+// merkle.NodeID. Leaves are level 0 and live in KeyLogEntry.LeafHash.
+type KeyLogNode struct {
+	Level int    `db:"level"`
+	Index int64  `db:"idx"`
+	Hash  []byte `db:"hash"`
+}
+
+type TreeHead struct {
+	TreeSize    int64  `db:"tree_size"`
+	RootHash    []byte `db:"root_hash"`
+	TimestampMs int64  `db:"timestamp_ms"`
+	Signature   []byte `db:"signature"`
+}
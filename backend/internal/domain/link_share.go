@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLinkUnavailable      = &Error{Kind: KindGone, Code: "link_unavailable", Message: "link has expired, been revoked or reached its download limit"}
+	ErrLinkPasswordRequired = &Error{Kind: KindUnauthorized, Code: "link_password_required", Message: "link password is missing or incorrect"}
+	ErrLinkLocked           = &Error{Kind: KindTooManyRequests, Code: "link_locked", Message: "too many password attempts, try again later"}
+)
+
+// LinkShare grants anonymous access to a file's ciphertext through an opaque
+// token. The file key never reaches the server: clients carry it in the URL
+// fragment. Only a hash of the token is stored, and MaxDownloads of zero
+// means unlimited.
+type LinkShare struct {
+	ID            uuid.UUID  `db:"id"`
+	FileID        uuid.UUID  `db:"file_id"`
+	OwnerID       uuid.UUID  `db:"owner_id"`
+	TokenHash     []byte     `db:"token_hash"`
+	PasswordHash  string     `db:"password_hash"`
+	MaxDownloads  int        `db:"max_downloads"`
+	DownloadCount int        `db:"download_count"`
+	CreatedAt     time.Time  `db:"created_at"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	RevokedAt     *time.Time `db:"revoked_at"`
+}
+
+func (l LinkShare) Active(now time.Time) bool {
+	if l.RevokedAt != nil || !now.Before(l.ExpiresAt) {
+		return false
+	}
+	return l.MaxDownloads == 0 || l.DownloadCount < l.MaxDownloads
+}
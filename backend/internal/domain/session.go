@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSessionRevoked      = &Error{Kind: KindUnauthorized, Code: "session_revoked", Message: "session has been revoked or expired"}
+	ErrInvalidRefreshToken = &Error{Kind: KindUnauthorized, Code: "invalid_refresh_token", Message: "invalid refresh token"}
+	ErrRefreshTokenReused  = &Error{Kind: KindUnauthorized, Code: "refresh_token_reused", Message: "refresh token reuse detected"}
+)
+
+type Session struct {
+	ID         uuid.UUID  `db:"id"`
+	UserID     uuid.UUID  `db:"user_id"`
+	UserAgent  string     `db:"user_agent"`
+	IPAddress  string     `db:"ip_address"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt time.Time  `db:"last_used_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type RefreshToken struct {
+	TokenHash []byte     `db:"token_hash"`
+	SessionID uuid.UUID  `db:"session_id"`
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
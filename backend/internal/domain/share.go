@@ -1,15 +1,98 @@
 package domain
 
 import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ShareEventExpired is recorded in share_events when the reaper removes a
+// share whose ExpiresAt has passed.
+const ShareEventExpired = "expired"
+
+var (
+	// ErrAlreadyShared is returned when the recipient already holds a share
+	// of the same file or folder.
+	ErrAlreadyShared = &Error{Kind: KindConflict, Code: "already_shared", Message: "already shared with this recipient"}
+	ErrShareExpired  = &Error{Kind: KindGone, Code: "share_expired", Message: "share has expired"}
+)
+
 type Share struct {
-	ID          uuid.UUID `db:"id"`
-	FileID      uuid.UUID `db:"file_id"`
-	RecipientID uuid.UUID `db:"recipient_id"`
-	WrappedKey  []byte    `db:"wrapped_key"`
-	CreatedAt   time.Time `db:"created_at"`
+	ID          uuid.UUID       `db:"id"`
+	FileID      uuid.UUID       `db:"file_id"`
+	RecipientID uuid.UUID       `db:"recipient_id"`
+	WrappedKey  []byte          `db:"wrapped_key"`
+	KeyVersion  int             `db:"key_version"`
+	CreatedAt   time.Time       `db:"created_at"`
+	ExpiresAt   *time.Time      `db:"expires_at"`
+	Permissions SharePermission `db:"permissions"`
+	// GrantedBy is the user who created the share; GrantorChain lists every
+	// grantor from the file owner down to GrantedBy.
+	GrantedBy    uuid.UUID   `db:"granted_by"`
+	GrantorChain []uuid.UUID `db:"grantor_chain"`
+}
+
+// Expired reports whether the share is time-boxed and its window has closed.
+// A nil ExpiresAt never expires.
+func (s Share) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// Kinds of ShareListing.
+const (
+	ShareListingUser   = "user"
+	ShareListingGroup  = "group"
+	ShareListingFolder = "folder"
+)
+
+// ShareListing is a share as presented to the file's owner or a manager,
+// joined with the names they need to recognise it. Kind says which table it
+// came from: a user share fills RecipientID, a group share of the file fills
+// GroupID instead, and a folder share fills FolderID and RecipientID but has
+// no FileID or Filename.
+type ShareListing struct {
+	Share
+	Kind              string    `db:"kind"`
+	GroupID           uuid.UUID `db:"group_id"`
+	FolderID          uuid.UUID `db:"folder_id"`
+	Filename          string    `db:"filename"`
+	RecipientUsername string    `db:"recipient_username"`
+	GroupName         string    `db:"group_name"`
+}
+
+var errInvalidShareCursor = errors.New("invalid share cursor")
+
+// ShareCursor marks a position in a share listing ordered newest first by
+// (CreatedAt, ID). It carries both keys, so a page can follow a share that
+// has since been revoked. The zero cursor is the start of the listing.
+type ShareCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (c ShareCursor) IsZero() bool {
+	return c.ID == uuid.Nil
+}
+
+// String encodes the cursor as URL-safe base64 of the creation time in Unix
+// nanoseconds followed by the ID.
+func (c ShareCursor) String() string {
+	b := binary.BigEndian.AppendUint64(nil, uint64(c.CreatedAt.UnixNano()))
+	return base64.RawURLEncoding.EncodeToString(append(b, c.ID[:]...))
+}
+
+func ParseShareCursor(s string) (ShareCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) != 8+len(uuid.UUID{}) {
+		return ShareCursor{}, errInvalidShareCursor
+	}
+	c := ShareCursor{CreatedAt: time.Unix(0, int64(binary.BigEndian.Uint64(b))).UTC()}
+	copy(c.ID[:], b[8:])
+	if c.IsZero() {
+		return ShareCursor{}, errInvalidShareCursor
+	}
+	return c, nil
 }
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SharePermission is a bit set of what a share recipient may do with the
+// shared file.
+type SharePermission int
+
+const (
+	PermView SharePermission = 1 << iota
+	PermDownload
+	PermReshare
+	PermManage
+
+	PermAll = PermView | PermDownload | PermReshare | PermManage
+
+	// PermDefault is what a share grants when no permissions are given, and
+	// what every share created before permissions existed was migrated to.
+	PermDefault = PermView | PermDownload
+)
+
+var ErrPermissionDenied = &Error{Kind: KindForbidden, Code: "permission_denied", Message: "permission denied"}
+
+var permissionNames = []struct {
+	perm SharePermission
+	name string
+}{
+	{PermView, "view"},
+	{PermDownload, "download"},
+	{PermReshare, "reshare"},
+	{PermManage, "manage"},
+}
+
+func (p SharePermission) Has(want SharePermission) bool {
+	return p&want == want
+}
+
+// Normalize adds the permissions every other permission depends on: nothing
+// is useful without view.
+func (p SharePermission) Normalize() SharePermission {
+	if p != 0 {
+		p |= PermView
+	}
+	return p & PermAll
+}
+
+func (p SharePermission) Strings() []string {
+	names := []string{}
+	for _, n := range permissionNames {
+		if p.Has(n.perm) {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+func ParsePermissions(names []string) (SharePermission, error) {
+	var p SharePermission
+	for _, name := range names {
+		found := false
+		for _, n := range permissionNames {
+			if n.name == name {
+				p |= n.perm
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	return p.Normalize(), nil
+}
+
+func (p SharePermission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Strings())
+}
+
+func (p *SharePermission) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	parsed, err := ParsePermissions(names)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
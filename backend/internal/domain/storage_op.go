@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+const StorageOpDelete = "delete"
+
+// StorageOp is an outbox entry for an object operation that must happen
+// after a database change has committed. Entries are retried until they
+// succeed.
+type StorageOp struct {
+	ID            int64     `db:"id"`
+	Op            string    `db:"op"`
+	Bucket        string    `db:"bucket"`
+	ObjectName    string    `db:"object_name"`
+	Attempts      int       `db:"attempts"`
+	LastError     string    `db:"last_error"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	CreatedAt     time.Time `db:"created_at"`
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUploadOffsetMismatch = &Error{Kind: KindConflict, Code: "upload_offset_mismatch", Message: "upload offset does not match current offset"}
+	ErrUploadSessionExpired = &Error{Kind: KindGone, Code: "upload_session_expired", Message: "upload session expired"}
+	ErrUploadBusy           = &Error{Kind: KindConflict, Code: "upload_busy", Message: "another request is using this upload session"}
+)
+
+type UploadSession struct {
+	ID                uuid.UUID `db:"id"`
+	OwnerID           uuid.UUID `db:"owner_id"`
+	FileID            uuid.UUID `db:"file_id"`
+	Filename          string    `db:"filename"`
+	MimeType          string    `db:"mime_type"`
+	EncryptedMetadata []byte    `db:"encrypted_metadata"`
+	Size              int64     `db:"size"`
+	Offset            int64     `db:"upload_offset"`
+	PartCount         int       `db:"part_count"`
+	IV                []byte    `db:"iv"`
+	EncryptedKey      []byte    `db:"encrypted_key"`
+	FormatVersion     int       `db:"format_version"`
+	ChunkSize         int       `db:"chunk_size"`
+	StorageUploadID   string    `db:"storage_upload_id"`
+	ExpiresAt         time.Time `db:"expires_at"`
+	CreatedAt         time.Time `db:"created_at"`
+}
+
+type UploadPart struct {
+	SessionID  uuid.UUID `db:"session_id"`
+	PartNumber int       `db:"part_number"`
+	ETag       string    `db:"etag"`
+	Size       int64     `db:"size"`
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "github.com/google/uuid"
+
+var (
+	// ErrQuotaExceeded means the upload would fit the quota but not the
+	// space the user has left.
+	ErrQuotaExceeded = &Error{Kind: KindQuotaExceeded, Code: "quota_exceeded", Message: "storage quota exceeded"}
+	// ErrFileTooLarge means the upload is larger than the whole quota.
+	ErrFileTooLarge = &Error{Kind: KindTooLarge, Code: "file_too_large", Message: "file is larger than the storage quota"}
+)
+
+// Usage is how many bytes of ciphertext a user stores, counting every file
+// version, trashed files and in-progress uploads, against the quota of their
+// plan or their own override.
+type Usage struct {
+	UserID     uuid.UUID `db:"id"`
+	Plan       string    `db:"plan"`
+	UsedBytes  int64     `db:"used_bytes"`
+	QuotaBytes int64     `db:"quota_bytes"`
+}
+
+func (u Usage) Available() int64 {
+	if u.UsedBytes >= u.QuotaBytes {
+		return 0
+	}
+	return u.QuotaBytes - u.UsedBytes
+}
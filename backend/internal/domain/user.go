@@ -6,11 +6,46 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	ErrInvalidCredentials  = &Error{Kind: KindUnauthorized, Code: "invalid_credentials", Message: "invalid credentials"}
+	ErrPublicKeyMismatch   = &Error{Kind: KindValidation, Code: "public_key_mismatch", Message: "public key does not match the stored key of that version"}
+	ErrKeyVersionMissing   = &Error{Kind: KindValidation, Code: "key_version_missing", Message: "a re-encrypted private key is required for every key version"}
+	ErrDuplicateKeyVersion = &Error{Kind: KindValidation, Code: "duplicate_key_version", Message: "each key version may be submitted only once"}
+	ErrUsernameTaken       = &Error{Kind: KindConflict, Code: "username_taken", Message: "username already exists"}
+)
+
 type User struct {
 	ID                  uuid.UUID `db:"id"`
 	Username            string    `db:"username"`
 	PasswordHash        string    `db:"password_hash"`
 	PublicKey           string    `db:"public_key"`
 	EncryptedPrivateKey []byte    `db:"encrypted_private_key"`
+	KeyVersion          int       `db:"key_version"`
 	CreatedAt           time.Time `db:"created_at"`
 }
+
+// UserKey is one version of a user's keypair. User.PublicKey and
+// User.EncryptedPrivateKey always mirror the current, unretired version.
+type UserKey struct {
+	UserID              uuid.UUID  `db:"user_id"`
+	Version             int        `db:"version"`
+	PublicKey           string     `db:"public_key"`
+	EncryptedPrivateKey []byte     `db:"encrypted_private_key"`
+	CreatedAt           time.Time  `db:"created_at"`
+	RetiredAt           *time.Time `db:"retired_at"`
+}
+
+const (
+	RewrapKindShare = "share"
+	RewrapKindFile  = "file"
+)
+
+// RewrapItem is a wrapped file key that still targets a retired keypair
+// version and must be rewrapped client-side under the current one.
+type RewrapItem struct {
+	Kind       string    `json:"kind"`
+	ID         uuid.UUID `json:"id"`
+	FileID     uuid.UUID `json:"file_id"`
+	KeyVersion int       `json:"key_version"`
+	WrappedKey []byte    `json:"wrapped_key"`
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+)
+
+// Handlers record failures with c.Error and return; middleware.ErrorHandler
+// renders them. These build the errors for malformed requests, which never
+// reach a usecase.
+
+var errUnauthenticated = &domain.Error{Kind: domain.KindUnauthorized, Code: "unauthenticated", Message: "unauthorized"}
+
+// invalidParam reports an unparsable path or query parameter, e.g.
+// invalidParam("file id") is "invalid file id" with code invalid_file_id.
+func invalidParam(name string) error {
+	return domain.Invalid("invalid_"+strings.ReplaceAll(name, " ", "_"), "invalid "+name)
+}
+
+// invalidRequest reports a body that failed to bind.
+func invalidRequest(err error) error {
+	return domain.Invalid("invalid_request", err.Error())
+}
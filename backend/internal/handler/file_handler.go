@@ -2,11 +2,14 @@ package handler
 
 import (
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/middleware"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -23,13 +26,13 @@ func NewFileHandler(fu usecase.FileUsecase) *FileHandler {
 func (h *FileHandler) Upload(c *gin.Context) {
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		c.Error(domain.Invalid("file_required", "file is required"))
 		return
 	}
 
 	fileContent, err := fileHeader.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot open file"})
+		c.Error(err)
 		return
 	}
 	defer fileContent.Close()
@@ -40,24 +43,58 @@ func (h *FileHandler) Upload(c *gin.Context) {
 	size := fileHeader.Size
 	filename := fileHeader.Filename
 
+	// Like the JSON upload-session body, the form carries the sealed
+	// metadata as standard base64.
+	encryptedMetadata, err := base64.StdEncoding.DecodeString(c.PostForm("encrypted_metadata"))
+	if err != nil {
+		c.Error(invalidParam("encrypted_metadata"))
+		return
+	}
+
+	formatVersion := domain.FormatLegacy
+	if v := c.PostForm("format_version"); v != "" {
+		formatVersion, err = strconv.Atoi(v)
+		if err != nil {
+			c.Error(invalidParam("format_version"))
+			return
+		}
+	}
+
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.Error(errUnauthenticated)
 		return
 	}
 	ownerID := uid.(uuid.UUID)
 
+	var folderID *uuid.UUID
+	var folderWrappedKey []byte
+	if v := c.PostForm("folder_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.Error(invalidParam("folder_id"))
+			return
+		}
+		folderID = &id
+		folderWrappedKey = []byte(c.PostForm("folder_wrapped_key"))
+	}
+
 	file := domain.File{
-		OwnerID:      ownerID,
-		Filename:     filename,
-		MimeType:     mimeType,
-		Size:         size,
-		IV:           []byte(iv),
-		EncryptedKey: []byte(encryptedKey),
+		OwnerID:           ownerID,
+		Filename:          filename,
+		MimeType:          mimeType,
+		EncryptedMetadata: encryptedMetadata,
+		Size:              size,
+		IV:                []byte(iv),
+		EncryptedKey:      []byte(encryptedKey),
+		FormatVersion:     formatVersion,
+		FolderID:          folderID,
+		FolderWrappedKey:  folderWrappedKey,
 	}
 
-	if err := h.fileUsecase.Upload(c.Request.Context(), file, fileContent); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	file, err = h.fileUsecase.Upload(c.Request.Context(), file, fileContent)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -67,59 +104,132 @@ func (h *FileHandler) Upload(c *gin.Context) {
 func (h *FileHandler) Download(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		c.Error(invalidParam("file id"))
 		return
 	}
 
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.Error(errUnauthenticated)
 		return
 	}
 	recipientID := uid.(uuid.UUID)
 
-	content, file, wrappedKey, err := h.fileUsecase.Download(c.Request.Context(), id, recipientID)
+	version := 0
+	if v := c.Query("version"); v != "" {
+		version, err = strconv.Atoi(v)
+		if err != nil || version < 1 {
+			c.Error(invalidParam("version"))
+			return
+		}
+	}
+
+	file, grant, info, err := h.fileUsecase.Stat(c.Request.Context(), id, recipientID, version)
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	defer content.Close()
 
-	c.Header("Content-Disposition", "attachment; filename="+file.Filename)
-	c.Header("Content-Type", file.MimeType)
-	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	etag := quoteETag(info.ETag)
+	c.Header("Accept-Ranges", "bytes")
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
 
-	if len(wrappedKey) > 0 {
-		c.Header("X-Wrapped-Key", base64.StdEncoding.EncodeToString(wrappedKey))
+	if len(grant.WrappedKey) > 0 {
+		c.Header("X-Wrapped-Key", base64.StdEncoding.EncodeToString(grant.WrappedKey))
+		if grant.FolderID != uuid.Nil {
+			c.Header("X-Folder-ID", grant.FolderID.String())
+		} else {
+			c.Header("X-Key-Version", strconv.Itoa(grant.KeyVersion))
+		}
+	}
+	if grant.GroupID != uuid.Nil {
+		c.Header("X-Group-ID", grant.GroupID.String())
+		c.Header("X-Group-Key-Version", strconv.Itoa(grant.GroupKeyVersion))
+		c.Header("X-Wrapped-Group-Key", base64.StdEncoding.EncodeToString(grant.WrappedGroupKey))
 	}
 
 	if len(file.IV) > 0 {
 		c.Header("X-IV", base64.StdEncoding.EncodeToString(file.IV))
 	}
+	if len(file.EncryptedMetadata) > 0 {
+		c.Header("X-Encrypted-Metadata", base64.StdEncoding.EncodeToString(file.EncryptedMetadata))
+	}
+	c.Header("X-Version", strconv.Itoa(file.Version))
+	if len(file.VersionKey) > 0 {
+		c.Header("X-Version-Key", base64.StdEncoding.EncodeToString(file.VersionKey))
+	}
 
-	_, err = io.Copy(c.Writer, content)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to stream file"})
+	c.Header("X-Format-Version", strconv.Itoa(file.FormatVersion))
+	if file.ChunkSize > 0 {
+		c.Header("X-Chunk-Size", strconv.Itoa(file.ChunkSize))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatchesAny(inm, etag) {
+		c.Status(http.StatusNotModified)
 		return
 	}
-}
 
-func (h *FileHandler) GetByID(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
+	status := http.StatusOK
+	rng := byteRange{start: 0, length: info.Size}
+	if header := c.GetHeader("Range"); header != "" && ifRangeMatches(c.GetHeader("If-Range"), etag, info.LastModified) {
+		parsed, ok, err := parseRange(header, info.Size)
+		if errors.Is(err, errRangeNotSatisfiable) {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			middleware.WriteProblem(c, http.StatusRequestedRangeNotSatisfiable, "range_not_satisfiable", err.Error())
+			return
+		}
+		if ok {
+			status = http.StatusPartialContent
+			rng = parsed
+			c.Header("Content-Range", rng.contentRange(info.Size))
+		}
+	}
+
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// The real name may be encrypted, so the download is named after the ID
+	// and the client renames it once it has decrypted the metadata.
+	c.Header("Content-Disposition", `attachment; filename="`+file.ID.String()+`"`)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(rng.length, 10))
+
+	if c.Request.Method == http.MethodHead || rng.length == 0 {
+		c.Status(status)
+		return
+	}
+
+	content, err := h.fileUsecase.DownloadRange(c.Request.Context(), file, rng.start, rng.length, info.ETag)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		c.Error(err)
 		return
 	}
+	defer content.Close()
 
-	file, err := h.fileUsecase.GetByID(c.Request.Context(), id)
+	c.Status(status)
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		// Headers are already on the wire, so all we can do is record it.
+		_ = c.Error(err)
+	}
+}
+
+func (h *FileHandler) GetByID(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		c.Error(invalidParam("file id"))
 		return
 	}
 
 	uid, _ := c.Get("userID")
-	if file.OwnerID != uid.(uuid.UUID) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	file, err := h.fileUsecase.GetByID(c.Request.Context(), id, uid.(uuid.UUID))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -129,7 +239,7 @@ func (h *FileHandler) GetByID(c *gin.Context) {
 func (h *FileHandler) ListByOwner(c *gin.Context) {
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.Error(errUnauthenticated)
 		return
 	}
 
@@ -137,31 +247,243 @@ func (h *FileHandler) ListByOwner(c *gin.Context) {
 
 	files, err := h.fileUsecase.ListByOwner(c.Request.Context(), ownerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, files)
 }
 
+type updateMetadataRequest struct {
+	EncryptedMetadata []byte `json:"encrypted_metadata" binding:"required"`
+}
+
+func (h *FileHandler) UpdateMetadata(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	var req updateMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	if err := h.fileUsecase.UpdateMetadata(c.Request.Context(), id, uid.(uuid.UUID), req.EncryptedMetadata); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "metadata updated"})
+}
+
 func (h *FileHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		c.Error(invalidParam("file id"))
 		return
 	}
 
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.Error(errUnauthenticated)
 		return
 	}
 	ownerID := uid.(uuid.UUID)
 
 	if err := h.fileUsecase.Delete(c.Request.Context(), id, ownerID); err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "file moved to trash"})
+}
+
+func (h *FileHandler) ListTrash(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	files, err := h.fileUsecase.ListTrash(c.Request.Context(), uid.(uuid.UUID))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, files)
+}
+
+func (h *FileHandler) RestoreFromTrash(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	if err := h.fileUsecase.RestoreFromTrash(c.Request.Context(), id, uid.(uuid.UUID)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "file restored"})
+}
+
+func (h *FileHandler) DeleteForever(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	if err := h.fileUsecase.DeleteForever(c.Request.Context(), id, uid.(uuid.UUID)); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "file deleted"})
+}
+
+func (h *FileHandler) EmptyTrash(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	n, err := h.fileUsecase.EmptyTrash(c.Request.Context(), uid.(uuid.UUID))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": n})
+}
+
+func (h *FileHandler) UploadVersion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.Error(domain.Invalid("file_required", "file is required"))
+		return
+	}
+
+	fileContent, err := fileHeader.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer fileContent.Close()
+
+	formatVersion := domain.FormatLegacy
+	if v := c.PostForm("format_version"); v != "" {
+		formatVersion, err = strconv.Atoi(v)
+		if err != nil {
+			c.Error(invalidParam("format_version"))
+			return
+		}
+	}
+
+	version := domain.FileVersion{
+		Size:          fileHeader.Size,
+		IV:            []byte(c.PostForm("iv")),
+		VersionKey:    []byte(c.PostForm("version_key")),
+		FormatVersion: formatVersion,
+	}
+
+	uid, _ := c.Get("userID")
+	version, err = h.fileUsecase.UploadVersion(c.Request.Context(), id, uid.(uuid.UUID), version, fileContent)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, version)
+}
+
+func (h *FileHandler) ListVersions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	versions, err := h.fileUsecase.ListVersions(c.Request.Context(), id, uid.(uuid.UUID))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func (h *FileHandler) RestoreVersion(c *gin.Context) {
+	id, version, ok := parseFileVersion(c)
+	if !ok {
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	if err := h.fileUsecase.RestoreVersion(c.Request.Context(), id, uid.(uuid.UUID), version); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "version restored", "version": version})
+}
+
+func (h *FileHandler) DeleteVersion(c *gin.Context) {
+	id, version, ok := parseFileVersion(c)
+	if !ok {
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	if err := h.fileUsecase.DeleteVersion(c.Request.Context(), id, uid.(uuid.UUID), version); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "version deleted"})
+}
+
+// PruneVersions deletes all but the newest ?keep= versions, never touching
+// the current one.
+func (h *FileHandler) PruneVersions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	keep, err := strconv.Atoi(c.Query("keep"))
+	if err != nil {
+		c.Error(invalidParam("keep"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	pruned, err := h.fileUsecase.PruneVersions(c.Request.Context(), id, uid.(uuid.UUID), keep)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pruned": pruned})
+}
+
+func parseFileVersion(c *gin.Context) (uuid.UUID, int, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return uuid.Nil, 0, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.Error(invalidParam("version"))
+		return uuid.Nil, 0, false
+	}
+	return id, version, true
 }
\ No newline at end of file
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+	"github.com/google/uuid"
+)
+
+func TestDownload(t *testing.T) {
+	content := []byte("0123456789abcdef")
+
+	tests := []struct {
+		name string
+		// as is "owner", "downloader", "viewer" or "stranger".
+		as      string
+		path    string
+		header  http.Header
+		trashed bool
+
+		wantStatus int
+		wantBody   string
+		wantHeader map[string]string
+		// wantCode is the problem code of an error response.
+		wantCode string
+	}{
+		{
+			name:       "owner",
+			as:         "owner",
+			wantStatus: http.StatusOK,
+			wantBody:   string(content),
+			wantHeader: map[string]string{
+				"X-Wrapped-Key":  base64.StdEncoding.EncodeToString([]byte("owner-wrapped-key")),
+				"X-Version":      "1",
+				"Content-Length": "16",
+				"Content-Type":   "application/pdf",
+			},
+		},
+		{
+			name:       "recipient with download",
+			as:         "downloader",
+			wantStatus: http.StatusOK,
+			wantBody:   string(content),
+			wantHeader: map[string]string{
+				"X-Wrapped-Key": base64.StdEncoding.EncodeToString([]byte("recipient-wrapped-key")),
+			},
+		},
+		{
+			name:       "range",
+			as:         "owner",
+			header:     http.Header{"Range": {"bytes=4-7"}},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "4567",
+			wantHeader: map[string]string{"Content-Range": "bytes 4-7/16"},
+		},
+		{
+			name:       "unsatisfiable range",
+			as:         "owner",
+			header:     http.Header{"Range": {"bytes=100-"}},
+			wantStatus: http.StatusRequestedRangeNotSatisfiable,
+			wantHeader: map[string]string{"Content-Range": "bytes */16"},
+			wantCode:   "range_not_satisfiable",
+		},
+		{name: "recipient with view only", as: "viewer", wantStatus: http.StatusForbidden, wantCode: "permission_denied"},
+		{name: "stranger", as: "stranger", wantStatus: http.StatusNotFound, wantCode: "file_not_found"},
+		{name: "trashed", as: "owner", trashed: true, wantStatus: http.StatusGone, wantCode: "file_unavailable"},
+		{name: "invalid id", as: "owner", path: "/api/files/not-a-uuid/content", wantStatus: http.StatusBadRequest, wantCode: "invalid_file_id"},
+		{name: "invalid version", as: "owner", path: "?version=0", wantStatus: http.StatusBadRequest, wantCode: "invalid_version"},
+		{name: "missing version", as: "owner", path: "?version=7", wantStatus: http.StatusNotFound, wantCode: "file_version_not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			owner := f.user(t)
+			file := f.file(t, owner.ID, content)
+			users := map[string]uuid.UUID{"owner": owner.ID, "stranger": f.user(t).ID}
+			for name, perms := range map[string]domain.SharePermission{"downloader": domain.PermDefault, "viewer": domain.PermView} {
+				u := f.user(t)
+				repotest.NewShare(t, f.repos, file, u.ID, perms, time.Now(), nil)
+				users[name] = u.ID
+			}
+			if tt.trashed {
+				if err := f.files.Delete(context.Background(), file.ID, owner.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			path := "/api/files/" + file.ID.String() + "/content"
+			if len(tt.path) > 0 && tt.path[0] == '/' {
+				path = tt.path
+			} else {
+				path += tt.path
+			}
+
+			w := f.do(t, http.MethodGet, path, users[tt.as], nil, tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode != "" {
+				if got := problemCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %q, want %q", got, tt.wantCode)
+				}
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+			for k, v := range tt.wantHeader {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadConditional(t *testing.T) {
+	f := newFixture(t)
+	owner := f.user(t)
+	file := f.file(t, owner.ID, []byte("content"))
+	path := "/api/files/" + file.ID.String() + "/content"
+
+	w := f.do(t, http.MethodHead, path, owner.ID, nil, nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.Len() != 0 {
+		t.Fatalf("HEAD = %d with etag %q and %d body bytes", w.Code, etag, w.Body.Len())
+	}
+
+	w = f.do(t, http.MethodGet, path, owner.ID, nil, http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	f := newFixture(t)
+	owner := f.user(t)
+	recipient := f.user(t)
+	file := f.file(t, owner.ID, []byte("content"))
+	repotest.NewShare(t, f.repos, file, recipient.ID, domain.PermView, time.Now(), nil)
+	path := "/api/files/" + file.ID.String()
+
+	tests := []struct {
+		name       string
+		as         uuid.UUID
+		wantStatus int
+	}{
+		{"owner", owner.ID, http.StatusOK},
+		{"recipient", recipient.ID, http.StatusOK},
+		{"stranger", uuid.New(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := f.do(t, http.MethodGet, path, tt.as, nil, nil); w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	if err := f.files.Delete(context.Background(), file.ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w := f.do(t, http.MethodGet, path, owner.ID, nil, nil); w.Code != http.StatusGone {
+		t.Fatalf("status after trash = %d, want %d", w.Code, http.StatusGone)
+	}
+}
+
+func TestUploadMultipart(t *testing.T) {
+	tests := []struct {
+		name         string
+		metadata     string
+		wantStatus   int
+		wantCode     string
+		wantMetadata []byte
+	}{
+		{"base64 metadata", base64.StdEncoding.EncodeToString([]byte("sealed\x00metadata")), http.StatusCreated, "", []byte("sealed\x00metadata")},
+		{"no metadata", "", http.StatusCreated, "", nil},
+		{"not base64", "sealed metadata!", http.StatusBadRequest, "invalid_encrypted_metadata", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			owner := f.user(t)
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			mw.WriteField("iv", "iv")
+			mw.WriteField("encrypted_key", "key")
+			mw.WriteField("encrypted_metadata", tt.metadata)
+			part, _ := mw.CreateFormFile("file", "report.pdf")
+			part.Write([]byte("ciphertext"))
+			mw.Close()
+
+			w := f.do(t, http.MethodPost, "/api/files", owner.ID, &body, http.Header{"Content-Type": {mw.FormDataContentType()}})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode != "" {
+				if got := problemCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %q, want %q", got, tt.wantCode)
+				}
+				return
+			}
+
+			var res struct {
+				ID uuid.UUID `json:"id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+			stored, err := f.repos.Files.FindByID(context.Background(), res.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(stored.EncryptedMetadata, tt.wantMetadata) {
+				t.Fatalf("encrypted metadata = %q, want %q", stored.EncryptedMetadata, tt.wantMetadata)
+			}
+		})
+	}
+}
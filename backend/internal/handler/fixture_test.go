@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/middleware"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/memory"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fixture serves the file and share handlers over the in-memory
+// repositories. Requests authenticate as the user in the X-Test-User header.
+type fixture struct {
+	repos  repotest.Repositories
+	files  usecase.FileUsecase
+	router *gin.Engine
+}
+
+func newFixture(t *testing.T) *fixture {
+	s := memory.NewStore()
+	f := &fixture{repos: repotest.Repositories{
+		Users:  memory.NewUserRepository(s),
+		Files:  memory.NewFileRepository(s),
+		Shares: memory.NewShareRepository(s),
+		Groups: memory.NewGroupRepository(s),
+	}}
+	f.files = usecase.NewFileUsecase(f.repos.Files, f.repos.Shares, f.repos.Groups, repotest.NoFolders{}, storage.NewMemoryStorage(), usecase.MetadataCompat)
+	fileHandler := NewFileHandler(f.files)
+	shareHandler := NewShareHandler(usecase.NewShareUsecase(f.repos.Shares, f.repos.Files))
+
+	f.router = gin.New()
+	f.router.Use(middleware.ErrorHandler())
+	api := f.router.Group("/api", testAuth)
+	api.POST("/files", fileHandler.Upload)
+	api.GET("/files/:id", fileHandler.GetByID)
+	api.GET("/files/:id/content", fileHandler.Download)
+	api.HEAD("/files/:id/content", fileHandler.Download)
+	api.POST("/shares", shareHandler.ShareFile)
+	api.GET("/shares", shareHandler.ListShares)
+	api.PATCH("/shares/:share_id", shareHandler.UpdateExpiry)
+	return f
+}
+
+func testAuth(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetHeader("X-Test-User"))
+	if err != nil {
+		middleware.WriteProblem(c, http.StatusUnauthorized, "unauthenticated", "unauthorized")
+		return
+	}
+	c.Set("userID", userID)
+	c.Next()
+}
+
+func (f *fixture) do(t *testing.T, method, path string, userID uuid.UUID, body io.Reader, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Test-User", userID.String())
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+// problemCode decodes a problem+json response and returns its code.
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json", ct)
+	}
+	var p middleware.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode problem %s: %v", w.Body, err)
+	}
+	if p.Status != w.Code {
+		t.Fatalf("problem status = %d, response status = %d", p.Status, w.Code)
+	}
+	return p.Code
+}
+
+func (f *fixture) user(t *testing.T) domain.User {
+	t.Helper()
+	return repotest.NewUser(t, f.repos, "user-")
+}
+
+func (f *fixture) file(t *testing.T, ownerID uuid.UUID, content []byte) domain.File {
+	t.Helper()
+	file, err := f.files.Upload(context.Background(), domain.File{
+		OwnerID:      ownerID,
+		MimeType:     "application/pdf",
+		Size:         int64(len(content)),
+		IV:           []byte("iv"),
+		EncryptedKey: []byte("owner-wrapped-key"),
+	}, io.NopCloser(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	return file
+}
@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FolderHandler struct {
+	folderUsecase usecase.FolderUsecase
+}
+
+func NewFolderHandler(fu usecase.FolderUsecase) *FolderHandler {
+	return &FolderHandler{folderUsecase: fu}
+}
+
+func folderResponse(f domain.Folder) gin.H {
+	return gin.H{
+		"id":             f.ID,
+		"owner_id":       f.OwnerID,
+		"parent_id":      f.ParentID,
+		"encrypted_name": f.EncryptedName,
+		"wrapped_key":    f.WrappedKey,
+		"key_version":    f.KeyVersion,
+		"created_at":     f.CreatedAt,
+		"updated_at":     f.UpdatedAt,
+	}
+}
+
+func folderListResponse(folders []domain.Folder) []gin.H {
+	res := make([]gin.H, 0, len(folders))
+	for _, f := range folders {
+		res = append(res, folderResponse(f))
+	}
+	return res
+}
+
+func fileList(files []domain.File) []domain.File {
+	if files == nil {
+		return []domain.File{}
+	}
+	return files
+}
+
+func (h *FolderHandler) Create(c *gin.Context) {
+	var req struct {
+		ParentID      *uuid.UUID `json:"parent_id"`
+		EncryptedName []byte     `json:"encrypted_name" binding:"required"`
+		WrappedKey    []byte     `json:"wrapped_key" binding:"required"`
+		KeyVersion    int        `json:"key_version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	folder, err := h.folderUsecase.Create(c.Request.Context(), domain.Folder{
+		OwnerID:       ownerID,
+		ParentID:      req.ParentID,
+		EncryptedName: req.EncryptedName,
+		WrappedKey:    req.WrappedKey,
+		KeyVersion:    req.KeyVersion,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, folderResponse(folder))
+}
+
+func (h *FolderHandler) ListRoot(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	contents, err := h.folderUsecase.ListRoot(c.Request.Context(), ownerID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"folders": folderListResponse(contents.Folders),
+		"files":   fileList(contents.Files),
+	})
+}
+
+func (h *FolderHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	contents, err := h.folderUsecase.Get(c.Request.Context(), id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := folderResponse(contents.Folder)
+	res["folders"] = folderListResponse(contents.Folders)
+	res["files"] = fileList(contents.Files)
+	c.JSON(http.StatusOK, res)
+}
+
+// Tree returns every folder and file below a folder in one response so a
+// recipient of a folder share can unwrap the whole key hierarchy.
+func (h *FolderHandler) Tree(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	folders, files, err := h.folderUsecase.Tree(c.Request.Context(), id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"folders": folderListResponse(folders),
+		"files":   fileList(files),
+	})
+}
+
+func (h *FolderHandler) Rename(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+
+	var req struct {
+		EncryptedName []byte `json:"encrypted_name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	if err := h.folderUsecase.Rename(c.Request.Context(), id, ownerID, req.EncryptedName); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder renamed"})
+}
+
+func (h *FolderHandler) Move(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+
+	var req struct {
+		ParentID   *uuid.UUID `json:"parent_id"`
+		WrappedKey []byte     `json:"wrapped_key" binding:"required"`
+		KeyVersion int        `json:"key_version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	if err := h.folderUsecase.Move(c.Request.Context(), id, ownerID, req.ParentID, req.WrappedKey, req.KeyVersion); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder moved"})
+}
+
+func (h *FolderHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	if err := h.folderUsecase.Delete(c.Request.Context(), id, ownerID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder deleted"})
+}
+
+// MoveFile places a file in a folder; a null folder_id moves it back to the
+// top level.
+func (h *FolderHandler) MoveFile(c *gin.Context) {
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	var req struct {
+		FolderID         *uuid.UUID `json:"folder_id"`
+		FolderWrappedKey []byte     `json:"folder_wrapped_key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	if err := h.folderUsecase.MoveFile(c.Request.Context(), fileID, ownerID, req.FolderID, req.FolderWrappedKey); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "file moved"})
+}
+
+func (h *FolderHandler) Share(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+
+	var req struct {
+		RecipientID uuid.UUID              `json:"recipient_id" binding:"required"`
+		WrappedKey  []byte                 `json:"wrapped_key" binding:"required"`
+		KeyVersion  int                    `json:"key_version"`
+		Permissions domain.SharePermission `json:"permissions"`
+		ExpiresAt   *time.Time             `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	share, err := h.folderUsecase.Share(c.Request.Context(), ownerID, domain.FolderShare{
+		FolderID:    id,
+		RecipientID: req.RecipientID,
+		WrappedKey:  req.WrappedKey,
+		KeyVersion:  req.KeyVersion,
+		Permissions: req.Permissions,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "folder shared", "id": share.ID})
+}
+
+func (h *FolderHandler) ListShares(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	shares, err := h.folderUsecase.ListShares(c.Request.Context(), id, ownerID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *FolderHandler) ListSharedWithMe(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	recipientID := uid.(uuid.UUID)
+
+	shares, err := h.folderUsecase.ListSharedWith(c.Request.Context(), recipientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *FolderHandler) Unshare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("folder id"))
+		return
+	}
+	shareID, err := uuid.Parse(c.Param("share_id"))
+	if err != nil {
+		c.Error(invalidParam("share id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	if err := h.folderUsecase.Unshare(c.Request.Context(), id, shareID, ownerID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "share revoked"})
+}
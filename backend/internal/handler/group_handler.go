@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type GroupHandler struct {
+	groupUsecase usecase.GroupUsecase
+}
+
+func NewGroupHandler(gu usecase.GroupUsecase) *GroupHandler {
+	return &GroupHandler{groupUsecase: gu}
+}
+
+// memberKeyRequest is the group private key wrapped for one member. A zero
+// user_key_version means the member's current keypair.
+type memberKeyRequest struct {
+	UserID            uuid.UUID `json:"user_id" binding:"required"`
+	WrappedPrivateKey []byte    `json:"wrapped_private_key" binding:"required"`
+	UserKeyVersion    int       `json:"user_key_version"`
+}
+
+func toMemberKeys(reqs []memberKeyRequest) []domain.GroupMemberKey {
+	keys := make([]domain.GroupMemberKey, 0, len(reqs))
+	for _, k := range reqs {
+		keys = append(keys, domain.GroupMemberKey{
+			UserID:            k.UserID,
+			WrappedPrivateKey: k.WrappedPrivateKey,
+			UserKeyVersion:    k.UserKeyVersion,
+		})
+	}
+	return keys
+}
+
+// shareRewrapRequest is a group share's file key wrapped for the group's
+// new public key.
+type shareRewrapRequest struct {
+	ShareID    uuid.UUID `json:"share_id" binding:"required"`
+	WrappedKey []byte    `json:"wrapped_key" binding:"required"`
+}
+
+func toShareRewraps(reqs []shareRewrapRequest) []domain.GroupShareRewrap {
+	rewraps := make([]domain.GroupShareRewrap, 0, len(reqs))
+	for _, s := range reqs {
+		rewraps = append(rewraps, domain.GroupShareRewrap{ShareID: s.ShareID, WrappedKey: s.WrappedKey})
+	}
+	return rewraps
+}
+
+func groupResponse(g domain.Group) gin.H {
+	return gin.H{
+		"id":          g.ID,
+		"name":        g.Name,
+		"owner_id":    g.OwnerID,
+		"key_version": g.KeyVersion,
+		"public_key":  []byte(g.PublicKey),
+		"created_at":  g.CreatedAt,
+	}
+}
+
+func (h *GroupHandler) Create(c *gin.Context) {
+	var req struct {
+		Name       string             `json:"name" binding:"required"`
+		PublicKey  []byte             `json:"public_key" binding:"required"`
+		MemberKeys []memberKeyRequest `json:"member_keys" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	group, err := h.groupUsecase.Create(c.Request.Context(), ownerID, req.Name, req.PublicKey, toMemberKeys(req.MemberKeys))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, groupResponse(group))
+}
+
+func (h *GroupHandler) List(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	groups, err := h.groupUsecase.ListForUser(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := make([]gin.H, 0, len(groups))
+	for _, g := range groups {
+		res = append(res, groupResponse(g))
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *GroupHandler) Get(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("group id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	group, members, keys, err := h.groupUsecase.Get(c.Request.Context(), groupID, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	memberRes := make([]gin.H, 0, len(members))
+	for _, m := range members {
+		memberRes = append(memberRes, gin.H{
+			"user_id":   m.UserID,
+			"username":  m.Username,
+			"joined_at": m.JoinedAt,
+		})
+	}
+
+	keyRes := make([]gin.H, 0, len(keys))
+	for _, k := range keys {
+		keyRes = append(keyRes, gin.H{
+			"version":             k.Version,
+			"wrapped_private_key": k.WrappedPrivateKey,
+			"user_key_version":    k.UserKeyVersion,
+		})
+	}
+
+	res := groupResponse(group)
+	res["members"] = memberRes
+	res["keys"] = keyRes
+	c.JSON(http.StatusOK, res)
+}
+
+// Rotate changes membership and installs a new group keypair in one step.
+// member_keys must wrap the new private key for every member left after
+// applying add and remove, and shares must rewrap the file key of every
+// share the group holds for the new public key.
+func (h *GroupHandler) Rotate(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("group id"))
+		return
+	}
+
+	var req struct {
+		KeyVersion int                  `json:"key_version"`
+		PublicKey  []byte               `json:"public_key" binding:"required"`
+		Add        []uuid.UUID          `json:"add"`
+		Remove     []uuid.UUID          `json:"remove"`
+		MemberKeys []memberKeyRequest   `json:"member_keys" binding:"required,dive"`
+		Shares     []shareRewrapRequest `json:"shares" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	actorID := uid.(uuid.UUID)
+
+	group, err := h.groupUsecase.Rotate(c.Request.Context(), actorID, domain.GroupRotation{
+		GroupID:    groupID,
+		Version:    req.KeyVersion,
+		PublicKey:  string(req.PublicKey),
+		Add:        req.Add,
+		Remove:     req.Remove,
+		MemberKeys: toMemberKeys(req.MemberKeys),
+		Shares:     toShareRewraps(req.Shares),
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, groupResponse(group))
+}
+
+func (h *GroupHandler) Delete(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("group id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	actorID := uid.(uuid.UUID)
+
+	if err := h.groupUsecase.Delete(c.Request.Context(), groupID, actorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "group deleted"})
+}
+
+func (h *GroupHandler) ShareFile(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("group id"))
+		return
+	}
+
+	var req struct {
+		FileID      uuid.UUID              `json:"file_id" binding:"required"`
+		WrappedKey  []byte                 `json:"wrapped_key" binding:"required"`
+		KeyVersion  int                    `json:"key_version"`
+		Permissions domain.SharePermission `json:"permissions"`
+		ExpiresAt   *time.Time             `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	grantorID := uid.(uuid.UUID)
+
+	share, err := h.groupUsecase.ShareFile(c.Request.Context(), grantorID, domain.GroupShare{
+		FileID:      req.FileID,
+		GroupID:     groupID,
+		WrappedKey:  req.WrappedKey,
+		KeyVersion:  req.KeyVersion,
+		Permissions: req.Permissions,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "file shared", "id": share.ID})
+}
+
+func (h *GroupHandler) ListShares(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("group id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	shares, err := h.groupUsecase.ListShares(c.Request.Context(), groupID, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *GroupHandler) Unshare(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("group id"))
+		return
+	}
+	shareID, err := uuid.Parse(c.Param("share_id"))
+	if err != nil {
+		c.Error(invalidParam("share id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	actorID := uid.(uuid.UUID)
+
+	if err := h.groupUsecase.Unshare(c.Request.Context(), groupID, shareID, actorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "share revoked"})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type KeyHandler struct {
+	keyUsecase usecase.KeyUsecase
+}
+
+func NewKeyHandler(ku usecase.KeyUsecase) *KeyHandler {
+	return &KeyHandler{keyUsecase: ku}
+}
+
+func (h *KeyHandler) List(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	keys, err := h.keyUsecase.ListKeys(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := make([]gin.H, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, gin.H{
+			"version":               k.Version,
+			"public_key":            []byte(k.PublicKey),
+			"encrypted_private_key": k.EncryptedPrivateKey,
+			"created_at":            k.CreatedAt,
+			"retired_at":            k.RetiredAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+type rotateKeyRequest struct {
+	PublicKey           []byte `json:"public_key" binding:"required"`
+	EncryptedPrivateKey []byte `json:"encrypted_private_key" binding:"required"`
+}
+
+func (h *KeyHandler) Rotate(c *gin.Context) {
+	var req rotateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	key, err := h.keyUsecase.Rotate(c.Request.Context(), userID, req.PublicKey, req.EncryptedPrivateKey)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "key rotated", "version": key.Version})
+}
+
+func (h *KeyHandler) RewrapQueue(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	items, err := h.keyUsecase.RewrapQueue(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *KeyHandler) SubmitRewrap(c *gin.Context) {
+	var req struct {
+		Items []domain.RewrapItem `json:"items" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	if err := h.keyUsecase.SubmitRewrap(c.Request.Context(), userID, req.Items); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "keys rewrapped", "count": len(req.Items)})
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/keylog"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type KeyLogHandler struct {
+	keyLogUsecase usecase.KeyLogUsecase
+}
+
+func NewKeyLogHandler(ku usecase.KeyLogUsecase) *KeyLogHandler {
+	return &KeyLogHandler{keyLogUsecase: ku}
+}
+
+func treeHeadResponse(h domain.TreeHead) keylog.TreeHead {
+	return keylog.TreeHead{
+		TreeSize:  uint64(h.TreeSize),
+		Timestamp: h.TimestampMs,
+		RootHash:  h.RootHash,
+		Signature: h.Signature,
+	}
+}
+
+func (h *KeyLogHandler) PublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "Ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(h.keyLogUsecase.PublicKey()),
+	})
+}
+
+func (h *KeyLogHandler) TreeHead(c *gin.Context) {
+	treeSize, err := strconv.ParseInt(c.DefaultQuery("tree_size", "0"), 10, 64)
+	if err != nil || treeSize < 0 {
+		c.Error(invalidParam("tree_size"))
+		return
+	}
+
+	head, err := h.keyLogUsecase.TreeHead(c.Request.Context(), treeSize)
+	if err != nil {
+		c.Error(domain.NotFound("tree head"))
+		return
+	}
+
+	c.JSON(http.StatusOK, treeHeadResponse(head))
+}
+
+func (h *KeyLogHandler) Inclusion(c *gin.Context) {
+	userID, err := uuid.Parse(c.Query("user_id"))
+	if err != nil {
+		c.Error(invalidParam("user_id"))
+		return
+	}
+
+	treeSize, err := strconv.ParseInt(c.DefaultQuery("tree_size", "0"), 10, 64)
+	if err != nil || treeSize < 0 {
+		c.Error(invalidParam("tree_size"))
+		return
+	}
+
+	proof, err := h.keyLogUsecase.InclusionProof(c.Request.Context(), userID, treeSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	entry, err := keylog.DecodeEntry(proof.Entry.LeafData)
+	if err != nil {
+		c.Error(fmt.Errorf("corrupt key log entry %d: %w", proof.Entry.Index, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"index":     proof.Entry.Index,
+		"entry":     entry,
+		"proof":     proof.Proof,
+		"tree_head": treeHeadResponse(proof.Head),
+	})
+}
+
+func (h *KeyLogHandler) Consistency(c *gin.Context) {
+	first, err1 := strconv.ParseInt(c.Query("first"), 10, 64)
+	second, err2 := strconv.ParseInt(c.Query("second"), 10, 64)
+	if err1 != nil || err2 != nil {
+		c.Error(domain.Invalid("tree_sizes_required", "first and second tree sizes are required"))
+		return
+	}
+
+	proof, err := h.keyLogUsecase.ConsistencyProof(c.Request.Context(), first, second)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"first": first, "second": second, "proof": proof})
+}
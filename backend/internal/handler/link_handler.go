@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type LinkHandler struct {
+	linkUsecase usecase.LinkUsecase
+}
+
+func NewLinkHandler(lu usecase.LinkUsecase) *LinkHandler {
+	return &LinkHandler{linkUsecase: lu}
+}
+
+func linkResponse(link domain.LinkShare) gin.H {
+	return gin.H{
+		"id":                link.ID,
+		"file_id":           link.FileID,
+		"password_required": link.PasswordHash != "",
+		"max_downloads":     link.MaxDownloads,
+		"download_count":    link.DownloadCount,
+		"created_at":        link.CreatedAt,
+		"expires_at":        link.ExpiresAt,
+		"revoked_at":        link.RevokedAt,
+	}
+}
+
+type createLinkRequest struct {
+	FileID       string    `json:"file_id" binding:"required"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxDownloads int       `json:"max_downloads"`
+	Password     string    `json:"password"`
+}
+
+// Create returns the link token exactly once. Clients build the shareable
+// URL by appending the file key as a fragment, which browsers never send.
+func (h *LinkHandler) Create(c *gin.Context) {
+	var req createLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	fileID, err := uuid.Parse(req.FileID)
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	link, token, err := h.linkUsecase.Create(c.Request.Context(), fileID, ownerID, req.ExpiresAt, req.MaxDownloads, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := linkResponse(link)
+	res["token"] = token
+	res["path"] = "/api/links/" + token
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *LinkHandler) List(c *gin.Context) {
+	var fileID uuid.UUID
+	if v := c.Query("file_id"); v != "" {
+		var err error
+		if fileID, err = uuid.Parse(v); err != nil {
+			c.Error(invalidParam("file id"))
+			return
+		}
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	links, err := h.linkUsecase.List(c.Request.Context(), ownerID, fileID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := make([]gin.H, 0, len(links))
+	for _, l := range links {
+		res = append(res, linkResponse(l))
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *LinkHandler) Revoke(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("link id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	if err := h.linkUsecase.Revoke(c.Request.Context(), id, ownerID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "link revoked"})
+}
+
+// Open streams the ciphertext behind a link to an anonymous caller. A
+// password-gated link expects the password in the X-Link-Password header so
+// it stays out of URLs and access logs. Guesses are limited per client
+// address, which is only trustworthy behind the configured trusted proxies.
+func (h *LinkHandler) Open(c *gin.Context) {
+	content, file, err := h.linkUsecase.Open(c.Request.Context(), c.Param("token"), c.GetHeader("X-Link-Password"), c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer content.Close()
+
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Disposition", `attachment; filename="`+file.ID.String()+`"`)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	if len(file.IV) > 0 {
+		c.Header("X-IV", base64.StdEncoding.EncodeToString(file.IV))
+	}
+	if len(file.EncryptedMetadata) > 0 {
+		c.Header("X-Encrypted-Metadata", base64.StdEncoding.EncodeToString(file.EncryptedMetadata))
+	}
+	if len(file.VersionKey) > 0 {
+		c.Header("X-Version-Key", base64.StdEncoding.EncodeToString(file.VersionKey))
+	}
+	c.Header("X-Format-Version", strconv.Itoa(file.FormatVersion))
+	if file.ChunkSize > 0 {
+		c.Header("X-Chunk-Size", strconv.Itoa(file.ChunkSize))
+	}
+
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		_ = c.Error(err)
+	}
+}
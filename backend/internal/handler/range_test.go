@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		size   int64
+
+		want    byteRange
+		wantOK  bool
+		wantErr error
+	}{
+		{"closed", "bytes=0-99", 1000, byteRange{0, 100}, true, nil},
+		{"single byte", "bytes=5-5", 1000, byteRange{5, 1}, true, nil},
+		{"open ended", "bytes=100-", 1000, byteRange{100, 900}, true, nil},
+		{"end past size", "bytes=990-2000", 1000, byteRange{990, 10}, true, nil},
+		{"suffix", "bytes=-100", 1000, byteRange{900, 100}, true, nil},
+		{"suffix longer than object", "bytes=-5000", 1000, byteRange{0, 1000}, true, nil},
+		{"spaces", "bytes= 10 - 19 ", 1000, byteRange{10, 10}, true, nil},
+
+		{"multi range", "bytes=0-9,20-29", 1000, byteRange{}, false, nil},
+		{"other unit", "items=0-9", 1000, byteRange{}, false, nil},
+		{"no dash", "bytes=10", 1000, byteRange{}, false, nil},
+		{"not a number", "bytes=a-b", 1000, byteRange{}, false, nil},
+		{"end before start", "bytes=20-10", 1000, byteRange{}, false, nil},
+		{"negative suffix", "bytes=--5", 1000, byteRange{}, false, nil},
+
+		{"start at size", "bytes=1000-", 1000, byteRange{}, false, errRangeNotSatisfiable},
+		{"start past size", "bytes=2000-3000", 1000, byteRange{}, false, errRangeNotSatisfiable},
+		{"empty suffix", "bytes=-0", 1000, byteRange{}, false, errRangeNotSatisfiable},
+		{"empty object", "bytes=0-", 0, byteRange{}, false, errRangeNotSatisfiable},
+		{"suffix of empty object", "bytes=-10", 0, byteRange{}, false, errRangeNotSatisfiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseRange(tt.header, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("got %+v ok=%v, want %+v ok=%v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIfRangeMatches(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		ifRange string
+		want    bool
+	}{
+		{"absent", "", true},
+		{"same etag", `"abc"`, true},
+		{"other etag", `"def"`, false},
+		{"weak etag", `W/"abc"`, false},
+		{"same date", "Sat, 01 Mar 2025 12:00:00 GMT", true},
+		{"earlier date", "Sat, 01 Mar 2025 11:59:59 GMT", false},
+		{"garbage", "yesterday", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ifRangeMatches(tt.ifRange, `"abc"`, modified.Add(300*time.Millisecond)); got != tt.want {
+				t.Fatalf("ifRangeMatches(%q) = %v, want %v", tt.ifRange, got, tt.want)
+			}
+		})
+	}
+}
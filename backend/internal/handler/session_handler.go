@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SessionHandler struct {
+	sessionUsecase usecase.SessionUsecase
+}
+
+func NewSessionHandler(su usecase.SessionUsecase) *SessionHandler {
+	return &SessionHandler{sessionUsecase: su}
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+	sid, _ := c.Get("sessionID")
+	currentID := sid.(uuid.UUID)
+
+	sessions, err := h.sessionUsecase.List(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, gin.H{
+			"id":           s.ID,
+			"user_agent":   s.UserAgent,
+			"ip_address":   s.IPAddress,
+			"created_at":   s.CreatedAt,
+			"last_used_at": s.LastUsedAt,
+			"expires_at":   s.ExpiresAt,
+			"current":      s.ID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("session id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+
+	if err := h.sessionUsecase.Revoke(c.Request.Context(), sessionID, userID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -21,24 +25,35 @@ func (h *ShareHandler) ShareFile(c *gin.Context) {
 		FileID			string	`json:"file_id" binding:"required"`
 		RecipientID		string	`json:"recipient_id" binding:"required"`
 		WrappedKey		[]byte	`json:"wrapped_key" binding:"required"`
+		KeyVersion		int		`json:"key_version"`
+		ExpiresAt		*time.Time	`json:"expires_at"`
+		Permissions		domain.SharePermission	`json:"permissions"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
 	fileID, _ := uuid.Parse(req.FileID)
 	recipientID, _ := uuid.Parse(req.RecipientID)
 	uid, _ := c.Get("userID")
-	ownerID := uid.(uuid.UUID)
+	grantorID := uid.(uuid.UUID)
 
-	if err := h.shareUsecase.ShareFile(c.Request.Context(), fileID, ownerID, recipientID, req.WrappedKey); err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	share, err := h.shareUsecase.ShareFile(c.Request.Context(), grantorID, domain.Share{
+		FileID:      fileID,
+		RecipientID: recipientID,
+		WrappedKey:  req.WrappedKey,
+		KeyVersion:  req.KeyVersion,
+		ExpiresAt:   req.ExpiresAt,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "file shared"})
+	c.JSON(http.StatusCreated, gin.H{"message": "file shared", "id": share.ID})
 }
 
 func (h *ShareHandler) ListShares(c *gin.Context) {
@@ -47,7 +62,7 @@ func (h *ShareHandler) ListShares(c *gin.Context) {
 
 	shares, err := h.shareUsecase.GetSharesForRecipient(c.Request.Context(), recipientID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -57,7 +72,7 @@ func (h *ShareHandler) ListShares(c *gin.Context) {
 func (h *ShareHandler) GetShare(c *gin.Context) {
 	fileID, err := uuid.Parse(c.Param("file_id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		c.Error(invalidParam("file id"))
 		return
 	}
 
@@ -66,7 +81,7 @@ func (h *ShareHandler) GetShare(c *gin.Context) {
 
 	share, err := h.shareUsecase.GetShare(c.Request.Context(), fileID, recipientID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "share not found"})
+		c.Error(domain.NotFound("share"))
 		return
 	}
 	c.JSON(http.StatusOK, share)
@@ -75,7 +90,7 @@ func (h *ShareHandler) GetShare(c *gin.Context) {
 func (h *ShareHandler) Unshare(c * gin.Context) {
 	shareID, err := uuid.Parse(c.Param("share_id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid share id"})
+		c.Error(invalidParam("share id"))
 		return
 	}
 
@@ -83,9 +98,179 @@ func (h *ShareHandler) Unshare(c * gin.Context) {
 	ownerID := uid.(uuid.UUID)
 
 	if err := h.shareUsecase.Unshare(c.Request.Context(), shareID, ownerID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "share revoked"})
+}
+
+// UpdateExpiry sets a new expires_at on a share; an explicit null removes
+// the expiry altogether. A body without expires_at is rejected rather than
+// read as null.
+func (h *ShareHandler) UpdateExpiry(c *gin.Context) {
+	shareID, err := uuid.Parse(c.Param("share_id"))
+	if err != nil {
+		c.Error(invalidParam("share id"))
+		return
+	}
+
+	var req struct {
+		ExpiresAt json.RawMessage `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+	if req.ExpiresAt == nil {
+		c.Error(domain.Invalid("expires_at_required", "expires_at is required; send null to remove the expiry"))
+		return
+	}
+	var expiresAt *time.Time
+	if err := json.Unmarshal(req.ExpiresAt, &expiresAt); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	actorID := uid.(uuid.UUID)
+
+	share, err := h.shareUsecase.UpdateExpiry(c.Request.Context(), shareID, actorID, expiresAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+func (h *ShareHandler) UpdatePermissions(c *gin.Context) {
+	shareID, err := uuid.Parse(c.Param("share_id"))
+	if err != nil {
+		c.Error(invalidParam("share id"))
+		return
+	}
+
+	var req struct {
+		Permissions domain.SharePermission `json:"permissions" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	actorID := uid.(uuid.UUID)
+
+	share, err := h.shareUsecase.UpdatePermissions(c.Request.Context(), shareID, actorID, req.Permissions)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// shareListingResponse renders a listing with the fields of its kind: a
+// file and a recipient for a user share, a file and a group for a group
+// share, and a folder and a recipient for a folder share.
+func shareListingResponse(l domain.ShareListing) gin.H {
+	res := gin.H{
+		"id":          l.ID,
+		"kind":        l.Kind,
+		"granted_by":  l.GrantedBy,
+		"permissions": l.Permissions,
+		"created_at":  l.CreatedAt,
+		"expires_at":  l.ExpiresAt,
+	}
+	if l.Kind == domain.ShareListingFolder {
+		res["folder_id"] = l.FolderID
+	} else {
+		res["file_id"] = l.FileID
+		res["filename"] = l.Filename
+	}
+	if l.Kind == domain.ShareListingGroup {
+		res["group_id"] = l.GroupID
+		res["group_name"] = l.GroupName
+	} else {
+		res["recipient_id"] = l.RecipientID
+		res["recipient_username"] = l.RecipientUsername
+	}
+	return res
+}
+
+// writeShareListings renders a page of listings. next_cursor is the opaque
+// cursor to pass as after for the following page, or empty on the last
+// page.
+func writeShareListings(c *gin.Context, listings []domain.ShareListing, limit int) {
+	res := make([]gin.H, 0, len(listings))
+	for _, l := range listings {
+		res = append(res, shareListingResponse(l))
+	}
+
+	nextCursor := ""
+	if len(listings) > 0 && len(listings) == limit {
+		last := listings[len(listings)-1]
+		nextCursor = domain.ShareCursor{CreatedAt: last.CreatedAt, ID: last.ID}.String()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": res, "next_cursor": nextCursor})
+}
+
+func parsePage(c *gin.Context) (domain.ShareCursor, int, bool) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	limit = usecase.ShareListingLimit(limit)
+
+	var after domain.ShareCursor
+	if v := c.Query("after"); v != "" {
+		var err error
+		if after, err = domain.ParseShareCursor(v); err != nil {
+			c.Error(invalidParam("cursor"))
+			return domain.ShareCursor{}, 0, false
+		}
+	}
+
+	return after, limit, true
+}
+
+func (h *ShareHandler) ListOutgoing(c *gin.Context) {
+	after, limit, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	listings, err := h.shareUsecase.ListOutgoing(c.Request.Context(), ownerID, after, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	writeShareListings(c, listings, limit)
+}
+
+func (h *ShareHandler) ListForFile(c *gin.Context) {
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("file id"))
+		return
+	}
+
+	after, limit, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	actorID := uid.(uuid.UUID)
+
+	listings, err := h.shareUsecase.ListForFile(c.Request.Context(), fileID, actorID, after, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	writeShareListings(c, listings, limit)
 }
\ No newline at end of file
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+)
+
+func TestShareFile(t *testing.T) {
+	tests := []struct {
+		name string
+		// body is a template: FILE, RECIPIENT and OWNER are replaced with
+		// IDs.
+		body       string
+		byStranger bool
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "owner",
+			body:       `{"file_id":"FILE","recipient_id":"RECIPIENT","wrapped_key":"a2V5","permissions":["view"]}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "missing wrapped key",
+			body:       `{"file_id":"FILE","recipient_id":"RECIPIENT"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_request",
+		},
+		{
+			name:       "unknown permission",
+			body:       `{"file_id":"FILE","recipient_id":"RECIPIENT","wrapped_key":"a2V5","permissions":["own"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not the owner",
+			body:       `{"file_id":"FILE","recipient_id":"RECIPIENT","wrapped_key":"a2V5"}`,
+			byStranger: true,
+			wantStatus: http.StatusForbidden,
+			wantCode:   "permission_denied",
+		},
+		{
+			name:       "with the owner",
+			body:       `{"file_id":"FILE","recipient_id":"OWNER","wrapped_key":"a2V5"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "share_with_self",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			owner := f.user(t)
+			recipient := f.user(t)
+			file := f.file(t, owner.ID, []byte("content"))
+
+			body := strings.NewReplacer("FILE", file.ID.String(), "RECIPIENT", recipient.ID.String(), "OWNER", owner.ID.String()).Replace(tt.body)
+			as := owner.ID
+			if tt.byStranger {
+				as = f.user(t).ID
+			}
+
+			w := f.do(t, http.MethodPost, "/api/shares", as, strings.NewReader(body), nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode != "" {
+				if got := problemCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %q, want %q", got, tt.wantCode)
+				}
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			w = f.do(t, http.MethodGet, "/api/shares", recipient.ID, nil, nil)
+			var shares []domain.Share
+			if err := json.Unmarshal(w.Body.Bytes(), &shares); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+			if len(shares) != 1 || shares[0].FileID != file.ID || shares[0].Permissions != domain.PermView {
+				t.Fatalf("recipient's shares = %+v", shares)
+			}
+		})
+	}
+}
+
+func TestUpdateShareExpiry(t *testing.T) {
+	later := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantExpires *time.Time
+	}{
+		{"set", `{"expires_at":"` + later.Format(time.RFC3339) + `"}`, http.StatusOK, "", &later},
+		{"explicit null clears", `{"expires_at":null}`, http.StatusOK, "", nil},
+		{"empty body", `{}`, http.StatusBadRequest, "expires_at_required", &later},
+		{"not a time", `{"expires_at":"soon"}`, http.StatusBadRequest, "invalid_request", &later},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			owner := f.user(t)
+			file := f.file(t, owner.ID, []byte("content"))
+			share := repotest.NewShare(t, f.repos, file, f.user(t).ID, domain.PermView, time.Now(), nil)
+			if err := f.repos.Shares.UpdateExpiry(context.Background(), share.ID, &later); err != nil {
+				t.Fatal(err)
+			}
+
+			w := f.do(t, http.MethodPatch, "/api/shares/"+share.ID.String(), owner.ID, strings.NewReader(tt.body), nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode != "" {
+				if got := problemCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %q, want %q", got, tt.wantCode)
+				}
+			}
+
+			got, err := f.repos.Shares.FindByID(context.Background(), share.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got.ExpiresAt == nil) != (tt.wantExpires == nil) || (got.ExpiresAt != nil && !got.ExpiresAt.Equal(*tt.wantExpires)) {
+				t.Fatalf("expires_at = %v, want %v", got.ExpiresAt, tt.wantExpires)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/middleware"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UploadHandler struct {
+	uploadUsecase usecase.UploadUsecase
+}
+
+func NewUploadHandler(uu usecase.UploadUsecase) *UploadHandler {
+	return &UploadHandler{uploadUsecase: uu}
+}
+
+type createUploadRequest struct {
+	Filename          string `json:"filename"`
+	MimeType          string `json:"mime_type"`
+	EncryptedMetadata []byte `json:"encrypted_metadata"`
+	Size              int64  `json:"size" binding:"required"`
+	IV                []byte `json:"iv" binding:"required"`
+	EncryptedKey      []byte `json:"encrypted_key" binding:"required"`
+	FormatVersion     int    `json:"format_version"`
+	ChunkSize         int    `json:"chunk_size"`
+}
+
+func (h *UploadHandler) Create(c *gin.Context) {
+	var req createUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	session, err := h.uploadUsecase.CreateSession(c.Request.Context(), domain.UploadSession{
+		OwnerID:           ownerID,
+		Filename:          req.Filename,
+		MimeType:          req.MimeType,
+		EncryptedMetadata: req.EncryptedMetadata,
+		Size:              req.Size,
+		IV:                req.IV,
+		EncryptedKey:      req.EncryptedKey,
+		FormatVersion:     req.FormatVersion,
+		ChunkSize:         req.ChunkSize,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+session.ID.String())
+	c.JSON(http.StatusCreated, uploadSessionResponse(session))
+}
+
+func (h *UploadHandler) Status(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("upload id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	session, err := h.uploadUsecase.GetSession(c.Request.Context(), id, ownerID)
+	if err != nil {
+		c.Error(domain.NotFound("upload session"))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Size, 10))
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.JSON(http.StatusOK, uploadSessionResponse(session))
+}
+
+func (h *UploadHandler) WriteChunk(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("upload id"))
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		middleware.WriteProblem(c, http.StatusUnsupportedMediaType, "unsupported_media_type", "content type must be application/offset+octet-stream")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.Error(domain.Invalid("invalid_upload_offset", "invalid Upload-Offset header"))
+		return
+	}
+
+	length := c.Request.ContentLength
+	if length <= 0 {
+		middleware.WriteProblem(c, http.StatusLengthRequired, "length_required", "Content-Length is required")
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, length)
+	newOffset, err := h.uploadUsecase.WriteChunk(c.Request.Context(), id, ownerID, offset, body, length)
+	if err != nil {
+		if errors.Is(err, domain.ErrUploadOffsetMismatch) {
+			c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+		}
+		c.Error(err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UploadHandler) Complete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("upload id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	file, err := h.uploadUsecase.Finalize(c.Request.Context(), id, ownerID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "file uploaded", "id": file.ID})
+}
+
+func (h *UploadHandler) Abort(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("upload id"))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	ownerID := uid.(uuid.UUID)
+
+	if err := h.uploadUsecase.Abort(c.Request.Context(), id, ownerID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "upload aborted"})
+}
+
+func uploadSessionResponse(s domain.UploadSession) gin.H {
+	return gin.H{
+		"id":             s.ID,
+		"file_id":        s.FileID,
+		"size":           s.Size,
+		"format_version": s.FormatVersion,
+		"chunk_size":     s.ChunkSize,
+		"offset":         s.Offset,
+		"min_chunk_size": usecase.MinChunkSize,
+		"max_chunk_size": usecase.MaxChunkSize,
+		"expires_at":     s.ExpiresAt,
+	}
+}
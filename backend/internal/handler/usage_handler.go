@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UsageHandler struct {
+	usageUsecase usecase.UsageUsecase
+}
+
+func NewUsageHandler(usageUsecase usecase.UsageUsecase) *UsageHandler {
+	return &UsageHandler{usageUsecase: usageUsecase}
+}
+
+func (h *UsageHandler) Get(c *gin.Context) {
+	uid, _ := c.Get("userID")
+	usage, err := h.usageUsecase.Get(c.Request.Context(), uid.(uuid.UUID))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"plan":            usage.Plan,
+		"used_bytes":      usage.UsedBytes,
+		"quota_bytes":     usage.QuotaBytes,
+		"available_bytes": usage.Available(),
+	})
+}
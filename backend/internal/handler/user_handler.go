@@ -1,19 +1,36 @@
 package handler
 
 import (
+	"encoding/base64"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/auth"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/pubkey"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type UserHandler struct {
-	userUsecase usecase.UserUsecase
+	userUsecase    usecase.UserUsecase
+	sessionUsecase usecase.SessionUsecase
 }
 
-func NewUserHandler(uc usecase.UserUsecase) *UserHandler {
-	return &UserHandler{userUsecase: uc}
+func NewUserHandler(uc usecase.UserUsecase, su usecase.SessionUsecase) *UserHandler {
+	return &UserHandler{userUsecase: uc, sessionUsecase: su}
+}
+
+func setAuthCookies(c *gin.Context, tokens usecase.SessionTokens) {
+	c.SetCookie("auth_token", tokens.AccessToken, int(auth.AccessTokenTTL.Seconds()), "/", "", true, true)
+	c.SetCookie("refresh_token", tokens.RefreshToken, int(auth.RefreshTokenTTL.Seconds()), "/api/auth", "", true, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("auth_token", "", -1, "/", "", true, true)
+	c.SetCookie("refresh_token", "", -1, "/api/auth", "", true, true)
 }
 
 type registerRequest struct {
@@ -26,13 +43,13 @@ type registerRequest struct {
 func (h *UserHandler) Register(c *gin.Context) {
 	var req registerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
 	user, err := h.userUsecase.Register(c.Request.Context(), req.Username, req.Password, req.PublicKey, req.EncryptedPrivateKey)
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -51,40 +68,183 @@ type loginRequest struct {
 func (h *UserHandler) Login(c *gin.Context) {
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidRequest(err))
 		return
 	}
 
 	user, encryptedPrivateKey, err := h.userUsecase.Login(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID.String(), user.Username)
+	tokens, err := h.sessionUsecase.Create(c.Request.Context(), user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+		c.Error(fmt.Errorf("create session: %w", err))
 		return
 	}
 
-	c.SetCookie(
-		"auth_token",
-		token,
-		3600*24,
-		"/",
-		"",
-		true,
-		true,
-	)
+	setAuthCookies(c, tokens)
 
 	c.JSON(http.StatusOK, gin.H{
 		"id":                    user.ID,
 		"username":              user.Username,
 		"encrypted_private_key": encryptedPrivateKey,
+		"key_version":           user.KeyVersion,
 	})
 }
 
+func (h *UserHandler) Refresh(c *gin.Context) {
+	refreshToken, err := c.Cookie("refresh_token")
+	if err != nil {
+		c.Error(domain.ErrInvalidRefreshToken)
+		return
+	}
+
+	tokens, err := h.sessionUsecase.Refresh(c.Request.Context(), refreshToken)
+	if err != nil {
+		clearAuthCookies(c)
+		c.Error(err)
+		return
+	}
+
+	setAuthCookies(c, tokens)
+	c.JSON(http.StatusOK, gin.H{"message": "token refreshed"})
+}
+
 func (h *UserHandler) Logout(c *gin.Context) {
-	c.SetCookie("auth_token", "", -1, "/", "", true, true)
+	if refreshToken, err := c.Cookie("refresh_token"); err == nil {
+		_ = h.sessionUsecase.RevokeByRefreshToken(c.Request.Context(), refreshToken)
+	}
+
+	clearAuthCookies(c)
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
+
+type reencryptedKey struct {
+	Version             int    `json:"version" binding:"required"`
+	PublicKey           []byte `json:"public_key" binding:"required"`
+	EncryptedPrivateKey []byte `json:"encrypted_private_key" binding:"required"`
+}
+
+type changePasswordRequest struct {
+	OldPassword         string           `json:"old_password" binding:"required"`
+	NewPassword         string           `json:"new_password" binding:"required"`
+	PublicKey           []byte           `json:"public_key" binding:"required"`
+	EncryptedPrivateKey []byte           `json:"encrypted_private_key" binding:"required"`
+	RetiredKeys         []reencryptedKey `json:"retired_keys"`
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidRequest(err))
+		return
+	}
+
+	uid, _ := c.Get("userID")
+	userID := uid.(uuid.UUID)
+	sid, _ := c.Get("sessionID")
+	sessionID := sid.(uuid.UUID)
+
+	user, err := h.userUsecase.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(domain.NotFound("user"))
+		return
+	}
+
+	keys := []domain.UserKey{{
+		UserID:              userID,
+		Version:             user.KeyVersion,
+		PublicKey:           string(req.PublicKey),
+		EncryptedPrivateKey: req.EncryptedPrivateKey,
+	}}
+	for _, k := range req.RetiredKeys {
+		keys = append(keys, domain.UserKey{
+			UserID:              userID,
+			Version:             k.Version,
+			PublicKey:           string(k.PublicKey),
+			EncryptedPrivateKey: k.EncryptedPrivateKey,
+		})
+	}
+
+	err = h.userUsecase.ChangePassword(c.Request.Context(), userID, req.OldPassword, req.NewPassword, keys)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.sessionUsecase.RevokeOthers(c.Request.Context(), userID, sessionID); err != nil {
+		c.Error(fmt.Errorf("password changed but other sessions could not be revoked: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+func publicKeyResponse(user domain.User) gin.H {
+	der := []byte(user.PublicKey)
+	return gin.H{
+		"user_id":     user.ID,
+		"username":    user.Username,
+		"public_key":  base64.StdEncoding.EncodeToString(der),
+		"key_version": user.KeyVersion,
+		"fingerprint": pubkey.Fingerprint(der),
+		"algorithm":   pubkey.Algorithm(der),
+	}
+}
+
+func (h *UserHandler) GetPublicKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(invalidParam("user id"))
+		return
+	}
+
+	user, err := h.userUsecase.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.Error(domain.NotFound("user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, publicKeyResponse(user))
+}
+
+func (h *UserHandler) Lookup(c *gin.Context) {
+	username := c.Query("username")
+	if username == "" {
+		c.Error(domain.Invalid("username_required", "username is required"))
+		return
+	}
+
+	user, err := h.userUsecase.GetByUsername(c.Request.Context(), username)
+	if err != nil {
+		c.Error(domain.NotFound("user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, publicKeyResponse(user))
+}
+
+func (h *UserHandler) Search(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	limit = usecase.UserSearchLimit(limit)
+
+	users, err := h.userUsecase.SearchByUsernamePrefix(c.Request.Context(), c.Query("prefix"), c.Query("after"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := make([]gin.H, 0, len(users))
+	for _, u := range users {
+		res = append(res, publicKeyResponse(u))
+	}
+
+	nextCursor := ""
+	if len(users) > 0 && len(users) == limit {
+		nextCursor = users[len(users)-1].Username
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": res, "next_cursor": nextCursor})
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is an RFC 7807 problem details body. Code is a stable identifier
+// for the specific failure; Type stays about:blank, so Title is always the
+// status text.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Code     string `json:"code"`
+	Instance string `json:"instance,omitempty"`
+}
+
+var kindStatus = map[domain.Kind]int{
+	domain.KindValidation:      http.StatusBadRequest,
+	domain.KindUnauthorized:    http.StatusUnauthorized,
+	domain.KindForbidden:       http.StatusForbidden,
+	domain.KindNotFound:        http.StatusNotFound,
+	domain.KindConflict:        http.StatusConflict,
+	domain.KindGone:            http.StatusGone,
+	domain.KindTooLarge:        http.StatusRequestEntityTooLarge,
+	domain.KindQuotaExceeded:   http.StatusInsufficientStorage,
+	domain.KindTooManyRequests: http.StatusTooManyRequests,
+	domain.KindUnprocessable:   http.StatusUnprocessableEntity,
+}
+
+// ErrorHandler renders the last error a handler recorded with c.Error as
+// problem+json, unless the handler already wrote a response. A domain.Error
+// is reported as is; anything else is logged and answered with a generic
+// 500 so driver and storage messages never reach the client.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			if status, ok := kindStatus[domainErr.Kind]; ok {
+				WriteProblem(c, status, domainErr.Code, domainErr.Message)
+				return
+			}
+		}
+
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+		WriteProblem(c, http.StatusInternalServerError, "internal_error", "")
+	}
+}
+
+// WriteProblem aborts the request with a problem+json response. Handlers use
+// it directly only for protocol-level failures that have no domain error,
+// such as an unsatisfiable range.
+func WriteProblem(c *gin.Context, status int, code, detail string) {
+	// Drop entity headers set for the response the handler meant to send.
+	h := c.Writer.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Disposition")
+	h.Set("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Code:     code,
+		Instance: c.Request.URL.Path,
+	})
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		err  error
+
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{"not found", domain.NotFound("file"), http.StatusNotFound, "file_not_found", "file not found"},
+		{"validation", domain.Invalid("invalid_expiry", "expiry must be in the future"), http.StatusBadRequest, "invalid_expiry", "expiry must be in the future"},
+		{"forbidden", domain.ErrPermissionDenied, http.StatusForbidden, "permission_denied", "permission denied"},
+		{"conflict", domain.ErrUsernameTaken, http.StatusConflict, "username_taken", "username already exists"},
+		{"unauthorized", domain.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials", "invalid credentials"},
+		{"gone", domain.ErrFileUnavailable, http.StatusGone, "file_unavailable", "file is unavailable"},
+		{"too large", domain.ErrFileTooLarge, http.StatusRequestEntityTooLarge, "file_too_large", "file is larger than the storage quota"},
+		{"quota exceeded", domain.ErrQuotaExceeded, http.StatusInsufficientStorage, "quota_exceeded", "storage quota exceeded"},
+		{"wrapped", fmt.Errorf("rename: %w", domain.NotFound("folder")), http.StatusNotFound, "folder_not_found", "folder not found"},
+		{"internal", errors.New(`ERROR: relation "files" does not exist (SQLSTATE 42P01)`), http.StatusInternalServerError, "internal_error", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(ErrorHandler())
+			r.GET("/things/:id", func(c *gin.Context) {
+				c.Error(tt.err)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/things/1", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("Content-Type = %q", ct)
+			}
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+			want := Problem{
+				Type:     "about:blank",
+				Title:    http.StatusText(tt.wantStatus),
+				Status:   tt.wantStatus,
+				Detail:   tt.wantDetail,
+				Code:     tt.wantCode,
+				Instance: "/things/1",
+			}
+			if p != want {
+				t.Fatalf("problem = %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerKeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/stream", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		c.Error(errors.New("connection reset"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Fatalf("got %d %q, want the handler's response untouched", w.Code, w.Body)
+	}
+}
+
+func TestWriteProblemDropsEntityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/download", func(c *gin.Context) {
+		c.Header("Content-Length", "1048576")
+		c.Header("Content-Disposition", `attachment; filename="x"`)
+		WriteProblem(c, http.StatusRequestedRangeNotSatisfiable, "range_not_satisfiable", "")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download", nil))
+
+	if w.Header().Get("Content-Disposition") != "" {
+		t.Fatal("Content-Disposition left on the problem response")
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "" && cl != fmt.Sprint(w.Body.Len()) {
+		t.Fatalf("Content-Length = %s for a %d byte body", cl, w.Body.Len())
+	}
+	if !strings.Contains(w.Body.String(), `"code":"range_not_satisfiable"`) {
+		t.Fatalf("body = %s", w.Body)
+	}
+}
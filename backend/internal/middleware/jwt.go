@@ -1,30 +1,52 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/auth"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-func JWTAuthMiddleware() gin.HandlerFunc {
+type SessionValidator interface {
+	Validate(ctx context.Context, sessionID uuid.UUID) error
+}
+
+func JWTAuthMiddleware(tokens *auth.TokenSigner, sessions SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := c.Cookie("auth_token")
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing auth token"})
-			c.Abort()
+			WriteProblem(c, http.StatusUnauthorized, "missing_auth_token", "missing auth token")
 			return
 		}
 
-		claims, err := auth.ValidateToken(token)
+		claims, err := tokens.ValidateToken(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-			c.Abort()
+			WriteProblem(c, http.StatusUnauthorized, "invalid_auth_token", "invalid or expired token")
+			return
+		}
+
+		userID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			WriteProblem(c, http.StatusUnauthorized, "invalid_auth_token", "invalid or expired token")
+			return
+		}
+
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			WriteProblem(c, http.StatusUnauthorized, "invalid_auth_token", "invalid or expired token")
+			return
+		}
+
+		if err := sessions.Validate(c.Request.Context(), sessionID); err != nil {
+			WriteProblem(c, http.StatusUnauthorized, "session_revoked", "session revoked")
 			return
 		}
 
-		c.Set("userID", claims.UserID)
+		c.Set("userID", userID)
 		c.Set("username", claims.Username)
+		c.Set("sessionID", sessionID)
 		c.Next()
 	}
 }
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// notFound turns pgx.ErrNoRows into domain.NotFound(resource) and returns
+// other errors unchanged.
+func notFound(err error, resource string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.NotFound(resource)
+	}
+	return err
+}
+
+// uniqueViolation turns a unique constraint violation into conflict and
+// returns other errors unchanged.
+func uniqueViolation(err, conflict error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return conflict
+	}
+	return err
+}
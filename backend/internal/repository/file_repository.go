@@ -2,18 +2,66 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const fileColumns = `id, owner_id, filename, mime_type, size, iv, encrypted_key, format_version, chunk_size, key_version, created_at, folder_id, folder_wrapped_key, encrypted_metadata, version, version_key, object_name, deleted_at, state`
+
+const fileVersionColumns = `file_id, version, object_name, size, iv, version_key, format_version, chunk_size, state, created_at`
+
+// currentVersionSQL copies the content fields of the file_versions row v
+// onto the files row being updated.
+const currentVersionSQL = `
+	version = v.version, version_key = v.version_key, object_name = v.object_name,
+	size = v.size, iv = v.iv, format_version = v.format_version, chunk_size = v.chunk_size`
+
+func scanFile(row pgx.Row) (domain.File, error) {
+	var f domain.File
+	err := row.Scan(&f.ID, &f.OwnerID, &f.Filename, &f.MimeType, &f.Size, &f.IV, &f.EncryptedKey, &f.FormatVersion, &f.ChunkSize, &f.KeyVersion, &f.CreatedAt, &f.FolderID, &f.FolderWrappedKey, &f.EncryptedMetadata, &f.Version, &f.VersionKey, &f.ObjectName, &f.DeletedAt, &f.State)
+	return f, notFound(err, "file")
+}
+
+func scanFileVersion(row pgx.Row) (domain.FileVersion, error) {
+	var v domain.FileVersion
+	err := row.Scan(&v.FileID, &v.Version, &v.ObjectName, &v.Size, &v.IV, &v.VersionKey, &v.FormatVersion, &v.ChunkSize, &v.State, &v.CreatedAt)
+	return v, notFound(err, "file version")
+}
+
 type FileRepository interface {
 	Save(ctx context.Context, file domain.File) error
 	FindByID(ctx context.Context, id uuid.UUID) (domain.File, error)
 	FindByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error)
+	FindByFolder(ctx context.Context, ownerID uuid.UUID, folderID *uuid.UUID) ([]domain.File, error)
+	FindInFolderTree(ctx context.Context, folderID uuid.UUID) ([]domain.File, error)
+	UpdateMetadata(ctx context.Context, id, ownerID uuid.UUID, encryptedMetadata []byte) error
+	MoveToFolder(ctx context.Context, id, ownerID uuid.UUID, folderID *uuid.UUID, folderWrappedKey []byte) error
+	FindStaleByOwner(ctx context.Context, ownerID uuid.UUID, currentVersion int) ([]domain.File, error)
+	UpdateEncryptedKey(ctx context.Context, id, ownerID uuid.UUID, encryptedKey []byte, keyVersion int) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	Commit(ctx context.Context, id uuid.UUID) error
+	FindPending(ctx context.Context, before time.Time, limit int) ([]domain.File, error)
+
+	Trash(ctx context.Context, id, ownerID uuid.UUID, now time.Time) error
+	RestoreFromTrash(ctx context.Context, id, ownerID uuid.UUID) error
+	FindTrashed(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error)
+	FindTrashedBefore(ctx context.Context, cutoff time.Time, limit int) ([]domain.File, error)
+	Purge(ctx context.Context, id uuid.UUID, cutoff time.Time) error
+
+	AddVersion(ctx context.Context, ownerID uuid.UUID, version domain.FileVersion) (domain.FileVersion, error)
+	FindVersions(ctx context.Context, fileID uuid.UUID) ([]domain.FileVersion, error)
+	FindVersion(ctx context.Context, fileID uuid.UUID, version int) (domain.FileVersion, error)
+	RestoreVersion(ctx context.Context, fileID, ownerID uuid.UUID, version int) error
+	DeleteVersion(ctx context.Context, fileID, ownerID uuid.UUID, version int) (domain.FileVersion, error)
+	PruneVersions(ctx context.Context, fileID, ownerID uuid.UUID, keep int) ([]domain.FileVersion, error)
+	CommitVersion(ctx context.Context, fileID uuid.UUID, version int) error
+	AbortVersion(ctx context.Context, fileID uuid.UUID, version int) error
+	FindPendingVersions(ctx context.Context, before time.Time, limit int) ([]domain.FileVersion, error)
 }
 
 type fileRepository struct {
@@ -24,62 +72,539 @@ func NewFileRepository(db *pgxpool.Pool) FileRepository {
 	return &fileRepository{db: db}
 }
 
+// Save stores a new file together with its first version, both pending
+// until Commit, and reserves the file's size against the owner's quota. It
+// defaults a zero KeyVersion to the owner's current key version and an empty
+// ObjectName to the file ID.
 func (r *fileRepository) Save(ctx context.Context, file domain.File) error {
-	_, err := r.db.Exec(ctx, `
-		INSERT INTO files (id, owner_id, filename, mime_type, size, iv, encrypted_key, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, file.ID, file.OwnerID, file.Filename, file.MimeType, file.Size, file.IV, file.EncryptedKey, file.CreatedAt)
+	if file.Version == 0 {
+		file.Version = 1
+	}
+	if file.ObjectName == "" {
+		file.ObjectName = file.ID.String()
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := reserveUsage(ctx, tx, file.OwnerID, file.Size); err != nil {
+		return err
+	}
+	if err := insertFile(ctx, tx, file, domain.FileStatePending); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertFile adds the files row and its first file_versions row in state.
+func insertFile(ctx context.Context, tx pgx.Tx, file domain.File, state string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO files (`+fileColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE(NULLIF($10::integer, 0), (SELECT key_version FROM users WHERE id = $2)), $11, $12, $13, $14, $15, $16, $17, $18, $19)
+	`, file.ID, file.OwnerID, file.Filename, file.MimeType, file.Size, file.IV, file.EncryptedKey, file.FormatVersion, file.ChunkSize, file.KeyVersion, file.CreatedAt, file.FolderID, file.FolderWrappedKey, file.EncryptedMetadata, file.Version, file.VersionKey, file.ObjectName, file.DeletedAt, state)
+	if err != nil {
+		return err
+	}
 
+	_, err = tx.Exec(ctx, `
+		INSERT INTO file_versions (`+fileVersionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, file.ID, file.Version, file.ObjectName, file.Size, file.IV, file.VersionKey, file.FormatVersion, file.ChunkSize, state, file.CreatedAt)
 	return err
 }
 
 func (r *fileRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.File, error) {
-	var f domain.File
-	err := r.db.QueryRow(ctx, `
-		SELECT id, owner_id, filename, mime_type, size, iv, encrypted_key, created_at
-		FROM files WHERE id = $1
-	`, id).Scan(&f.ID, &f.OwnerID, &f.Filename, &f.MimeType, &f.Size, &f.IV, &f.EncryptedKey, &f.CreatedAt)
-
-	return f, err
+	return scanFile(r.db.QueryRow(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE id = $1 AND state = 'committed'
+	`, id))
 }
 
 func (r *fileRepository) FindByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error) {
-	rows, err := r.db.Query(ctx, `
-		SELECT id, owner_id, filename, mime_type, size, iv, encrypted_key, created_at
-		FROM files WHERE owner_id = $1
+	return r.query(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE owner_id = $1 AND deleted_at IS NULL AND state = 'committed'
 		ORDER BY created_at DESC
 	`, ownerID)
+}
 
+// FindByFolder lists the files directly inside folderID, or the owner's
+// top-level files when folderID is nil.
+func (r *fileRepository) FindByFolder(ctx context.Context, ownerID uuid.UUID, folderID *uuid.UUID) ([]domain.File, error) {
+	return r.query(ctx, `
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE owner_id = $1 AND folder_id IS NOT DISTINCT FROM $2 AND deleted_at IS NULL AND state = 'committed'
+		ORDER BY created_at DESC
+	`, ownerID, folderID)
+}
+
+// FindInFolderTree lists every file outside the trash in folderID and all of
+// its descendants.
+func (r *fileRepository) FindInFolderTree(ctx context.Context, folderID uuid.UUID) ([]domain.File, error) {
+	return r.query(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id FROM folders WHERE id = $1
+			UNION ALL
+			SELECT f.id FROM folders f JOIN tree t ON f.parent_id = t.id
+		)
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE folder_id IN (SELECT id FROM tree) AND deleted_at IS NULL AND state = 'committed'
+		ORDER BY created_at DESC
+	`, folderID)
+}
+
+// Trash moves a file into its owner's trash.
+func (r *fileRepository) Trash(ctx context.Context, id, ownerID uuid.UUID, now time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE files SET deleted_at = $3
+		WHERE id = $1 AND owner_id = $2 AND deleted_at IS NULL
+	`, id, ownerID, now)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("file")
 	}
+	return nil
+}
 
-	defer rows.Close()
+// RestoreFromTrash takes a file out of the trash. A file whose folder is
+// still in the trash comes back at the top level.
+func (r *fileRepository) RestoreFromTrash(ctx context.Context, id, ownerID uuid.UUID) error {
+	cmd, err := r.db.Exec(ctx, `
+		WITH live AS (
+			SELECT EXISTS (
+				SELECT 1 FROM files f JOIN folders fo ON fo.id = f.folder_id
+				WHERE f.id = $1 AND fo.deleted_at IS NULL
+			) AS in_folder
+		)
+		UPDATE files
+		SET deleted_at = NULL,
+		    folder_id = CASE WHEN live.in_folder THEN folder_id END,
+		    folder_wrapped_key = CASE WHEN live.in_folder THEN folder_wrapped_key END
+		FROM live
+		WHERE id = $1 AND owner_id = $2 AND deleted_at IS NOT NULL
+	`, id, ownerID)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("trashed file")
+	}
+	return nil
+}
 
-	var files []domain.File
-	for rows.Next() {
-		var f domain.File
-		if err := rows.Scan(&f.ID, &f.OwnerID, &f.Filename, &f.MimeType, &f.Size, &f.IV, &f.EncryptedKey, &f.CreatedAt); err != nil {
-			return nil, err
-		}
-		files = append(files, f)
+func (r *fileRepository) FindTrashed(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error) {
+	return r.query(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE owner_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`, ownerID)
+}
+
+// FindTrashedBefore returns up to limit files trashed before cutoff, oldest
+// first.
+func (r *fileRepository) FindTrashedBefore(ctx context.Context, cutoff time.Time, limit int) ([]domain.File, error) {
+	return r.query(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`, cutoff, limit)
+}
+
+// UpdateMetadata stores encrypted metadata for a file and drops its legacy
+// plaintext filename and MIME type.
+func (r *fileRepository) UpdateMetadata(ctx context.Context, id, ownerID uuid.UUID, encryptedMetadata []byte) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE files SET encrypted_metadata = $3, filename = '', mime_type = ''
+		WHERE id = $1 AND owner_id = $2
+	`, id, ownerID, encryptedMetadata)
+	if err != nil {
+		return err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("file")
 	}
+	return nil
+}
 
-	return files, nil
+// MoveToFolder places a file in folderID (nil for the top level) together
+// with its key rewrapped under that folder's key. The target folder must
+// belong to the file's owner.
+func (r *fileRepository) MoveToFolder(ctx context.Context, id, ownerID uuid.UUID, folderID *uuid.UUID, folderWrappedKey []byte) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE files SET folder_id = $3, folder_wrapped_key = $4
+		WHERE id = $1 AND owner_id = $2
+		  AND ($3::uuid IS NULL OR EXISTS (SELECT 1 FROM folders WHERE id = $3 AND owner_id = $2 AND deleted_at IS NULL))
+	`, id, ownerID, folderID, folderWrappedKey)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("file or folder")
+	}
+	return nil
+}
+
+func (r *fileRepository) FindStaleByOwner(ctx context.Context, ownerID uuid.UUID, currentVersion int) ([]domain.File, error) {
+	return r.query(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE owner_id = $1 AND key_version < $2
+		ORDER BY created_at
+	`, ownerID, currentVersion)
 }
 
+func (r *fileRepository) UpdateEncryptedKey(ctx context.Context, id, ownerID uuid.UUID, encryptedKey []byte, keyVersion int) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE files SET encrypted_key = $3, key_version = $4
+		WHERE id = $1 AND owner_id = $2
+	`, id, ownerID, encryptedKey, keyVersion)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("file")
+	}
+	return nil
+}
+
+// deleteFileSQL deletes the file matching where with its versions, releases
+// their bytes from the owner's usage and queues their objects for deletion
+// in the storage outbox.
+func deleteFileSQL(where string) string {
+	return `
+		WITH target AS (
+			SELECT id FROM files WHERE ` + where + ` FOR UPDATE
+		), versions AS (
+			SELECT object_name, size FROM file_versions WHERE file_id IN (SELECT id FROM target)
+		), freed AS (
+			SELECT COALESCE(SUM(size), 0) AS bytes FROM versions
+		), queued AS (
+			INSERT INTO storage_outbox (op, bucket, object_name)
+			SELECT 'delete', 'files', object_name FROM versions
+		), deleted AS (
+			DELETE FROM files WHERE id IN (SELECT id FROM target) RETURNING owner_id
+		)
+		UPDATE users SET used_bytes = GREATEST(used_bytes - freed.bytes, 0)
+		FROM deleted, freed
+		WHERE users.id = deleted.owner_id
+	`
+}
+
+// Delete removes a file in any state together with its versions, releases
+// their bytes from the owner's usage and queues their objects for deletion
+// in the storage outbox.
 func (r *fileRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	cmd, err := r.db.Exec(ctx, deleteFileSQL(`id = $1`), id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("file")
+	}
+	return nil
+}
+
+// Purge deletes a file like Delete, but only while it is still in the trash
+// and was trashed before cutoff, so a restore that lands first keeps it.
+func (r *fileRepository) Purge(ctx context.Context, id uuid.UUID, cutoff time.Time) error {
+	cmd, err := r.db.Exec(ctx, deleteFileSQL(`id = $1 AND deleted_at IS NOT NULL AND deleted_at < $2`), id, cutoff)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("trashed file")
+	}
+	return nil
+}
+
+// Commit marks a pending file and its first version as committed once the
+// object is in storage.
+func (r *fileRepository) Commit(ctx context.Context, id uuid.UUID) error {
 	cmd, err := r.db.Exec(ctx, `
-		DELETE FROM files WHERE id = $1
+		WITH committed AS (
+			UPDATE files SET state = 'committed'
+			WHERE id = $1 AND state = 'pending'
+			RETURNING id, version
+		)
+		UPDATE file_versions v SET state = 'committed'
+		FROM committed c
+		WHERE v.file_id = c.id AND v.version = c.version
 	`, id)
 	if err != nil {
 		return err
 	}
 	if cmd.RowsAffected() == 0 {
-		return fmt.Errorf("file not found")
+		return domain.NotFound("pending file")
+	}
+	return nil
+}
+
+// FindPending returns up to limit files that have been pending since before
+// the cutoff, oldest first.
+func (r *fileRepository) FindPending(ctx context.Context, before time.Time, limit int) ([]domain.File, error) {
+	return r.query(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE state = 'pending' AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`, before, limit)
+}
+
+// AddVersion stores version as pending under the next version number of its
+// file and reserves its size against the owner's quota. It becomes current
+// on CommitVersion. The file must belong to ownerID.
+func (r *fileRepository) AddVersion(ctx context.Context, ownerID uuid.UUID, version domain.FileVersion) (domain.FileVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return domain.FileVersion{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx, `
+		SELECT 1 FROM files WHERE id = $1 AND owner_id = $2 FOR UPDATE
+	`, version.FileID, ownerID)
+	if err != nil {
+		return domain.FileVersion{}, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.FileVersion{}, domain.NotFound("file")
+	}
+
+	if err := reserveUsage(ctx, tx, ownerID, version.Size); err != nil {
+		return domain.FileVersion{}, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(MAX(version), 0) + 1 FROM file_versions WHERE file_id = $1
+	`, version.FileID).Scan(&version.Version)
+	if err != nil {
+		return domain.FileVersion{}, err
+	}
+
+	version.State = domain.FileStatePending
+	_, err = tx.Exec(ctx, `
+		INSERT INTO file_versions (`+fileVersionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, version.FileID, version.Version, version.ObjectName, version.Size, version.IV, version.VersionKey, version.FormatVersion, version.ChunkSize, version.State, version.CreatedAt)
+	if err != nil {
+		return domain.FileVersion{}, err
+	}
+
+	return version, tx.Commit(ctx)
+}
+
+// CommitVersion marks a pending version as committed and makes it current
+// unless a newer version already is.
+func (r *fileRepository) CommitVersion(ctx context.Context, fileID uuid.UUID, version int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx, `
+		UPDATE file_versions SET state = 'committed'
+		WHERE file_id = $1 AND version = $2 AND state = 'pending'
+	`, fileID, version)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("pending file version")
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE files f SET `+currentVersionSQL+`
+		FROM file_versions v
+		WHERE f.id = $1 AND v.file_id = f.id AND v.version = $2 AND f.version < $2
+	`, fileID, version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// AbortVersion rolls back a pending version: the row is removed, its bytes
+// are released and its object, if it was written, is queued for deletion.
+func (r *fileRepository) AbortVersion(ctx context.Context, fileID uuid.UUID, version int) error {
+	cmd, err := r.db.Exec(ctx, `
+		WITH deleted AS (
+			DELETE FROM file_versions
+			WHERE file_id = $1 AND version = $2 AND state = 'pending'
+			RETURNING object_name, size
+		), queued AS (
+			INSERT INTO storage_outbox (op, bucket, object_name)
+			SELECT 'delete', 'files', object_name FROM deleted
+		)
+		UPDATE users SET used_bytes = GREATEST(used_bytes - (SELECT size FROM deleted), 0)
+		WHERE id = (SELECT owner_id FROM files WHERE id = $1) AND EXISTS (SELECT 1 FROM deleted)
+	`, fileID, version)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("pending file version")
+	}
+	return nil
+}
+
+func (r *fileRepository) FindPendingVersions(ctx context.Context, before time.Time, limit int) ([]domain.FileVersion, error) {
+	return r.queryVersions(ctx, `
+		SELECT `+fileVersionColumns+`
+		FROM file_versions WHERE state = 'pending' AND created_at < $1
+		  AND file_id IN (SELECT id FROM files WHERE state = 'committed')
+		ORDER BY created_at
+		LIMIT $2
+	`, before, limit)
+}
+
+func (r *fileRepository) FindVersions(ctx context.Context, fileID uuid.UUID) ([]domain.FileVersion, error) {
+	return r.queryVersions(ctx, `
+		SELECT `+fileVersionColumns+`
+		FROM file_versions WHERE file_id = $1 AND state = 'committed'
+		ORDER BY version DESC
+	`, fileID)
+}
+
+func (r *fileRepository) FindVersion(ctx context.Context, fileID uuid.UUID, version int) (domain.FileVersion, error) {
+	return scanFileVersion(r.db.QueryRow(ctx, `
+		SELECT `+fileVersionColumns+`
+		FROM file_versions WHERE file_id = $1 AND version = $2 AND state = 'committed'
+	`, fileID, version))
+}
+
+// RestoreVersion makes an older version current again. Later versions are
+// kept, so a restore can itself be undone. The version history of a trashed
+// file cannot be changed.
+func (r *fileRepository) RestoreVersion(ctx context.Context, fileID, ownerID uuid.UUID, version int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setCurrentVersion(ctx, tx, fileID, ownerID, version); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func setCurrentVersion(ctx context.Context, tx pgx.Tx, fileID, ownerID uuid.UUID, version int) error {
+	cmd, err := tx.Exec(ctx, `
+		UPDATE files f SET `+currentVersionSQL+`
+		FROM file_versions v
+		WHERE f.id = $1 AND f.owner_id = $2 AND f.deleted_at IS NULL
+		  AND v.file_id = f.id AND v.version = $3 AND v.state = 'committed'
+	`, fileID, ownerID, version)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("file version")
 	}
 	return nil
 }
+
+// DeleteVersion removes a committed, non-current version, releases its bytes
+// and queues its object for deletion.
+func (r *fileRepository) DeleteVersion(ctx context.Context, fileID, ownerID uuid.UUID, version int) (domain.FileVersion, error) {
+	v, err := scanFileVersion(r.db.QueryRow(ctx, `
+		WITH deleted AS (
+			DELETE FROM file_versions v
+			USING files f
+			WHERE v.file_id = $1 AND v.version = $3 AND v.state = 'committed'
+			  AND f.id = v.file_id AND f.owner_id = $2 AND f.deleted_at IS NULL AND f.version <> v.version
+			RETURNING v.*
+		), queued AS (
+			INSERT INTO storage_outbox (op, bucket, object_name)
+			SELECT 'delete', 'files', object_name FROM deleted
+		), released AS (
+			UPDATE users SET used_bytes = GREATEST(used_bytes - (SELECT COALESCE(SUM(size), 0) FROM deleted), 0)
+			WHERE id = $2
+		)
+		SELECT `+fileVersionColumns+` FROM deleted
+	`, fileID, ownerID, version))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.FileVersion{}, &domain.Error{Kind: domain.KindNotFound, Code: "file_version_not_found", Message: "file version not found or current"}
+	}
+	return v, err
+}
+
+// PruneVersions deletes every committed version except the keep newest and
+// the current one, releasing their bytes and queueing their objects for
+// deletion, and returns the deleted versions.
+func (r *fileRepository) PruneVersions(ctx context.Context, fileID, ownerID uuid.UUID, keep int) ([]domain.FileVersion, error) {
+	return r.queryVersions(ctx, `
+		WITH deleted AS (
+			DELETE FROM file_versions v
+			USING files f
+			WHERE v.file_id = $1 AND f.id = v.file_id AND f.owner_id = $2 AND f.deleted_at IS NULL
+			  AND v.state = 'committed' AND v.version <> f.version
+			  AND v.version NOT IN (
+				SELECT version FROM file_versions WHERE file_id = $1 AND state = 'committed'
+				ORDER BY version DESC LIMIT $3
+			  )
+			RETURNING v.*
+		), queued AS (
+			INSERT INTO storage_outbox (op, bucket, object_name)
+			SELECT 'delete', 'files', object_name FROM deleted
+		), released AS (
+			UPDATE users SET used_bytes = GREATEST(used_bytes - (SELECT COALESCE(SUM(size), 0) FROM deleted), 0)
+			WHERE id = $2
+		)
+		SELECT `+fileVersionColumns+` FROM deleted
+	`, fileID, ownerID, keep)
+}
+
+func (r *fileRepository) queryVersions(ctx context.Context, sql string, args ...any) ([]domain.FileVersion, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []domain.FileVersion
+	for rows.Next() {
+		v, err := scanFileVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (r *fileRepository) query(ctx context.Context, sql string, args ...any) ([]domain.File, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []domain.File
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
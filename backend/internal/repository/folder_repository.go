@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const folderColumns = `id, owner_id, parent_id, encrypted_name, wrapped_key, key_version, created_at, updated_at`
+
+const folderShareColumns = `id, folder_id, recipient_id, wrapped_key, key_version, permissions, granted_by, created_at, expires_at`
+
+// ancestorsCTE walks from folder $1 up to the root of its tree. A trashed
+// folder has no ancestors, so nothing reaches it through them.
+const ancestorsCTE = `
+	WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM folders WHERE id = $1 AND deleted_at IS NULL
+		UNION ALL
+		SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+	)`
+
+func scanFolder(row pgx.Row) (domain.Folder, error) {
+	var f domain.Folder
+	err := row.Scan(&f.ID, &f.OwnerID, &f.ParentID, &f.EncryptedName, &f.WrappedKey, &f.KeyVersion, &f.CreatedAt, &f.UpdatedAt)
+	return f, notFound(err, "folder")
+}
+
+func scanFolderShare(row pgx.Row) (domain.FolderShare, error) {
+	var s domain.FolderShare
+	err := row.Scan(&s.ID, &s.FolderID, &s.RecipientID, &s.WrappedKey, &s.KeyVersion, &s.Permissions, &s.GrantedBy, &s.CreatedAt, &s.ExpiresAt)
+	return s, notFound(err, "folder share")
+}
+
+type FolderRepository interface {
+	Save(ctx context.Context, folder domain.Folder) error
+	FindByID(ctx context.Context, id uuid.UUID) (domain.Folder, error)
+	FindChildren(ctx context.Context, ownerID uuid.UUID, parentID *uuid.UUID) ([]domain.Folder, error)
+	FindSubtree(ctx context.Context, id uuid.UUID) ([]domain.Folder, error)
+	Rename(ctx context.Context, id uuid.UUID, encryptedName []byte, now time.Time) error
+	Move(ctx context.Context, id uuid.UUID, parentID *uuid.UUID, wrappedKey []byte, keyVersion int, now time.Time) error
+	Trash(ctx context.Context, id uuid.UUID, now time.Time) error
+	PurgeTrashed(ctx context.Context, cutoff time.Time) (int64, error)
+
+	SaveShare(ctx context.Context, share domain.FolderShare) error
+	FindShareByID(ctx context.Context, id uuid.UUID) (domain.FolderShare, error)
+	FindSharesByFolder(ctx context.Context, folderID uuid.UUID) ([]domain.FolderShare, error)
+	FindSharesByRecipient(ctx context.Context, recipientID uuid.UUID, now time.Time) ([]domain.FolderShare, error)
+	FindShareCovering(ctx context.Context, folderID, recipientID uuid.UUID, need domain.SharePermission, now time.Time) (domain.FolderShare, error)
+	DeleteShare(ctx context.Context, id uuid.UUID) error
+}
+
+type folderRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFolderRepository(db *pgxpool.Pool) FolderRepository {
+	return &folderRepository{db: db}
+}
+
+// Save defaults a zero KeyVersion on a top-level folder to the owner's
+// current key version. Nested folders are wrapped under their parent's key
+// and keep zero. A parent must belong to the same owner and be outside the
+// trash.
+func (r *folderRepository) Save(ctx context.Context, f domain.Folder) error {
+	cmd, err := r.db.Exec(ctx, `
+		INSERT INTO folders (`+folderColumns+`)
+		SELECT $1, $2, $3, $4, $5,
+		       CASE WHEN $3::uuid IS NULL THEN COALESCE(NULLIF($6::integer, 0), (SELECT key_version FROM users WHERE id = $2)) ELSE 0 END,
+		       $7, $8
+		WHERE $3::uuid IS NULL OR EXISTS (SELECT 1 FROM folders WHERE id = $3 AND owner_id = $2 AND deleted_at IS NULL)
+	`, f.ID, f.OwnerID, f.ParentID, f.EncryptedName, f.WrappedKey, f.KeyVersion, f.CreatedAt, f.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("parent folder")
+	}
+	return nil
+}
+
+// FindByID finds a folder outside the trash.
+func (r *folderRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.Folder, error) {
+	return scanFolder(r.db.QueryRow(ctx, `
+		SELECT `+folderColumns+`
+		FROM folders WHERE id = $1 AND deleted_at IS NULL
+	`, id))
+}
+
+// FindChildren lists the folders directly below parentID, or the owner's
+// top-level folders when parentID is nil.
+func (r *folderRepository) FindChildren(ctx context.Context, ownerID uuid.UUID, parentID *uuid.UUID) ([]domain.Folder, error) {
+	return r.query(ctx, `
+		SELECT `+folderColumns+`
+		FROM folders
+		WHERE owner_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND deleted_at IS NULL
+		ORDER BY created_at
+	`, ownerID, parentID)
+}
+
+// FindSubtree returns id and all of its descendants, parents before
+// children.
+func (r *folderRepository) FindSubtree(ctx context.Context, id uuid.UUID) ([]domain.Folder, error) {
+	return r.query(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT `+folderColumns+`, 0 AS depth FROM folders WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT f.id, f.owner_id, f.parent_id, f.encrypted_name, f.wrapped_key, f.key_version, f.created_at, f.updated_at, t.depth + 1
+			FROM folders f JOIN tree t ON f.parent_id = t.id
+			WHERE f.deleted_at IS NULL
+		)
+		SELECT `+folderColumns+`
+		FROM tree
+		ORDER BY depth, created_at
+	`, id)
+}
+
+func (r *folderRepository) Rename(ctx context.Context, id uuid.UUID, encryptedName []byte, now time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE folders SET encrypted_name = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL
+	`, id, encryptedName, now)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("folder")
+	}
+	return nil
+}
+
+// Move reparents a folder along with its key rewrapped for the new parent.
+// Moving a folder below itself or one of its descendants is rejected, as is
+// a parent owned by someone else or in the trash.
+func (r *folderRepository) Move(ctx context.Context, id uuid.UUID, parentID *uuid.UUID, wrappedKey []byte, keyVersion int, now time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var ownerID uuid.UUID
+	if err := tx.QueryRow(ctx, `
+		SELECT owner_id FROM folders WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, id).Scan(&ownerID); err != nil {
+		return notFound(err, "folder")
+	}
+
+	if parentID != nil {
+		var parentOwner uuid.UUID
+		var cycle bool
+		err := tx.QueryRow(ctx, ancestorsCTE+`
+			SELECT owner_id, EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+			FROM folders WHERE id = $1 AND deleted_at IS NULL
+		`, *parentID, id).Scan(&parentOwner, &cycle)
+		if err != nil {
+			return notFound(err, "parent folder")
+		}
+		if parentOwner != ownerID {
+			return domain.NotFound("parent folder")
+		}
+		if cycle {
+			return domain.Conflict("folder_cycle", "cannot move a folder into itself or one of its descendants")
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE folders
+		SET parent_id = $2, wrapped_key = $3,
+		    key_version = CASE WHEN $2::uuid IS NULL THEN COALESCE(NULLIF($4::integer, 0), (SELECT key_version FROM users WHERE id = owner_id)) ELSE 0 END,
+		    updated_at = $5
+		WHERE id = $1
+	`, id, parentID, wrappedKey, keyVersion, now); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Trash moves a folder, its descendants and every file inside them into the
+// trash. Folders and files already there keep their original deletion time.
+func (r *folderRepository) Trash(ctx context.Context, id uuid.UUID, now time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id FROM folders WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT f.id FROM folders f JOIN tree t ON f.parent_id = t.id
+		), trashed_files AS (
+			UPDATE files SET deleted_at = $2
+			WHERE folder_id IN (SELECT id FROM tree) AND deleted_at IS NULL
+		)
+		UPDATE folders SET deleted_at = COALESCE(deleted_at, $2)
+		WHERE id IN (SELECT id FROM tree)
+	`, id, now)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("folder")
+	}
+	return nil
+}
+
+// PurgeTrashed removes the rows of folders trashed before cutoff once no file
+// is left anywhere in their subtree. Files are purged on their own first; a
+// tree still holding one, say a file restored while the purge ran, stays in
+// the trash until the next purge.
+func (r *folderRepository) PurgeTrashed(ctx context.Context, cutoff time.Time) (int64, error) {
+	cmd, err := r.db.Exec(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id, id AS root FROM folders WHERE deleted_at < $1
+			UNION ALL
+			SELECT f.id, t.root FROM folders f JOIN tree t ON f.parent_id = t.id
+		), kept AS (
+			SELECT t.root FROM tree t JOIN folders f ON f.id = t.id
+			WHERE f.deleted_at IS NULL OR f.deleted_at >= $1
+			   OR EXISTS (SELECT 1 FROM files WHERE folder_id = t.id)
+		)
+		DELETE FROM folders
+		WHERE id IN (SELECT id FROM tree WHERE root NOT IN (SELECT root FROM kept))
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// SaveShare defaults a zero KeyVersion to the recipient's current key
+// version.
+func (r *folderRepository) SaveShare(ctx context.Context, s domain.FolderShare) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO folder_shares (`+folderShareColumns+`)
+		VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5::integer, 0), (SELECT key_version FROM users WHERE id = $3)), $6, $7, $8, $9)
+	`, s.ID, s.FolderID, s.RecipientID, s.WrappedKey, s.KeyVersion, s.Permissions, s.GrantedBy, s.CreatedAt, s.ExpiresAt)
+	return uniqueViolation(err, domain.ErrAlreadyShared)
+}
+
+func (r *folderRepository) FindShareByID(ctx context.Context, id uuid.UUID) (domain.FolderShare, error) {
+	return scanFolderShare(r.db.QueryRow(ctx, `
+		SELECT `+folderShareColumns+`
+		FROM folder_shares WHERE id = $1
+	`, id))
+}
+
+func (r *folderRepository) FindSharesByFolder(ctx context.Context, folderID uuid.UUID) ([]domain.FolderShare, error) {
+	return r.queryShares(ctx, `
+		SELECT `+folderShareColumns+`
+		FROM folder_shares WHERE folder_id = $1
+		ORDER BY created_at DESC
+	`, folderID)
+}
+
+func (r *folderRepository) FindSharesByRecipient(ctx context.Context, recipientID uuid.UUID, now time.Time) ([]domain.FolderShare, error) {
+	return r.queryShares(ctx, `
+		SELECT `+folderShareColumns+`
+		FROM folder_shares
+		WHERE recipient_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		  AND folder_id IN (SELECT id FROM folders WHERE deleted_at IS NULL)
+		ORDER BY created_at DESC
+	`, recipientID, now)
+}
+
+// FindShareCovering finds an unexpired share of folderID or any of its
+// ancestors to recipientID that carries need.
+func (r *folderRepository) FindShareCovering(ctx context.Context, folderID, recipientID uuid.UUID, need domain.SharePermission, now time.Time) (domain.FolderShare, error) {
+	return scanFolderShare(r.db.QueryRow(ctx, ancestorsCTE+`
+		SELECT `+folderShareColumns+`
+		FROM folder_shares
+		WHERE folder_id IN (SELECT id FROM ancestors)
+		  AND recipient_id = $2
+		  AND permissions & $3 = $3
+		  AND (expires_at IS NULL OR expires_at > $4)
+		LIMIT 1
+	`, folderID, recipientID, need, now))
+}
+
+func (r *folderRepository) DeleteShare(ctx context.Context, id uuid.UUID) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM folder_shares WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("share")
+	}
+	return nil
+}
+
+func (r *folderRepository) query(ctx context.Context, sql string, args ...any) ([]domain.Folder, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []domain.Folder
+	for rows.Next() {
+		f, err := scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+
+	return folders, rows.Err()
+}
+
+func (r *folderRepository) queryShares(ctx context.Context, sql string, args ...any) ([]domain.FolderShare, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []domain.FolderShare
+	for rows.Next() {
+		s, err := scanFolderShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+
+	return shares, rows.Err()
+}
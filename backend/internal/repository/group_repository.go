@@ -0,0 +1,407 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const groupColumns = `id, name, owner_id, key_version, public_key, created_at`
+
+const groupShareColumns = `id, file_id, group_id, wrapped_key, key_version, permissions, granted_by, created_at, expires_at`
+
+func scanGroup(row pgx.Row) (domain.Group, error) {
+	var g domain.Group
+	err := row.Scan(&g.ID, &g.Name, &g.OwnerID, &g.KeyVersion, &g.PublicKey, &g.CreatedAt)
+	return g, notFound(err, "group")
+}
+
+func scanGroupShare(row pgx.Row) (domain.GroupShare, error) {
+	var s domain.GroupShare
+	err := row.Scan(&s.ID, &s.FileID, &s.GroupID, &s.WrappedKey, &s.KeyVersion, &s.Permissions, &s.GrantedBy, &s.CreatedAt, &s.ExpiresAt)
+	return s, notFound(err, "group share")
+}
+
+type GroupRepository interface {
+	Create(ctx context.Context, group domain.Group, memberKeys []domain.GroupMemberKey) error
+	FindByID(ctx context.Context, id uuid.UUID) (domain.Group, error)
+	FindByMember(ctx context.Context, userID uuid.UUID) ([]domain.Group, error)
+	IsMember(ctx context.Context, groupID, userID uuid.UUID) (bool, error)
+	ListMembers(ctx context.Context, groupID uuid.UUID) ([]domain.GroupMember, error)
+	FindMemberKeys(ctx context.Context, groupID, userID uuid.UUID) ([]domain.GroupMemberKey, error)
+	Rotate(ctx context.Context, rotation domain.GroupRotation) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	SaveShare(ctx context.Context, share domain.GroupShare) error
+	FindShareByID(ctx context.Context, id uuid.UUID) (domain.GroupShare, error)
+	FindSharesByGroup(ctx context.Context, groupID uuid.UUID) ([]domain.GroupShare, error)
+	FindShareForMember(ctx context.Context, fileID, userID uuid.UUID, need domain.SharePermission, now time.Time) (domain.GroupShare, domain.GroupMemberKey, error)
+	DeleteShare(ctx context.Context, id uuid.UUID) error
+}
+
+type groupRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewGroupRepository(db *pgxpool.Pool) GroupRepository {
+	return &groupRepository{db: db}
+}
+
+// insertMemberKeys stores wrapped group private keys. A zero UserKeyVersion
+// defaults to the member's current keypair version.
+func insertMemberKeys(ctx context.Context, tx pgx.Tx, keys []domain.GroupMemberKey) error {
+	for _, k := range keys {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO group_member_keys (group_id, version, user_id, wrapped_private_key, user_key_version)
+			VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5::integer, 0), (SELECT key_version FROM users WHERE id = $3)))
+		`, k.GroupID, k.Version, k.UserID, k.WrappedPrivateKey, k.UserKeyVersion)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create stores a new group whose members are exactly the users in
+// memberKeys.
+func (r *groupRepository) Create(ctx context.Context, g domain.Group, memberKeys []domain.GroupMemberKey) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO groups (`+groupColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, g.ID, g.Name, g.OwnerID, g.KeyVersion, g.PublicKey, g.CreatedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO group_keys (group_id, version, public_key, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, g.ID, g.KeyVersion, g.PublicKey, g.CreatedAt); err != nil {
+		return err
+	}
+
+	for _, k := range memberKeys {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO group_members (group_id, user_id, joined_at)
+			VALUES ($1, $2, $3)
+		`, g.ID, k.UserID, g.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := insertMemberKeys(ctx, tx, memberKeys); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *groupRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.Group, error) {
+	return scanGroup(r.db.QueryRow(ctx, `
+		SELECT `+groupColumns+`
+		FROM groups WHERE id = $1
+	`, id))
+}
+
+func (r *groupRepository) FindByMember(ctx context.Context, userID uuid.UUID) ([]domain.Group, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT g.id, g.name, g.owner_id, g.key_version, g.public_key, g.created_at
+		FROM groups g
+		JOIN group_members m ON m.group_id = g.id
+		WHERE m.user_id = $1
+		ORDER BY g.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []domain.Group
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+func (r *groupRepository) IsMember(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2)
+	`, groupID, userID).Scan(&ok)
+	return ok, err
+}
+
+func (r *groupRepository) ListMembers(ctx context.Context, groupID uuid.UUID) ([]domain.GroupMember, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.group_id, m.user_id, u.username, m.joined_at
+		FROM group_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = $1
+		ORDER BY u.username
+	`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []domain.GroupMember
+	for rows.Next() {
+		var m domain.GroupMember
+		if err := rows.Scan(&m.GroupID, &m.UserID, &m.Username, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *groupRepository) FindMemberKeys(ctx context.Context, groupID, userID uuid.UUID) ([]domain.GroupMemberKey, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT group_id, version, user_id, wrapped_private_key, user_key_version
+		FROM group_member_keys
+		WHERE group_id = $1 AND user_id = $2
+		ORDER BY version DESC
+	`, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []domain.GroupMemberKey
+	for rows.Next() {
+		var k domain.GroupMemberKey
+		if err := rows.Scan(&k.GroupID, &k.Version, &k.UserID, &k.WrappedPrivateKey, &k.UserKeyVersion); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// Rotate applies a membership change, installs the next group keypair and
+// moves every group share to it in one transaction. Removed members lose
+// every wrapped group key they held. It fails if rotation.Version is not
+// exactly one past the current version, if MemberKeys does not cover the
+// resulting membership exactly or if Shares does not cover the group's
+// shares exactly.
+func (r *groupRepository) Rotate(ctx context.Context, rot domain.GroupRotation) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current int
+	if err := tx.QueryRow(ctx, `
+		SELECT key_version FROM groups WHERE id = $1 FOR UPDATE
+	`, rot.GroupID).Scan(&current); err != nil {
+		return notFound(err, "group")
+	}
+	if current != rot.Version-1 {
+		return domain.ErrGroupKeyVersionConflict
+	}
+
+	if len(rot.Remove) > 0 {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM group_members WHERE group_id = $1 AND user_id = ANY($2)
+		`, rot.GroupID, rot.Remove); err != nil {
+			return err
+		}
+	}
+
+	for _, userID := range rot.Add {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO group_members (group_id, user_id, joined_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+		`, rot.GroupID, userID, rot.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	var members []uuid.UUID
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(user_id ORDER BY user_id), '{}') FROM group_members WHERE group_id = $1
+	`, rot.GroupID).Scan(&members); err != nil {
+		return err
+	}
+
+	wrapped := make([]uuid.UUID, 0, len(rot.MemberKeys))
+	for _, k := range rot.MemberKeys {
+		wrapped = append(wrapped, k.UserID)
+	}
+	slices.SortFunc(wrapped, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	if !slices.Equal(members, wrapped) {
+		return domain.ErrGroupMembershipMismatch
+	}
+
+	// SaveShare holds the group row FOR SHARE, so no share can appear
+	// between this read and the commit.
+	var shares []uuid.UUID
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(id ORDER BY id), '{}') FROM group_shares WHERE group_id = $1
+	`, rot.GroupID).Scan(&shares); err != nil {
+		return err
+	}
+
+	rewrapped := make([]uuid.UUID, 0, len(rot.Shares))
+	for _, s := range rot.Shares {
+		rewrapped = append(rewrapped, s.ShareID)
+	}
+	slices.SortFunc(rewrapped, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	if !slices.Equal(shares, rewrapped) {
+		return domain.ErrGroupSharesMismatch
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE group_keys SET retired_at = $2
+		WHERE group_id = $1 AND retired_at IS NULL
+	`, rot.GroupID, rot.CreatedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO group_keys (group_id, version, public_key, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, rot.GroupID, rot.Version, rot.PublicKey, rot.CreatedAt); err != nil {
+		return err
+	}
+
+	if err := insertMemberKeys(ctx, tx, rot.MemberKeys); err != nil {
+		return err
+	}
+
+	for _, s := range rot.Shares {
+		if _, err := tx.Exec(ctx, `
+			UPDATE group_shares SET wrapped_key = $3, key_version = $4
+			WHERE id = $1 AND group_id = $2
+		`, s.ShareID, rot.GroupID, s.WrappedKey, rot.Version); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE groups SET key_version = $2, public_key = $3 WHERE id = $1
+	`, rot.GroupID, rot.Version, rot.PublicKey); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *groupRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM groups WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("group")
+	}
+	return nil
+}
+
+// SaveShare defaults a zero KeyVersion to the group's current key version
+// and fails with domain.ErrGroupKeyVersionConflict for any other version,
+// since only current-version shares are carried through a rotation. It
+// locks the group row so that it cannot interleave with Rotate.
+func (r *groupRepository) SaveShare(ctx context.Context, s domain.GroupShare) error {
+	cmd, err := r.db.Exec(ctx, `
+		INSERT INTO group_shares (`+groupShareColumns+`)
+		SELECT $1, $2, g.id, $4, g.key_version, $6, $7, $8, $9
+		FROM (SELECT id, key_version FROM groups WHERE id = $3 FOR SHARE) g
+		WHERE g.key_version = COALESCE(NULLIF($5::integer, 0), g.key_version)
+	`, s.ID, s.FileID, s.GroupID, s.WrappedKey, s.KeyVersion, s.Permissions, s.GrantedBy, s.CreatedAt, s.ExpiresAt)
+	if err != nil {
+		return uniqueViolation(err, domain.ErrAlreadyShared)
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrGroupKeyVersionConflict
+	}
+	return nil
+}
+
+func (r *groupRepository) FindShareByID(ctx context.Context, id uuid.UUID) (domain.GroupShare, error) {
+	return scanGroupShare(r.db.QueryRow(ctx, `
+		SELECT `+groupShareColumns+`
+		FROM group_shares WHERE id = $1
+	`, id))
+}
+
+func (r *groupRepository) FindSharesByGroup(ctx context.Context, groupID uuid.UUID) ([]domain.GroupShare, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+groupShareColumns+`
+		FROM group_shares
+		WHERE group_id = $1 AND file_id IN (SELECT id FROM files WHERE deleted_at IS NULL)
+		ORDER BY created_at DESC
+	`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []domain.GroupShare
+	for rows.Next() {
+		s, err := scanGroupShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+
+	return shares, rows.Err()
+}
+
+// FindShareForMember resolves userID's access to fileID through any group
+// they belong to, returning an unexpired group share carrying need along
+// with the member's copy of the group private key that unwraps it.
+func (r *groupRepository) FindShareForMember(ctx context.Context, fileID, userID uuid.UUID, need domain.SharePermission, now time.Time) (domain.GroupShare, domain.GroupMemberKey, error) {
+	var s domain.GroupShare
+	var k domain.GroupMemberKey
+	err := r.db.QueryRow(ctx, `
+		SELECT s.id, s.file_id, s.group_id, s.wrapped_key, s.key_version, s.permissions, s.granted_by, s.created_at, s.expires_at,
+		       k.group_id, k.version, k.user_id, k.wrapped_private_key, k.user_key_version
+		FROM group_shares s
+		JOIN group_member_keys k ON k.group_id = s.group_id AND k.version = s.key_version AND k.user_id = $2
+		WHERE s.file_id = $1
+		  AND s.permissions & $3 = $3
+		  AND (s.expires_at IS NULL OR s.expires_at > $4)
+		ORDER BY s.created_at
+		LIMIT 1
+	`, fileID, userID, need, now).Scan(
+		&s.ID, &s.FileID, &s.GroupID, &s.WrappedKey, &s.KeyVersion, &s.Permissions, &s.GrantedBy, &s.CreatedAt, &s.ExpiresAt,
+		&k.GroupID, &k.Version, &k.UserID, &k.WrappedPrivateKey, &k.UserKeyVersion,
+	)
+	return s, k, err
+}
+
+func (r *groupRepository) DeleteShare(ctx context.Context, id uuid.UUID) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM group_shares WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("share")
+	}
+	return nil
+}
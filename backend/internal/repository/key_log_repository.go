@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/merkle"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const keyLogEntryColumns = `idx, user_id, public_key, leaf_data, leaf_hash, created_at`
+
+func scanKeyLogEntry(row pgx.Row) (domain.KeyLogEntry, error) {
+	var e domain.KeyLogEntry
+	err := row.Scan(&e.Index, &e.UserID, &e.PublicKey, &e.LeafData, &e.LeafHash, &e.CreatedAt)
+	return e, notFound(err, "key log entry")
+}
+
+type KeyLogRepository interface {
+	Size(ctx context.Context) (int64, error)
+	AppendEntry(ctx context.Context, entry domain.KeyLogEntry, nodes []domain.KeyLogNode) error
+	FindEntry(ctx context.Context, index int64) (domain.KeyLogEntry, error)
+	FindLatestByUser(ctx context.Context, userID uuid.UUID, maxIndex int64) (domain.KeyLogEntry, error)
+	FindNodes(ctx context.Context, ids []merkle.NodeID) (map[merkle.NodeID][]byte, error)
+	SaveTreeHead(ctx context.Context, head domain.TreeHead) error
+	LatestTreeHead(ctx context.Context) (domain.TreeHead, error)
+	FindTreeHead(ctx context.Context, size int64) (domain.TreeHead, error)
+}
+
+type keyLogRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewKeyLogRepository(db *pgxpool.Pool) KeyLogRepository {
+	return &keyLogRepository{db: db}
+}
+
+func (r *keyLogRepository) Size(ctx context.Context) (int64, error) {
+	var size int64
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(MAX(idx) + 1, 0) FROM key_log_entries
+	`).Scan(&size)
+
+	return size, err
+}
+
+// AppendEntry stores an entry together with the subtree roots it
+// completes. It returns domain.ErrKeyLogIndexTaken if another writer already
+// took entry.Index; callers re-read Size and retry.
+func (r *keyLogRepository) AppendEntry(ctx context.Context, e domain.KeyLogEntry, nodes []domain.KeyLogNode) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertKeyLogEntry(ctx, tx, e, nodes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertKeyLogEntry lets other repositories log a key in the transaction
+// that makes it current.
+func insertKeyLogEntry(ctx context.Context, tx pgx.Tx, e domain.KeyLogEntry, nodes []domain.KeyLogNode) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO key_log_entries (`+keyLogEntryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, e.Index, e.UserID, e.PublicKey, e.LeafData, e.LeafHash, e.CreatedAt)
+	if err != nil {
+		return uniqueViolation(err, domain.ErrKeyLogIndexTaken)
+	}
+
+	for _, n := range nodes {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO key_log_nodes (level, idx, hash) VALUES ($1, $2, $3)
+		`, n.Level, n.Index, n.Hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *keyLogRepository) FindEntry(ctx context.Context, index int64) (domain.KeyLogEntry, error) {
+	return scanKeyLogEntry(r.db.QueryRow(ctx, `
+		SELECT `+keyLogEntryColumns+`
+		FROM key_log_entries WHERE idx = $1
+	`, index))
+}
+
+func (r *keyLogRepository) FindLatestByUser(ctx context.Context, userID uuid.UUID, maxIndex int64) (domain.KeyLogEntry, error) {
+	return scanKeyLogEntry(r.db.QueryRow(ctx, `
+		SELECT `+keyLogEntryColumns+`
+		FROM key_log_entries WHERE user_id = $1 AND idx <= $2
+		ORDER BY idx DESC
+		LIMIT 1
+	`, userID, maxIndex))
+}
+
+// FindNodes returns the hashes of the requested nodes that exist, reading
+// leaves from the entries themselves.
+func (r *keyLogRepository) FindNodes(ctx context.Context, ids []merkle.NodeID) (map[merkle.NodeID][]byte, error) {
+	var leaves, levels, indexes []int64
+	for _, id := range ids {
+		if id.Level == 0 {
+			leaves = append(leaves, int64(id.Index))
+			continue
+		}
+		levels = append(levels, int64(id.Level))
+		indexes = append(indexes, int64(id.Index))
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT 0, idx, leaf_hash FROM key_log_entries WHERE idx = ANY($1)
+		UNION ALL
+		SELECT n.level, n.idx, n.hash
+		FROM key_log_nodes n
+		JOIN unnest($2::bigint[], $3::bigint[]) AS want (level, idx)
+		  ON n.level = want.level AND n.idx = want.idx
+	`, leaves, levels, indexes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := make(map[merkle.NodeID][]byte, len(ids))
+	for rows.Next() {
+		var level, index int64
+		var hash []byte
+		if err := rows.Scan(&level, &index, &hash); err != nil {
+			return nil, err
+		}
+		nodes[merkle.NodeID{Level: uint8(level), Index: uint64(index)}] = hash
+	}
+
+	return nodes, rows.Err()
+}
+
+func (r *keyLogRepository) SaveTreeHead(ctx context.Context, head domain.TreeHead) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO key_log_tree_heads (tree_size, root_hash, timestamp_ms, signature)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tree_size) DO NOTHING
+	`, head.TreeSize, head.RootHash, head.TimestampMs, head.Signature)
+
+	return err
+}
+
+func (r *keyLogRepository) LatestTreeHead(ctx context.Context) (domain.TreeHead, error) {
+	var h domain.TreeHead
+	err := r.db.QueryRow(ctx, `
+		SELECT tree_size, root_hash, timestamp_ms, signature
+		FROM key_log_tree_heads
+		ORDER BY tree_size DESC
+		LIMIT 1
+	`).Scan(&h.TreeSize, &h.RootHash, &h.TimestampMs, &h.Signature)
+
+	return h, notFound(err, "tree head")
+}
+
+func (r *keyLogRepository) FindTreeHead(ctx context.Context, size int64) (domain.TreeHead, error) {
+	var h domain.TreeHead
+	err := r.db.QueryRow(ctx, `
+		SELECT tree_size, root_hash, timestamp_ms, signature
+		FROM key_log_tree_heads WHERE tree_size = $1
+	`, size).Scan(&h.TreeSize, &h.RootHash, &h.TimestampMs, &h.Signature)
+
+	return h, notFound(err, "tree head")
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const linkShareColumns = `id, file_id, owner_id, token_hash, password_hash, max_downloads, download_count, created_at, expires_at, revoked_at`
+
+func scanLinkShare(row pgx.Row) (domain.LinkShare, error) {
+	var l domain.LinkShare
+	err := row.Scan(&l.ID, &l.FileID, &l.OwnerID, &l.TokenHash, &l.PasswordHash, &l.MaxDownloads, &l.DownloadCount, &l.CreatedAt, &l.ExpiresAt, &l.RevokedAt)
+	return l, notFound(err, "link")
+}
+
+type LinkShareRepository interface {
+	Save(ctx context.Context, link domain.LinkShare) error
+	FindByTokenHash(ctx context.Context, tokenHash []byte) (domain.LinkShare, error)
+	FindByOwner(ctx context.Context, ownerID, fileID uuid.UUID) ([]domain.LinkShare, error)
+	ConsumeDownload(ctx context.Context, id uuid.UUID, now time.Time) error
+	CountPasswordAttempt(ctx context.Context, id uuid.UUID, clientIP string, now, resetAt time.Time) (linkAttempts, ipAttempts int, err error)
+	ResetPasswordAttempts(ctx context.Context, id uuid.UUID) error
+	DeleteStaleAttempts(ctx context.Context, now time.Time) (int64, error)
+	Revoke(ctx context.Context, id, ownerID uuid.UUID, now time.Time) error
+}
+
+type linkShareRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLinkShareRepository(db *pgxpool.Pool) LinkShareRepository {
+	return &linkShareRepository{db: db}
+}
+
+func (r *linkShareRepository) Save(ctx context.Context, l domain.LinkShare) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO link_shares (`+linkShareColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, l.ID, l.FileID, l.OwnerID, l.TokenHash, l.PasswordHash, l.MaxDownloads, l.DownloadCount, l.CreatedAt, l.ExpiresAt, l.RevokedAt)
+	return err
+}
+
+func (r *linkShareRepository) FindByTokenHash(ctx context.Context, tokenHash []byte) (domain.LinkShare, error) {
+	return scanLinkShare(r.db.QueryRow(ctx, `
+		SELECT `+linkShareColumns+`
+		FROM link_shares WHERE token_hash = $1
+	`, tokenHash))
+}
+
+// FindByOwner lists the owner's links, restricted to one file unless fileID
+// is uuid.Nil.
+func (r *linkShareRepository) FindByOwner(ctx context.Context, ownerID, fileID uuid.UUID) ([]domain.LinkShare, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+linkShareColumns+`
+		FROM link_shares
+		WHERE owner_id = $1 AND ($2::uuid IS NULL OR file_id = $2)
+		ORDER BY created_at DESC
+	`, ownerID, uuid.NullUUID{UUID: fileID, Valid: fileID != uuid.Nil})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []domain.LinkShare
+	for rows.Next() {
+		l, err := scanLinkShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	return links, rows.Err()
+}
+
+// ConsumeDownload counts one download against the link, failing with
+// domain.ErrLinkUnavailable if the link is no longer usable. The check and
+// the increment are a single statement so concurrent downloads cannot
+// overshoot MaxDownloads.
+func (r *linkShareRepository) ConsumeDownload(ctx context.Context, id uuid.UUID, now time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE link_shares SET download_count = download_count + 1
+		WHERE id = $1
+		  AND revoked_at IS NULL
+		  AND expires_at > $2
+		  AND (max_downloads = 0 OR download_count < max_downloads)
+	`, id, now)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrLinkUnavailable
+	}
+	return nil
+}
+
+// CountPasswordAttempt records one password attempt against the link and
+// against clientIP and returns both counts for the current window. A counter
+// whose window ended by now starts over at one with a window ending at
+// resetAt. Both increments commit together, and each is a single statement,
+// so concurrent guesses cannot slip past a limit checked on the result.
+func (r *linkShareRepository) CountPasswordAttempt(ctx context.Context, id uuid.UUID, clientIP string, now, resetAt time.Time) (int, int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var linkAttempts int
+	err = tx.QueryRow(ctx, `
+		UPDATE link_shares SET
+			password_attempts = CASE WHEN attempts_reset_at IS NULL OR attempts_reset_at <= $2 THEN 1 ELSE password_attempts + 1 END,
+			attempts_reset_at = CASE WHEN attempts_reset_at IS NULL OR attempts_reset_at <= $2 THEN $3 ELSE attempts_reset_at END
+		WHERE id = $1
+		RETURNING password_attempts
+	`, id, now, resetAt).Scan(&linkAttempts)
+	if err != nil {
+		return 0, 0, notFound(err, "link")
+	}
+
+	var ipAttempts int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO link_password_attempts (client_ip, attempts, reset_at)
+		VALUES ($1, 1, $3)
+		ON CONFLICT (client_ip) DO UPDATE SET
+			attempts = CASE WHEN link_password_attempts.reset_at <= $2 THEN 1 ELSE link_password_attempts.attempts + 1 END,
+			reset_at = CASE WHEN link_password_attempts.reset_at <= $2 THEN $3 ELSE link_password_attempts.reset_at END
+		RETURNING attempts
+	`, clientIP, now, resetAt).Scan(&ipAttempts)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return linkAttempts, ipAttempts, tx.Commit(ctx)
+}
+
+// ResetPasswordAttempts clears the link's counter after a correct password.
+// The per-address counter is left alone so that opening one link does not
+// buy more guesses against another.
+func (r *linkShareRepository) ResetPasswordAttempts(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE link_shares SET password_attempts = 0, attempts_reset_at = NULL
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// DeleteStaleAttempts drops per-address counters whose window has ended.
+func (r *linkShareRepository) DeleteStaleAttempts(ctx context.Context, now time.Time) (int64, error) {
+	cmd, err := r.db.Exec(ctx, `DELETE FROM link_password_attempts WHERE reset_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (r *linkShareRepository) Revoke(ctx context.Context, id, ownerID uuid.UUID, now time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE link_shares SET revoked_at = $3
+		WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL
+	`, id, ownerID, now)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("link")
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fileRepository mirrors repository.FileRepository. The store holds no
+// folders, so only the top level exists: files cannot be saved into or
+// moved to a folder.
+type fileRepository struct {
+	s *Store
+}
+
+func NewFileRepository(s *Store) repository.FileRepository {
+	return &fileRepository{s: s}
+}
+
+func (r *fileRepository) Save(ctx context.Context, file domain.File) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	owner, ok := r.s.users[file.OwnerID]
+	if !ok {
+		return fmt.Errorf("owner %s does not exist", file.OwnerID)
+	}
+	if file.FolderID != nil {
+		return fmt.Errorf("folder %s does not exist", *file.FolderID)
+	}
+	if _, ok := r.s.files[file.ID]; ok {
+		return fmt.Errorf("file %s already exists", file.ID)
+	}
+
+	if file.Version == 0 {
+		file.Version = 1
+	}
+	if file.ObjectName == "" {
+		file.ObjectName = file.ID.String()
+	}
+	if file.KeyVersion == 0 {
+		file.KeyVersion = owner.KeyVersion
+	}
+	if err := r.s.reserve(file.OwnerID, file.Size); err != nil {
+		return err
+	}
+	file.State = domain.FileStatePending
+
+	r.s.files[file.ID] = file
+	r.s.versions[file.ID] = map[int]domain.FileVersion{
+		file.Version: {
+			FileID:        file.ID,
+			Version:       file.Version,
+			ObjectName:    file.ObjectName,
+			Size:          file.Size,
+			IV:            file.IV,
+			VersionKey:    file.VersionKey,
+			FormatVersion: file.FormatVersion,
+			ChunkSize:     file.ChunkSize,
+			State:         domain.FileStatePending,
+			CreatedAt:     file.CreatedAt,
+		},
+	}
+	return nil
+}
+
+func (r *fileRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[id]
+	if !ok || f.State != domain.FileStateCommitted {
+		return domain.File{}, domain.NotFound("file")
+	}
+	return f, nil
+}
+
+func (r *fileRepository) FindByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.sortedFiles(func(f domain.File) bool {
+		return f.OwnerID == ownerID && !f.Trashed() && f.State == domain.FileStateCommitted
+	}), nil
+}
+
+func (r *fileRepository) FindByFolder(ctx context.Context, ownerID uuid.UUID, folderID *uuid.UUID) ([]domain.File, error) {
+	if folderID != nil {
+		return nil, nil
+	}
+	return r.FindByOwner(ctx, ownerID)
+}
+
+func (r *fileRepository) FindInFolderTree(ctx context.Context, folderID uuid.UUID) ([]domain.File, error) {
+	return nil, nil
+}
+
+func (r *fileRepository) UpdateMetadata(ctx context.Context, id, ownerID uuid.UUID, encryptedMetadata []byte) error {
+	return r.update(id, ownerID, func(f *domain.File) {
+		f.EncryptedMetadata = encryptedMetadata
+		f.Filename, f.MimeType = "", ""
+	})
+}
+
+func (r *fileRepository) MoveToFolder(ctx context.Context, id, ownerID uuid.UUID, folderID *uuid.UUID, folderWrappedKey []byte) error {
+	if folderID != nil {
+		return domain.NotFound("file or folder")
+	}
+	return r.update(id, ownerID, func(f *domain.File) {
+		f.FolderID = nil
+		f.FolderWrappedKey = folderWrappedKey
+	})
+}
+
+func (r *fileRepository) FindStaleByOwner(ctx context.Context, ownerID uuid.UUID, currentVersion int) ([]domain.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	files := r.s.sortedFiles(func(f domain.File) bool {
+		return f.OwnerID == ownerID && f.KeyVersion < currentVersion
+	})
+	reverse(files)
+	return files, nil
+}
+
+func (r *fileRepository) UpdateEncryptedKey(ctx context.Context, id, ownerID uuid.UUID, encryptedKey []byte, keyVersion int) error {
+	return r.update(id, ownerID, func(f *domain.File) {
+		f.EncryptedKey = encryptedKey
+		f.KeyVersion = keyVersion
+	})
+}
+
+// update applies fn to the file if ownerID owns it, in any state.
+func (r *fileRepository) update(id, ownerID uuid.UUID, fn func(*domain.File)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[id]
+	if !ok || f.OwnerID != ownerID {
+		return domain.NotFound("file")
+	}
+	fn(&f)
+	r.s.files[id] = f
+	return nil
+}
+
+func (r *fileRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.files[id]; !ok {
+		return domain.NotFound("file")
+	}
+	r.s.deleteFile(id)
+	return nil
+}
+
+func (r *fileRepository) Commit(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[id]
+	if !ok || f.State != domain.FileStatePending {
+		return domain.NotFound("pending file")
+	}
+	f.State = domain.FileStateCommitted
+	r.s.files[id] = f
+
+	if v, ok := r.s.versions[id][f.Version]; ok {
+		v.State = domain.FileStateCommitted
+		r.s.versions[id][f.Version] = v
+	}
+	return nil
+}
+
+func (r *fileRepository) FindPending(ctx context.Context, before time.Time, limit int) ([]domain.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	files := r.s.sortedFiles(func(f domain.File) bool {
+		return f.State == domain.FileStatePending && f.CreatedAt.Before(before)
+	})
+	reverse(files)
+	return head(files, limit), nil
+}
+
+func (r *fileRepository) Trash(ctx context.Context, id, ownerID uuid.UUID, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[id]
+	if !ok || f.OwnerID != ownerID || f.Trashed() {
+		return domain.NotFound("file")
+	}
+	f.DeletedAt = &now
+	r.s.files[id] = f
+	return nil
+}
+
+func (r *fileRepository) RestoreFromTrash(ctx context.Context, id, ownerID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[id]
+	if !ok || f.OwnerID != ownerID || !f.Trashed() {
+		return domain.NotFound("trashed file")
+	}
+	f.DeletedAt = nil
+	r.s.files[id] = f
+	return nil
+}
+
+func (r *fileRepository) FindTrashed(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	files := r.s.sortedFiles(func(f domain.File) bool {
+		return f.OwnerID == ownerID && f.Trashed()
+	})
+	sort.SliceStable(files, func(i, j int) bool { return files[i].DeletedAt.After(*files[j].DeletedAt) })
+	return files, nil
+}
+
+func (r *fileRepository) FindTrashedBefore(ctx context.Context, cutoff time.Time, limit int) ([]domain.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	files := r.s.sortedFiles(func(f domain.File) bool {
+		return f.Trashed() && f.DeletedAt.Before(cutoff)
+	})
+	sort.SliceStable(files, func(i, j int) bool { return files[i].DeletedAt.Before(*files[j].DeletedAt) })
+	return head(files, limit), nil
+}
+
+func (r *fileRepository) Purge(ctx context.Context, id uuid.UUID, cutoff time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[id]
+	if !ok || !f.Trashed() || !f.DeletedAt.Before(cutoff) {
+		return domain.NotFound("trashed file")
+	}
+	r.s.deleteFile(id)
+	return nil
+}
+
+func (r *fileRepository) AddVersion(ctx context.Context, ownerID uuid.UUID, version domain.FileVersion) (domain.FileVersion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[version.FileID]
+	if !ok || f.OwnerID != ownerID {
+		return domain.FileVersion{}, domain.NotFound("file")
+	}
+	if err := r.s.reserve(ownerID, version.Size); err != nil {
+		return domain.FileVersion{}, err
+	}
+
+	version.Version = 1
+	for v := range r.s.versions[f.ID] {
+		version.Version = max(version.Version, v+1)
+	}
+	version.State = domain.FileStatePending
+	r.s.versions[f.ID][version.Version] = version
+	return version, nil
+}
+
+func (r *fileRepository) CommitVersion(ctx context.Context, fileID uuid.UUID, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	v, ok := r.s.versions[fileID][version]
+	if !ok || v.State != domain.FileStatePending {
+		return domain.NotFound("pending file version")
+	}
+	v.State = domain.FileStateCommitted
+	r.s.versions[fileID][version] = v
+
+	if f := r.s.files[fileID]; f.Version < version {
+		r.s.files[fileID] = f.AtVersion(v)
+	}
+	return nil
+}
+
+func (r *fileRepository) AbortVersion(ctx context.Context, fileID uuid.UUID, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	v, ok := r.s.versions[fileID][version]
+	if !ok || v.State != domain.FileStatePending {
+		return domain.NotFound("pending file version")
+	}
+	delete(r.s.versions[fileID], version)
+	r.s.release(r.s.files[fileID].OwnerID, v.Size)
+	r.s.enqueueDelete(v.ObjectName)
+	return nil
+}
+
+func (r *fileRepository) FindPendingVersions(ctx context.Context, before time.Time, limit int) ([]domain.FileVersion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var versions []domain.FileVersion
+	for fileID, vs := range r.s.versions {
+		if r.s.files[fileID].State != domain.FileStateCommitted {
+			continue
+		}
+		for _, v := range vs {
+			if v.State == domain.FileStatePending && v.CreatedAt.Before(before) {
+				versions = append(versions, v)
+			}
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].CreatedAt.Before(versions[j].CreatedAt) })
+	return head(versions, limit), nil
+}
+
+func (r *fileRepository) FindVersions(ctx context.Context, fileID uuid.UUID) ([]domain.FileVersion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.committedVersions(fileID), nil
+}
+
+// committedVersions returns the committed versions of a file, newest first.
+func (r *fileRepository) committedVersions(fileID uuid.UUID) []domain.FileVersion {
+	var versions []domain.FileVersion
+	for _, v := range r.s.versions[fileID] {
+		if v.State == domain.FileStateCommitted {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions
+}
+
+func (r *fileRepository) FindVersion(ctx context.Context, fileID uuid.UUID, version int) (domain.FileVersion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	v, ok := r.s.versions[fileID][version]
+	if !ok || v.State != domain.FileStateCommitted {
+		return domain.FileVersion{}, domain.NotFound("file version")
+	}
+	return v, nil
+}
+
+func (r *fileRepository) RestoreVersion(ctx context.Context, fileID, ownerID uuid.UUID, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[fileID]
+	v, vok := r.s.versions[fileID][version]
+	if !ok || !vok || f.OwnerID != ownerID || f.Trashed() || v.State != domain.FileStateCommitted {
+		return domain.NotFound("file version")
+	}
+	r.s.files[fileID] = f.AtVersion(v)
+	return nil
+}
+
+func (r *fileRepository) DeleteVersion(ctx context.Context, fileID, ownerID uuid.UUID, version int) (domain.FileVersion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[fileID]
+	v, vok := r.s.versions[fileID][version]
+	if !ok || !vok || f.OwnerID != ownerID || f.Trashed() || v.State != domain.FileStateCommitted || f.Version == version {
+		return domain.FileVersion{}, &domain.Error{Kind: domain.KindNotFound, Code: "file_version_not_found", Message: "file version not found or current"}
+	}
+	r.deleteVersion(f, v)
+	return v, nil
+}
+
+func (r *fileRepository) PruneVersions(ctx context.Context, fileID, ownerID uuid.UUID, keep int) ([]domain.FileVersion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	f, ok := r.s.files[fileID]
+	if !ok || f.OwnerID != ownerID || f.Trashed() {
+		return nil, nil
+	}
+
+	var deleted []domain.FileVersion
+	for i, v := range r.committedVersions(fileID) {
+		if i < keep || v.Version == f.Version {
+			continue
+		}
+		r.deleteVersion(f, v)
+		deleted = append(deleted, v)
+	}
+	return deleted, nil
+}
+
+func (r *fileRepository) deleteVersion(f domain.File, v domain.FileVersion) {
+	delete(r.s.versions[f.ID], v.Version)
+	r.s.release(f.OwnerID, v.Size)
+	r.s.enqueueDelete(v.ObjectName)
+}
+
+func reverse[T any](s []T) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+func head[T any](s []T, limit int) []T {
+	if len(s) > limit {
+		return s[:limit]
+	}
+	return s
+}
@@ -0,0 +1,307 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+type groupRepository struct {
+	s *Store
+}
+
+func NewGroupRepository(s *Store) repository.GroupRepository {
+	return &groupRepository{s: s}
+}
+
+// addMemberKeys stores wrapped group private keys. A zero UserKeyVersion
+// defaults to the member's current keypair version.
+func (r *groupRepository) addMemberKeys(keys []domain.GroupMemberKey) {
+	for _, k := range keys {
+		if k.UserKeyVersion == 0 {
+			k.UserKeyVersion = r.s.users[k.UserID].KeyVersion
+		}
+		r.s.groupKeys[k.GroupID] = append(r.s.groupKeys[k.GroupID], k)
+	}
+}
+
+func (r *groupRepository) Create(ctx context.Context, g domain.Group, memberKeys []domain.GroupMemberKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.groups[g.ID]; ok {
+		return fmt.Errorf("group %s already exists", g.ID)
+	}
+	for _, k := range memberKeys {
+		if _, ok := r.s.users[k.UserID]; !ok {
+			return fmt.Errorf("member %s does not exist", k.UserID)
+		}
+	}
+
+	r.s.groups[g.ID] = g
+	members := map[uuid.UUID]time.Time{}
+	for _, k := range memberKeys {
+		members[k.UserID] = g.CreatedAt
+	}
+	r.s.groupMembers[g.ID] = members
+	r.addMemberKeys(memberKeys)
+	return nil
+}
+
+func (r *groupRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.Group, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	g, ok := r.s.groups[id]
+	if !ok {
+		return domain.Group{}, domain.NotFound("group")
+	}
+	return g, nil
+}
+
+func (r *groupRepository) FindByMember(ctx context.Context, userID uuid.UUID) ([]domain.Group, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var groups []domain.Group
+	for id, members := range r.s.groupMembers {
+		if _, ok := members[userID]; ok {
+			groups = append(groups, r.s.groups[id])
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+func (r *groupRepository) IsMember(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, ok := r.s.groupMembers[groupID][userID]
+	return ok, nil
+}
+
+func (r *groupRepository) ListMembers(ctx context.Context, groupID uuid.UUID) ([]domain.GroupMember, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var members []domain.GroupMember
+	for userID, joinedAt := range r.s.groupMembers[groupID] {
+		members = append(members, domain.GroupMember{
+			GroupID:  groupID,
+			UserID:   userID,
+			Username: r.s.users[userID].Username,
+			JoinedAt: joinedAt,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+	return members, nil
+}
+
+func (r *groupRepository) FindMemberKeys(ctx context.Context, groupID, userID uuid.UUID) ([]domain.GroupMemberKey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var keys []domain.GroupMemberKey
+	for _, k := range r.s.groupKeys[groupID] {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Version > keys[j].Version })
+	return keys, nil
+}
+
+// Rotate checks the whole rotation before changing anything, so a rejected
+// one leaves the group as it was, like the rolled back transaction.
+func (r *groupRepository) Rotate(ctx context.Context, rot domain.GroupRotation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	g, ok := r.s.groups[rot.GroupID]
+	if !ok {
+		return domain.NotFound("group")
+	}
+	if g.KeyVersion != rot.Version-1 {
+		return domain.ErrGroupKeyVersionConflict
+	}
+
+	members := map[uuid.UUID]time.Time{}
+	for userID, joinedAt := range r.s.groupMembers[rot.GroupID] {
+		if !slices.Contains(rot.Remove, userID) {
+			members[userID] = joinedAt
+		}
+	}
+	for _, userID := range rot.Add {
+		if _, ok := members[userID]; !ok {
+			members[userID] = rot.CreatedAt
+		}
+	}
+
+	var memberIDs, wrapped []uuid.UUID
+	for userID := range members {
+		memberIDs = append(memberIDs, userID)
+	}
+	for _, k := range rot.MemberKeys {
+		wrapped = append(wrapped, k.UserID)
+	}
+	if !sameIDs(memberIDs, wrapped) {
+		return domain.ErrGroupMembershipMismatch
+	}
+
+	var shareIDs, rewrapped []uuid.UUID
+	for id, gs := range r.s.groupShares {
+		if gs.GroupID == rot.GroupID {
+			shareIDs = append(shareIDs, id)
+		}
+	}
+	for _, s := range rot.Shares {
+		rewrapped = append(rewrapped, s.ShareID)
+	}
+	if !sameIDs(shareIDs, rewrapped) {
+		return domain.ErrGroupSharesMismatch
+	}
+
+	r.s.groupMembers[rot.GroupID] = members
+	r.s.groupKeys[rot.GroupID] = slices.DeleteFunc(r.s.groupKeys[rot.GroupID], func(k domain.GroupMemberKey) bool {
+		_, ok := members[k.UserID]
+		return !ok
+	})
+	r.addMemberKeys(rot.MemberKeys)
+
+	for _, s := range rot.Shares {
+		gs := r.s.groupShares[s.ShareID]
+		gs.WrappedKey = s.WrappedKey
+		gs.KeyVersion = rot.Version
+		r.s.groupShares[s.ShareID] = gs
+	}
+
+	g.KeyVersion = rot.Version
+	g.PublicKey = rot.PublicKey
+	r.s.groups[rot.GroupID] = g
+	return nil
+}
+
+// sameIDs reports whether a and b hold the same IDs in any order.
+func sameIDs(a, b []uuid.UUID) bool {
+	cmp := func(x, y uuid.UUID) int { return bytes.Compare(x[:], y[:]) }
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.SortFunc(a, cmp)
+	slices.SortFunc(b, cmp)
+	return slices.Equal(a, b)
+}
+
+func (r *groupRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.groups[id]; !ok {
+		return domain.NotFound("group")
+	}
+	delete(r.s.groups, id)
+	delete(r.s.groupMembers, id)
+	delete(r.s.groupKeys, id)
+	for shareID, gs := range r.s.groupShares {
+		if gs.GroupID == id {
+			delete(r.s.groupShares, shareID)
+		}
+	}
+	return nil
+}
+
+// SaveShare defaults a zero KeyVersion to the group's current key version
+// and fails with domain.ErrGroupKeyVersionConflict for any other version.
+func (r *groupRepository) SaveShare(ctx context.Context, share domain.GroupShare) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.files[share.FileID]; !ok {
+		return fmt.Errorf("file %s does not exist", share.FileID)
+	}
+	g, ok := r.s.groups[share.GroupID]
+	if !ok || (share.KeyVersion != 0 && share.KeyVersion != g.KeyVersion) {
+		return domain.ErrGroupKeyVersionConflict
+	}
+	if _, ok := r.s.groupShares[share.ID]; ok {
+		return fmt.Errorf("group share %s already exists", share.ID)
+	}
+	for _, gs := range r.s.groupShares {
+		if gs.FileID == share.FileID && gs.GroupID == share.GroupID {
+			return domain.ErrAlreadyShared
+		}
+	}
+
+	share.KeyVersion = g.KeyVersion
+	r.s.groupShares[share.ID] = share
+	return nil
+}
+
+func (r *groupRepository) FindShareByID(ctx context.Context, id uuid.UUID) (domain.GroupShare, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	gs, ok := r.s.groupShares[id]
+	if !ok {
+		return domain.GroupShare{}, domain.NotFound("group share")
+	}
+	return gs, nil
+}
+
+func (r *groupRepository) FindSharesByGroup(ctx context.Context, groupID uuid.UUID) ([]domain.GroupShare, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var shares []domain.GroupShare
+	for _, gs := range r.s.groupShares {
+		if gs.GroupID == groupID && !r.s.files[gs.FileID].Trashed() {
+			shares = append(shares, gs)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.After(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+func (r *groupRepository) FindShareForMember(ctx context.Context, fileID, userID uuid.UUID, need domain.SharePermission, now time.Time) (domain.GroupShare, domain.GroupMemberKey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var share domain.GroupShare
+	var key domain.GroupMemberKey
+	found := false
+	for _, gs := range r.s.groupShares {
+		if gs.FileID != fileID || !gs.Permissions.Has(need) || gs.Expired(now) {
+			continue
+		}
+		if found && !gs.CreatedAt.Before(share.CreatedAt) {
+			continue
+		}
+		for _, k := range r.s.groupKeys[gs.GroupID] {
+			if k.UserID == userID && k.Version == gs.KeyVersion {
+				share, key, found = gs, k, true
+				break
+			}
+		}
+	}
+	if !found {
+		return domain.GroupShare{}, domain.GroupMemberKey{}, domain.NotFound("group share")
+	}
+	return share, key, nil
+}
+
+func (r *groupRepository) DeleteShare(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.groupShares[id]; !ok {
+		return domain.NotFound("share")
+	}
+	delete(r.s.groupShares, id)
+	return nil
+}
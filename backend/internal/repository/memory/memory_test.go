@@ -0,0 +1,20 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/memory"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		s := memory.NewStore()
+		return repotest.Repositories{
+			Users:  memory.NewUserRepository(s),
+			Files:  memory.NewFileRepository(s),
+			Shares: memory.NewShareRepository(s),
+			Groups: memory.NewGroupRepository(s),
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+)
+
+type outboxRepository struct {
+	s *Store
+}
+
+func NewOutboxRepository(s *Store) repository.OutboxRepository {
+	return &outboxRepository{s: s}
+}
+
+func (r *outboxRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]domain.StorageOp, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var ops []domain.StorageOp
+	for _, op := range r.s.outbox {
+		if !op.NextAttemptAt.After(now) {
+			ops = append(ops, op)
+		}
+	}
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].NextAttemptAt.Before(ops[j].NextAttemptAt) })
+	return head(ops, limit), nil
+}
+
+func (r *outboxRepository) Complete(ctx context.Context, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i, op := range r.s.outbox {
+		if op.ID == id {
+			r.s.outbox = append(r.s.outbox[:i], r.s.outbox[i+1:]...)
+			return nil
+		}
+	}
+	return domain.NotFound("outbox entry")
+}
+
+func (r *outboxRepository) Retry(ctx context.Context, id int64, lastError string, next time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := range r.s.outbox {
+		if op := &r.s.outbox[i]; op.ID == id {
+			op.Attempts++
+			op.LastError = lastError
+			op.NextAttemptAt = next
+			return nil
+		}
+	}
+	return domain.NotFound("outbox entry")
+}
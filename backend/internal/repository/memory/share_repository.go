@@ -0,0 +1,288 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+type shareRepository struct {
+	s *Store
+}
+
+func NewShareRepository(s *Store) repository.ShareRepository {
+	return &shareRepository{s: s}
+}
+
+func (r *shareRepository) Save(ctx context.Context, share domain.Share) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.files[share.FileID]; !ok {
+		return fmt.Errorf("file %s does not exist", share.FileID)
+	}
+	recipient, ok := r.s.users[share.RecipientID]
+	if !ok {
+		return fmt.Errorf("recipient %s does not exist", share.RecipientID)
+	}
+	if _, ok := r.s.shares[share.ID]; ok {
+		return fmt.Errorf("share %s already exists", share.ID)
+	}
+	for _, sh := range r.s.shares {
+		if sh.FileID == share.FileID && sh.RecipientID == share.RecipientID {
+			return domain.ErrAlreadyShared
+		}
+	}
+
+	if share.KeyVersion == 0 {
+		share.KeyVersion = recipient.KeyVersion
+	}
+	r.s.shares[share.ID] = share
+	return nil
+}
+
+func (r *shareRepository) FindByID(ctx context.Context, shareID uuid.UUID) (domain.Share, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sh, ok := r.s.shares[shareID]
+	if !ok {
+		return domain.Share{}, domain.NotFound("share")
+	}
+	return sh, nil
+}
+
+func (r *shareRepository) FindByRecipient(ctx context.Context, recipientID uuid.UUID, now time.Time) ([]domain.Share, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	shares := r.filter(func(sh domain.Share) bool {
+		return sh.RecipientID == recipientID && !sh.Expired(now) && !r.s.files[sh.FileID].Trashed()
+	})
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.After(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+func (r *shareRepository) FindByFileAndRecipient(ctx context.Context, fileID, recipientID uuid.UUID) (domain.Share, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, sh := range r.s.shares {
+		if sh.FileID == fileID && sh.RecipientID == recipientID {
+			return sh, nil
+		}
+	}
+	return domain.Share{}, domain.NotFound("share")
+}
+
+func (r *shareRepository) FindOutgoingByOwner(ctx context.Context, ownerID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.listings(func(f domain.File) bool {
+		return f.OwnerID == ownerID && !f.Trashed()
+	}, after, limit), nil
+}
+
+func (r *shareRepository) FindListingsByFile(ctx context.Context, fileID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.listings(func(f domain.File) bool {
+		return f.ID == fileID
+	}, after, limit), nil
+}
+
+// listings pages through the user and group shares of the files matching
+// keep newest first, ordered by (created_at, id) like the SQL. The store
+// keeps no folder shares, so those never appear.
+func (r *shareRepository) listings(keep func(domain.File) bool, after domain.ShareCursor, limit int) []domain.ShareListing {
+	var all []domain.ShareListing
+	for _, sh := range r.s.shares {
+		if f := r.s.files[sh.FileID]; keep(f) {
+			all = append(all, domain.ShareListing{
+				Share:             sh,
+				Kind:              domain.ShareListingUser,
+				Filename:          f.Filename,
+				RecipientUsername: r.s.users[sh.RecipientID].Username,
+			})
+		}
+	}
+	for _, gs := range r.s.groupShares {
+		if f := r.s.files[gs.FileID]; keep(f) {
+			all = append(all, domain.ShareListing{
+				Share: domain.Share{
+					ID:           gs.ID,
+					FileID:       gs.FileID,
+					WrappedKey:   gs.WrappedKey,
+					KeyVersion:   gs.KeyVersion,
+					CreatedAt:    gs.CreatedAt,
+					ExpiresAt:    gs.ExpiresAt,
+					Permissions:  gs.Permissions,
+					GrantedBy:    gs.GrantedBy,
+					GrantorChain: []uuid.UUID{},
+				},
+				Kind:      domain.ShareListingGroup,
+				GroupID:   gs.GroupID,
+				Filename:  f.Filename,
+				GroupName: r.s.groups[gs.GroupID].Name,
+			})
+		}
+	}
+
+	less := func(a, b domain.ShareCursor) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return bytes.Compare(a.ID[:], b.ID[:]) < 0
+	}
+	cursor := func(l domain.ShareListing) domain.ShareCursor {
+		return domain.ShareCursor{CreatedAt: l.CreatedAt, ID: l.ID}
+	}
+
+	var listings []domain.ShareListing
+	for _, l := range all {
+		if after.IsZero() || less(cursor(l), after) {
+			listings = append(listings, l)
+		}
+	}
+	sort.Slice(listings, func(i, j int) bool { return less(cursor(listings[j]), cursor(listings[i])) })
+	return head(listings, limit)
+}
+
+func (r *shareRepository) FindStaleByRecipient(ctx context.Context, recipientID uuid.UUID, currentVersion int) ([]domain.Share, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	shares := r.filter(func(sh domain.Share) bool {
+		return sh.RecipientID == recipientID && sh.KeyVersion < currentVersion
+	})
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.Before(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+func (r *shareRepository) UpdateWrappedKey(ctx context.Context, shareID, recipientID uuid.UUID, wrappedKey []byte, keyVersion int) error {
+	return r.update(shareID, func(sh *domain.Share) bool {
+		if sh.RecipientID != recipientID {
+			return false
+		}
+		sh.WrappedKey = wrappedKey
+		sh.KeyVersion = keyVersion
+		return true
+	})
+}
+
+func (r *shareRepository) UpdateExpiry(ctx context.Context, shareID uuid.UUID, expiresAt *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	target, ok := r.s.shares[shareID]
+	if !ok {
+		return domain.NotFound("share")
+	}
+	target.ExpiresAt = expiresAt
+	r.s.shares[shareID] = target
+
+	if expiresAt == nil {
+		return nil
+	}
+	for id, sh := range r.s.shares {
+		if r.grantedBelow(sh, target) && (sh.ExpiresAt == nil || sh.ExpiresAt.After(*expiresAt)) {
+			sh.ExpiresAt = expiresAt
+			r.s.shares[id] = sh
+		}
+	}
+	return nil
+}
+
+// grantedBelow reports whether sh was reshared, directly or not, by the
+// recipient of target.
+func (r *shareRepository) grantedBelow(sh, target domain.Share) bool {
+	return sh.FileID == target.FileID && slices.Contains(sh.GrantorChain, target.RecipientID)
+}
+
+func (r *shareRepository) UpdatePermissions(ctx context.Context, shareID uuid.UUID, perms domain.SharePermission) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	target, ok := r.s.shares[shareID]
+	if !ok {
+		return domain.NotFound("share")
+	}
+	target.Permissions = perms
+	r.s.shares[shareID] = target
+
+	for id, sh := range r.s.shares {
+		if r.grantedBelow(sh, target) {
+			sh.Permissions &= perms
+			r.s.shares[id] = sh
+		}
+	}
+	return nil
+}
+
+// update applies fn to the share, which reports whether the share matched.
+func (r *shareRepository) update(shareID uuid.UUID, fn func(*domain.Share) bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sh, ok := r.s.shares[shareID]
+	if !ok || !fn(&sh) {
+		return domain.NotFound("share")
+	}
+	r.s.shares[shareID] = sh
+	return nil
+}
+
+func (r *shareRepository) Delete(ctx context.Context, shareID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	target, ok := r.s.shares[shareID]
+	if !ok {
+		return domain.NotFound("share")
+	}
+	for id, sh := range r.s.shares {
+		if id == shareID || r.grantedBelow(sh, target) {
+			delete(r.s.shares, id)
+		}
+	}
+	return nil
+}
+
+func (r *shareRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var n int64
+	for id, sh := range r.s.shares {
+		if sh.Expired(now) {
+			delete(r.s.shares, id)
+			n++
+		}
+	}
+	for id, gs := range r.s.groupShares {
+		if gs.Expired(now) {
+			delete(r.s.groupShares, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *shareRepository) filter(keep func(domain.Share) bool) []domain.Share {
+	var shares []domain.Share
+	for _, sh := range r.s.shares {
+		if keep(sh) {
+			shares = append(shares, sh)
+		}
+	}
+	return shares
+}
@@ -0,0 +1,120 @@
+// Package memory implements repositories in memory so usecases and handlers
+// can be tested without Postgres. Repositories built on the same Store see
+// each other's rows, and the Store enforces the keys, cascades and filters
+// of the schema. Every implementation must pass the suites in repotest.
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+)
+
+// FreePlanQuota is the quota of every user in the store, matching the free
+// plan.
+const FreePlanQuota = 5 << 30
+
+type Store struct {
+	mu sync.Mutex
+
+	users     map[uuid.UUID]domain.User
+	userKeys  map[uuid.UUID]map[int]domain.UserKey
+	usedBytes map[uuid.UUID]int64
+	files     map[uuid.UUID]domain.File
+	versions  map[uuid.UUID]map[int]domain.FileVersion
+	shares    map[uuid.UUID]domain.Share
+
+	groups       map[uuid.UUID]domain.Group
+	groupMembers map[uuid.UUID]map[uuid.UUID]time.Time
+	groupKeys    map[uuid.UUID][]domain.GroupMemberKey
+	groupShares  map[uuid.UUID]domain.GroupShare
+
+	outbox       []domain.StorageOp
+	lastOutboxID int64
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:     map[uuid.UUID]domain.User{},
+		userKeys:  map[uuid.UUID]map[int]domain.UserKey{},
+		usedBytes: map[uuid.UUID]int64{},
+		files:     map[uuid.UUID]domain.File{},
+		versions:  map[uuid.UUID]map[int]domain.FileVersion{},
+		shares:    map[uuid.UUID]domain.Share{},
+
+		groups:       map[uuid.UUID]domain.Group{},
+		groupMembers: map[uuid.UUID]map[uuid.UUID]time.Time{},
+		groupKeys:    map[uuid.UUID][]domain.GroupMemberKey{},
+		groupShares:  map[uuid.UUID]domain.GroupShare{},
+	}
+}
+
+// Outbox returns the storage operations queued so far.
+func (s *Store) Outbox() []domain.StorageOp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]domain.StorageOp(nil), s.outbox...)
+}
+
+func (s *Store) enqueueDelete(objectName string) {
+	s.lastOutboxID++
+	s.outbox = append(s.outbox, domain.StorageOp{
+		ID:         s.lastOutboxID,
+		Op:         domain.StorageOpDelete,
+		Bucket:     "files",
+		ObjectName: objectName,
+	})
+}
+
+// reserve adds bytes to the user's usage if it stays within the free plan.
+func (s *Store) reserve(userID uuid.UUID, bytes int64) error {
+	if bytes > FreePlanQuota {
+		return domain.ErrFileTooLarge
+	}
+	if s.usedBytes[userID]+bytes > FreePlanQuota {
+		return domain.ErrQuotaExceeded
+	}
+	s.usedBytes[userID] += bytes
+	return nil
+}
+
+func (s *Store) release(userID uuid.UUID, bytes int64) {
+	s.usedBytes[userID] = max(s.usedBytes[userID]-bytes, 0)
+}
+
+// deleteFile removes a file with its versions and user and group shares,
+// releasing and queueing what the versions stored.
+func (s *Store) deleteFile(id uuid.UUID) {
+	f := s.files[id]
+	for _, v := range s.versions[id] {
+		s.release(f.OwnerID, v.Size)
+		s.enqueueDelete(v.ObjectName)
+	}
+	delete(s.versions, id)
+	for shareID, sh := range s.shares {
+		if sh.FileID == id {
+			delete(s.shares, shareID)
+		}
+	}
+	for shareID, gs := range s.groupShares {
+		if gs.FileID == id {
+			delete(s.groupShares, shareID)
+		}
+	}
+	delete(s.files, id)
+}
+
+// sortedFiles returns the files matching keep, newest first.
+func (s *Store) sortedFiles(keep func(domain.File) bool) []domain.File {
+	var files []domain.File
+	for _, f := range s.files {
+		if keep(f) {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files
+}
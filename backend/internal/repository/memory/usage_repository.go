@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+// usageRepository puts every user on the free plan. Usage is reserved and
+// released by the file repository as rows are added and deleted, as it is
+// in Postgres.
+type usageRepository struct {
+	s *Store
+}
+
+func NewUsageRepository(s *Store) repository.UsageRepository {
+	return &usageRepository{s: s}
+}
+
+func (r *usageRepository) Get(ctx context.Context, userID uuid.UUID) (domain.Usage, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[userID]; !ok {
+		return domain.Usage{}, domain.NotFound("user")
+	}
+	return domain.Usage{UserID: userID, Plan: "free", UsedBytes: r.s.usedBytes[userID], QuotaBytes: FreePlanQuota}, nil
+}
+
+// Recompute counts stored versions only; the store keeps no upload
+// sessions.
+func (r *usageRepository) Recompute(ctx context.Context) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	actual := map[uuid.UUID]int64{}
+	for fileID, vs := range r.s.versions {
+		for _, v := range vs {
+			actual[r.s.files[fileID].OwnerID] += v.Size
+		}
+	}
+
+	drifted := 0
+	for id := range r.s.users {
+		if r.s.usedBytes[id] != actual[id] {
+			r.s.usedBytes[id] = actual[id]
+			drifted++
+		}
+	}
+	return drifted, nil
+}
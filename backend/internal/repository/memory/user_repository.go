@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+type userRepository struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) repository.UserRepository {
+	return &userRepository{s: s}
+}
+
+func (r *userRepository) Save(ctx context.Context, user domain.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[user.ID]; ok {
+		return fmt.Errorf("user %s already exists", user.ID)
+	}
+	for _, u := range r.s.users {
+		if u.Username == user.Username {
+			return domain.ErrUsernameTaken
+		}
+	}
+
+	r.s.users[user.ID] = user
+	r.s.userKeys[user.ID] = map[int]domain.UserKey{
+		user.KeyVersion: {
+			UserID:              user.ID,
+			Version:             user.KeyVersion,
+			PublicKey:           user.PublicKey,
+			EncryptedPrivateKey: user.EncryptedPrivateKey,
+			CreatedAt:           user.CreatedAt,
+		},
+	}
+	return nil
+}
+
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, u := range r.s.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return domain.User{}, domain.NotFound("user")
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return domain.User{}, domain.NotFound("user")
+	}
+	return u, nil
+}
+
+// SearchByUsernamePrefix returns only the public fields, as the SQL does.
+func (r *userRepository) SearchByUsernamePrefix(ctx context.Context, prefix, after string, limit int) ([]domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []domain.User
+	for _, u := range r.s.users {
+		if strings.HasPrefix(u.Username, prefix) && u.Username > after {
+			users = append(users, domain.User{
+				ID:         u.ID,
+				Username:   u.Username,
+				PublicKey:  u.PublicKey,
+				KeyVersion: u.KeyVersion,
+				CreatedAt:  u.CreatedAt,
+			})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return domain.NotFound("user")
+	}
+	u.PasswordHash = passwordHash
+	r.s.users[id] = u
+	return nil
+}
+
+func (r *userRepository) UpdateCredentials(ctx context.Context, id uuid.UUID, passwordHash string, keys []domain.UserKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return domain.NotFound("user")
+	}
+	stored := r.s.userKeys[id]
+
+	updated := make(map[int]domain.UserKey, len(stored))
+	for v, k := range stored {
+		updated[v] = k
+	}
+	seen := make(map[int]bool, len(keys))
+	var currentKey []byte
+	for _, k := range keys {
+		if seen[k.Version] {
+			return domain.ErrDuplicateKeyVersion
+		}
+		seen[k.Version] = true
+		s, ok := updated[k.Version]
+		if !ok || s.PublicKey != k.PublicKey {
+			return domain.ErrPublicKeyMismatch
+		}
+		s.EncryptedPrivateKey = k.EncryptedPrivateKey
+		updated[k.Version] = s
+		if k.Version == u.KeyVersion {
+			currentKey = k.EncryptedPrivateKey
+		}
+	}
+	if len(seen) != len(stored) || currentKey == nil {
+		return domain.ErrKeyVersionMissing
+	}
+
+	r.s.userKeys[id] = updated
+	u.PasswordHash = passwordHash
+	u.EncryptedPrivateKey = currentKey
+	r.s.users[id] = u
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const storageOpColumns = `id, op, bucket, object_name, attempts, last_error, next_attempt_at, created_at`
+
+func scanStorageOp(row pgx.Row) (domain.StorageOp, error) {
+	var op domain.StorageOp
+	err := row.Scan(&op.ID, &op.Op, &op.Bucket, &op.ObjectName, &op.Attempts, &op.LastError, &op.NextAttemptAt, &op.CreatedAt)
+	return op, err
+}
+
+// OutboxRepository reads the storage outbox. Entries are written by the
+// statements that delete rows, in the same transaction, so an object is
+// never forgotten once its record is gone.
+type OutboxRepository interface {
+	FindDue(ctx context.Context, now time.Time, limit int) ([]domain.StorageOp, error)
+	Complete(ctx context.Context, id int64) error
+	Retry(ctx context.Context, id int64, lastError string, next time.Time) error
+}
+
+type outboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// FindDue returns up to limit entries whose next attempt is due. Storage
+// operations are idempotent, so two workers picking the same entry is
+// harmless.
+func (r *outboxRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]domain.StorageOp, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+storageOpColumns+`
+		FROM storage_outbox
+		WHERE next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ops []domain.StorageOp
+	for rows.Next() {
+		op, err := scanStorageOp(rows)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
+
+func (r *outboxRepository) Complete(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM storage_outbox WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("outbox entry")
+	}
+	return nil
+}
+
+func (r *outboxRepository) Retry(ctx context.Context, id int64, lastError string, next time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE storage_outbox
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1
+	`, id, lastError, next)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("outbox entry")
+	}
+	return nil
+}
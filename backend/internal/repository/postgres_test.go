@@ -0,0 +1,33 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestContract runs against the migrated database at TEST_DB_URL. The
+// suite only adds rows, so any scratch database will do.
+func TestContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_URL")
+	if dsn == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+	db, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	repos := repotest.Repositories{
+		Users:  repository.NewUserRepository(db),
+		Files:  repository.NewFileRepository(db),
+		Shares: repository.NewShareRepository(db),
+		Groups: repository.NewGroupRepository(db),
+	}
+	repotest.Run(t, func(t *testing.T) repotest.Repositories { return repos })
+}
@@ -0,0 +1,311 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+)
+
+func RunFiles(t *testing.T, open func(t *testing.T) Repositories) {
+	ctx := context.Background()
+
+	t.Run("PendingUntilCommitted", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "pending-")
+		f := NewFile(t, r, owner.ID, now(), true)
+
+		_, err := r.Files.FindByID(ctx, f.ID)
+		isErr(t, "find pending file", err, domain.ErrNotFound)
+		files, err := r.Files.FindByOwner(ctx, owner.ID)
+		must(t, "list", err)
+		equalIDs(t, "listing with a pending file", fileIDs(files), nil)
+
+		must(t, "commit", r.Files.Commit(ctx, f.ID))
+		isErr(t, "commit twice", r.Files.Commit(ctx, f.ID), domain.ErrNotFound)
+
+		got, err := r.Files.FindByID(ctx, f.ID)
+		must(t, "find", err)
+		if got.State != domain.FileStateCommitted || got.Version != 1 || got.ObjectName != f.ID.String() || got.KeyVersion != owner.KeyVersion {
+			t.Fatalf("committed file = %+v", got)
+		}
+		versions, err := r.Files.FindVersions(ctx, f.ID)
+		must(t, "versions", err)
+		if len(versions) != 1 || versions[0].State != domain.FileStateCommitted {
+			t.Fatalf("versions = %+v, want the first version committed", versions)
+		}
+	})
+
+	t.Run("RejectsUnknownOwner", func(t *testing.T) {
+		r := open(t)
+		err := r.Files.Save(ctx, domain.File{ID: uuid.New(), OwnerID: uuid.New(), IV: []byte("iv"), CreatedAt: now()})
+		mustFail(t, "save file for a missing owner", err)
+	})
+
+	t.Run("FindPending", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "stale-")
+		old := NewFile(t, r, owner.ID, now().Add(-2*time.Hour), true)
+		recent := NewFile(t, r, owner.ID, now(), true)
+		committed := NewFile(t, r, owner.ID, now().Add(-2*time.Hour), false)
+
+		files, err := r.Files.FindPending(ctx, now().Add(-time.Hour), 1000)
+		must(t, "find pending", err)
+		ids := fileIDs(files)
+		if !contains(ids, old.ID) || contains(ids, recent.ID) || contains(ids, committed.ID) {
+			t.Fatalf("pending files = %v, want %s only among this test's files", ids, old.ID)
+		}
+	})
+
+	t.Run("ListNewestFirst", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "list-")
+		older := NewFile(t, r, owner.ID, now().Add(-time.Minute), false)
+		newer := NewFile(t, r, owner.ID, now(), false)
+
+		files, err := r.Files.FindByOwner(ctx, owner.ID)
+		must(t, "list", err)
+		equalIDs(t, "by owner", fileIDs(files), []uuid.UUID{newer.ID, older.ID})
+
+		files, err = r.Files.FindByFolder(ctx, owner.ID, nil)
+		must(t, "list top level", err)
+		equalIDs(t, "top level", fileIDs(files), []uuid.UUID{newer.ID, older.ID})
+	})
+
+	t.Run("SaveOverQuota", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "quota-")
+		f := domain.File{ID: uuid.New(), OwnerID: owner.ID, Size: 1 << 62, IV: []byte("iv"), EncryptedKey: []byte("encrypted-key"), CreatedAt: now()}
+
+		isErr(t, "save over quota", r.Files.Save(ctx, f), domain.ErrFileTooLarge)
+		isErr(t, "commit a rejected file", r.Files.Commit(ctx, f.ID), domain.ErrNotFound)
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "trash-")
+		other := NewUser(t, r, "trash-other-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		trashedAt := now()
+
+		mustFail(t, "trash someone else's file", r.Files.Trash(ctx, f.ID, other.ID, trashedAt))
+		must(t, "trash", r.Files.Trash(ctx, f.ID, owner.ID, trashedAt))
+		mustFail(t, "trash twice", r.Files.Trash(ctx, f.ID, owner.ID, trashedAt))
+
+		got, err := r.Files.FindByID(ctx, f.ID)
+		must(t, "find trashed", err)
+		if got.DeletedAt == nil || !got.DeletedAt.Equal(trashedAt) {
+			t.Fatalf("deleted_at = %v, want %v", got.DeletedAt, trashedAt)
+		}
+		files, err := r.Files.FindByOwner(ctx, owner.ID)
+		must(t, "list", err)
+		equalIDs(t, "listing with a trashed file", fileIDs(files), nil)
+		files, err = r.Files.FindTrashed(ctx, owner.ID)
+		must(t, "list trash", err)
+		equalIDs(t, "trash", fileIDs(files), []uuid.UUID{f.ID})
+
+		files, err = r.Files.FindTrashedBefore(ctx, trashedAt.Add(time.Second), 1000)
+		must(t, "trashed before", err)
+		if !contains(fileIDs(files), f.ID) {
+			t.Fatal("file trashed before the cutoff not returned")
+		}
+		files, err = r.Files.FindTrashedBefore(ctx, trashedAt, 1000)
+		must(t, "trashed before", err)
+		if contains(fileIDs(files), f.ID) {
+			t.Fatal("file trashed at the cutoff returned")
+		}
+
+		must(t, "restore", r.Files.RestoreFromTrash(ctx, f.ID, owner.ID))
+		isErr(t, "restore twice", r.Files.RestoreFromTrash(ctx, f.ID, owner.ID), domain.ErrNotFound)
+		files, err = r.Files.FindByOwner(ctx, owner.ID)
+		must(t, "list", err)
+		equalIDs(t, "listing after restore", fileIDs(files), []uuid.UUID{f.ID})
+		isErr(t, "purge a restored file", r.Files.Purge(ctx, f.ID, trashedAt.Add(time.Hour)), domain.ErrNotFound)
+
+		must(t, "trash again", r.Files.Trash(ctx, f.ID, owner.ID, trashedAt))
+		isErr(t, "purge before the cutoff", r.Files.Purge(ctx, f.ID, trashedAt), domain.ErrNotFound)
+		must(t, "purge", r.Files.Purge(ctx, f.ID, trashedAt.Add(time.Second)))
+		_, err = r.Files.FindByID(ctx, f.ID)
+		isErr(t, "find purged", err, domain.ErrNotFound)
+	})
+
+	t.Run("Updates", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "update-")
+		other := NewUser(t, r, "update-other-")
+		f := NewFile(t, r, owner.ID, now(), false)
+
+		mustFail(t, "update someone else's metadata", r.Files.UpdateMetadata(ctx, f.ID, other.ID, []byte("sealed")))
+		must(t, "update metadata", r.Files.UpdateMetadata(ctx, f.ID, owner.ID, []byte("sealed")))
+
+		stale, err := r.Files.FindStaleByOwner(ctx, owner.ID, 2)
+		must(t, "stale", err)
+		equalIDs(t, "stale files", fileIDs(stale), []uuid.UUID{f.ID})
+		must(t, "update key", r.Files.UpdateEncryptedKey(ctx, f.ID, owner.ID, []byte("rewrapped"), 2))
+		stale, err = r.Files.FindStaleByOwner(ctx, owner.ID, 2)
+		must(t, "stale", err)
+		equalIDs(t, "stale files after rewrap", fileIDs(stale), nil)
+
+		got, err := r.Files.FindByID(ctx, f.ID)
+		must(t, "find", err)
+		if got.Filename != "" || got.MimeType != "" || string(got.EncryptedMetadata) != "sealed" {
+			t.Fatalf("plaintext metadata kept: %+v", got)
+		}
+		if string(got.EncryptedKey) != "rewrapped" || got.KeyVersion != 2 {
+			t.Fatalf("key not updated: %+v", got)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "versions-")
+		f := NewFile(t, r, owner.ID, now(), false)
+
+		addVersion := func(size int64) domain.FileVersion {
+			t.Helper()
+			v, err := r.Files.AddVersion(ctx, owner.ID, domain.FileVersion{
+				FileID:     f.ID,
+				ObjectName: f.ID.String() + "/" + uuid.NewString(),
+				Size:       size,
+				IV:         []byte("iv"),
+				CreatedAt:  now(),
+			})
+			must(t, "add version", err)
+			return v
+		}
+
+		v2 := addVersion(200)
+		if v2.Version != 2 || v2.State != domain.FileStatePending {
+			t.Fatalf("added version = %+v, want pending version 2", v2)
+		}
+		_, err := r.Files.FindVersion(ctx, f.ID, 2)
+		isErr(t, "find pending version", err, domain.ErrNotFound)
+
+		must(t, "commit version", r.Files.CommitVersion(ctx, f.ID, 2))
+		got, err := r.Files.FindByID(ctx, f.ID)
+		must(t, "find", err)
+		if got.Version != 2 || got.ObjectName != v2.ObjectName || got.Size != 200 {
+			t.Fatalf("current = %+v, want version 2", got)
+		}
+
+		v3 := addVersion(300)
+		must(t, "abort version", r.Files.AbortVersion(ctx, f.ID, v3.Version))
+		isErr(t, "commit aborted version", r.Files.CommitVersion(ctx, f.ID, v3.Version), domain.ErrNotFound)
+
+		must(t, "restore", r.Files.RestoreVersion(ctx, f.ID, owner.ID, 1))
+		got, err = r.Files.FindByID(ctx, f.ID)
+		must(t, "find", err)
+		if got.Version != 1 || got.ObjectName != f.ID.String() || got.Size != 100 {
+			t.Fatalf("current = %+v, want version 1", got)
+		}
+		isErr(t, "restore missing version", r.Files.RestoreVersion(ctx, f.ID, owner.ID, 9), domain.ErrNotFound)
+
+		_, err = r.Files.DeleteVersion(ctx, f.ID, owner.ID, 1)
+		isErr(t, "delete current version", err, domain.ErrNotFound)
+		deleted, err := r.Files.DeleteVersion(ctx, f.ID, owner.ID, 2)
+		must(t, "delete version", err)
+		if deleted.ObjectName != v2.ObjectName {
+			t.Fatalf("deleted %+v, want version 2", deleted)
+		}
+
+		v4 := addVersion(400)
+		must(t, "commit version", r.Files.CommitVersion(ctx, f.ID, v4.Version))
+		v5 := addVersion(500)
+		must(t, "commit version", r.Files.CommitVersion(ctx, f.ID, v5.Version))
+		must(t, "restore", r.Files.RestoreVersion(ctx, f.ID, owner.ID, v4.Version))
+
+		// Keeping one leaves the newest and the current version.
+		pruned, err := r.Files.PruneVersions(ctx, f.ID, owner.ID, 1)
+		must(t, "prune", err)
+		if len(pruned) != 1 || pruned[0].Version != 1 {
+			t.Fatalf("pruned %+v, want version 1", pruned)
+		}
+		versions, err := r.Files.FindVersions(ctx, f.ID)
+		must(t, "versions", err)
+		if len(versions) != 2 || versions[0].Version != v5.Version || versions[1].Version != v4.Version {
+			t.Fatalf("versions = %+v, want %d and %d newest first", versions, v5.Version, v4.Version)
+		}
+	})
+
+	t.Run("TrashedVersionsAreFrozen", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "frozen-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		v2, err := r.Files.AddVersion(ctx, owner.ID, domain.FileVersion{
+			FileID:     f.ID,
+			ObjectName: f.ID.String() + "/" + uuid.NewString(),
+			Size:       200,
+			IV:         []byte("iv"),
+			CreatedAt:  now(),
+		})
+		must(t, "add version", err)
+		must(t, "commit version", r.Files.CommitVersion(ctx, f.ID, v2.Version))
+		must(t, "trash", r.Files.Trash(ctx, f.ID, owner.ID, now()))
+
+		isErr(t, "restore version of a trashed file", r.Files.RestoreVersion(ctx, f.ID, owner.ID, 1), domain.ErrNotFound)
+		_, err = r.Files.DeleteVersion(ctx, f.ID, owner.ID, 1)
+		isErr(t, "delete version of a trashed file", err, domain.ErrNotFound)
+		pruned, err := r.Files.PruneVersions(ctx, f.ID, owner.ID, 1)
+		must(t, "prune", err)
+		if len(pruned) != 0 {
+			t.Fatalf("pruned %+v from a trashed file", pruned)
+		}
+
+		versions, err := r.Files.FindVersions(ctx, f.ID)
+		must(t, "versions", err)
+		if len(versions) != 2 || versions[0].Version != v2.Version {
+			t.Fatalf("versions = %+v, want both left alone", versions)
+		}
+	})
+
+	t.Run("FindPendingVersions", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "pending-versions-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		v, err := r.Files.AddVersion(ctx, owner.ID, domain.FileVersion{
+			FileID: f.ID, ObjectName: f.ID.String() + "/v2", Size: 1, IV: []byte("iv"), CreatedAt: now().Add(-2 * time.Hour),
+		})
+		must(t, "add version", err)
+
+		versions, err := r.Files.FindPendingVersions(ctx, now().Add(-time.Hour), 1000)
+		must(t, "find pending versions", err)
+		found := false
+		for _, pv := range versions {
+			found = found || pv.FileID == f.ID && pv.Version == v.Version
+		}
+		if !found {
+			t.Fatal("stale pending version not returned")
+		}
+	})
+
+	t.Run("AddVersionChecksOwner", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "add-owner-")
+		other := NewUser(t, r, "add-other-")
+		f := NewFile(t, r, owner.ID, now(), false)
+
+		_, err := r.Files.AddVersion(ctx, other.ID, domain.FileVersion{FileID: f.ID, ObjectName: "x", IV: []byte("iv"), CreatedAt: now()})
+		mustFail(t, "add version to someone else's file", err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "delete-")
+		pending := NewFile(t, r, owner.ID, now(), true)
+		committed := NewFile(t, r, owner.ID, now(), false)
+
+		must(t, "delete pending", r.Files.Delete(ctx, pending.ID))
+		must(t, "delete committed", r.Files.Delete(ctx, committed.ID))
+		isErr(t, "delete twice", r.Files.Delete(ctx, committed.ID), domain.ErrNotFound)
+
+		_, err := r.Files.FindByID(ctx, committed.ID)
+		isErr(t, "find deleted", err, domain.ErrNotFound)
+		versions, err := r.Files.FindVersions(ctx, committed.ID)
+		must(t, "versions", err)
+		if len(versions) != 0 {
+			t.Fatalf("versions of a deleted file = %+v", versions)
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+)
+
+func RunGroups(t *testing.T, open func(t *testing.T) Repositories) {
+	ctx := context.Background()
+
+	t.Run("Membership", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "group-owner-")
+		member := NewUser(t, r, "group-member-")
+		outsider := NewUser(t, r, "group-outsider-")
+		g := NewGroup(t, r, owner, member)
+
+		got, err := r.Groups.FindByID(ctx, g.ID)
+		must(t, "find", err)
+		if got.OwnerID != owner.ID || got.KeyVersion != 1 || got.PublicKey != g.PublicKey {
+			t.Fatalf("found %+v", got)
+		}
+		_, err = r.Groups.FindByID(ctx, uuid.New())
+		isErr(t, "find missing group", err, domain.ErrNotFound)
+
+		for _, tt := range []struct {
+			user domain.User
+			want bool
+		}{{owner, true}, {member, true}, {outsider, false}} {
+			ok, err := r.Groups.IsMember(ctx, g.ID, tt.user.ID)
+			must(t, "is member", err)
+			if ok != tt.want {
+				t.Fatalf("IsMember(%s) = %v, want %v", tt.user.Username, ok, tt.want)
+			}
+		}
+
+		groups, err := r.Groups.FindByMember(ctx, member.ID)
+		must(t, "find by member", err)
+		if len(groups) != 1 || groups[0].ID != g.ID {
+			t.Fatalf("groups of member = %+v", groups)
+		}
+
+		members, err := r.Groups.ListMembers(ctx, g.ID)
+		must(t, "list members", err)
+		if len(members) != 2 {
+			t.Fatalf("members = %+v", members)
+		}
+
+		keys, err := r.Groups.FindMemberKeys(ctx, g.ID, member.ID)
+		must(t, "find member keys", err)
+		if len(keys) != 1 || keys[0].Version != 1 || keys[0].UserKeyVersion != member.KeyVersion {
+			t.Fatalf("member keys = %+v", keys)
+		}
+
+		must(t, "delete", r.Groups.Delete(ctx, g.ID))
+		isErr(t, "delete twice", r.Groups.Delete(ctx, g.ID), domain.ErrNotFound)
+	})
+
+	t.Run("Shares", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "gshare-owner-")
+		member := NewUser(t, r, "gshare-member-")
+		outsider := NewUser(t, r, "gshare-outsider-")
+		g := NewGroup(t, r, owner, member)
+		f := NewFile(t, r, owner.ID, now(), false)
+		sh := NewGroupShare(t, r, f, g.ID, domain.PermView, now(), nil)
+
+		got, err := r.Groups.FindShareByID(ctx, sh.ID)
+		must(t, "find", err)
+		if got.FileID != f.ID || got.GroupID != g.ID || got.KeyVersion != 1 || got.Permissions != domain.PermView {
+			t.Fatalf("found %+v", got)
+		}
+
+		dup := sh
+		dup.ID = uuid.New()
+		isErr(t, "share twice with one group", r.Groups.SaveShare(ctx, dup), domain.ErrAlreadyShared)
+		stale := sh
+		stale.ID, stale.FileID, stale.KeyVersion = uuid.New(), NewFile(t, r, owner.ID, now(), false).ID, 2
+		isErr(t, "share for another key version", r.Groups.SaveShare(ctx, stale), domain.ErrGroupKeyVersionConflict)
+
+		share, key, err := r.Groups.FindShareForMember(ctx, f.ID, member.ID, domain.PermView, now())
+		must(t, "find for member", err)
+		if share.ID != sh.ID || key.UserID != member.ID || key.Version != share.KeyVersion {
+			t.Fatalf("found %+v with key %+v", share, key)
+		}
+		_, _, err = r.Groups.FindShareForMember(ctx, f.ID, member.ID, domain.PermDownload, now())
+		mustFail(t, "find for member without the permission", err)
+		_, _, err = r.Groups.FindShareForMember(ctx, f.ID, outsider.ID, domain.PermView, now())
+		mustFail(t, "find for outsider", err)
+
+		trashed := NewFile(t, r, owner.ID, now().Add(time.Second), false)
+		NewGroupShare(t, r, trashed, g.ID, domain.PermView, now().Add(time.Second), nil)
+		must(t, "trash", r.Files.Trash(ctx, trashed.ID, owner.ID, now()))
+		shares, err := r.Groups.FindSharesByGroup(ctx, g.ID)
+		must(t, "find by group", err)
+		if len(shares) != 1 || shares[0].ID != sh.ID {
+			t.Fatalf("shares of group = %+v", shares)
+		}
+
+		must(t, "delete share", r.Groups.DeleteShare(ctx, sh.ID))
+		isErr(t, "delete share twice", r.Groups.DeleteShare(ctx, sh.ID), domain.ErrNotFound)
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "rotate-owner-")
+		leaving := NewUser(t, r, "rotate-leaving-")
+		joining := NewUser(t, r, "rotate-joining-")
+		g := NewGroup(t, r, owner, leaving)
+		f := NewFile(t, r, owner.ID, now(), false)
+		sh := NewGroupShare(t, r, f, g.ID, domain.PermDefault, now(), nil)
+
+		memberKey := func(u domain.User) domain.GroupMemberKey {
+			return domain.GroupMemberKey{GroupID: g.ID, Version: 2, UserID: u.ID, WrappedPrivateKey: []byte("wrapped-group-key-2")}
+		}
+		rot := domain.GroupRotation{
+			GroupID:    g.ID,
+			Version:    2,
+			PublicKey:  "group-public-key-2",
+			Add:        []uuid.UUID{joining.ID},
+			Remove:     []uuid.UUID{leaving.ID},
+			MemberKeys: []domain.GroupMemberKey{memberKey(owner), memberKey(joining)},
+			Shares:     []domain.GroupShareRewrap{{ShareID: sh.ID, WrappedKey: []byte("rewrapped-key")}},
+			CreatedAt:  now(),
+		}
+
+		skipped := rot
+		skipped.Version = 3
+		isErr(t, "rotate past the next version", r.Groups.Rotate(ctx, skipped), domain.ErrGroupKeyVersionConflict)
+		unwrapped := rot
+		unwrapped.MemberKeys = rot.MemberKeys[:1]
+		isErr(t, "rotate without a member's key", r.Groups.Rotate(ctx, unwrapped), domain.ErrGroupMembershipMismatch)
+		unshared := rot
+		unshared.Shares = nil
+		isErr(t, "rotate without a share", r.Groups.Rotate(ctx, unshared), domain.ErrGroupSharesMismatch)
+
+		ok, err := r.Groups.IsMember(ctx, g.ID, leaving.ID)
+		must(t, "is member", err)
+		if !ok {
+			t.Fatal("rejected rotation removed a member")
+		}
+
+		must(t, "rotate", r.Groups.Rotate(ctx, rot))
+
+		got, err := r.Groups.FindByID(ctx, g.ID)
+		must(t, "find", err)
+		if got.KeyVersion != 2 || got.PublicKey != rot.PublicKey {
+			t.Fatalf("group after rotation = %+v", got)
+		}
+		if ok, _ := r.Groups.IsMember(ctx, g.ID, leaving.ID); ok {
+			t.Fatal("removed member still belongs to the group")
+		}
+		if ok, _ := r.Groups.IsMember(ctx, g.ID, joining.ID); !ok {
+			t.Fatal("added member does not belong to the group")
+		}
+		keys, err := r.Groups.FindMemberKeys(ctx, g.ID, leaving.ID)
+		must(t, "find removed member's keys", err)
+		if len(keys) != 0 {
+			t.Fatalf("removed member kept keys %+v", keys)
+		}
+		keys, err = r.Groups.FindMemberKeys(ctx, g.ID, owner.ID)
+		must(t, "find owner's keys", err)
+		if len(keys) != 2 || keys[0].Version != 2 {
+			t.Fatalf("owner keys = %+v", keys)
+		}
+
+		share, key, err := r.Groups.FindShareForMember(ctx, f.ID, joining.ID, domain.PermView, now())
+		must(t, "find for added member", err)
+		if share.KeyVersion != 2 || string(share.WrappedKey) != "rewrapped-key" || key.Version != 2 {
+			t.Fatalf("found %+v with key %+v", share, key)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+// Package repotest is the contract every repository implementation must
+// pass, whether in memory or on Postgres. Each test creates its own users
+// and files, so the suite can run against a shared database.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+type Repositories struct {
+	Users  repository.UserRepository
+	Files  repository.FileRepository
+	Shares repository.ShareRepository
+	Groups repository.GroupRepository
+}
+
+// Run runs the whole contract. open is called once per test and may hand
+// out the same repositories every time.
+func Run(t *testing.T, open func(t *testing.T) Repositories) {
+	t.Run("Users", func(t *testing.T) { RunUsers(t, open) })
+	t.Run("Files", func(t *testing.T) { RunFiles(t, open) })
+	t.Run("Shares", func(t *testing.T) { RunShares(t, open) })
+	t.Run("Groups", func(t *testing.T) { RunGroups(t, open) })
+}
+
+// now is truncated to the precision Postgres stores.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// NewUser saves a user with a unique username beginning with prefix.
+func NewUser(t *testing.T, r Repositories, prefix string) domain.User {
+	t.Helper()
+	u := domain.User{
+		ID:                  uuid.New(),
+		Username:            prefix + uuid.NewString()[:8],
+		PasswordHash:        "hash",
+		PublicKey:           "public-key",
+		EncryptedPrivateKey: []byte("encrypted-private-key"),
+		KeyVersion:          1,
+		CreatedAt:           now(),
+	}
+	if err := r.Users.Save(context.Background(), u); err != nil {
+		t.Fatalf("save user: %v", err)
+	}
+	return u
+}
+
+// NewFile saves a file owned by ownerID and commits it unless pending is
+// set.
+func NewFile(t *testing.T, r Repositories, ownerID uuid.UUID, createdAt time.Time, pending bool) domain.File {
+	t.Helper()
+	ctx := context.Background()
+	f := domain.File{
+		ID:           uuid.New(),
+		OwnerID:      ownerID,
+		Filename:     "report.pdf",
+		MimeType:     "application/pdf",
+		Size:         100,
+		IV:           []byte("iv"),
+		EncryptedKey: []byte("encrypted-key"),
+		CreatedAt:    createdAt,
+	}
+	if err := r.Files.Save(ctx, f); err != nil {
+		t.Fatalf("save file: %v", err)
+	}
+	f.Version, f.ObjectName, f.KeyVersion, f.State = 1, f.ID.String(), 1, domain.FileStatePending
+	if pending {
+		return f
+	}
+	if err := r.Files.Commit(ctx, f.ID); err != nil {
+		t.Fatalf("commit file: %v", err)
+	}
+	f.State = domain.FileStateCommitted
+	return f
+}
+
+// NewShare saves a share of file from its owner to recipientID carrying
+// perms.
+func NewShare(t *testing.T, r Repositories, file domain.File, recipientID uuid.UUID, perms domain.SharePermission, createdAt time.Time, expiresAt *time.Time) domain.Share {
+	t.Helper()
+	sh := domain.Share{
+		ID:           uuid.New(),
+		FileID:       file.ID,
+		RecipientID:  recipientID,
+		WrappedKey:   []byte("recipient-wrapped-key"),
+		CreatedAt:    createdAt,
+		ExpiresAt:    expiresAt,
+		Permissions:  perms,
+		GrantedBy:    file.OwnerID,
+		GrantorChain: []uuid.UUID{file.OwnerID},
+	}
+	if err := r.Shares.Save(context.Background(), sh); err != nil {
+		t.Fatalf("save share: %v", err)
+	}
+	sh.KeyVersion = 1
+	return sh
+}
+
+// NewReshare saves a share of grant's file that grant's recipient passed on
+// to recipientID.
+func NewReshare(t *testing.T, r Repositories, grant domain.Share, recipientID uuid.UUID, createdAt time.Time, expiresAt *time.Time) domain.Share {
+	t.Helper()
+	sh := domain.Share{
+		ID:           uuid.New(),
+		FileID:       grant.FileID,
+		RecipientID:  recipientID,
+		WrappedKey:   []byte("recipient-wrapped-key"),
+		CreatedAt:    createdAt,
+		ExpiresAt:    expiresAt,
+		Permissions:  domain.PermDefault,
+		GrantedBy:    grant.RecipientID,
+		GrantorChain: append(append([]uuid.UUID{}, grant.GrantorChain...), grant.RecipientID),
+	}
+	if err := r.Shares.Save(context.Background(), sh); err != nil {
+		t.Fatalf("save reshare: %v", err)
+	}
+	sh.KeyVersion = 1
+	return sh
+}
+
+// NewGroup saves a group owned by owner whose members are owner and
+// members, with its first keypair wrapped for each of them.
+func NewGroup(t *testing.T, r Repositories, owner domain.User, members ...domain.User) domain.Group {
+	t.Helper()
+	g := domain.Group{
+		ID:         uuid.New(),
+		Name:       "group-" + uuid.NewString()[:8],
+		OwnerID:    owner.ID,
+		KeyVersion: 1,
+		PublicKey:  "group-public-key",
+		CreatedAt:  now(),
+	}
+	var keys []domain.GroupMemberKey
+	for _, u := range append([]domain.User{owner}, members...) {
+		keys = append(keys, domain.GroupMemberKey{GroupID: g.ID, Version: 1, UserID: u.ID, WrappedPrivateKey: []byte("wrapped-group-key")})
+	}
+	if err := r.Groups.Create(context.Background(), g, keys); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	return g
+}
+
+// NewGroupShare saves a share of file from its owner to groupID carrying
+// perms.
+func NewGroupShare(t *testing.T, r Repositories, file domain.File, groupID uuid.UUID, perms domain.SharePermission, createdAt time.Time, expiresAt *time.Time) domain.GroupShare {
+	t.Helper()
+	sh := domain.GroupShare{
+		ID:          uuid.New(),
+		FileID:      file.ID,
+		GroupID:     groupID,
+		WrappedKey:  []byte("group-wrapped-key"),
+		Permissions: perms,
+		GrantedBy:   file.OwnerID,
+		CreatedAt:   createdAt,
+		ExpiresAt:   expiresAt,
+	}
+	if err := r.Groups.SaveShare(context.Background(), sh); err != nil {
+		t.Fatalf("save group share: %v", err)
+	}
+	sh.KeyVersion = 1
+	return sh
+}
+
+// NoFolders is a folder repository without folders, for usecases that only
+// purge them.
+type NoFolders struct {
+	repository.FolderRepository
+}
+
+func (NoFolders) PurgeTrashed(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+func fileIDs(files []domain.File) []uuid.UUID {
+	ids := make([]uuid.UUID, len(files))
+	for i, f := range files {
+		ids[i] = f.ID
+	}
+	return ids
+}
+
+func shareIDs(shares []domain.Share) []uuid.UUID {
+	ids := make([]uuid.UUID, len(shares))
+	for i, s := range shares {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func listingIDs(listings []domain.ShareListing) []uuid.UUID {
+	ids := make([]uuid.UUID, len(listings))
+	for i, l := range listings {
+		ids[i] = l.ID
+	}
+	return ids
+}
+
+func equalIDs(t *testing.T, what string, got, want []uuid.UUID) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", what, got, want)
+		}
+	}
+}
+
+func contains(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+func mustFail(t *testing.T, what string, err error) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("%s succeeded, want an error", what)
+	}
+}
+
+func must(t *testing.T, what string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+func isErr(t *testing.T, what string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("%s = %v, want %v", what, err, want)
+	}
+}
@@ -0,0 +1,268 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+)
+
+func RunShares(t *testing.T, open func(t *testing.T) Repositories) {
+	ctx := context.Background()
+
+	t.Run("SaveAndFind", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "share-owner-")
+		recipient := NewUser(t, r, "share-recipient-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		sh := NewShare(t, r, f, recipient.ID, domain.PermDefault, now(), nil)
+
+		got, err := r.Shares.FindByID(ctx, sh.ID)
+		must(t, "find", err)
+		if got.FileID != f.ID || got.RecipientID != recipient.ID || got.KeyVersion != recipient.KeyVersion ||
+			got.Permissions != domain.PermDefault || got.GrantedBy != owner.ID || len(got.GrantorChain) != 1 {
+			t.Fatalf("found %+v", got)
+		}
+
+		got, err = r.Shares.FindByFileAndRecipient(ctx, f.ID, recipient.ID)
+		must(t, "find by file and recipient", err)
+		if got.ID != sh.ID {
+			t.Fatalf("found %s, want %s", got.ID, sh.ID)
+		}
+
+		_, err = r.Shares.FindByID(ctx, uuid.New())
+		isErr(t, "find missing share", err, domain.ErrNotFound)
+		_, err = r.Shares.FindByFileAndRecipient(ctx, f.ID, owner.ID)
+		isErr(t, "find missing recipient", err, domain.ErrNotFound)
+	})
+
+	t.Run("OneSharePerRecipient", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "dup-owner-")
+		recipient := NewUser(t, r, "dup-recipient-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		sh := NewShare(t, r, f, recipient.ID, domain.PermDefault, now(), nil)
+
+		sh.ID = uuid.New()
+		isErr(t, "share twice with one recipient", r.Shares.Save(ctx, sh), domain.ErrAlreadyShared)
+	})
+
+	t.Run("FindByRecipient", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "incoming-owner-")
+		recipient := NewUser(t, r, "incoming-recipient-")
+		active := NewShare(t, r, NewFile(t, r, owner.ID, now(), false), recipient.ID, domain.PermDefault, now(), nil)
+		future := now().Add(time.Hour)
+		expiring := NewShare(t, r, NewFile(t, r, owner.ID, now(), false), recipient.ID, domain.PermDefault, now().Add(time.Second), &future)
+		past := now().Add(-time.Second)
+		NewShare(t, r, NewFile(t, r, owner.ID, now(), false), recipient.ID, domain.PermDefault, now(), &past)
+		trashed := NewFile(t, r, owner.ID, now(), false)
+		NewShare(t, r, trashed, recipient.ID, domain.PermDefault, now(), nil)
+		must(t, "trash", r.Files.Trash(ctx, trashed.ID, owner.ID, now()))
+
+		shares, err := r.Shares.FindByRecipient(ctx, recipient.ID, now())
+		must(t, "find by recipient", err)
+		equalIDs(t, "incoming shares", shareIDs(shares), []uuid.UUID{expiring.ID, active.ID})
+	})
+
+	t.Run("Listings", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "listing-owner-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		base := now()
+		var ids []uuid.UUID
+		for i := 0; i < 3; i++ {
+			recipient := NewUser(t, r, "listing-recipient-")
+			ids = append([]uuid.UUID{NewShare(t, r, f, recipient.ID, domain.PermDefault, base.Add(time.Duration(i)*time.Second), nil).ID}, ids...)
+		}
+		other := NewFile(t, r, owner.ID, now(), false)
+		otherShare := NewShare(t, r, other, NewUser(t, r, "listing-other-").ID, domain.PermDefault, base.Add(time.Minute), nil)
+
+		page, err := r.Shares.FindListingsByFile(ctx, f.ID, domain.ShareCursor{}, 2)
+		must(t, "first page", err)
+		equalIDs(t, "first page", listingIDs(page), ids[:2])
+		if page[0].Kind != domain.ShareListingUser || page[0].Filename != f.Filename || page[0].RecipientUsername == "" {
+			t.Fatalf("listing missing names: %+v", page[0])
+		}
+		// Revoking the share a cursor points at must not end the listing.
+		cursor := domain.ShareCursor{CreatedAt: page[1].CreatedAt, ID: page[1].ID}
+		must(t, "revoke cursor share", r.Shares.Delete(ctx, page[1].ID))
+		page, err = r.Shares.FindListingsByFile(ctx, f.ID, cursor, 2)
+		must(t, "second page", err)
+		equalIDs(t, "second page", listingIDs(page), ids[2:])
+		ids = append(ids[:1], ids[2:]...)
+
+		outgoing, err := r.Shares.FindOutgoingByOwner(ctx, owner.ID, domain.ShareCursor{}, 10)
+		must(t, "outgoing", err)
+		equalIDs(t, "outgoing", listingIDs(outgoing), append([]uuid.UUID{otherShare.ID}, ids...))
+
+		must(t, "trash", r.Files.Trash(ctx, other.ID, owner.ID, now()))
+		outgoing, err = r.Shares.FindOutgoingByOwner(ctx, owner.ID, domain.ShareCursor{}, 10)
+		must(t, "outgoing", err)
+		equalIDs(t, "outgoing without trashed files", listingIDs(outgoing), ids)
+
+		g := NewGroup(t, r, owner)
+		gs := NewGroupShare(t, r, f, g.ID, domain.PermDefault, base.Add(2*time.Minute), nil)
+		page, err = r.Shares.FindListingsByFile(ctx, f.ID, domain.ShareCursor{}, 10)
+		must(t, "listings with a group share", err)
+		equalIDs(t, "listings with a group share", listingIDs(page), append([]uuid.UUID{gs.ID}, ids...))
+		if page[0].Kind != domain.ShareListingGroup || page[0].GroupID != g.ID || page[0].GroupName != g.Name || page[0].Filename != f.Filename {
+			t.Fatalf("group listing: %+v", page[0])
+		}
+	})
+
+	t.Run("Updates", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "update-owner-")
+		recipient := NewUser(t, r, "update-recipient-")
+		sh := NewShare(t, r, NewFile(t, r, owner.ID, now(), false), recipient.ID, domain.PermDefault, now(), nil)
+
+		stale, err := r.Shares.FindStaleByRecipient(ctx, recipient.ID, 2)
+		must(t, "stale", err)
+		equalIDs(t, "stale shares", shareIDs(stale), []uuid.UUID{sh.ID})
+
+		mustFail(t, "rewrap for another recipient", r.Shares.UpdateWrappedKey(ctx, sh.ID, owner.ID, []byte("k"), 2))
+		must(t, "rewrap", r.Shares.UpdateWrappedKey(ctx, sh.ID, recipient.ID, []byte("rewrapped"), 2))
+		expiresAt := now().Add(time.Hour)
+		must(t, "expiry", r.Shares.UpdateExpiry(ctx, sh.ID, &expiresAt))
+		must(t, "permissions", r.Shares.UpdatePermissions(ctx, sh.ID, domain.PermAll))
+
+		got, err := r.Shares.FindByID(ctx, sh.ID)
+		must(t, "find", err)
+		if string(got.WrappedKey) != "rewrapped" || got.KeyVersion != 2 || got.ExpiresAt == nil ||
+			!got.ExpiresAt.Equal(expiresAt) || got.Permissions != domain.PermAll {
+			t.Fatalf("share not updated: %+v", got)
+		}
+
+		must(t, "clear expiry", r.Shares.UpdateExpiry(ctx, sh.ID, nil))
+		got, err = r.Shares.FindByID(ctx, sh.ID)
+		must(t, "find", err)
+		if got.ExpiresAt != nil {
+			t.Fatalf("expiry = %v, want none", got.ExpiresAt)
+		}
+
+		missing := uuid.New()
+		isErr(t, "update missing expiry", r.Shares.UpdateExpiry(ctx, missing, nil), domain.ErrNotFound)
+		isErr(t, "update missing permissions", r.Shares.UpdatePermissions(ctx, missing, domain.PermView), domain.ErrNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "unshare-owner-")
+		recipient := NewUser(t, r, "unshare-recipient-")
+		sh := NewShare(t, r, NewFile(t, r, owner.ID, now(), false), recipient.ID, domain.PermDefault, now(), nil)
+
+		must(t, "delete", r.Shares.Delete(ctx, sh.ID))
+		isErr(t, "delete twice", r.Shares.Delete(ctx, sh.ID), domain.ErrNotFound)
+	})
+
+	t.Run("DeleteCascadesToReshares", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "revoke-owner-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		grant := NewShare(t, r, f, NewUser(t, r, "revoke-a-").ID, domain.PermDefault, now(), nil)
+		child := NewReshare(t, r, grant, NewUser(t, r, "revoke-b-").ID, now(), nil)
+		grandchild := NewReshare(t, r, child, NewUser(t, r, "revoke-c-").ID, now(), nil)
+		sibling := NewShare(t, r, f, NewUser(t, r, "revoke-d-").ID, domain.PermDefault, now(), nil)
+
+		must(t, "revoke child", r.Shares.Delete(ctx, child.ID))
+		for _, id := range []uuid.UUID{child.ID, grandchild.ID} {
+			_, err := r.Shares.FindByID(ctx, id)
+			isErr(t, "find revoked reshare", err, domain.ErrNotFound)
+		}
+		for _, id := range []uuid.UUID{grant.ID, sibling.ID} {
+			_, err := r.Shares.FindByID(ctx, id)
+			must(t, "find share outside the revoked chain", err)
+		}
+	})
+
+	t.Run("UpdateExpiryCascadesToReshares", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "narrow-owner-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		grant := NewShare(t, r, f, NewUser(t, r, "narrow-a-").ID, domain.PermDefault, now(), nil)
+		later := now().Add(2 * time.Hour)
+		unbounded := NewReshare(t, r, grant, NewUser(t, r, "narrow-b-").ID, now(), nil)
+		long := NewReshare(t, r, grant, NewUser(t, r, "narrow-c-").ID, now(), &later)
+		sooner := now().Add(30 * time.Minute)
+		short := NewReshare(t, r, grant, NewUser(t, r, "narrow-d-").ID, now(), &sooner)
+
+		expiresAt := now().Add(time.Hour)
+		must(t, "narrow grant", r.Shares.UpdateExpiry(ctx, grant.ID, &expiresAt))
+		want := map[uuid.UUID]time.Time{unbounded.ID: expiresAt, long.ID: expiresAt, short.ID: sooner}
+		for id, wantExpiry := range want {
+			got, err := r.Shares.FindByID(ctx, id)
+			must(t, "find reshare", err)
+			if got.ExpiresAt == nil || !got.ExpiresAt.Equal(wantExpiry) {
+				t.Errorf("reshare %s expires at %v, want %v", id, got.ExpiresAt, wantExpiry)
+			}
+		}
+	})
+
+	t.Run("UpdatePermissionsNarrowsReshares", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "perm-owner-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		grant := NewShare(t, r, f, NewUser(t, r, "perm-a-").ID, domain.PermAll, now(), nil)
+		child := NewReshare(t, r, grant, NewUser(t, r, "perm-b-").ID, now(), nil)
+		sibling := NewShare(t, r, f, NewUser(t, r, "perm-c-").ID, domain.PermDefault, now(), nil)
+
+		permissions := func(id uuid.UUID) domain.SharePermission {
+			t.Helper()
+			got, err := r.Shares.FindByID(ctx, id)
+			must(t, "find share", err)
+			return got.Permissions
+		}
+
+		must(t, "downgrade grant", r.Shares.UpdatePermissions(ctx, grant.ID, domain.PermView))
+		if got := permissions(child.ID); got != domain.PermView {
+			t.Fatalf("reshare permissions = %v, want view only", got.Strings())
+		}
+		if got := permissions(sibling.ID); got != domain.PermDefault {
+			t.Fatalf("unrelated share permissions = %v, want unchanged", got.Strings())
+		}
+
+		must(t, "upgrade grant", r.Shares.UpdatePermissions(ctx, grant.ID, domain.PermAll))
+		if got := permissions(grant.ID); got != domain.PermAll {
+			t.Fatalf("grant permissions = %v, want all", got.Strings())
+		}
+		if got := permissions(child.ID); got != domain.PermView {
+			t.Fatalf("reshare permissions = %v, want them not widened", got.Strings())
+		}
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "reap-owner-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		expiresAt := now().Add(time.Minute)
+		expired := NewShare(t, r, f, NewUser(t, r, "reap-a-").ID, domain.PermDefault, now(), &expiresAt)
+		kept := NewShare(t, r, f, NewUser(t, r, "reap-b-").ID, domain.PermDefault, now(), nil)
+		expiredGroup := NewGroupShare(t, r, f, NewGroup(t, r, owner).ID, domain.PermDefault, now(), &expiresAt)
+
+		n, err := r.Shares.DeleteExpired(ctx, expiresAt)
+		must(t, "delete expired", err)
+		if n < 2 {
+			t.Fatalf("deleted %d, want at least 2", n)
+		}
+		_, err = r.Groups.FindShareByID(ctx, expiredGroup.ID)
+		isErr(t, "find reaped group share", err, domain.ErrNotFound)
+		_, err = r.Shares.FindByID(ctx, expired.ID)
+		isErr(t, "find reaped share", err, domain.ErrNotFound)
+		_, err = r.Shares.FindByID(ctx, kept.ID)
+		must(t, "find unexpiring share", err)
+	})
+
+	t.Run("FileDeleteCascades", func(t *testing.T) {
+		r := open(t)
+		owner := NewUser(t, r, "cascade-owner-")
+		f := NewFile(t, r, owner.ID, now(), false)
+		sh := NewShare(t, r, f, NewUser(t, r, "cascade-recipient-").ID, domain.PermDefault, now(), nil)
+
+		must(t, "delete file", r.Files.Delete(ctx, f.ID))
+		_, err := r.Shares.FindByID(ctx, sh.ID)
+		isErr(t, "find share of a deleted file", err, domain.ErrNotFound)
+	})
+}
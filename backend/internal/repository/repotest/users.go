@@ -0,0 +1,119 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+)
+
+func RunUsers(t *testing.T, open func(t *testing.T) Repositories) {
+	ctx := context.Background()
+
+	t.Run("SaveAndFind", func(t *testing.T) {
+		r := open(t)
+		u := NewUser(t, r, "find-")
+
+		byID, err := r.Users.FindByID(ctx, u.ID)
+		must(t, "find by id", err)
+		if byID.Username != u.Username || byID.PasswordHash != u.PasswordHash || byID.PublicKey != u.PublicKey ||
+			string(byID.EncryptedPrivateKey) != string(u.EncryptedPrivateKey) || byID.KeyVersion != 1 || !byID.CreatedAt.Equal(u.CreatedAt) {
+			t.Fatalf("found %+v, want %+v", byID, u)
+		}
+
+		byName, err := r.Users.FindByUsername(ctx, u.Username)
+		must(t, "find by username", err)
+		if byName.ID != u.ID {
+			t.Fatalf("found %s, want %s", byName.ID, u.ID)
+		}
+
+		_, err = r.Users.FindByID(ctx, uuid.New())
+		isErr(t, "find missing user", err, domain.ErrNotFound)
+		_, err = r.Users.FindByUsername(ctx, "missing-"+uuid.NewString())
+		isErr(t, "find missing username", err, domain.ErrNotFound)
+	})
+
+	t.Run("UniqueUsername", func(t *testing.T) {
+		r := open(t)
+		u := NewUser(t, r, "unique-")
+
+		dup := u
+		dup.ID = uuid.New()
+		isErr(t, "save duplicate username", r.Users.Save(ctx, dup), domain.ErrUsernameTaken)
+	})
+
+	t.Run("SearchByUsernamePrefix", func(t *testing.T) {
+		r := open(t)
+		prefix := "s" + uuid.NewString()[:8] + "_"
+		a := NewUser(t, r, prefix+"a")
+		b := NewUser(t, r, prefix+"b")
+		c := NewUser(t, r, prefix+"c")
+		// The prefix's "_" must match literally, not as a wildcard.
+		NewUser(t, r, prefix[:len(prefix)-1]+"x")
+
+		page, err := r.Users.SearchByUsernamePrefix(ctx, prefix, "", 2)
+		must(t, "search", err)
+		equalIDs(t, "first page", userIDs(page), []uuid.UUID{a.ID, b.ID})
+		if page[0].PasswordHash != "" || page[0].EncryptedPrivateKey != nil {
+			t.Fatal("search returned private fields")
+		}
+
+		page, err = r.Users.SearchByUsernamePrefix(ctx, prefix, b.Username, 2)
+		must(t, "search", err)
+		equalIDs(t, "second page", userIDs(page), []uuid.UUID{c.ID})
+
+		page, err = r.Users.SearchByUsernamePrefix(ctx, prefix[:len(prefix)-1]+"%", "", 10)
+		must(t, "search", err)
+		equalIDs(t, "wildcard search", userIDs(page), nil)
+	})
+
+	t.Run("UpdatePasswordHash", func(t *testing.T) {
+		r := open(t)
+		u := NewUser(t, r, "pw-")
+
+		must(t, "update", r.Users.UpdatePasswordHash(ctx, u.ID, "new-hash"))
+		got, err := r.Users.FindByID(ctx, u.ID)
+		must(t, "find", err)
+		if got.PasswordHash != "new-hash" {
+			t.Fatalf("password hash = %q, want %q", got.PasswordHash, "new-hash")
+		}
+
+		isErr(t, "update missing user", r.Users.UpdatePasswordHash(ctx, uuid.New(), "hash"), domain.ErrNotFound)
+	})
+
+	t.Run("UpdateCredentials", func(t *testing.T) {
+		r := open(t)
+		u := NewUser(t, r, "creds-")
+
+		isErr(t, "no keys", r.Users.UpdateCredentials(ctx, u.ID, "new-hash", nil), domain.ErrKeyVersionMissing)
+		isErr(t, "wrong public key", r.Users.UpdateCredentials(ctx, u.ID, "new-hash", []domain.UserKey{
+			{Version: 1, PublicKey: "other-key", EncryptedPrivateKey: []byte("rewrapped")},
+		}), domain.ErrPublicKeyMismatch)
+		isErr(t, "duplicate version", r.Users.UpdateCredentials(ctx, u.ID, "new-hash", []domain.UserKey{
+			{Version: 1, PublicKey: u.PublicKey, EncryptedPrivateKey: []byte("rewrapped")},
+			{Version: 1, PublicKey: u.PublicKey, EncryptedPrivateKey: []byte("other")},
+		}), domain.ErrDuplicateKeyVersion)
+		isErr(t, "unknown version", r.Users.UpdateCredentials(ctx, u.ID, "new-hash", []domain.UserKey{
+			{Version: 1, PublicKey: u.PublicKey, EncryptedPrivateKey: []byte("rewrapped")},
+			{Version: 2, PublicKey: u.PublicKey, EncryptedPrivateKey: []byte("other")},
+		}), domain.ErrPublicKeyMismatch)
+
+		must(t, "update", r.Users.UpdateCredentials(ctx, u.ID, "new-hash", []domain.UserKey{
+			{Version: 1, PublicKey: u.PublicKey, EncryptedPrivateKey: []byte("rewrapped")},
+		}))
+		got, err := r.Users.FindByID(ctx, u.ID)
+		must(t, "find", err)
+		if got.PasswordHash != "new-hash" || string(got.EncryptedPrivateKey) != "rewrapped" {
+			t.Fatalf("credentials not updated: %+v", got)
+		}
+	})
+}
+
+func userIDs(users []domain.User) []uuid.UUID {
+	ids := make([]uuid.UUID, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	return ids
+}
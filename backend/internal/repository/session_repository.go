@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const sessionColumns = `id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row pgx.Row) (domain.Session, error) {
+	var s domain.Session
+	err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RevokedAt)
+	return s, notFound(err, "session")
+}
+
+type SessionRepository interface {
+	Save(ctx context.Context, session domain.Session, token domain.RefreshToken) error
+	FindByID(ctx context.Context, id uuid.UUID) (domain.Session, error)
+	FindByRefreshToken(ctx context.Context, tokenHash []byte) (domain.Session, error)
+	FindActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]domain.Session, error)
+	Rotate(ctx context.Context, oldHash []byte, next domain.RefreshToken) (domain.Session, error)
+	Revoke(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	RevokeAllForUser(ctx context.Context, userID, exceptID uuid.UUID, now time.Time) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type sessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Save(ctx context.Context, s domain.Session, token domain.RefreshToken) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO sessions (`+sessionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, s.ID, s.UserID, s.UserAgent, s.IPAddress, s.CreatedAt, s.LastUsedAt, s.ExpiresAt, s.RevokedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (token_hash, session_id, created_at)
+		VALUES ($1, $2, $3)
+	`, token.TokenHash, s.ID, token.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *sessionRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.Session, error) {
+	return scanSession(r.db.QueryRow(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions WHERE id = $1
+	`, id))
+}
+
+func (r *sessionRepository) FindByRefreshToken(ctx context.Context, tokenHash []byte) (domain.Session, error) {
+	return scanSession(r.db.QueryRow(ctx, `
+		SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_used_at, s.expires_at, s.revoked_at
+		FROM sessions s
+		JOIN refresh_tokens t ON t.session_id = s.id
+		WHERE t.token_hash = $1
+	`, tokenHash))
+}
+
+func (r *sessionRepository) FindActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]domain.Session, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC
+	`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Rotate marks oldHash as used and stores next in its place. Presenting a
+// token that was already used returns the owning session together with
+// domain.ErrRefreshTokenReused so the caller can revoke it.
+func (r *sessionRepository) Rotate(ctx context.Context, oldHash []byte, next domain.RefreshToken) (domain.Session, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return domain.Session{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var sessionID uuid.UUID
+	var usedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT session_id, used_at FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, oldHash).Scan(&sessionID, &usedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Session{}, domain.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	session, err := scanSession(tx.QueryRow(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions WHERE id = $1
+		FOR UPDATE
+	`, sessionID))
+	if err != nil {
+		return domain.Session{}, err
+	}
+
+	if usedAt != nil {
+		return session, domain.ErrRefreshTokenReused
+	}
+	if !session.Active(next.CreatedAt) {
+		return session, domain.ErrSessionRevoked
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET used_at = $2 WHERE token_hash = $1
+	`, oldHash, next.CreatedAt); err != nil {
+		return domain.Session{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (token_hash, session_id, created_at)
+		VALUES ($1, $2, $3)
+	`, next.TokenHash, sessionID, next.CreatedAt); err != nil {
+		return domain.Session{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE sessions SET last_used_at = $2 WHERE id = $1
+	`, sessionID, next.CreatedAt); err != nil {
+		return domain.Session{}, err
+	}
+	session.LastUsedAt = next.CreatedAt
+
+	return session, tx.Commit(ctx)
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE sessions SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID, now)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("session")
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeAllForUser(ctx context.Context, userID, exceptID uuid.UUID, now time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE sessions SET revoked_at = $3
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`, userID, exceptID, now)
+	return err
+}
+
+// DeleteExpired removes every session whose expiry has passed, revoked or
+// not. Their refresh tokens go with them through ON DELETE CASCADE; used
+// tokens of live sessions stay so a replay is still detected.
+func (r *sessionRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM sessions WHERE expires_at <= $1
+	`, now)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
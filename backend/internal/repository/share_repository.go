@@ -2,19 +2,96 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"strings"
+	"time"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const shareColumns = `id, file_id, recipient_id, wrapped_key, key_version, created_at, expires_at, permissions, granted_by, grantor_chain`
+
+// shareListingSources is the union of user, group and folder shares that
+// listings select from, with the columns scanShareListing reads. Each
+// branch is filtered by the WHERE clause substituted for its placeholder.
+const shareListingSources = `
+	SELECT 'user' AS kind, s.id, s.file_id, NULL::uuid AS folder_id, s.recipient_id, NULL::uuid AS group_id,
+	       s.wrapped_key, s.key_version, s.created_at, s.expires_at, s.permissions, s.granted_by, s.grantor_chain,
+	       f.filename, u.username AS recipient_username, '' AS group_name
+	FROM shares s
+	JOIN files f ON f.id = s.file_id
+	JOIN users u ON u.id = s.recipient_id
+	WHERE {user}
+	UNION ALL
+	SELECT 'group', gs.id, gs.file_id, NULL, NULL, gs.group_id,
+	       gs.wrapped_key, gs.key_version, gs.created_at, gs.expires_at, gs.permissions, gs.granted_by, ARRAY[]::uuid[],
+	       f.filename, '', g.name
+	FROM group_shares gs
+	JOIN files f ON f.id = gs.file_id
+	JOIN groups g ON g.id = gs.group_id
+	WHERE {group}
+	UNION ALL
+	SELECT 'folder', fs.id, NULL, fs.folder_id, fs.recipient_id, NULL,
+	       fs.wrapped_key, fs.key_version, fs.created_at, fs.expires_at, fs.permissions, fs.granted_by, ARRAY[]::uuid[],
+	       '', u.username, ''
+	FROM folder_shares fs
+	JOIN folders fo ON fo.id = fs.folder_id AND fo.deleted_at IS NULL
+	JOIN users u ON u.id = fs.recipient_id
+	WHERE {folder}
+`
+
+// shareListingQuery pages through shareListingSources newest first. The
+// cursor is $2 and $3 and the limit $4.
+func shareListingQuery(prefix, user, group, folder string) string {
+	sources := strings.NewReplacer("{user}", user, "{group}", group, "{folder}", folder).Replace(shareListingSources)
+	return prefix + `
+		SELECT kind, id, file_id, folder_id, recipient_id, group_id, wrapped_key, key_version, created_at, expires_at,
+		       permissions, granted_by, grantor_chain, filename, recipient_username, group_name
+		FROM (` + sources + `) l
+		WHERE ($2::timestamptz IS NULL OR (l.created_at, l.id) < ($2, $3))
+		ORDER BY l.created_at DESC, l.id DESC
+		LIMIT $4
+	`
+}
+
+func cursorArgs(after domain.ShareCursor) (*time.Time, uuid.UUID) {
+	if after.IsZero() {
+		return nil, uuid.Nil
+	}
+	return &after.CreatedAt, after.ID
+}
+
+func scanShare(row pgx.Row) (domain.Share, error) {
+	var s domain.Share
+	err := row.Scan(&s.ID, &s.FileID, &s.RecipientID, &s.WrappedKey, &s.KeyVersion, &s.CreatedAt, &s.ExpiresAt, &s.Permissions, &s.GrantedBy, &s.GrantorChain)
+	return s, notFound(err, "share")
+}
+
+func scanShareListing(row pgx.Row) (domain.ShareListing, error) {
+	var l domain.ShareListing
+	var fileID, folderID, recipientID, groupID uuid.NullUUID
+	s := &l.Share
+	err := row.Scan(&l.Kind, &s.ID, &fileID, &folderID, &recipientID, &groupID, &s.WrappedKey, &s.KeyVersion, &s.CreatedAt, &s.ExpiresAt,
+		&s.Permissions, &s.GrantedBy, &s.GrantorChain, &l.Filename, &l.RecipientUsername, &l.GroupName)
+	s.FileID, l.FolderID, s.RecipientID, l.GroupID = fileID.UUID, folderID.UUID, recipientID.UUID, groupID.UUID
+	return l, err
+}
+
 type ShareRepository interface {
 	Save(ctx context.Context, share domain.Share) error
-	FindByID(ctx context.Context,  shareID uuid.UUID) (domain.Share, error)
-	FindByRecipient(ctx context.Context, recipientID uuid.UUID) ([]domain.Share, error)
+	FindByID(ctx context.Context, shareID uuid.UUID) (domain.Share, error)
+	FindByRecipient(ctx context.Context, recipientID uuid.UUID, now time.Time) ([]domain.Share, error)
 	FindByFileAndRecipient(ctx context.Context, fileID, recipientID uuid.UUID) (domain.Share, error)
+	FindOutgoingByOwner(ctx context.Context, ownerID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error)
+	FindListingsByFile(ctx context.Context, fileID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error)
+	FindStaleByRecipient(ctx context.Context, recipientID uuid.UUID, currentVersion int) ([]domain.Share, error)
+	UpdateWrappedKey(ctx context.Context, shareID, recipientID uuid.UUID, wrappedKey []byte, keyVersion int) error
+	UpdateExpiry(ctx context.Context, shareID uuid.UUID, expiresAt *time.Time) error
+	UpdatePermissions(ctx context.Context, shareID uuid.UUID, perms domain.SharePermission) error
 	Delete(ctx context.Context, shareID uuid.UUID) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
 }
 
 type shareRepository struct {
@@ -25,63 +102,165 @@ func NewShareRepository(db *pgxpool.Pool) ShareRepository {
 	return &shareRepository{db: db}
 }
 
+// Save defaults a zero KeyVersion to the recipient's current key version.
 func (r *shareRepository) Save(ctx context.Context, share domain.Share) error {
 	_, err := r.db.Exec(ctx, `
-		INSERT INTO shares (id, file_id, recipient_id, wrapped_key, created_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, share.ID, share.FileID, share.RecipientID, share.WrappedKey, share.CreatedAt)
+		INSERT INTO shares (`+shareColumns+`)
+		VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5::integer, 0), (SELECT key_version FROM users WHERE id = $3)), $6, $7, $8, $9, $10)
+	`, share.ID, share.FileID, share.RecipientID, share.WrappedKey, share.KeyVersion, share.CreatedAt, share.ExpiresAt, share.Permissions, share.GrantedBy, share.GrantorChain)
 
-	return err
+	return uniqueViolation(err, domain.ErrAlreadyShared)
 }
 
 func (r *shareRepository) FindByID(ctx context.Context, shareID uuid.UUID) (domain.Share, error) {
-	var s domain.Share
-	err := r.db.QueryRow(ctx, `
-		SELECT id, file_id, recipient_id, wrapped_key, created_at
+	return scanShare(r.db.QueryRow(ctx, `
+		SELECT `+shareColumns+`
 		FROM shares
 		WHERE id = $1
-	`, shareID).Scan(&s.ID, &s.FileID, &s.RecipientID, &s.WrappedKey, &s.CreatedAt)
-
-	return s, err
+	`, shareID))
 }
 
-func (r *shareRepository) FindByRecipient(ctx context.Context, recipientID uuid.UUID) ([]domain.Share, error) {
-	rows, err := r.db.Query(ctx, `
-		SELECT id, file_id, recipient_id, wrapped_key, created_at
-		FROM shares WHERE recipient_id = $1
+// FindByRecipient omits shares expired as of now that the reaper has not
+// removed yet and shares of files in their owner's trash.
+func (r *shareRepository) FindByRecipient(ctx context.Context, recipientID uuid.UUID, now time.Time) ([]domain.Share, error) {
+	return r.query(ctx, `
+		SELECT `+shareColumns+`
+		FROM shares
+		WHERE recipient_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		  AND file_id IN (SELECT id FROM files WHERE deleted_at IS NULL)
 		ORDER BY created_at DESC
-	`, recipientID)
+	`, recipientID, now)
+}
+
+func (r *shareRepository) FindByFileAndRecipient(ctx context.Context, fileID, recipientID uuid.UUID) (domain.Share, error) {
+	return scanShare(r.db.QueryRow(ctx, `
+		SELECT `+shareColumns+`
+		FROM shares WHERE file_id = $1 AND recipient_id = $2
+	`, fileID, recipientID))
+}
+
+// FindOutgoingByOwner pages through every user and group share on files
+// owned by ownerID and every share of their folders, newest first. after is
+// the cursor of the last listing on the previous page, or the zero cursor
+// for the first page.
+func (r *shareRepository) FindOutgoingByOwner(ctx context.Context, ownerID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error) {
+	createdAt, id := cursorArgs(after)
+	return r.queryListings(ctx, shareListingQuery("",
+		`f.owner_id = $1 AND f.deleted_at IS NULL`,
+		`f.owner_id = $1 AND f.deleted_at IS NULL`,
+		`fo.owner_id = $1`,
+	), ownerID, createdAt, id, limit)
+}
+
+// FindListingsByFile pages through everyone with access to the file: its
+// user and group shares and the shares of every folder above it.
+func (r *shareRepository) FindListingsByFile(ctx context.Context, fileID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error) {
+	createdAt, id := cursorArgs(after)
+	return r.queryListings(ctx, shareListingQuery(`
+		WITH RECURSIVE ancestors AS (
+			SELECT folder_id AS id FROM files WHERE id = $1 AND folder_id IS NOT NULL
+			UNION
+			SELECT fo.parent_id FROM folders fo JOIN ancestors a ON fo.id = a.id WHERE fo.parent_id IS NOT NULL
+		)`,
+		`s.file_id = $1`,
+		`gs.file_id = $1`,
+		`fs.folder_id IN (SELECT id FROM ancestors)`,
+	), fileID, createdAt, id, limit)
+}
+
+func (r *shareRepository) FindStaleByRecipient(ctx context.Context, recipientID uuid.UUID, currentVersion int) ([]domain.Share, error) {
+	return r.query(ctx, `
+		SELECT `+shareColumns+`
+		FROM shares WHERE recipient_id = $1 AND key_version < $2
+		ORDER BY created_at
+	`, recipientID, currentVersion)
+}
+
+func (r *shareRepository) UpdateWrappedKey(ctx context.Context, shareID, recipientID uuid.UUID, wrappedKey []byte, keyVersion int) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE shares SET wrapped_key = $3, key_version = $4
+		WHERE id = $1 AND recipient_id = $2
+	`, shareID, recipientID, wrappedKey, keyVersion)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("share")
+	}
+	return nil
+}
 
-	var shares []domain.Share
-	for rows.Next() {
-		var s domain.Share
-		if err := rows.Scan(&s.ID, &s.FileID, &s.RecipientID, &s.WrappedKey, &s.CreatedAt); err != nil {
-			return nil, err
-		}
+// UpdateExpiry sets the share's expiry and pulls in the expiry of every
+// reshare granted below its recipient that would otherwise outlive it.
+func (r *shareRepository) UpdateExpiry(ctx context.Context, shareID uuid.UUID, expiresAt *time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
 
-		shares = append(shares, s)
+	var fileID, recipientID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		UPDATE shares SET expires_at = $2 WHERE id = $1
+		RETURNING file_id, recipient_id
+	`, shareID, expiresAt).Scan(&fileID, &recipientID)
+	if err != nil {
+		return notFound(err, "share")
 	}
 
-	return shares, rows.Err()
+	if expiresAt != nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE shares SET expires_at = $3
+			WHERE file_id = $1 AND $2 = ANY(grantor_chain)
+			  AND (expires_at IS NULL OR expires_at > $3)
+		`, fileID, recipientID, expiresAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *shareRepository) FindByFileAndRecipient(ctx context.Context, fileID, recipientID uuid.UUID) (domain.Share, error) {
-	var s domain.Share
-	err := r.db.QueryRow(ctx, `
-		SELECT id, file_id, recipient_id, wrapped_key, created_at
-		FROM shares WHERE file_id = $1 AND recipient_id = $2	
-	`, fileID, recipientID).Scan(&s.ID, &s.FileID, &s.RecipientID, &s.WrappedKey, &s.CreatedAt)
+// UpdatePermissions replaces the share's permissions and takes away what it
+// lost from every reshare granted below its recipient. Reshares are only
+// ever narrowed: permissions added to the share are not passed down.
+func (r *shareRepository) UpdatePermissions(ctx context.Context, shareID uuid.UUID, perms domain.SharePermission) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var fileID, recipientID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		UPDATE shares SET permissions = $2 WHERE id = $1
+		RETURNING file_id, recipient_id
+	`, shareID, perms).Scan(&fileID, &recipientID)
+	if err != nil {
+		return notFound(err, "share")
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE shares SET permissions = permissions & $3
+		WHERE file_id = $1 AND $2 = ANY(grantor_chain)
+	`, fileID, recipientID, perms)
+	if err != nil {
+		return err
+	}
 
-	return s, err
+	return tx.Commit(ctx)
 }
 
+// Delete revokes the share together with every reshare granted below its
+// recipient, whose grantor chains pass through them.
 func (r *shareRepository) Delete(ctx context.Context, shareID uuid.UUID) error {
 	cmd, err := r.db.Exec(ctx, `
-		DELETE FROM shares WHERE id = $1
+		WITH target AS (
+			SELECT file_id, recipient_id FROM shares WHERE id = $1
+		)
+		DELETE FROM shares s USING target t
+		WHERE s.id = $1 OR (s.file_id = t.file_id AND t.recipient_id = ANY(s.grantor_chain))
 	`, shareID)
 
 	if err != nil {
@@ -89,7 +268,71 @@ func (r *shareRepository) Delete(ctx context.Context, shareID uuid.UUID) error {
 	}
 
 	if cmd.RowsAffected() == 0 {
-		return fmt.Errorf("share not found")
+		return domain.NotFound("share")
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// DeleteExpired removes every user and group share whose expiry has passed
+// and records a share_events row for each in the same statement.
+func (r *shareRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	cmd, err := r.db.Exec(ctx, `
+		WITH expired AS (
+			DELETE FROM shares
+			WHERE expires_at IS NOT NULL AND expires_at <= $1
+			RETURNING id, file_id, recipient_id
+		), expired_group AS (
+			DELETE FROM group_shares
+			WHERE expires_at IS NOT NULL AND expires_at <= $1
+			RETURNING id, file_id, group_id
+		)
+		INSERT INTO share_events (share_id, file_id, recipient_id, group_id, event, created_at)
+		SELECT id, file_id, recipient_id, NULL::uuid, $2::text, $1::timestamptz FROM expired
+		UNION ALL
+		SELECT id, file_id, NULL, group_id, $2, $1 FROM expired_group
+	`, now, domain.ShareEventExpired)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (r *shareRepository) query(ctx context.Context, sql string, args ...any) ([]domain.Share, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []domain.Share
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		shares = append(shares, s)
+	}
+
+	return shares, rows.Err()
+}
+
+func (r *shareRepository) queryListings(ctx context.Context, sql string, args ...any) ([]domain.ShareListing, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var listings []domain.ShareListing
+	for rows.Next() {
+		l, err := scanShareListing(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		listings = append(listings, l)
+	}
+
+	return listings, rows.Err()
+}
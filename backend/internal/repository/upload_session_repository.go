@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const uploadSessionColumns = `id, owner_id, file_id, filename, mime_type, encrypted_metadata, size, upload_offset, part_count, iv, encrypted_key, format_version, chunk_size, storage_upload_id, expires_at, created_at`
+
+func scanUploadSession(row pgx.Row) (domain.UploadSession, error) {
+	var s domain.UploadSession
+	err := row.Scan(&s.ID, &s.OwnerID, &s.FileID, &s.Filename, &s.MimeType, &s.EncryptedMetadata, &s.Size, &s.Offset, &s.PartCount, &s.IV, &s.EncryptedKey, &s.FormatVersion, &s.ChunkSize, &s.StorageUploadID, &s.ExpiresAt, &s.CreatedAt)
+	return s, notFound(err, "upload session")
+}
+
+type UploadSessionRepository interface {
+	Save(ctx context.Context, session domain.UploadSession) error
+	FindByID(ctx context.Context, id uuid.UUID) (domain.UploadSession, error)
+	FindExpired(ctx context.Context, before time.Time, limit int) ([]domain.UploadSession, error)
+	Lease(ctx context.Context, id, lease uuid.UUID, now, until time.Time) (domain.UploadSession, error)
+	ReleaseLease(ctx context.Context, id, lease uuid.UUID) error
+	AddPart(ctx context.Context, part domain.UploadPart, lease uuid.UUID, expiresAt time.Time) error
+	ListParts(ctx context.Context, sessionID uuid.UUID) ([]domain.UploadPart, error)
+	Finish(ctx context.Context, id, lease uuid.UUID, file domain.File) error
+	Delete(ctx context.Context, id, lease uuid.UUID) error
+}
+
+type uploadSessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUploadSessionRepository(db *pgxpool.Pool) UploadSessionRepository {
+	return &uploadSessionRepository{db: db}
+}
+
+// Save stores a new session and reserves the whole upload against the
+// owner's quota; finishing hands the bytes to the file.
+func (r *uploadSessionRepository) Save(ctx context.Context, s domain.UploadSession) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := reserveUsage(ctx, tx, s.OwnerID, s.Size); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO upload_sessions (`+uploadSessionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`, s.ID, s.OwnerID, s.FileID, s.Filename, s.MimeType, s.EncryptedMetadata, s.Size, s.Offset, s.PartCount, s.IV, s.EncryptedKey, s.FormatVersion, s.ChunkSize, s.StorageUploadID, s.ExpiresAt, s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *uploadSessionRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.UploadSession, error) {
+	return scanUploadSession(r.db.QueryRow(ctx, `
+		SELECT `+uploadSessionColumns+`
+		FROM upload_sessions WHERE id = $1
+	`, id))
+}
+
+func (r *uploadSessionRepository) FindExpired(ctx context.Context, before time.Time, limit int) ([]domain.UploadSession, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+uploadSessionColumns+`
+		FROM upload_sessions
+		WHERE expires_at < $1 AND (write_lease_until IS NULL OR write_lease_until <= $1)
+		ORDER BY expires_at
+		LIMIT $2
+	`, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.UploadSession
+	for rows.Next() {
+		s, err := scanUploadSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Lease gives the caller exclusive use of the session until the lease runs
+// out, and returns the session as it stands under the lease. Writing a
+// chunk, finalizing and discarding all hold it, so two requests never
+// upload the same part number or complete an upload being aborted.
+func (r *uploadSessionRepository) Lease(ctx context.Context, id, lease uuid.UUID, now, until time.Time) (domain.UploadSession, error) {
+	s, err := scanUploadSession(r.db.QueryRow(ctx, `
+		UPDATE upload_sessions
+		SET write_lease = $2, write_lease_until = $4
+		WHERE id = $1 AND (write_lease_until IS NULL OR write_lease_until <= $3)
+		RETURNING `+uploadSessionColumns+`
+	`, id, lease, now, until))
+	if !errors.Is(err, domain.ErrNotFound) {
+		return s, err
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM upload_sessions WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return domain.UploadSession{}, err
+	}
+	if exists {
+		return domain.UploadSession{}, domain.ErrUploadBusy
+	}
+	return domain.UploadSession{}, domain.NotFound("upload session")
+}
+
+func (r *uploadSessionRepository) ReleaseLease(ctx context.Context, id, lease uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE upload_sessions
+		SET write_lease = NULL, write_lease_until = NULL
+		WHERE id = $1 AND write_lease = $2
+	`, id, lease)
+	return err
+}
+
+// AddPart records an uploaded part, advances the session offset and gives
+// up the lease, but only while the caller still holds it.
+func (r *uploadSessionRepository) AddPart(ctx context.Context, part domain.UploadPart, lease uuid.UUID, expiresAt time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx, `
+		UPDATE upload_sessions
+		SET upload_offset = upload_offset + $2, part_count = $3, expires_at = $4,
+		    write_lease = NULL, write_lease_until = NULL
+		WHERE id = $1 AND write_lease = $5 AND part_count = $3 - 1
+	`, part.SessionID, part.Size, part.PartNumber, expiresAt, lease)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrUploadBusy
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO upload_session_parts (session_id, part_number, etag, size)
+		VALUES ($1, $2, $3, $4)
+	`, part.SessionID, part.PartNumber, part.ETag, part.Size)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *uploadSessionRepository) ListParts(ctx context.Context, sessionID uuid.UUID) ([]domain.UploadPart, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT session_id, part_number, etag, size
+		FROM upload_session_parts WHERE session_id = $1
+		ORDER BY part_number
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []domain.UploadPart
+	for rows.Next() {
+		var p domain.UploadPart
+		if err := rows.Scan(&p.SessionID, &p.PartNumber, &p.ETag, &p.Size); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+
+	return parts, rows.Err()
+}
+
+// Finish turns the session into its committed file in one transaction, so
+// the usage reserved for the session passes to the file unchanged. The
+// object must already be complete.
+func (r *uploadSessionRepository) Finish(ctx context.Context, id, lease uuid.UUID, file domain.File) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := deleteLeased(ctx, tx, id, lease); err != nil {
+		return err
+	}
+	if err := insertFile(ctx, tx, file, domain.FileStateCommitted); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete drops a session the caller holds the lease on and gives the usage
+// reserved for it back.
+func (r *uploadSessionRepository) Delete(ctx context.Context, id, lease uuid.UUID) error {
+	cmd, err := r.db.Exec(ctx, `
+		WITH deleted AS (
+			DELETE FROM upload_sessions WHERE id = $1 AND write_lease = $2
+			RETURNING owner_id, size
+		)
+		UPDATE users SET used_bytes = GREATEST(used_bytes - deleted.size, 0)
+		FROM deleted
+		WHERE users.id = deleted.owner_id
+	`, id, lease)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("upload session")
+	}
+	return nil
+}
+
+func deleteLeased(ctx context.Context, tx pgx.Tx, id, lease uuid.UUID) error {
+	cmd, err := tx.Exec(ctx, `
+		DELETE FROM upload_sessions WHERE id = $1 AND write_lease = $2
+	`, id, lease)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("upload session")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// usedBytesSQL is the usage of the user aliased u, derived from the rows
+// that hold storage: every file version and every open upload session.
+const usedBytesSQL = `
+	COALESCE((SELECT SUM(v.size) FROM file_versions v JOIN files f ON f.id = v.file_id WHERE f.owner_id = u.id), 0)
+	+ COALESCE((SELECT SUM(s.size) FROM upload_sessions s WHERE s.owner_id = u.id), 0)`
+
+type UsageRepository interface {
+	Get(ctx context.Context, userID uuid.UUID) (domain.Usage, error)
+	Recompute(ctx context.Context) (int, error)
+}
+
+type usageRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUsageRepository(db *pgxpool.Pool) UsageRepository {
+	return &usageRepository{db: db}
+}
+
+func (r *usageRepository) Get(ctx context.Context, userID uuid.UUID) (domain.Usage, error) {
+	var u domain.Usage
+	err := r.db.QueryRow(ctx, `
+		SELECT u.id, u.plan, u.used_bytes, COALESCE(u.quota_bytes, p.quota_bytes)
+		FROM users u JOIN plans p ON p.name = u.plan
+		WHERE u.id = $1
+	`, userID).Scan(&u.UserID, &u.Plan, &u.UsedBytes, &u.QuotaBytes)
+	return u, notFound(err, "user")
+}
+
+// Recompute rebuilds every user's usage from the stored rows and returns
+// how many users had drifted.
+func (r *usageRepository) Recompute(ctx context.Context) (int, error) {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE users u SET used_bytes = actual.bytes
+		FROM (SELECT u.id, `+usedBytesSQL+` AS bytes FROM users u) actual
+		WHERE u.id = actual.id AND u.used_bytes <> actual.bytes
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return int(cmd.RowsAffected()), nil
+}
+
+// reserveUsage adds bytes to the user's usage inside tx if it stays within
+// their quota. Repositories call it in the transaction that inserts the rows
+// holding the bytes, so the reservation commits or rolls back with them.
+func reserveUsage(ctx context.Context, tx pgx.Tx, userID uuid.UUID, bytes int64) error {
+	var used, quota int64
+	err := tx.QueryRow(ctx, `
+		SELECT u.used_bytes, COALESCE(u.quota_bytes, p.quota_bytes)
+		FROM users u JOIN plans p ON p.name = u.plan
+		WHERE u.id = $1
+		FOR UPDATE OF u
+	`, userID).Scan(&used, &quota)
+	if err != nil {
+		return notFound(err, "user")
+	}
+	if bytes > quota {
+		return domain.ErrFileTooLarge
+	}
+	if used+bytes > quota {
+		return domain.ErrQuotaExceeded
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET used_bytes = used_bytes + $2 WHERE id = $1
+	`, userID, bytes)
+	return err
+}
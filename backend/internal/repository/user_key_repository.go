@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserKeyRepository interface {
+	Rotate(ctx context.Context, key domain.UserKey, entry domain.KeyLogEntry, nodes []domain.KeyLogNode) error
+	ApplyRewrap(ctx context.Context, userID uuid.UUID, keyVersion int, items []domain.RewrapItem) error
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]domain.UserKey, error)
+	FindVersion(ctx context.Context, userID uuid.UUID, version int) (domain.UserKey, error)
+}
+
+type userKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserKeyRepository(db *pgxpool.Pool) UserKeyRepository {
+	return &userKeyRepository{db: db}
+}
+
+// Rotate retires the user's current keypair, makes key current and logs it
+// as entry in one transaction, so the key log never names a key that did
+// not become current. It fails if key.Version is not exactly one past the
+// current version, which stops two concurrent rotations from both
+// succeeding, and with domain.ErrKeyLogIndexTaken if another writer took
+// entry.Index.
+func (r *userKeyRepository) Rotate(ctx context.Context, key domain.UserKey, entry domain.KeyLogEntry, nodes []domain.KeyLogNode) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current int
+	if err := tx.QueryRow(ctx, `
+		SELECT key_version FROM users WHERE id = $1 FOR UPDATE
+	`, key.UserID).Scan(&current); err != nil {
+		return notFound(err, "user")
+	}
+	if current != key.Version-1 {
+		return domain.Conflict("key_version_conflict", "key version conflict")
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE user_keys SET retired_at = $2
+		WHERE user_id = $1 AND retired_at IS NULL
+	`, key.UserID, key.CreatedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_keys (user_id, version, public_key, encrypted_private_key, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, key.UserID, key.Version, key.PublicKey, key.EncryptedPrivateKey, key.CreatedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET public_key = $2, encrypted_private_key = $3, key_version = $4
+		WHERE id = $1
+	`, key.UserID, key.PublicKey, key.EncryptedPrivateKey, key.Version); err != nil {
+		return err
+	}
+
+	if err := insertKeyLogEntry(ctx, tx, entry, nodes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ApplyRewrap stores every rewrapped key or none of them. The user's row is
+// locked while it runs, so a rotation cannot slip in between the version
+// check and the updates.
+func (r *userKeyRepository) ApplyRewrap(ctx context.Context, userID uuid.UUID, keyVersion int, items []domain.RewrapItem) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current int
+	if err := tx.QueryRow(ctx, `
+		SELECT key_version FROM users WHERE id = $1 FOR SHARE
+	`, userID).Scan(&current); err != nil {
+		return notFound(err, "user")
+	}
+	if current != keyVersion {
+		return domain.Conflict("key_version_conflict", "key version conflict")
+	}
+
+	for _, item := range items {
+		var sql, resource string
+		switch item.Kind {
+		case domain.RewrapKindShare:
+			sql = `UPDATE shares SET wrapped_key = $3, key_version = $4 WHERE id = $1 AND recipient_id = $2`
+			resource = "share"
+		case domain.RewrapKindFile:
+			sql = `UPDATE files SET encrypted_key = $3, key_version = $4 WHERE id = $1 AND owner_id = $2`
+			resource = "file"
+		default:
+			return domain.Invalid("unknown_rewrap_kind", "unknown rewrap kind "+item.Kind)
+		}
+
+		cmd, err := tx.Exec(ctx, sql, item.ID, userID, item.WrappedKey, keyVersion)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return domain.NotFound(resource)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *userKeyRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]domain.UserKey, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT user_id, version, public_key, encrypted_private_key, created_at, retired_at
+		FROM user_keys WHERE user_id = $1
+		ORDER BY version DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []domain.UserKey
+	for rows.Next() {
+		var k domain.UserKey
+		if err := rows.Scan(&k.UserID, &k.Version, &k.PublicKey, &k.EncryptedPrivateKey, &k.CreatedAt, &k.RetiredAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *userKeyRepository) FindVersion(ctx context.Context, userID uuid.UUID, version int) (domain.UserKey, error) {
+	var k domain.UserKey
+	err := r.db.QueryRow(ctx, `
+		SELECT user_id, version, public_key, encrypted_private_key, created_at, retired_at
+		FROM user_keys WHERE user_id = $1 AND version = $2
+	`, userID, version).Scan(&k.UserID, &k.Version, &k.PublicKey, &k.EncryptedPrivateKey, &k.CreatedAt, &k.RetiredAt)
+
+	return k, notFound(err, "key version")
+}
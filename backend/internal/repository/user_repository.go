@@ -2,16 +2,29 @@ package repository
 
 import (
 	"context"
+	"strings"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const userColumns = `id, username, password_hash, public_key, encrypted_private_key, key_version, created_at`
+
+func scanUser(row pgx.Row) (domain.User, error) {
+	var u domain.User
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.PublicKey, &u.EncryptedPrivateKey, &u.KeyVersion, &u.CreatedAt)
+	return u, notFound(err, "user")
+}
+
 type UserRepository interface {
 	Save(ctx context.Context, user domain.User) error
 	FindByUsername(ctx context.Context, username string) (domain.User, error)
 	FindByID(ctx context.Context, id uuid.UUID) (domain.User, error)
+	SearchByUsernamePrefix(ctx context.Context, prefix, after string, limit int) ([]domain.User, error)
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error
+	UpdateCredentials(ctx context.Context, id uuid.UUID, passwordHash string, keys []domain.UserKey) error
 }
 
 type userRepository struct {
@@ -22,31 +35,166 @@ func NewUserRepository(db *pgxpool.Pool) UserRepository {
 	return &userRepository{db: db}
 }
 
+// Save stores the user together with their initial keypair version.
 func (r *userRepository) Save(ctx context.Context, user domain.User) error {
-	_, err := r.db.Exec(ctx, `
-		INSERT INTO users (id, username, password_hash, public_key, encrypted_private_key, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, user.ID, user.Username, user.PasswordHash, user.PublicKey, user.EncryptedPrivateKey, user.CreatedAt)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO users (`+userColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, user.ID, user.Username, user.PasswordHash, user.PublicKey, user.EncryptedPrivateKey, user.KeyVersion, user.CreatedAt)
+	if err != nil {
+		return uniqueViolation(err, domain.ErrUsernameTaken)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_keys (user_id, version, public_key, encrypted_private_key, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, user.ID, user.KeyVersion, user.PublicKey, user.EncryptedPrivateKey, user.CreatedAt)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit(ctx)
 }
 
 func (r *userRepository) FindByUsername(ctx context.Context, username string) (domain.User, error) {
-	var u domain.User
-	err := r.db.QueryRow(ctx, `
-		SELECT id, username, password_hash, public_key, encrypted_private_key, created_at 
+	return scanUser(r.db.QueryRow(ctx, `
+		SELECT `+userColumns+`
 		FROM users WHERE username=$1
-	`, username).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.PublicKey, &u.EncryptedPrivateKey, &u.CreatedAt)
-
-	return u, err
+	`, username))
 }
 
 func (r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (domain.User, error) {
-	var u domain.User
-	err := r.db.QueryRow(ctx, `
-		SELECT id, username, password_hash, public_key, encrypted_private_key, created_at 
+	return scanUser(r.db.QueryRow(ctx, `
+		SELECT `+userColumns+`
 		FROM users WHERE id=$1
-	`, id).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.PublicKey, &u.EncryptedPrivateKey, &u.CreatedAt)
+	`, id))
+}
+
+func (r *userRepository) SearchByUsernamePrefix(ctx context.Context, prefix, after string, limit int) ([]domain.User, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, username, public_key, key_version, created_at
+		FROM users
+		WHERE username LIKE $1 || '%' AND username > $2
+		ORDER BY username
+		LIMIT $3
+	`, escaped, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []domain.User
+	for rows.Next() {
+		var u domain.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.PublicKey, &u.KeyVersion, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE users SET password_hash = $2 WHERE id = $1
+	`, id, passwordHash)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.NotFound("user")
+	}
+	return nil
+}
+
+// UpdateCredentials replaces the password hash and every version of the
+// user's password-encrypted private key in one transaction. The versions in
+// keys must be exactly the stored ones, each given once with its stored
+// public key.
+func (r *userRepository) UpdateCredentials(ctx context.Context, id uuid.UUID, passwordHash string, keys []domain.UserKey) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current int
+	err = tx.QueryRow(ctx, `
+		SELECT key_version FROM users WHERE id = $1 FOR UPDATE
+	`, id).Scan(&current)
+	if err != nil {
+		return notFound(err, "user")
+	}
+
+	var stored []int
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(version), '{}') FROM user_keys WHERE user_id = $1
+	`, id).Scan(&stored)
+	if err != nil {
+		return err
+	}
+	if err := matchKeyVersions(stored, keys); err != nil {
+		return err
+	}
+
+	var currentKey []byte
+	for _, k := range keys {
+		cmd, err := tx.Exec(ctx, `
+			UPDATE user_keys SET encrypted_private_key = $4
+			WHERE user_id = $1 AND version = $2 AND public_key = $3
+		`, id, k.Version, k.PublicKey, k.EncryptedPrivateKey)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return domain.ErrPublicKeyMismatch
+		}
+		if k.Version == current {
+			currentKey = k.EncryptedPrivateKey
+		}
+	}
+	if currentKey == nil {
+		return domain.ErrKeyVersionMissing
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET password_hash = $2, encrypted_private_key = $3 WHERE id = $1
+	`, id, passwordHash, currentKey)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
 
-	return u, err
+// matchKeyVersions checks that keys holds every stored version exactly once
+// and nothing else.
+func matchKeyVersions(stored []int, keys []domain.UserKey) error {
+	want := make(map[int]bool, len(stored))
+	for _, v := range stored {
+		want[v] = true
+	}
+	seen := make(map[int]bool, len(keys))
+	for _, k := range keys {
+		if seen[k.Version] {
+			return domain.ErrDuplicateKeyVersion
+		}
+		seen[k.Version] = true
+		if !want[k.Version] {
+			return domain.ErrPublicKeyMismatch
+		}
+	}
+	if len(seen) != len(want) {
+		return domain.ErrKeyVersionMissing
+	}
+	return nil
 }
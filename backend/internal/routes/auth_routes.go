@@ -5,11 +5,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRoutes(rg *gin.RouterGroup, userHandler *handler.UserHandler) {
+func AuthRoutes(rg *gin.RouterGroup, userHandler *handler.UserHandler, authMiddleware gin.HandlerFunc) {
 	users := rg.Group("/auth")
 	{
 		users.POST("/register", userHandler.Register)
 		users.POST("/login", userHandler.Login)
+		users.POST("/refresh", userHandler.Refresh)
 		users.POST("/logout", userHandler.Logout)
+		users.POST("/password", authMiddleware, userHandler.ChangePassword)
 	}
 }
@@ -2,17 +2,24 @@ package router
 
 import (
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
-	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func FileRoutes(rg *gin.RouterGroup, fileHandler *handler.FileHandler) {
+func FileRoutes(rg *gin.RouterGroup, fileHandler *handler.FileHandler, authMiddleware gin.HandlerFunc) {
 	files := rg.Group("/files")
-	files.Use(middleware.JWTAuthMiddleware())
+	files.Use(authMiddleware)
 	{
 		files.POST("/", fileHandler.Upload)
 		files.GET("/:id", fileHandler.GetByID)
+		files.GET("/:id/content", fileHandler.Download)
+		files.HEAD("/:id/content", fileHandler.Download)
 		files.GET("/", fileHandler.ListByOwner)
+		files.PUT("/:id/metadata", fileHandler.UpdateMetadata)
+		files.POST("/:id/versions", fileHandler.UploadVersion)
+		files.GET("/:id/versions", fileHandler.ListVersions)
+		files.DELETE("/:id/versions", fileHandler.PruneVersions)
+		files.POST("/:id/versions/:version/restore", fileHandler.RestoreVersion)
+		files.DELETE("/:id/versions/:version", fileHandler.DeleteVersion)
 		files.DELETE("/:id", fileHandler.Delete)
 	}
 }
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func FolderRoutes(rg *gin.RouterGroup, folderHandler *handler.FolderHandler, authMiddleware gin.HandlerFunc) {
+	folders := rg.Group("/folders")
+	folders.Use(authMiddleware)
+	{
+		folders.POST("/", folderHandler.Create)
+		folders.GET("/", folderHandler.ListRoot)
+		folders.GET("/shared", folderHandler.ListSharedWithMe)
+		folders.GET("/:id", folderHandler.Get)
+		folders.GET("/:id/tree", folderHandler.Tree)
+		folders.PATCH("/:id", folderHandler.Rename)
+		folders.POST("/:id/move", folderHandler.Move)
+		folders.DELETE("/:id", folderHandler.Delete)
+		folders.POST("/:id/shares", folderHandler.Share)
+		folders.GET("/:id/shares", folderHandler.ListShares)
+		folders.DELETE("/:id/shares/:share_id", folderHandler.Unshare)
+	}
+
+	rg.PUT("/files/:id/folder", authMiddleware, folderHandler.MoveFile)
+}
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func GroupRoutes(rg *gin.RouterGroup, groupHandler *handler.GroupHandler, authMiddleware gin.HandlerFunc) {
+	groups := rg.Group("/groups")
+	groups.Use(authMiddleware)
+	{
+		groups.POST("/", groupHandler.Create)
+		groups.GET("/", groupHandler.List)
+		groups.GET("/:id", groupHandler.Get)
+		groups.DELETE("/:id", groupHandler.Delete)
+		groups.POST("/:id/rotate", groupHandler.Rotate)
+		groups.POST("/:id/shares", groupHandler.ShareFile)
+		groups.GET("/:id/shares", groupHandler.ListShares)
+		groups.DELETE("/:id/shares/:share_id", groupHandler.Unshare)
+	}
+}
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+// KeyLogRoutes are public so that anyone can audit the log.
+func KeyLogRoutes(rg *gin.RouterGroup, keyLogHandler *handler.KeyLogHandler) {
+	keyLog := rg.Group("/keylog")
+	{
+		keyLog.GET("/public-key", keyLogHandler.PublicKey)
+		keyLog.GET("/sth", keyLogHandler.TreeHead)
+		keyLog.GET("/inclusion", keyLogHandler.Inclusion)
+		keyLog.GET("/consistency", keyLogHandler.Consistency)
+	}
+}
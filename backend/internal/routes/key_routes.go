@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func KeyRoutes(rg *gin.RouterGroup, keyHandler *handler.KeyHandler, authMiddleware gin.HandlerFunc) {
+	keys := rg.Group("/keys")
+	keys.Use(authMiddleware)
+	{
+		keys.GET("/", keyHandler.List)
+		keys.POST("/rotate", keyHandler.Rotate)
+		keys.GET("/rewrap-queue", keyHandler.RewrapQueue)
+		keys.POST("/rewrap", keyHandler.SubmitRewrap)
+	}
+}
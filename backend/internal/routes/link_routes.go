@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+// LinkRoutes registers link management behind authMiddleware; resolving a
+// token is deliberately public.
+func LinkRoutes(rg *gin.RouterGroup, linkHandler *handler.LinkHandler, authMiddleware gin.HandlerFunc) {
+	links := rg.Group("/links")
+	{
+		links.GET("/:token", linkHandler.Open)
+		links.POST("/", authMiddleware, linkHandler.Create)
+		links.GET("/", authMiddleware, linkHandler.List)
+		links.DELETE("/:id", authMiddleware, linkHandler.Revoke)
+	}
+}
@@ -2,16 +2,44 @@ package router
 
 import (
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/middleware"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/auth"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(r *gin.Engine, userHandler *handler.UserHandler, fileHandler *handler.FileHandler, shareHandler *handler.ShareHandler) *gin.Engine {
+type Handlers struct {
+	User    *handler.UserHandler
+	File    *handler.FileHandler
+	Share   *handler.ShareHandler
+	Upload  *handler.UploadHandler
+	Session *handler.SessionHandler
+	KeyLog  *handler.KeyLogHandler
+	Key     *handler.KeyHandler
+	Link    *handler.LinkHandler
+	Group   *handler.GroupHandler
+	Folder  *handler.FolderHandler
+	Usage   *handler.UsageHandler
+}
+
+func SetupRouter(r *gin.Engine, h Handlers, tokens *auth.TokenSigner, sessions middleware.SessionValidator) *gin.Engine {
+	authMiddleware := middleware.JWTAuthMiddleware(tokens, sessions)
+	r.Use(middleware.ErrorHandler())
 
 	api := r.Group("/api")
 	{
-		AuthRoutes(api, userHandler)
-		FileRoutes(api, fileHandler)
-		ShareRoutes(api, shareHandler)
+		AuthRoutes(api, h.User, authMiddleware)
+		UserRoutes(api, h.User, authMiddleware)
+		FileRoutes(api, h.File, authMiddleware)
+		TrashRoutes(api, h.File, authMiddleware)
+		ShareRoutes(api, h.Share, authMiddleware)
+		UploadRoutes(api, h.Upload, authMiddleware)
+		SessionRoutes(api, h.Session, authMiddleware)
+		KeyLogRoutes(api, h.KeyLog)
+		KeyRoutes(api, h.Key, authMiddleware)
+		LinkRoutes(api, h.Link, authMiddleware)
+		GroupRoutes(api, h.Group, authMiddleware)
+		FolderRoutes(api, h.Folder, authMiddleware)
+		UsageRoutes(api, h.Usage, authMiddleware)
 	}
 
 	return r
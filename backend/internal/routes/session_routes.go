@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func SessionRoutes(rg *gin.RouterGroup, sessionHandler *handler.SessionHandler, authMiddleware gin.HandlerFunc) {
+	sessions := rg.Group("/sessions")
+	sessions.Use(authMiddleware)
+	{
+		sessions.GET("/", sessionHandler.List)
+		sessions.DELETE("/:id", sessionHandler.Revoke)
+	}
+}
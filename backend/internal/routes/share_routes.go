@@ -2,17 +2,21 @@ package router
 
 import (
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
-	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func ShareRoutes(rg *gin.RouterGroup, shareHandler *handler.ShareHandler) {
+func ShareRoutes(rg *gin.RouterGroup, shareHandler *handler.ShareHandler, authMiddleware gin.HandlerFunc) {
 	shares := rg.Group("/shares")
-	shares.Use(middleware.JWTAuthMiddleware())
+	shares.Use(authMiddleware)
 	{
 		shares.POST("/", shareHandler.ShareFile)
 		shares.GET("/", shareHandler.ListShares)
+		shares.GET("/outgoing", shareHandler.ListOutgoing)
 		shares.GET("/:file_id", shareHandler.GetShare)
+		shares.PATCH("/:share_id", shareHandler.UpdateExpiry)
+		shares.PUT("/:share_id/permissions", shareHandler.UpdatePermissions)
 		shares.DELETE("/:share_id", shareHandler.Unshare)
 	}
+
+	rg.GET("/files/:id/shares", authMiddleware, shareHandler.ListForFile)
 }
\ No newline at end of file
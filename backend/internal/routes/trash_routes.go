@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func TrashRoutes(rg *gin.RouterGroup, fileHandler *handler.FileHandler, authMiddleware gin.HandlerFunc) {
+	trash := rg.Group("/trash")
+	trash.Use(authMiddleware)
+	{
+		trash.GET("/", fileHandler.ListTrash)
+		trash.DELETE("/", fileHandler.EmptyTrash)
+		trash.POST("/:id/restore", fileHandler.RestoreFromTrash)
+		trash.DELETE("/:id", fileHandler.DeleteForever)
+	}
+}
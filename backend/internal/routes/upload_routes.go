@@ -0,0 +1,19 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func UploadRoutes(rg *gin.RouterGroup, uploadHandler *handler.UploadHandler, authMiddleware gin.HandlerFunc) {
+	uploads := rg.Group("/uploads")
+	uploads.Use(authMiddleware)
+	{
+		uploads.POST("/", uploadHandler.Create)
+		uploads.GET("/:id", uploadHandler.Status)
+		uploads.HEAD("/:id", uploadHandler.Status)
+		uploads.PATCH("/:id", uploadHandler.WriteChunk)
+		uploads.POST("/:id/complete", uploadHandler.Complete)
+		uploads.DELETE("/:id", uploadHandler.Abort)
+	}
+}
@@ -0,0 +1,14 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func UsageRoutes(rg *gin.RouterGroup, usageHandler *handler.UsageHandler, authMiddleware gin.HandlerFunc) {
+	me := rg.Group("/me")
+	me.Use(authMiddleware)
+	{
+		me.GET("/usage", usageHandler.Get)
+	}
+}
@@ -0,0 +1,16 @@
+package router
+
+import (
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+func UserRoutes(rg *gin.RouterGroup, userHandler *handler.UserHandler, authMiddleware gin.HandlerFunc) {
+	users := rg.Group("/users")
+	users.Use(authMiddleware)
+	{
+		users.GET("/lookup", userHandler.Lookup)
+		users.GET("/search", userHandler.Search)
+		users.GET("/:id/public-key", userHandler.GetPublicKey)
+	}
+}
@@ -0,0 +1,35 @@
+package storage_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage/storagetest"
+)
+
+func TestLocalStorageContract(t *testing.T) {
+	s, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storagetest.Run(t, s)
+}
+
+func TestMemoryStorageContract(t *testing.T) {
+	storagetest.Run(t, storage.NewMemoryStorage())
+}
+
+// TestMinioStorageContract runs against a live server given by
+// MINIO_TEST_ENDPOINT, using MINIO_ROOT_USER and MINIO_ROOT_PASSWORD.
+func TestMinioStorageContract(t *testing.T) {
+	endpoint := os.Getenv("MINIO_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_TEST_ENDPOINT not set")
+	}
+	s, err := storage.NewMinioStorage(endpoint, os.Getenv("MINIO_ROOT_USER"), os.Getenv("MINIO_ROOT_PASSWORD"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storagetest.Run(t, s)
+}
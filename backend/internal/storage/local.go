@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LocalStorage keeps objects on the local filesystem. Objects live under
+// root/<bucket>/objects, sharded two levels deep by the SHA-256 of the object
+// name, which is also the file name, so object names never become paths.
+// Writes go to a temporary file that is synced and renamed into place.
+// Stat does not report a content type.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if root == "" {
+		return nil, errors.New("storage root is required")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+func validBucket(bucket string) bool {
+	if bucket == "" || bucket == "." || bucket == ".." {
+		return false
+	}
+	for _, c := range bucket {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// validObjectName rejects names with empty, "." or ".." segments, so a name
+// never resolves outside its bucket even if it is later used as a path.
+func validObjectName(name string) bool {
+	if name == "" || len(name) > 1024 || strings.ContainsAny(name, "\x00\\") {
+		return false
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *LocalStorage) bucketDir(bucket string) (string, error) {
+	if !validBucket(bucket) {
+		return "", fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	return filepath.Join(s.root, bucket), nil
+}
+
+func (s *LocalStorage) objectPath(bucket, objectName string) (string, error) {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return "", err
+	}
+	if !validObjectName(objectName) {
+		return "", fmt.Errorf("invalid object name %q", objectName)
+	}
+	sum := sha256.Sum256([]byte(objectName))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(dir, "objects", name[:2], name[2:4], name), nil
+}
+
+func (s *LocalStorage) uploadDir(bucket, uploadID string) (string, error) {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return "", err
+	}
+	if _, err := hex.DecodeString(uploadID); err != nil || len(uploadID) != 32 {
+		return "", fmt.Errorf("invalid upload id %q", uploadID)
+	}
+	return filepath.Join(dir, "uploads", uploadID), nil
+}
+
+func (s *LocalStorage) tmpDir(bucket string) (string, error) {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "tmp")
+	return dir, os.MkdirAll(dir, 0o700)
+}
+
+// writeAtomic copies reader into a synced temporary file in the bucket and
+// renames it to path. A negative size skips the length check.
+func (s *LocalStorage) writeAtomic(bucket, path string, reader io.Reader, size int64) error {
+	tmpDir, err := s.tmpDir(bucket)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(tmpDir, "write-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, reader)
+	if err == nil && size >= 0 && n != size {
+		err = fmt.Errorf("wrote %d bytes, expected %d", n, size)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (s *LocalStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, objectSize int64, contentType string) error {
+	path, err := s.objectPath(bucket, objectName)
+	if err != nil {
+		return err
+	}
+	return s.writeAtomic(bucket, path, reader, objectSize)
+}
+
+func (s *LocalStorage) open(bucket, objectName string) (*os.File, error) {
+	path, err := s.objectPath(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	return s.open(bucket, objectName)
+}
+
+func (s *LocalStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64, etag string) (io.ReadCloser, error) {
+	f, err := s.open(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		// The open file keeps its content even if a rename replaces the
+		// object, so checking it once is enough.
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if localETag(fi) != etag {
+			f.Close()
+			return nil, ErrChanged
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, bucket, objectName string) (ObjectInfo, error) {
+	path, err := s.objectPath(bucket, objectName)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		Size:         fi.Size(),
+		ETag:         localETag(fi),
+		LastModified: fi.ModTime(),
+	}, nil
+}
+
+// localETag identifies an object's content by size and modification time,
+// which is enough because objects are only ever replaced by rename.
+func localETag(fi fs.FileInfo) string {
+	return strconv.FormatInt(fi.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(fi.Size(), 16)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	path, err := s.objectPath(bucket, objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Multipart uploads keep each part as its own file under
+// root/<bucket>/uploads/<upload id> until they are concatenated on
+// completion.
+
+func (s *LocalStorage) CreateMultipartUpload(ctx context.Context, bucket, objectName, contentType string) (string, error) {
+	if _, err := s.objectPath(bucket, objectName); err != nil {
+		return "", err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id)
+
+	dir, err := s.uploadDir(bucket, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "object"), []byte(objectName), 0o600); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+// openUpload returns the directory of an upload started for objectName.
+func (s *LocalStorage) openUpload(bucket, objectName, uploadID string) (string, error) {
+	dir, err := s.uploadDir(bucket, uploadID)
+	if err != nil {
+		return "", err
+	}
+	name, err := os.ReadFile(filepath.Join(dir, "object"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("upload %s not found", uploadID)
+	}
+	if err != nil {
+		return "", err
+	}
+	if string(name) != objectName {
+		return "", fmt.Errorf("upload %s belongs to a different object", uploadID)
+	}
+	return dir, nil
+}
+
+func partPath(dir string, partNumber int) string {
+	return filepath.Join(dir, "part-"+strconv.Itoa(partNumber))
+}
+
+func (s *LocalStorage) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	if partNumber < 1 || partNumber > 10000 {
+		return Part{}, fmt.Errorf("invalid part number %d", partNumber)
+	}
+	dir, err := s.openUpload(bucket, objectName, uploadID)
+	if err != nil {
+		return Part{}, err
+	}
+
+	hash := md5.New()
+	if err := s.writeAtomic(bucket, partPath(dir, partNumber), io.TeeReader(reader, hash), size); err != nil {
+		return Part{}, err
+	}
+	return Part{Number: partNumber, ETag: hex.EncodeToString(hash.Sum(nil)), Size: size}, nil
+}
+
+func (s *LocalStorage) CompleteMultipartUpload(ctx context.Context, bucket, objectName, uploadID string, parts []Part) error {
+	dir, err := s.openUpload(bucket, objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	path, err := s.objectPath(bucket, objectName)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return errors.New("no parts to complete")
+	}
+
+	files := make([]*os.File, 0, len(parts))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	readers := make([]io.Reader, 0, len(parts))
+	for i, p := range parts {
+		if i > 0 && p.Number <= parts[i-1].Number {
+			return errors.New("parts must be in ascending order")
+		}
+		f, err := os.Open(partPath(dir, p.Number))
+		if err != nil {
+			return fmt.Errorf("part %d: %w", p.Number, err)
+		}
+		files = append(files, f)
+		readers = append(readers, &etagCheck{r: f, hash: md5.New(), part: p})
+	}
+
+	if err := s.writeAtomic(bucket, path, io.MultiReader(readers...), -1); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// etagCheck fails the read at EOF if the part's content does not match the
+// ETag the caller recorded for it.
+type etagCheck struct {
+	r    io.Reader
+	hash hash.Hash
+	part Part
+}
+
+func (e *etagCheck) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	e.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(e.hash.Sum(nil)) != strings.Trim(e.part.ETag, `"`) {
+		return n, fmt.Errorf("part %d does not match its etag", e.part.Number)
+	}
+	return n, err
+}
+
+func (s *LocalStorage) AbortMultipartUpload(ctx context.Context, bucket, objectName, uploadID string) error {
+	dir, err := s.uploadDir(bucket, uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageRejectsUnsafeNames(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocalStorage(filepath.Join(root, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, name := range []string{"", "..", "../escape", "a/../../escape", "/abs", "a//b", "a/./b", "a\\b", "nul\x00"} {
+		if err := s.Upload(ctx, "files", name, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("object name %q accepted", name)
+		}
+	}
+	for _, bucket := range []string{"", "..", "../escape", "a/b", "Files"} {
+		if err := s.Upload(ctx, bucket, "obj", strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("bucket %q accepted", bucket)
+		}
+	}
+	for _, uploadID := range []string{"", "..", "../../files/objects"} {
+		if _, err := s.UploadPart(ctx, "files", "obj", uploadID, 1, strings.NewReader("x"), 1); err == nil {
+			t.Errorf("upload id %q accepted", uploadID)
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("files written outside the storage root: %v", entries)
+	}
+}
+
+func TestLocalStorageShortWriteLeavesNoObject(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := s.Upload(ctx, "files", "obj", bytes.NewReader([]byte("short")), 10, ""); err == nil {
+		t.Fatal("upload shorter than its declared size succeeded")
+	}
+	if _, err := s.Stat(ctx, "files", "obj"); err != ErrNotFound {
+		t.Fatalf("stat = %v, want ErrNotFound", err)
+	}
+	tmp, err := os.ReadDir(filepath.Join(root, "files", "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmp) != 0 {
+		t.Fatalf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestLocalStorageShardsObjects(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Upload(context.Background(), "files", "file-id/version-id", strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := s.objectPath("files", "file-id/version-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(filepath.Join(root, "files", "objects"), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts := strings.Split(rel, string(filepath.Separator)); len(parts) != 3 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		t.Fatalf("object stored at %s, want two shard directories", rel)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps objects in memory. It is meant for tests.
+type MemoryStorage struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+	uploads map[string]*memoryUpload
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+type memoryUpload struct {
+	key         string
+	contentType string
+	parts       map[int][]byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: map[string]memoryObject{}, uploads: map[string]*memoryUpload{}}
+}
+
+func memoryKey(bucket, objectName string) string {
+	return bucket + "/" + objectName
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *MemoryStorage) put(key string, data []byte, contentType string) {
+	s.objects[key] = memoryObject{data: data, info: ObjectInfo{
+		Size:         int64(len(data)),
+		ETag:         md5Hex(data),
+		ContentType:  contentType,
+		LastModified: time.Now().UTC(),
+	}}
+}
+
+func (s *MemoryStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, objectSize int64, contentType string) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if objectSize >= 0 && int64(len(data)) != objectSize {
+		return fmt.Errorf("read %d bytes, expected %d", len(data), objectSize)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(memoryKey(bucket, objectName), data, contentType)
+	return nil
+}
+
+func (s *MemoryStorage) get(bucket, objectName string) (memoryObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[memoryKey(bucket, objectName)]
+	if !ok {
+		return memoryObject{}, ErrNotFound
+	}
+	return obj, nil
+}
+
+func (s *MemoryStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	obj, err := s.get(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (s *MemoryStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64, etag string) (io.ReadCloser, error) {
+	obj, err := s.get(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" && etag != obj.info.ETag {
+		return nil, ErrChanged
+	}
+	return io.NopCloser(io.NewSectionReader(bytes.NewReader(obj.data), offset, length)), nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, bucket, objectName string) (ObjectInfo, error) {
+	obj, err := s.get(bucket, objectName)
+	return obj.info, err
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, memoryKey(bucket, objectName))
+	return nil
+}
+
+func (s *MemoryStorage) CreateMultipartUpload(ctx context.Context, bucket, objectName, contentType string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads[uploadID] = &memoryUpload{key: memoryKey(bucket, objectName), contentType: contentType, parts: map[int][]byte{}}
+	return uploadID, nil
+}
+
+func (s *MemoryStorage) upload(bucket, objectName, uploadID string) (*memoryUpload, error) {
+	u, ok := s.uploads[uploadID]
+	if !ok || u.key != memoryKey(bucket, objectName) {
+		return nil, fmt.Errorf("upload %s not found", uploadID)
+	}
+	return u, nil
+}
+
+func (s *MemoryStorage) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return Part{}, err
+	}
+	if int64(len(data)) != size {
+		return Part{}, fmt.Errorf("read %d bytes, expected %d", len(data), size)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.upload(bucket, objectName, uploadID)
+	if err != nil {
+		return Part{}, err
+	}
+	u.parts[partNumber] = data
+	return Part{Number: partNumber, ETag: md5Hex(data), Size: size}, nil
+}
+
+func (s *MemoryStorage) CompleteMultipartUpload(ctx context.Context, bucket, objectName, uploadID string, parts []Part) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.upload(bucket, objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return errors.New("no parts to complete")
+	}
+
+	var buf bytes.Buffer
+	for i, p := range parts {
+		if i > 0 && p.Number <= parts[i-1].Number {
+			return errors.New("parts must be in ascending order")
+		}
+		data, ok := u.parts[p.Number]
+		if !ok || md5Hex(data) != strings.Trim(p.ETag, `"`) {
+			return fmt.Errorf("part %d does not match its etag", p.Number)
+		}
+		buf.Write(data)
+	}
+
+	s.put(u.key, buf.Bytes(), u.contentType)
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+func (s *MemoryStorage) AbortMultipartUpload(ctx context.Context, bucket, objectName, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.uploads, uploadID)
+	return nil
+}
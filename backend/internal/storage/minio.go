@@ -24,7 +24,7 @@ func NewMinioStorage(endpoint, accessKey, secretKey string, useSSL bool) (*Minio
 	return &MinioStorage{client: client}, nil
 }
 
-func (s *MinioStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, objectSize int64, contentType string) error {
+func (s *MinioStorage) ensureBucket(ctx context.Context, bucket string) error {
 	exists, err := s.client.BucketExists(ctx, bucket)
 	if err != nil {
 		return err
@@ -36,15 +36,115 @@ func (s *MinioStorage) Upload(ctx context.Context, bucket, objectName string, re
 		}
 		log.Printf("Created bucket %s\n", bucket)
 	}
+	return nil
+}
+
+func (s *MinioStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, objectSize int64, contentType string) error {
+	if err := s.ensureBucket(ctx, bucket); err != nil {
+		return err
+	}
 
-	_, err = s.client.PutObject(ctx, bucket, objectName, reader, objectSize, minio.PutObjectOptions{ContentType: contentType})
+	_, err := s.client.PutObject(ctx, bucket, objectName, reader, objectSize, minio.PutObjectOptions{ContentType: contentType})
 	return err
 }
 
 func (s *MinioStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
-	return s.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+	obj, err := s.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// Like DownloadRange, send the request now so a missing object fails
+	// the call instead of the first Read.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *MinioStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64, etag string) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		if err := opts.SetMatchETag(etag); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := s.client.GetObject(ctx, bucket, objectName, opts)
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat sends the request so a failed precondition
+	// surfaces here rather than halfway through the response.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+			return nil, ErrChanged
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *MinioStorage) Stat(ctx context.Context, bucket, objectName string) (ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ObjectInfo{}, ErrNotFound
+		}
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		Size:         info.Size,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
 }
 
 func (s *MinioStorage) Delete(ctx context.Context, bucket, objectName string) error {
 	return s.client.RemoveObject(ctx, bucket, objectName, minio.RemoveObjectOptions{})
-}
\ No newline at end of file
+}
+
+func (s *MinioStorage) CreateMultipartUpload(ctx context.Context, bucket, objectName, contentType string) (string, error) {
+	if err := s.ensureBucket(ctx, bucket); err != nil {
+		return "", err
+	}
+
+	core := minio.Core{Client: s.client}
+	return core.NewMultipartUpload(ctx, bucket, objectName, minio.PutObjectOptions{ContentType: contentType})
+}
+
+func (s *MinioStorage) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	core := minio.Core{Client: s.client}
+	part, err := core.PutObjectPart(ctx, bucket, objectName, uploadID, partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Number: part.PartNumber, ETag: part.ETag, Size: part.Size}, nil
+}
+
+func (s *MinioStorage) CompleteMultipartUpload(ctx context.Context, bucket, objectName, uploadID string, parts []Part) error {
+	completed := make([]minio.CompletePart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, minio.CompletePart{PartNumber: p.Number, ETag: p.ETag})
+	}
+
+	core := minio.Core{Client: s.client}
+	_, err := core.CompleteMultipartUpload(ctx, bucket, objectName, uploadID, completed, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *MinioStorage) AbortMultipartUpload(ctx context.Context, bucket, objectName, uploadID string) error {
+	core := minio.Core{Client: s.client}
+	err := core.AbortMultipartUpload(ctx, bucket, objectName, uploadID)
+	if err != nil && minio.ToErrorResponse(err).Code == "NoSuchUpload" {
+		return nil
+	}
+	return err
+}
@@ -2,11 +2,40 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 )
 
+// ErrNotFound is returned by Stat for objects that do not exist.
+var ErrNotFound = errors.New("object not found")
+
+// ErrChanged is returned by DownloadRange when the object no longer has the
+// ETag the caller asked for.
+var ErrChanged = errors.New("object changed")
+
+type ObjectInfo struct {
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+}
+
+type Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
 type Storage interface {
 	Upload(ctx context.Context, bucket, objectName string, reader io.Reader, objectSize int64, contentType string) error
 	Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error)
+	DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64, etag string) (io.ReadCloser, error)
+	Stat(ctx context.Context, bucket, objectName string) (ObjectInfo, error)
 	Delete(ctx context.Context, bucket, objectName string) error
-}
\ No newline at end of file
+
+	CreateMultipartUpload(ctx context.Context, bucket, objectName, contentType string) (string, error)
+	UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, objectName, uploadID string, parts []Part) error
+	AbortMultipartUpload(ctx context.Context, bucket, objectName, uploadID string) error
+}
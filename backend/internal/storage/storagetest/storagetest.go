@@ -0,0 +1,239 @@
+// Package storagetest is the contract every storage.Storage implementation
+// must pass.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+)
+
+// Run checks the behaviour the usecases rely on. Each subtest uses a fresh
+// bucket, so a shared server is fine.
+func Run(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	upload := func(t *testing.T, bucket, name string, data []byte) {
+		t.Helper()
+		if err := s.Upload(ctx, bucket, name, bytes.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+			t.Fatalf("upload %s: %v", name, err)
+		}
+	}
+	t.Run("UploadDownload", func(t *testing.T) {
+		bucket := randomBucket(t)
+		data := randomBytes(t, 1000)
+		upload(t, bucket, "file-id", data)
+
+		got, err := readAll(s.Download(ctx, bucket, "file-id"))
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatal("downloaded content differs")
+		}
+	})
+
+	t.Run("NestedObjectName", func(t *testing.T) {
+		bucket := randomBucket(t)
+		upload(t, bucket, "file-id/version-id", []byte("v2"))
+		upload(t, bucket, "file-id", []byte("v1"))
+
+		got, err := readAll(s.Download(ctx, bucket, "file-id/version-id"))
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		if string(got) != "v2" {
+			t.Fatalf("got %q, want %q", got, "v2")
+		}
+	})
+
+	t.Run("DownloadRange", func(t *testing.T) {
+		bucket := randomBucket(t)
+		data := randomBytes(t, 1000)
+		upload(t, bucket, "obj", data)
+
+		got, err := readAll(s.DownloadRange(ctx, bucket, "obj", 100, 250, ""))
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		if !bytes.Equal(got, data[100:350]) {
+			t.Fatal("range content differs")
+		}
+	})
+
+	t.Run("DownloadRangePinnedToETag", func(t *testing.T) {
+		bucket := randomBucket(t)
+		data := randomBytes(t, 1000)
+		upload(t, bucket, "obj", data)
+
+		info, err := s.Stat(ctx, bucket, "obj")
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		got, err := readAll(s.DownloadRange(ctx, bucket, "obj", 0, 10, info.ETag))
+		if err != nil {
+			t.Fatalf("download with current etag: %v", err)
+		}
+		if !bytes.Equal(got, data[:10]) {
+			t.Fatal("range content differs")
+		}
+
+		upload(t, bucket, "obj", randomBytes(t, 500))
+		if _, err := s.DownloadRange(ctx, bucket, "obj", 0, 10, info.ETag); !errors.Is(err, storage.ErrChanged) {
+			t.Fatalf("download with stale etag: err = %v, want ErrChanged", err)
+		}
+	})
+
+	t.Run("Stat", func(t *testing.T) {
+		bucket := randomBucket(t)
+		upload(t, bucket, "obj", randomBytes(t, 123))
+
+		info, err := s.Stat(ctx, bucket, "obj")
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if info.Size != 123 || info.ETag == "" || info.LastModified.IsZero() {
+			t.Fatalf("unexpected info %+v", info)
+		}
+
+		if _, err := s.Stat(ctx, bucket, "missing"); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("stat missing = %v, want storage.ErrNotFound", err)
+		}
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		bucket := randomBucket(t)
+		upload(t, bucket, "obj", []byte("first"))
+		upload(t, bucket, "obj", []byte("second"))
+
+		got, err := readAll(s.Download(ctx, bucket, "obj"))
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		if string(got) != "second" {
+			t.Fatalf("got %q, want %q", got, "second")
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		bucket := randomBucket(t)
+		upload(t, bucket, "obj", []byte("data"))
+
+		if err := s.Delete(ctx, bucket, "obj"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := s.Stat(ctx, bucket, "obj"); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("stat after delete = %v, want storage.ErrNotFound", err)
+		}
+		// The outbox may retry a delete that already happened.
+		if err := s.Delete(ctx, bucket, "obj"); err != nil {
+			t.Fatalf("second delete: %v", err)
+		}
+	})
+
+	t.Run("Multipart", func(t *testing.T) {
+		bucket := randomBucket(t)
+		upload(t, bucket, "bucket-init", nil)
+		first := randomBytes(t, 5<<20)
+		second := randomBytes(t, 1000)
+
+		uploadID, err := s.CreateMultipartUpload(ctx, bucket, "obj", "application/octet-stream")
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		p2, err := s.UploadPart(ctx, bucket, "obj", uploadID, 2, bytes.NewReader(second), int64(len(second)))
+		if err != nil {
+			t.Fatalf("part 2: %v", err)
+		}
+		p1, err := s.UploadPart(ctx, bucket, "obj", uploadID, 1, bytes.NewReader(first), int64(len(first)))
+		if err != nil {
+			t.Fatalf("part 1: %v", err)
+		}
+		if p1.Number != 1 || p1.ETag == "" || p1.Size != int64(len(first)) {
+			t.Fatalf("unexpected part %+v", p1)
+		}
+
+		if err := s.CompleteMultipartUpload(ctx, bucket, "obj", uploadID, []storage.Part{p1, p2}); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+
+		got, err := readAll(s.Download(ctx, bucket, "obj"))
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		if !bytes.Equal(got, append(first, second...)) {
+			t.Fatal("assembled content differs")
+		}
+	})
+
+	t.Run("MultipartAbort", func(t *testing.T) {
+		bucket := randomBucket(t)
+		upload(t, bucket, "bucket-init", nil)
+
+		uploadID, err := s.CreateMultipartUpload(ctx, bucket, "obj", "application/octet-stream")
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if _, err := s.UploadPart(ctx, bucket, "obj", uploadID, 1, bytes.NewReader([]byte("part")), 4); err != nil {
+			t.Fatalf("part: %v", err)
+		}
+
+		if err := s.AbortMultipartUpload(ctx, bucket, "obj", uploadID); err != nil {
+			t.Fatalf("abort: %v", err)
+		}
+		if _, err := s.Stat(ctx, bucket, "obj"); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("stat after abort = %v, want storage.ErrNotFound", err)
+		}
+		// Session expiry may abort an upload that was already aborted.
+		if err := s.AbortMultipartUpload(ctx, bucket, "obj", uploadID); err != nil {
+			t.Fatalf("second abort: %v", err)
+		}
+	})
+
+	t.Run("MultipartWrongETag", func(t *testing.T) {
+		bucket := randomBucket(t)
+		upload(t, bucket, "bucket-init", nil)
+
+		uploadID, err := s.CreateMultipartUpload(ctx, bucket, "obj", "application/octet-stream")
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		defer s.AbortMultipartUpload(ctx, bucket, "obj", uploadID)
+		part, err := s.UploadPart(ctx, bucket, "obj", uploadID, 1, bytes.NewReader([]byte("part")), 4)
+		if err != nil {
+			t.Fatalf("part: %v", err)
+		}
+
+		part.ETag = "00000000000000000000000000000000"
+		if err := s.CompleteMultipartUpload(ctx, bucket, "obj", uploadID, []storage.Part{part}); err == nil {
+			t.Fatal("completed with a mismatched etag")
+		}
+	})
+}
+
+func readAll(rc io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func randomBucket(t *testing.T) string {
+	return "contract-" + hex.EncodeToString(randomBytes(t, 8))
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/chunkcrypt"
+)
+
+var errUnsupportedFormat = domain.Invalid("unsupported_format", "unsupported content format")
+
+// invalidContent reports a malformed chunkcrypt container, whose errors
+// describe only the client's own bytes.
+func invalidContent(err error) error {
+	return domain.Invalid("invalid_content", err.Error())
+}
+
+// inspectContainer checks the chunkcrypt header at the start of content and
+// that size is a valid ciphertext length for it. The key is not needed. The
+// returned reader replays the header bytes that were consumed.
+func inspectContainer(content io.Reader, size int64) (chunkcrypt.Header, io.Reader, error) {
+	buf := make([]byte, chunkcrypt.HeaderSize)
+	if _, err := io.ReadFull(content, buf); err != nil {
+		return chunkcrypt.Header{}, nil, invalidContent(fmt.Errorf("%w: %v", chunkcrypt.ErrInvalidHeader, err))
+	}
+
+	header, err := chunkcrypt.ParseHeader(buf)
+	if err != nil {
+		return chunkcrypt.Header{}, nil, invalidContent(err)
+	}
+
+	if _, err := header.PlaintextSize(size); err != nil {
+		return chunkcrypt.Header{}, nil, invalidContent(err)
+	}
+
+	return header, io.MultiReader(bytes.NewReader(buf), content), nil
+}
+
+// prepareContent validates content uploaded in one piece and returns the
+// reader to store along with the container's chunk size, which is zero for
+// FormatLegacy.
+func prepareContent(formatVersion int, content io.Reader, size int64) (io.Reader, int, error) {
+	switch formatVersion {
+	case domain.FormatLegacy:
+		return content, 0, nil
+	case domain.FormatChunkedV1:
+		header, r, err := inspectContainer(content, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		return r, int(header.ChunkSize), nil
+	default:
+		return nil, 0, errUnsupportedFormat
+	}
+}
+
+func validateFormat(formatVersion, chunkSize int, size int64) error {
+	switch formatVersion {
+	case domain.FormatLegacy:
+		return nil
+	case domain.FormatChunkedV1:
+		if chunkSize < chunkcrypt.MinChunkSize || chunkSize > chunkcrypt.MaxChunkSize {
+			return domain.Invalid("invalid_chunk_size", "invalid chunk size")
+		}
+		header := chunkcrypt.Header{ChunkSize: uint32(chunkSize)}
+		if _, err := header.PlaintextSize(size); err != nil {
+			return invalidContent(err)
+		}
+		return nil
+	default:
+		return errUnsupportedFormat
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"log"
 	"time"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
@@ -12,25 +13,64 @@ import (
 	"github.com/google/uuid"
 )
 
+// KeyGrant is the wrapped file key a requester should unwrap, together with
+// the version of their keypair it was wrapped for. When access comes through
+// a group, WrappedKey is wrapped for the group keypair GroupKeyVersion
+// instead, and WrappedGroupKey is that group private key wrapped for the
+// requester's KeyVersion. When access comes through a shared folder,
+// WrappedKey is wrapped under the key of FolderID, which the requester
+// derives from the folder tree.
+type KeyGrant struct {
+	WrappedKey []byte
+	KeyVersion int
+
+	FolderID uuid.UUID
+
+	GroupID         uuid.UUID
+	GroupKeyVersion int
+	WrappedGroupKey []byte
+}
+
 type FileUsecase interface {
-	Upload(ctx context.Context, file domain.File, content io.ReadCloser) error
-	Download(ctx context.Context, id, recipientID uuid.UUID) (io.ReadCloser, domain.File, []byte, error)
-	GetByID(ctx context.Context, id uuid.UUID) (domain.File, error)
+	Upload(ctx context.Context, file domain.File, content io.ReadCloser) (domain.File, error)
+	Download(ctx context.Context, id, recipientID uuid.UUID) (io.ReadCloser, domain.File, KeyGrant, error)
+	Stat(ctx context.Context, id, recipientID uuid.UUID, version int) (domain.File, KeyGrant, storage.ObjectInfo, error)
+	DownloadRange(ctx context.Context, file domain.File, offset, length int64, etag string) (io.ReadCloser, error)
+	GetByID(ctx context.Context, id, requesterID uuid.UUID) (domain.File, error)
 	ListByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error)
+	UpdateMetadata(ctx context.Context, id, ownerID uuid.UUID, encryptedMetadata []byte) error
 	Delete(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error
+
+	ListTrash(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error)
+	RestoreFromTrash(ctx context.Context, id, ownerID uuid.UUID) error
+	DeleteForever(ctx context.Context, id, ownerID uuid.UUID) error
+	EmptyTrash(ctx context.Context, ownerID uuid.UUID) (int, error)
+	PurgeTrash(ctx context.Context, cutoff time.Time) (int, error)
+
+	UploadVersion(ctx context.Context, id, ownerID uuid.UUID, version domain.FileVersion, content io.ReadCloser) (domain.FileVersion, error)
+	ListVersions(ctx context.Context, id, ownerID uuid.UUID) ([]domain.FileVersion, error)
+	RestoreVersion(ctx context.Context, id, ownerID uuid.UUID, version int) error
+	DeleteVersion(ctx context.Context, id, ownerID uuid.UUID, version int) error
+	PruneVersions(ctx context.Context, id, ownerID uuid.UUID, keep int) (int, error)
 }
 
 type fileUsecase struct {
 	fileRepo repository.FileRepository
 	shareRepo repository.ShareRepository
+	groupRepo repository.GroupRepository
+	folderRepo repository.FolderRepository
 	storage storage.Storage
+	metadataMode MetadataMode
 }
 
-func NewFileUsecase(fileRepo repository.FileRepository,shareRepo repository.ShareRepository, storage storage.Storage) FileUsecase {
-	return &fileUsecase{fileRepo: fileRepo, shareRepo: shareRepo, storage: storage}
+func NewFileUsecase(fileRepo repository.FileRepository,shareRepo repository.ShareRepository, groupRepo repository.GroupRepository, folderRepo repository.FolderRepository, storage storage.Storage, metadataMode MetadataMode) FileUsecase {
+	return &fileUsecase{fileRepo: fileRepo, shareRepo: shareRepo, groupRepo: groupRepo, folderRepo: folderRepo, storage: storage, metadataMode: metadataMode}
 }
 
-func (u *fileUsecase) Upload(ctx context.Context, file domain.File, content io.ReadCloser) error {
+// Upload runs the upload saga: the file is recorded as pending, the object is
+// written and the record is committed. A failed write rolls the record back;
+// a crash at any point leaves a pending record for the reconciler.
+func (u *fileUsecase) Upload(ctx context.Context, file domain.File, content io.ReadCloser) (domain.File, error) {
 	if file.ID == uuid.Nil {
 		file.ID = uuid.New()
 	}
@@ -38,60 +78,350 @@ func (u *fileUsecase) Upload(ctx context.Context, file domain.File, content io.R
 		file.CreatedAt = time.Now().UTC()
 	}
 
-	if err := u.storage.Upload(ctx, "files", file.ID.String(), content, file.Size, file.MimeType); err != nil {
-		return err
+	file.Version = 1
+	file.ObjectName = file.ID.String()
+
+	if err := u.metadataMode.apply(file.EncryptedMetadata, &file.Filename, &file.MimeType); err != nil {
+		return domain.File{}, err
 	}
 
-	return u.fileRepo.Save(ctx, file)
+	if file.FolderID != nil {
+		folder, err := u.folderRepo.FindByID(ctx, *file.FolderID)
+		if err != nil || folder.OwnerID != file.OwnerID {
+			return domain.File{}, domain.NotFound("folder")
+		}
+		if len(file.FolderWrappedKey) == 0 {
+			return domain.File{}, domain.Invalid("folder_wrapped_key_required", "folder wrapped key is required")
+		}
+	}
+
+	body, chunkSize, err := prepareContent(file.FormatVersion, content, file.Size)
+	if err != nil {
+		return domain.File{}, err
+	}
+	file.ChunkSize = chunkSize
+
+	// Save reserves the size against the owner's quota, so an upload over
+	// it is rejected before anything reaches storage.
+	if err := u.fileRepo.Save(ctx, file); err != nil {
+		return domain.File{}, err
+	}
+
+	if err := u.storage.Upload(ctx, "files", file.ObjectName, body, file.Size, file.MimeType); err != nil {
+		// Delete releases the reservation and queues the possibly partial
+		// object for removal.
+		if rbErr := u.fileRepo.Delete(ctx, file.ID); rbErr != nil {
+			log.Printf("failed to roll back upload of file %s: %v", file.ID, rbErr)
+		}
+		return domain.File{}, err
+	}
+
+	if err := u.fileRepo.Commit(ctx, file.ID); err != nil {
+		return domain.File{}, err
+	}
+	file.State = domain.FileStateCommitted
+	return file, nil
 }
 
-func (u *fileUsecase) Download(ctx context.Context, id uuid.UUID, recipientID uuid.UUID) (io.ReadCloser, domain.File, []byte, error) {
-	file, err := u.fileRepo.FindByID(ctx, id)
+func (u *fileUsecase) Download(ctx context.Context, id uuid.UUID, recipientID uuid.UUID) (io.ReadCloser, domain.File, KeyGrant, error) {
+	file, grant, err := u.authorize(ctx, id, recipientID, domain.PermDownload)
 	if err != nil {
-		return nil, domain.File{}, nil, err
+		return nil, domain.File{}, KeyGrant{}, err
 	}
 
-	if file.OwnerID == recipientID {
-		content, err := u.storage.Download(ctx, "files", id.String())
+	content, err := u.storage.Download(ctx, "files", file.ObjectName)
+	if err != nil {
+		return nil, domain.File{}, KeyGrant{}, err
+	}
+
+	return content, file, grant, nil
+}
+
+// Stat authorizes a download of the given version, or of the current one
+// when version is zero. Anyone who may download a file may download any of
+// its versions.
+func (u *fileUsecase) Stat(ctx context.Context, id, recipientID uuid.UUID, version int) (domain.File, KeyGrant, storage.ObjectInfo, error) {
+	file, grant, err := u.authorize(ctx, id, recipientID, domain.PermDownload)
+	if err != nil {
+		return domain.File{}, KeyGrant{}, storage.ObjectInfo{}, err
+	}
+
+	if version != 0 && version != file.Version {
+		v, err := u.fileRepo.FindVersion(ctx, id, version)
 		if err != nil {
-			return nil, domain.File{}, nil, err
+			return domain.File{}, KeyGrant{}, storage.ObjectInfo{}, domain.NotFound("file version")
 		}
-		return content, file, nil, nil
+		file = file.AtVersion(v)
 	}
 
-	share, err := u.shareRepo.FindByFileAndRecipient(ctx, id, recipientID)
-	if err != nil || share.ID == uuid.Nil {
-		return nil, domain.File{}, nil, errors.New("unauthorized: you don't have access to this file")
+	info, err := u.storage.Stat(ctx, "files", file.ObjectName)
+	if err != nil {
+		return domain.File{}, KeyGrant{}, storage.ObjectInfo{}, err
+	}
+
+	return file, grant, info, nil
+}
+
+// DownloadRange reads part of the object Stat described. Passing the ETag
+// Stat returned makes the read fail instead of mixing in another object's
+// bytes if the object was replaced in between.
+func (u *fileUsecase) DownloadRange(ctx context.Context, file domain.File, offset, length int64, etag string) (io.ReadCloser, error) {
+	content, err := u.storage.DownloadRange(ctx, "files", file.ObjectName, offset, length, etag)
+	if errors.Is(err, storage.ErrChanged) {
+		return nil, domain.Conflict("file_changed", "file changed while it was being read")
 	}
+	return content, err
+}
 
-	content, err := u.storage.Download(ctx, "files", id.String())
+// authorize returns the file together with the key grant the requester
+// should use: the owner's EncryptedKey, the recipient's direct share, a
+// share with one of the requester's groups or a share of a folder
+// containing the file, in that order. The share must carry the need
+// permissions.
+func (u *fileUsecase) authorize(ctx context.Context, id, recipientID uuid.UUID, need domain.SharePermission) (domain.File, KeyGrant, error) {
+	file, err := u.fileRepo.FindByID(ctx, id)
 	if err != nil {
-		return nil, domain.File{}, nil, err
+		return domain.File{}, KeyGrant{}, err
+	}
+	if file.Trashed() {
+		return domain.File{}, KeyGrant{}, domain.ErrFileUnavailable
+	}
+
+	if file.OwnerID == recipientID {
+		return file, KeyGrant{WrappedKey: file.EncryptedKey, KeyVersion: file.KeyVersion}, nil
+	}
+
+	now := time.Now()
+	share, err := u.shareRepo.FindByFileAndRecipient(ctx, id, recipientID)
+	if err == nil && share.ID != uuid.Nil && !share.Expired(now) && share.Permissions.Has(need) {
+		return file, KeyGrant{WrappedKey: share.WrappedKey, KeyVersion: share.KeyVersion}, nil
 	}
 
-	return content, file, share.WrappedKey, nil
+	groupShare, memberKey, err := u.groupRepo.FindShareForMember(ctx, id, recipientID, need, now)
+	if err == nil {
+		return file, KeyGrant{
+			WrappedKey:      groupShare.WrappedKey,
+			KeyVersion:      memberKey.UserKeyVersion,
+			GroupID:         groupShare.GroupID,
+			GroupKeyVersion: groupShare.KeyVersion,
+			WrappedGroupKey: memberKey.WrappedPrivateKey,
+		}, nil
+	}
+
+	if file.FolderID != nil {
+		if _, err := u.folderRepo.FindShareCovering(ctx, *file.FolderID, recipientID, need, now); err == nil {
+			return file, KeyGrant{WrappedKey: file.FolderWrappedKey, FolderID: *file.FolderID}, nil
+		}
+	}
+
+	if share.ID != uuid.Nil && !share.Expired(now) {
+		return domain.File{}, KeyGrant{}, domain.ErrPermissionDenied
+	}
+	// Without any grant the caller gets the same answer as for a missing
+	// file.
+	return domain.File{}, KeyGrant{}, domain.NotFound("file")
 }
 
-func (u *fileUsecase) GetByID(ctx context.Context, id uuid.UUID) (domain.File, error) {
-	return u.fileRepo.FindByID(ctx, id)
+// GetByID returns file metadata to its owner or to a recipient with
+// PermView. Recipients never see the owner's EncryptedKey.
+func (u *fileUsecase) GetByID(ctx context.Context, id, requesterID uuid.UUID) (domain.File, error) {
+	file, _, err := u.authorize(ctx, id, requesterID, domain.PermView)
+	if err != nil {
+		return domain.File{}, err
+	}
+	return redactOwnerKeys([]domain.File{file}, requesterID)[0], nil
 }
 
 func (u *fileUsecase) ListByOwner(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error) {
 	return u.fileRepo.FindByOwner(ctx, ownerID)
 }
 
+// UpdateMetadata replaces a file's encrypted metadata. Applied to a legacy
+// file it also erases the plaintext filename and MIME type.
+func (u *fileUsecase) UpdateMetadata(ctx context.Context, id, ownerID uuid.UUID, encryptedMetadata []byte) error {
+	if len(encryptedMetadata) == 0 {
+		return errMetadataRequired
+	}
+	if len(encryptedMetadata) > MaxEncryptedMetadataSize {
+		return errMetadataTooLarge
+	}
+	return u.fileRepo.UpdateMetadata(ctx, id, ownerID, encryptedMetadata)
+}
+
+// Delete moves a file into its owner's trash. Shares stay in place but stop
+// granting access until the file is restored.
 func (u *fileUsecase) Delete(ctx context.Context, id uuid.UUID, ownerID uuid.UUID) error {
 	file, err := u.fileRepo.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	if file.OwnerID != ownerID {
-		return errors.New("unauthorized: cannot delete someone else's file")
+		return domain.Forbidden("not_file_owner", "cannot delete someone else's file")
 	}
 
-	if err := u.storage.Delete(ctx, "files", id.String()); err != nil {
+	return u.fileRepo.Trash(ctx, id, ownerID, time.Now().UTC())
+}
+
+func (u *fileUsecase) ListTrash(ctx context.Context, ownerID uuid.UUID) ([]domain.File, error) {
+	return u.fileRepo.FindTrashed(ctx, ownerID)
+}
+
+func (u *fileUsecase) RestoreFromTrash(ctx context.Context, id, ownerID uuid.UUID) error {
+	return u.fileRepo.RestoreFromTrash(ctx, id, ownerID)
+}
+
+// DeleteForever removes a trashed file along with its stored ciphertext.
+func (u *fileUsecase) DeleteForever(ctx context.Context, id, ownerID uuid.UUID) error {
+	file, err := u.fileRepo.FindByID(ctx, id)
+	if err != nil || file.OwnerID != ownerID || !file.Trashed() {
+		return domain.NotFound("trashed file")
+	}
+	return u.fileRepo.Purge(ctx, file.ID, time.Now().UTC())
+}
+
+// EmptyTrash permanently deletes everything in the owner's trash. Files
+// restored while it runs are left alone.
+func (u *fileUsecase) EmptyTrash(ctx context.Context, ownerID uuid.UUID) (int, error) {
+	files, err := u.fileRepo.FindTrashed(ctx, ownerID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	purged := 0
+	for _, f := range files {
+		if err := u.fileRepo.Purge(ctx, f.ID, now); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				continue
+			}
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeTrash permanently deletes every file trashed before cutoff, then the
+// trashed folders left empty. Files restored while it runs are left alone.
+func (u *fileUsecase) PurgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	const batch = 100
+
+	purged := 0
+	for {
+		files, err := u.fileRepo.FindTrashedBefore(ctx, cutoff, batch)
+		if err != nil {
+			return purged, err
+		}
+		for _, f := range files {
+			if err := u.fileRepo.Purge(ctx, f.ID, cutoff); err != nil {
+				if errors.Is(err, domain.ErrNotFound) {
+					continue
+				}
+				return purged, err
+			}
+			purged++
+		}
+		if len(files) < batch {
+			break
+		}
+	}
+
+	if _, err := u.folderRepo.PurgeTrashed(ctx, cutoff); err != nil {
+		return purged, err
+	}
+	return purged, nil
+}
+
+// UploadVersion stores new ciphertext for an existing file and makes it the
+// current version. The version's content key, if any, is wrapped under the
+// file key, so existing shares keep working.
+func (u *fileUsecase) UploadVersion(ctx context.Context, id, ownerID uuid.UUID, version domain.FileVersion, content io.ReadCloser) (domain.FileVersion, error) {
+	file, err := u.versionedFile(ctx, id, ownerID)
+	if err != nil {
+		return domain.FileVersion{}, err
+	}
+
+	body, chunkSize, err := prepareContent(version.FormatVersion, content, version.Size)
+	if err != nil {
+		return domain.FileVersion{}, err
+	}
+
+	version.FileID = id
+	version.ChunkSize = chunkSize
+	version.ObjectName = id.String() + "/" + uuid.NewString()
+	if version.CreatedAt.IsZero() {
+		version.CreatedAt = time.Now().UTC()
+	}
+
+	saved, err := u.fileRepo.AddVersion(ctx, ownerID, version)
+	if err != nil {
+		return domain.FileVersion{}, err
+	}
+
+	if err := u.storage.Upload(ctx, "files", saved.ObjectName, body, saved.Size, file.MimeType); err != nil {
+		if rbErr := u.fileRepo.AbortVersion(ctx, id, saved.Version); rbErr != nil {
+			log.Printf("failed to roll back version %d of file %s: %v", saved.Version, id, rbErr)
+		}
+		return domain.FileVersion{}, err
+	}
+
+	if err := u.fileRepo.CommitVersion(ctx, id, saved.Version); err != nil {
+		return domain.FileVersion{}, err
+	}
+	saved.State = domain.FileStateCommitted
+	return saved, nil
+}
+
+func (u *fileUsecase) ListVersions(ctx context.Context, id, ownerID uuid.UUID) ([]domain.FileVersion, error) {
+	file, err := u.fileRepo.FindByID(ctx, id)
+	if err != nil || file.OwnerID != ownerID {
+		return nil, domain.NotFound("file")
+	}
+	return u.fileRepo.FindVersions(ctx, id)
+}
+
+// versionedFile returns the owner's file whose version history is about to
+// change. A trashed file's history is frozen until it is restored.
+func (u *fileUsecase) versionedFile(ctx context.Context, id, ownerID uuid.UUID) (domain.File, error) {
+	file, err := u.fileRepo.FindByID(ctx, id)
+	if err != nil || file.OwnerID != ownerID {
+		return domain.File{}, domain.NotFound("file")
+	}
+	if file.Trashed() {
+		return domain.File{}, domain.ErrFileUnavailable
+	}
+	return file, nil
+}
+
+func (u *fileUsecase) RestoreVersion(ctx context.Context, id, ownerID uuid.UUID, version int) error {
+	if _, err := u.versionedFile(ctx, id, ownerID); err != nil {
 		return err
 	}
+	return u.fileRepo.RestoreVersion(ctx, id, ownerID, version)
+}
 
-	return u.fileRepo.Delete(ctx, id)
+// DeleteVersion removes a version other than the current one.
+func (u *fileUsecase) DeleteVersion(ctx context.Context, id, ownerID uuid.UUID, version int) error {
+	if _, err := u.versionedFile(ctx, id, ownerID); err != nil {
+		return err
+	}
+	_, err := u.fileRepo.DeleteVersion(ctx, id, ownerID, version)
+	return err
+}
+
+// PruneVersions keeps the keep newest versions plus the current one and
+// deletes the rest, returning how many were removed.
+func (u *fileUsecase) PruneVersions(ctx context.Context, id, ownerID uuid.UUID, keep int) (int, error) {
+	if keep < 1 {
+		return 0, domain.Invalid("invalid_keep", "keep must be at least 1")
+	}
+	if _, err := u.versionedFile(ctx, id, ownerID); err != nil {
+		return 0, err
+	}
+
+	pruned, err := u.fileRepo.PruneVersions(ctx, id, ownerID, keep)
+	if err != nil {
+		return 0, err
+	}
+	return len(pruned), nil
 }
\ No newline at end of file
@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+	"github.com/google/uuid"
+)
+
+func TestDownloadAuthorization(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		perms     domain.SharePermission
+		expiresAt *time.Time
+		asOwner   bool
+		trashed   bool
+
+		wantErr    error
+		wantAnyErr bool
+		wantKey    string
+	}{
+		{name: "owner", asOwner: true, wantKey: "owner-wrapped-key"},
+		{name: "recipient with download", perms: domain.PermDefault, wantKey: "recipient-wrapped-key"},
+		{name: "recipient with view only", perms: domain.PermView, wantErr: domain.ErrPermissionDenied},
+		{name: "expired share", perms: domain.PermDefault, expiresAt: ptr(time.Now().Add(-time.Minute)), wantAnyErr: true},
+		{name: "no share", wantAnyErr: true},
+		{name: "trashed file", asOwner: true, trashed: true, wantErr: domain.ErrFileUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			owner := f.user(t)
+			file := f.file(t, owner.ID)
+
+			requester := owner.ID
+			if !tt.asOwner {
+				recipient := f.user(t)
+				if tt.perms != 0 {
+					repotest.NewShare(t, f.repos, file, recipient.ID, tt.perms, time.Now(), tt.expiresAt)
+				}
+				requester = recipient.ID
+			}
+			if tt.trashed {
+				if err := f.files.Delete(ctx, file.ID, owner.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			content, got, grant, err := f.files.Download(ctx, file.ID, requester)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("download: %v", err)
+			}
+			defer content.Close()
+
+			data, err := io.ReadAll(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(data)) != got.Size {
+				t.Errorf("read %d bytes, want %d", len(data), got.Size)
+			}
+			if string(grant.WrappedKey) != tt.wantKey {
+				t.Errorf("wrapped key = %q, want %q", grant.WrappedKey, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestGetByIDRedactsOwnerKey(t *testing.T) {
+	f := newFixture(t)
+	owner := f.user(t)
+	recipient := f.user(t)
+	file := f.file(t, owner.ID)
+	repotest.NewShare(t, f.repos, file, recipient.ID, domain.PermView, time.Now(), nil)
+
+	got, err := f.files.GetByID(context.Background(), file.ID, recipient.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.EncryptedKey != nil {
+		t.Fatal("recipient saw the owner's wrapped key")
+	}
+
+	if _, err := f.files.GetByID(context.Background(), file.ID, uuid.New()); err == nil {
+		t.Fatal("stranger got the file")
+	}
+}
+
+func TestTrashLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	owner := f.user(t)
+	file := f.file(t, owner.ID)
+
+	if err := f.files.DeleteForever(ctx, file.ID, owner.ID); err == nil {
+		t.Fatal("deleted a file that is not in the trash")
+	}
+	if err := f.files.Delete(ctx, file.ID, owner.ID); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if files, _ := f.files.ListByOwner(ctx, owner.ID); len(files) != 0 {
+		t.Fatalf("trashed file still listed: %v", files)
+	}
+	if err := f.files.RestoreFromTrash(ctx, file.ID, owner.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := f.files.Delete(ctx, file.ID, owner.ID); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+
+	purged, err := f.files.PurgeTrash(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d, want 1", purged)
+	}
+	usage, err := f.usage.Get(ctx, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.UsedBytes != 0 {
+		t.Fatalf("used bytes = %d after purge, want 0", usage.UsedBytes)
+	}
+}
+
+func TestTrashedFileVersionsAreFrozen(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	owner := f.user(t)
+	file := f.file(t, owner.ID)
+	if err := f.files.Delete(ctx, file.ID, owner.ID); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+
+	if err := f.files.RestoreVersion(ctx, file.ID, owner.ID, 1); !errors.Is(err, domain.ErrFileUnavailable) {
+		t.Errorf("restore version: err = %v, want ErrFileUnavailable", err)
+	}
+	if err := f.files.DeleteVersion(ctx, file.ID, owner.ID, 1); !errors.Is(err, domain.ErrFileUnavailable) {
+		t.Errorf("delete version: err = %v, want ErrFileUnavailable", err)
+	}
+	if _, err := f.files.PruneVersions(ctx, file.ID, owner.ID, 1); !errors.Is(err, domain.ErrFileUnavailable) {
+		t.Errorf("prune versions: err = %v, want ErrFileUnavailable", err)
+	}
+	if err := f.files.RestoreVersion(ctx, file.ID, f.user(t).ID, 1); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("restore version as a stranger: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/memory"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+	"github.com/google/uuid"
+)
+
+// fixture wires usecases to the in-memory repositories and storage.
+type fixture struct {
+	repos   repotest.Repositories
+	usage   repository.UsageRepository
+	storage *storage.MemoryStorage
+
+	files  FileUsecase
+	shares ShareUsecase
+}
+
+func newFixture(t *testing.T) *fixture {
+	s := memory.NewStore()
+	f := &fixture{
+		repos: repotest.Repositories{
+			Users:  memory.NewUserRepository(s),
+			Files:  memory.NewFileRepository(s),
+			Shares: memory.NewShareRepository(s),
+			Groups: memory.NewGroupRepository(s),
+		},
+		usage:   memory.NewUsageRepository(s),
+		storage: storage.NewMemoryStorage(),
+	}
+	f.files = NewFileUsecase(f.repos.Files, f.repos.Shares, f.repos.Groups, repotest.NoFolders{}, f.storage, MetadataCompat)
+	f.shares = NewShareUsecase(f.repos.Shares, f.repos.Files)
+	return f
+}
+
+func (f *fixture) user(t *testing.T) domain.User {
+	t.Helper()
+	return repotest.NewUser(t, f.repos, "user-")
+}
+
+// file stores a committed file with content owned by ownerID.
+func (f *fixture) file(t *testing.T, ownerID uuid.UUID) domain.File {
+	t.Helper()
+	file, err := f.files.Upload(context.Background(), domain.File{
+		OwnerID:      ownerID,
+		Size:         10,
+		IV:           []byte("iv"),
+		EncryptedKey: []byte("owner-wrapped-key"),
+	}, body(bytes.Repeat([]byte("x"), 10)))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	return file
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,244 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+// FolderContents is one level of a folder listing.
+type FolderContents struct {
+	Folder  domain.Folder
+	Folders []domain.Folder
+	Files   []domain.File
+}
+
+type FolderUsecase interface {
+	Create(ctx context.Context, folder domain.Folder) (domain.Folder, error)
+	ListRoot(ctx context.Context, ownerID uuid.UUID) (FolderContents, error)
+	Get(ctx context.Context, id, userID uuid.UUID) (FolderContents, error)
+	Tree(ctx context.Context, id, userID uuid.UUID) ([]domain.Folder, []domain.File, error)
+	Rename(ctx context.Context, id, ownerID uuid.UUID, encryptedName []byte) error
+	Move(ctx context.Context, id, ownerID uuid.UUID, parentID *uuid.UUID, wrappedKey []byte, keyVersion int) error
+	Delete(ctx context.Context, id, ownerID uuid.UUID) error
+	MoveFile(ctx context.Context, fileID, ownerID uuid.UUID, folderID *uuid.UUID, folderWrappedKey []byte) error
+	Share(ctx context.Context, ownerID uuid.UUID, share domain.FolderShare) (domain.FolderShare, error)
+	ListShares(ctx context.Context, folderID, ownerID uuid.UUID) ([]domain.FolderShare, error)
+	ListSharedWith(ctx context.Context, recipientID uuid.UUID) ([]domain.FolderShare, error)
+	Unshare(ctx context.Context, folderID, shareID, ownerID uuid.UUID) error
+}
+
+type folderUsecase struct {
+	folderRepo repository.FolderRepository
+	fileRepo   repository.FileRepository
+}
+
+func NewFolderUsecase(folderRepo repository.FolderRepository, fileRepo repository.FileRepository) FolderUsecase {
+	return &folderUsecase{folderRepo: folderRepo, fileRepo: fileRepo}
+}
+
+func (u *folderUsecase) Create(ctx context.Context, folder domain.Folder) (domain.Folder, error) {
+	if len(folder.EncryptedName) == 0 || len(folder.WrappedKey) == 0 {
+		return domain.Folder{}, domain.Invalid("folder_key_required", "encrypted name and wrapped key are required")
+	}
+
+	now := time.Now().UTC()
+	folder.ID = uuid.New()
+	folder.CreatedAt = now
+	folder.UpdatedAt = now
+
+	if err := u.folderRepo.Save(ctx, folder); err != nil {
+		return domain.Folder{}, err
+	}
+	return folder, nil
+}
+
+// ownedFolder loads a folder belonging to ownerID.
+func (u *folderUsecase) ownedFolder(ctx context.Context, id, ownerID uuid.UUID) (domain.Folder, error) {
+	folder, err := u.folderRepo.FindByID(ctx, id)
+	if err != nil || folder.OwnerID != ownerID {
+		return domain.Folder{}, domain.NotFound("folder")
+	}
+	return folder, nil
+}
+
+// readableFolder loads a folder that userID owns or reaches through a share
+// of the folder or one of its ancestors.
+func (u *folderUsecase) readableFolder(ctx context.Context, id, userID uuid.UUID) (domain.Folder, error) {
+	folder, err := u.folderRepo.FindByID(ctx, id)
+	if err != nil {
+		return domain.Folder{}, domain.NotFound("folder")
+	}
+	if folder.OwnerID == userID {
+		return folder, nil
+	}
+	if _, err := u.folderRepo.FindShareCovering(ctx, id, userID, domain.PermView, time.Now()); err != nil {
+		return domain.Folder{}, domain.NotFound("folder")
+	}
+	return folder, nil
+}
+
+func (u *folderUsecase) ListRoot(ctx context.Context, ownerID uuid.UUID) (FolderContents, error) {
+	folders, err := u.folderRepo.FindChildren(ctx, ownerID, nil)
+	if err != nil {
+		return FolderContents{}, err
+	}
+
+	files, err := u.fileRepo.FindByFolder(ctx, ownerID, nil)
+	if err != nil {
+		return FolderContents{}, err
+	}
+
+	return FolderContents{Folders: folders, Files: files}, nil
+}
+
+func (u *folderUsecase) Get(ctx context.Context, id, userID uuid.UUID) (FolderContents, error) {
+	folder, err := u.readableFolder(ctx, id, userID)
+	if err != nil {
+		return FolderContents{}, err
+	}
+
+	folders, err := u.folderRepo.FindChildren(ctx, folder.OwnerID, &folder.ID)
+	if err != nil {
+		return FolderContents{}, err
+	}
+
+	files, err := u.fileRepo.FindByFolder(ctx, folder.OwnerID, &folder.ID)
+	if err != nil {
+		return FolderContents{}, err
+	}
+
+	return FolderContents{Folder: folder, Folders: folders, Files: redactOwnerKeys(files, userID)}, nil
+}
+
+// Tree returns a folder with all of its descendant folders and files.
+func (u *folderUsecase) Tree(ctx context.Context, id, userID uuid.UUID) ([]domain.Folder, []domain.File, error) {
+	if _, err := u.readableFolder(ctx, id, userID); err != nil {
+		return nil, nil, err
+	}
+
+	folders, err := u.folderRepo.FindSubtree(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := u.fileRepo.FindInFolderTree(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return folders, redactOwnerKeys(files, userID), nil
+}
+
+// redactOwnerKeys strips the owner's wrapped file keys from files listed for
+// someone else; folder recipients use FolderWrappedKey instead.
+func redactOwnerKeys(files []domain.File, userID uuid.UUID) []domain.File {
+	for i := range files {
+		if files[i].OwnerID != userID {
+			files[i].EncryptedKey = nil
+		}
+	}
+	return files
+}
+
+func (u *folderUsecase) Rename(ctx context.Context, id, ownerID uuid.UUID, encryptedName []byte) error {
+	if len(encryptedName) == 0 {
+		return domain.Invalid("folder_name_required", "encrypted name is required")
+	}
+	if _, err := u.ownedFolder(ctx, id, ownerID); err != nil {
+		return err
+	}
+	return u.folderRepo.Rename(ctx, id, encryptedName, time.Now().UTC())
+}
+
+// Move reparents a folder. Because the folder key is wrapped under its
+// parent's key, the client must supply it rewrapped for the new parent, or
+// for its own keypair (keyVersion) when moving to the top level.
+func (u *folderUsecase) Move(ctx context.Context, id, ownerID uuid.UUID, parentID *uuid.UUID, wrappedKey []byte, keyVersion int) error {
+	if len(wrappedKey) == 0 {
+		return domain.Invalid("wrapped_key_required", "wrapped key is required")
+	}
+	if _, err := u.ownedFolder(ctx, id, ownerID); err != nil {
+		return err
+	}
+	return u.folderRepo.Move(ctx, id, parentID, wrappedKey, keyVersion, time.Now().UTC())
+}
+
+// Delete moves a folder, its descendants and every file inside them into
+// the trash. The files can be restored one by one, landing at the top level;
+// the folders are removed once the trash is purged.
+func (u *folderUsecase) Delete(ctx context.Context, id, ownerID uuid.UUID) error {
+	if _, err := u.ownedFolder(ctx, id, ownerID); err != nil {
+		return err
+	}
+
+	return u.folderRepo.Trash(ctx, id, time.Now().UTC())
+}
+
+// MoveFile places a file in folderID, or at the top level when nil, with its
+// key rewrapped under the target folder's key.
+func (u *folderUsecase) MoveFile(ctx context.Context, fileID, ownerID uuid.UUID, folderID *uuid.UUID, folderWrappedKey []byte) error {
+	if folderID != nil && len(folderWrappedKey) == 0 {
+		return domain.Invalid("folder_wrapped_key_required", "folder wrapped key is required")
+	}
+	if folderID == nil {
+		folderWrappedKey = nil
+	}
+	return u.fileRepo.MoveToFolder(ctx, fileID, ownerID, folderID, folderWrappedKey)
+}
+
+// Share grants a recipient access to a folder and everything below it. A
+// zero KeyVersion means the recipient's current key and zero Permissions
+// means domain.PermDefault.
+func (u *folderUsecase) Share(ctx context.Context, ownerID uuid.UUID, share domain.FolderShare) (domain.FolderShare, error) {
+	if _, err := u.ownedFolder(ctx, share.FolderID, ownerID); err != nil {
+		return domain.FolderShare{}, err
+	}
+	if share.RecipientID == ownerID {
+		return domain.FolderShare{}, domain.Invalid("share_with_self", "cannot share a folder with yourself")
+	}
+	if err := validateShareExpiry(share.ExpiresAt); err != nil {
+		return domain.FolderShare{}, err
+	}
+
+	share.Permissions = share.Permissions.Normalize()
+	if share.Permissions == 0 {
+		share.Permissions = domain.PermDefault
+	}
+
+	share.ID = uuid.New()
+	share.GrantedBy = ownerID
+	share.CreatedAt = time.Now().UTC()
+
+	if err := u.folderRepo.SaveShare(ctx, share); err != nil {
+		return domain.FolderShare{}, err
+	}
+	return share, nil
+}
+
+func (u *folderUsecase) ListShares(ctx context.Context, folderID, ownerID uuid.UUID) ([]domain.FolderShare, error) {
+	if _, err := u.ownedFolder(ctx, folderID, ownerID); err != nil {
+		return nil, err
+	}
+	return u.folderRepo.FindSharesByFolder(ctx, folderID)
+}
+
+func (u *folderUsecase) ListSharedWith(ctx context.Context, recipientID uuid.UUID) ([]domain.FolderShare, error) {
+	return u.folderRepo.FindSharesByRecipient(ctx, recipientID, time.Now())
+}
+
+func (u *folderUsecase) Unshare(ctx context.Context, folderID, shareID, ownerID uuid.UUID) error {
+	if _, err := u.ownedFolder(ctx, folderID, ownerID); err != nil {
+		return err
+	}
+
+	share, err := u.folderRepo.FindShareByID(ctx, shareID)
+	if err != nil || share.FolderID != folderID {
+		return domain.NotFound("share")
+	}
+
+	return u.folderRepo.DeleteShare(ctx, shareID)
+}
@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+type GroupUsecase interface {
+	Create(ctx context.Context, ownerID uuid.UUID, name string, publicKey []byte, memberKeys []domain.GroupMemberKey) (domain.Group, error)
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]domain.Group, error)
+	Get(ctx context.Context, groupID, userID uuid.UUID) (domain.Group, []domain.GroupMember, []domain.GroupMemberKey, error)
+	Rotate(ctx context.Context, actorID uuid.UUID, rotation domain.GroupRotation) (domain.Group, error)
+	Delete(ctx context.Context, groupID, actorID uuid.UUID) error
+	ShareFile(ctx context.Context, grantorID uuid.UUID, share domain.GroupShare) (domain.GroupShare, error)
+	ListShares(ctx context.Context, groupID, userID uuid.UUID) ([]domain.GroupShare, error)
+	Unshare(ctx context.Context, groupID, shareID, actorID uuid.UUID) error
+}
+
+type groupUsecase struct {
+	groupRepo repository.GroupRepository
+	fileRepo  repository.FileRepository
+}
+
+func NewGroupUsecase(groupRepo repository.GroupRepository, fileRepo repository.FileRepository) GroupUsecase {
+	return &groupUsecase{groupRepo: groupRepo, fileRepo: fileRepo}
+}
+
+// Create makes ownerID the administrator of a new group. memberKeys holds
+// the group private key wrapped for each initial member and must include
+// the owner.
+func (u *groupUsecase) Create(ctx context.Context, ownerID uuid.UUID, name string, publicKey []byte, memberKeys []domain.GroupMemberKey) (domain.Group, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return domain.Group{}, domain.Invalid("group_name_required", "group name is required")
+	}
+	if len(publicKey) == 0 {
+		return domain.Group{}, domain.Invalid("group_public_key_required", "group public key is required")
+	}
+	if !slices.ContainsFunc(memberKeys, func(k domain.GroupMemberKey) bool { return k.UserID == ownerID }) {
+		return domain.Group{}, domain.Invalid("group_owner_key_missing", "the group key must be wrapped for its owner")
+	}
+
+	group := domain.Group{
+		ID:         uuid.New(),
+		Name:       name,
+		OwnerID:    ownerID,
+		KeyVersion: 1,
+		PublicKey:  string(publicKey),
+		CreatedAt:  time.Now().UTC(),
+	}
+	for i := range memberKeys {
+		memberKeys[i].GroupID = group.ID
+		memberKeys[i].Version = group.KeyVersion
+	}
+
+	if err := u.groupRepo.Create(ctx, group, memberKeys); err != nil {
+		return domain.Group{}, err
+	}
+	return group, nil
+}
+
+func (u *groupUsecase) ListForUser(ctx context.Context, userID uuid.UUID) ([]domain.Group, error) {
+	return u.groupRepo.FindByMember(ctx, userID)
+}
+
+// memberGroup loads a group userID belongs to.
+func (u *groupUsecase) memberGroup(ctx context.Context, groupID, userID uuid.UUID) (domain.Group, error) {
+	ok, err := u.groupRepo.IsMember(ctx, groupID, userID)
+	if err != nil {
+		return domain.Group{}, err
+	}
+	if !ok {
+		return domain.Group{}, domain.NotFound("group")
+	}
+	return u.groupRepo.FindByID(ctx, groupID)
+}
+
+// ownedGroup loads a group administered by userID.
+func (u *groupUsecase) ownedGroup(ctx context.Context, groupID, userID uuid.UUID) (domain.Group, error) {
+	group, err := u.groupRepo.FindByID(ctx, groupID)
+	if err != nil {
+		return domain.Group{}, domain.NotFound("group")
+	}
+	if group.OwnerID != userID {
+		return domain.Group{}, domain.Forbidden("not_group_owner", "only the group owner can do that")
+	}
+	return group, nil
+}
+
+// Get returns a group, its members and every version of the group private
+// key wrapped for userID.
+func (u *groupUsecase) Get(ctx context.Context, groupID, userID uuid.UUID) (domain.Group, []domain.GroupMember, []domain.GroupMemberKey, error) {
+	group, err := u.memberGroup(ctx, groupID, userID)
+	if err != nil {
+		return domain.Group{}, nil, nil, err
+	}
+
+	members, err := u.groupRepo.ListMembers(ctx, groupID)
+	if err != nil {
+		return domain.Group{}, nil, nil, err
+	}
+
+	keys, err := u.groupRepo.FindMemberKeys(ctx, groupID, userID)
+	if err != nil {
+		return domain.Group{}, nil, nil, err
+	}
+
+	return group, members, keys, nil
+}
+
+// Rotate is the only way to change a group's membership: every change
+// installs a fresh group keypair so that removed members cannot read files
+// shared with the group afterwards. rotation.Version must be one past the
+// group's current version, and rotation.Shares must rewrap every file the
+// group holds for the new keypair.
+func (u *groupUsecase) Rotate(ctx context.Context, actorID uuid.UUID, rot domain.GroupRotation) (domain.Group, error) {
+	group, err := u.ownedGroup(ctx, rot.GroupID, actorID)
+	if err != nil {
+		return domain.Group{}, err
+	}
+	if len(rot.PublicKey) == 0 {
+		return domain.Group{}, domain.Invalid("group_public_key_required", "group public key is required")
+	}
+	if slices.Contains(rot.Remove, group.OwnerID) {
+		return domain.Group{}, domain.Invalid("group_owner_removed", "the group owner cannot be removed")
+	}
+	if rot.Version == 0 {
+		rot.Version = group.KeyVersion + 1
+	}
+
+	rot.CreatedAt = time.Now().UTC()
+	for i := range rot.MemberKeys {
+		rot.MemberKeys[i].GroupID = group.ID
+		rot.MemberKeys[i].Version = rot.Version
+	}
+
+	if err := u.groupRepo.Rotate(ctx, rot); err != nil {
+		return domain.Group{}, err
+	}
+
+	group.KeyVersion = rot.Version
+	group.PublicKey = rot.PublicKey
+	return group, nil
+}
+
+func (u *groupUsecase) Delete(ctx context.Context, groupID, actorID uuid.UUID) error {
+	if _, err := u.ownedGroup(ctx, groupID, actorID); err != nil {
+		return err
+	}
+	return u.groupRepo.Delete(ctx, groupID)
+}
+
+// ShareFile shares a file the grantor owns with every member of a group
+// using a single wrapped key. A zero KeyVersion means the group's current
+// key and zero Permissions means domain.PermDefault.
+func (u *groupUsecase) ShareFile(ctx context.Context, grantorID uuid.UUID, share domain.GroupShare) (domain.GroupShare, error) {
+	file, err := u.fileRepo.FindByID(ctx, share.FileID)
+	if err != nil {
+		return domain.GroupShare{}, err
+	}
+	if file.Trashed() {
+		return domain.GroupShare{}, domain.ErrFileUnavailable
+	}
+	if file.OwnerID != grantorID {
+		return domain.GroupShare{}, domain.ErrPermissionDenied
+	}
+
+	if _, err := u.groupRepo.FindByID(ctx, share.GroupID); err != nil {
+		return domain.GroupShare{}, domain.NotFound("group")
+	}
+
+	if err := validateShareExpiry(share.ExpiresAt); err != nil {
+		return domain.GroupShare{}, err
+	}
+
+	share.Permissions = share.Permissions.Normalize()
+	if share.Permissions == 0 {
+		share.Permissions = domain.PermDefault
+	}
+
+	share.ID = uuid.New()
+	share.GrantedBy = grantorID
+	share.CreatedAt = time.Now().UTC()
+
+	if err := u.groupRepo.SaveShare(ctx, share); err != nil {
+		return domain.GroupShare{}, err
+	}
+	return share, nil
+}
+
+func (u *groupUsecase) ListShares(ctx context.Context, groupID, userID uuid.UUID) ([]domain.GroupShare, error) {
+	if _, err := u.memberGroup(ctx, groupID, userID); err != nil {
+		return nil, err
+	}
+	return u.groupRepo.FindSharesByGroup(ctx, groupID)
+}
+
+// Unshare removes a group share. Either the file owner or the group owner
+// may do so.
+func (u *groupUsecase) Unshare(ctx context.Context, groupID, shareID, actorID uuid.UUID) error {
+	share, err := u.groupRepo.FindShareByID(ctx, shareID)
+	if err != nil || share.GroupID != groupID {
+		return domain.NotFound("share")
+	}
+
+	file, err := u.fileRepo.FindByID(ctx, share.FileID)
+	if err != nil {
+		return err
+	}
+
+	if file.OwnerID != actorID {
+		if _, err := u.ownedGroup(ctx, share.GroupID, actorID); err != nil {
+			return domain.ErrPermissionDenied
+		}
+	}
+
+	return u.groupRepo.DeleteShare(ctx, shareID)
+}
@@ -0,0 +1,225 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/keylog"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/merkle"
+	"github.com/google/uuid"
+)
+
+const keyLogAppendAttempts = 5
+
+type InclusionProof struct {
+	Entry domain.KeyLogEntry
+	Head  domain.TreeHead
+	Proof [][]byte
+}
+
+// KeyLogWriter persists a key log entry and the subtree roots it completes,
+// returning domain.ErrKeyLogIndexTaken when entry.Index is already used.
+type KeyLogWriter func(ctx context.Context, entry domain.KeyLogEntry, nodes []domain.KeyLogNode) error
+
+type KeyLogUsecase interface {
+	Append(ctx context.Context, userID uuid.UUID, publicKey []byte) error
+	AppendWith(ctx context.Context, userID uuid.UUID, publicKey []byte, write KeyLogWriter) error
+	PublicKey() ed25519.PublicKey
+	TreeHead(ctx context.Context, treeSize int64) (domain.TreeHead, error)
+	InclusionProof(ctx context.Context, userID uuid.UUID, treeSize int64) (InclusionProof, error)
+	ConsistencyProof(ctx context.Context, first, second int64) ([][]byte, error)
+}
+
+type keyLogUsecase struct {
+	keyLogRepo repository.KeyLogRepository
+	signingKey ed25519.PrivateKey
+}
+
+func NewKeyLogUsecase(keyLogRepo repository.KeyLogRepository, signingKey ed25519.PrivateKey) KeyLogUsecase {
+	return &keyLogUsecase{keyLogRepo: keyLogRepo, signingKey: signingKey}
+}
+
+func (u *keyLogUsecase) PublicKey() ed25519.PublicKey {
+	return u.signingKey.Public().(ed25519.PublicKey)
+}
+
+func (u *keyLogUsecase) Append(ctx context.Context, userID uuid.UUID, publicKey []byte) error {
+	return u.AppendWith(ctx, userID, publicKey, u.keyLogRepo.AppendEntry)
+}
+
+// AppendWith logs publicKey for userID through write, which lets a caller
+// store the entry in the same transaction as the change it records. The
+// tree head is published once write succeeds.
+func (u *keyLogUsecase) AppendWith(ctx context.Context, userID uuid.UUID, publicKey []byte, write KeyLogWriter) error {
+	for attempt := 0; attempt < keyLogAppendAttempts; attempt++ {
+		size, err := u.keyLogRepo.Size(ctx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		data := keylog.Entry{UserID: userID, PublicKey: publicKey, Timestamp: now.UnixMilli()}.Encode()
+		leafHash := merkle.LeafHash(data)
+		nodes, err := u.completedNodes(ctx, uint64(size), leafHash)
+		if err != nil {
+			return err
+		}
+
+		err = write(ctx, domain.KeyLogEntry{
+			Index:     size,
+			UserID:    userID,
+			PublicKey: publicKey,
+			LeafData:  data,
+			LeafHash:  leafHash,
+			CreatedAt: now,
+		}, nodes)
+		if errors.Is(err, domain.ErrKeyLogIndexTaken) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		return u.publishTreeHead(ctx, size+1)
+	}
+
+	return domain.Conflict("key_log_busy", "key log is busy, try again")
+}
+
+// completedNodes hashes the subtrees that appending leafHash at index
+// completes, each from its stored left child and the node below it.
+func (u *keyLogUsecase) completedNodes(ctx context.Context, index uint64, leafHash []byte) ([]domain.KeyLogNode, error) {
+	ids := merkle.CompletedBy(index)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	lefts := make([]merkle.NodeID, len(ids))
+	for i, id := range ids {
+		lefts[i], _ = id.Children()
+	}
+	stored, err := u.keyLogRepo.FindNodes(ctx, lefts)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]domain.KeyLogNode, 0, len(ids))
+	hash := leafHash
+	for i, id := range ids {
+		left, ok := stored[lefts[i]]
+		if !ok {
+			return nil, fmt.Errorf("key log node %d/%d is missing", lefts[i].Level, lefts[i].Index)
+		}
+		hash = merkle.NodeHash(left, hash)
+		nodes = append(nodes, domain.KeyLogNode{Level: int(id.Level), Index: int64(id.Index), Hash: hash})
+	}
+	return nodes, nil
+}
+
+// rangeRoots returns the root of each range from the stored subtree roots,
+// reading them all in one query.
+func (u *keyLogUsecase) rangeRoots(ctx context.Context, ranges []merkle.Range) ([][]byte, error) {
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	var ids []merkle.NodeID
+	for _, r := range ranges {
+		ids = append(ids, r.Nodes()...)
+	}
+	stored, err := u.keyLogRepo.FindNodes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := make([][]byte, 0, len(ranges))
+	for _, r := range ranges {
+		nodes := r.Nodes()
+		hashes := make([][]byte, len(nodes))
+		for i, id := range nodes {
+			h, ok := stored[id]
+			if !ok {
+				return nil, fmt.Errorf("key log node %d/%d is missing", id.Level, id.Index)
+			}
+			hashes[i] = h
+		}
+		roots = append(roots, merkle.Fold(hashes))
+	}
+	return roots, nil
+}
+
+func (u *keyLogUsecase) publishTreeHead(ctx context.Context, size int64) error {
+	roots, err := u.rangeRoots(ctx, []merkle.Range{{Start: 0, End: uint64(size)}})
+	if err != nil {
+		return err
+	}
+
+	head := keylog.SignTreeHead(keylog.TreeHead{
+		TreeSize:  uint64(size),
+		Timestamp: time.Now().UTC().UnixMilli(),
+		RootHash:  roots[0],
+	}, u.signingKey)
+
+	return u.keyLogRepo.SaveTreeHead(ctx, domain.TreeHead{
+		TreeSize:    size,
+		RootHash:    head.RootHash,
+		TimestampMs: head.Timestamp,
+		Signature:   head.Signature,
+	})
+}
+
+// TreeHead returns the signed head for treeSize, or the latest head when
+// treeSize is zero.
+func (u *keyLogUsecase) TreeHead(ctx context.Context, treeSize int64) (domain.TreeHead, error) {
+	if treeSize == 0 {
+		return u.keyLogRepo.LatestTreeHead(ctx)
+	}
+	return u.keyLogRepo.FindTreeHead(ctx, treeSize)
+}
+
+func (u *keyLogUsecase) InclusionProof(ctx context.Context, userID uuid.UUID, treeSize int64) (InclusionProof, error) {
+	head, err := u.TreeHead(ctx, treeSize)
+	if err != nil {
+		return InclusionProof{}, domain.NotFound("tree head")
+	}
+
+	entry, err := u.keyLogRepo.FindLatestByUser(ctx, userID, head.TreeSize-1)
+	if err != nil {
+		return InclusionProof{}, domain.NotFound("key log entry")
+	}
+
+	ranges, err := merkle.InclusionRanges(uint64(entry.Index), uint64(head.TreeSize))
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	proof, err := u.rangeRoots(ctx, ranges)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+
+	return InclusionProof{Entry: entry, Head: head, Proof: proof}, nil
+}
+
+func (u *keyLogUsecase) ConsistencyProof(ctx context.Context, first, second int64) ([][]byte, error) {
+	if first <= 0 || first > second {
+		return nil, domain.Invalid("invalid_tree_sizes", "invalid tree sizes")
+	}
+
+	size, err := u.keyLogRepo.Size(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if size < second {
+		return nil, domain.NotFound("tree size")
+	}
+
+	ranges, err := merkle.ConsistencyRanges(uint64(first), uint64(second))
+	if err != nil {
+		return nil, err
+	}
+	return u.rangeRoots(ctx, ranges)
+}
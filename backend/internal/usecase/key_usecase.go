@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+type KeyUsecase interface {
+	Rotate(ctx context.Context, userID uuid.UUID, publicKey, encryptedPrivateKey []byte) (domain.UserKey, error)
+	ListKeys(ctx context.Context, userID uuid.UUID) ([]domain.UserKey, error)
+	RewrapQueue(ctx context.Context, userID uuid.UUID) ([]domain.RewrapItem, error)
+	SubmitRewrap(ctx context.Context, userID uuid.UUID, items []domain.RewrapItem) error
+}
+
+type keyUsecase struct {
+	userRepo    repository.UserRepository
+	userKeyRepo repository.UserKeyRepository
+	fileRepo    repository.FileRepository
+	shareRepo   repository.ShareRepository
+	keyLog      KeyLogUsecase
+}
+
+func NewKeyUsecase(userRepo repository.UserRepository, userKeyRepo repository.UserKeyRepository, fileRepo repository.FileRepository, shareRepo repository.ShareRepository, keyLog KeyLogUsecase) KeyUsecase {
+	return &keyUsecase{userRepo: userRepo, userKeyRepo: userKeyRepo, fileRepo: fileRepo, shareRepo: shareRepo, keyLog: keyLog}
+}
+
+func (u *keyUsecase) Rotate(ctx context.Context, userID uuid.UUID, publicKey, encryptedPrivateKey []byte) (domain.UserKey, error) {
+	if len(publicKey) == 0 || len(encryptedPrivateKey) == 0 {
+		return domain.UserKey{}, domain.Invalid("key_required", "public key and encrypted private key are required")
+	}
+
+	user, err := u.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return domain.UserKey{}, err
+	}
+
+	key := domain.UserKey{
+		UserID:              userID,
+		Version:             user.KeyVersion + 1,
+		PublicKey:           string(publicKey),
+		EncryptedPrivateKey: encryptedPrivateKey,
+		CreatedAt:           time.Now().UTC(),
+	}
+
+	err = u.keyLog.AppendWith(ctx, userID, publicKey, func(ctx context.Context, entry domain.KeyLogEntry, nodes []domain.KeyLogNode) error {
+		return u.userKeyRepo.Rotate(ctx, key, entry, nodes)
+	})
+	if err != nil {
+		return domain.UserKey{}, err
+	}
+
+	return key, nil
+}
+
+func (u *keyUsecase) ListKeys(ctx context.Context, userID uuid.UUID) ([]domain.UserKey, error) {
+	return u.userKeyRepo.FindByUser(ctx, userID)
+}
+
+func (u *keyUsecase) RewrapQueue(ctx context.Context, userID uuid.UUID) ([]domain.RewrapItem, error) {
+	user, err := u.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := u.shareRepo.FindStaleByRecipient(ctx, userID, user.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := u.fileRepo.FindStaleByOwner(ctx, userID, user.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]domain.RewrapItem, 0, len(shares)+len(files))
+	for _, s := range shares {
+		items = append(items, domain.RewrapItem{
+			Kind:       domain.RewrapKindShare,
+			ID:         s.ID,
+			FileID:     s.FileID,
+			KeyVersion: s.KeyVersion,
+			WrappedKey: s.WrappedKey,
+		})
+	}
+	for _, f := range files {
+		items = append(items, domain.RewrapItem{
+			Kind:       domain.RewrapKindFile,
+			ID:         f.ID,
+			FileID:     f.ID,
+			KeyVersion: f.KeyVersion,
+			WrappedKey: f.EncryptedKey,
+		})
+	}
+
+	return items, nil
+}
+
+// SubmitRewrap stores keys the client has rewrapped, all or none. Every item
+// must target the user's current key version, so a rotation racing with a
+// rewrap can never leave a key wrapped for a version the server thinks is
+// current.
+func (u *keyUsecase) SubmitRewrap(ctx context.Context, userID uuid.UUID, items []domain.RewrapItem) error {
+	user, err := u.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if item.KeyVersion != user.KeyVersion {
+			return domain.Invalid("stale_key_version", fmt.Sprintf("item %s must be wrapped for key version %d", item.ID, user.KeyVersion))
+		}
+		if len(item.WrappedKey) == 0 {
+			return domain.Invalid("wrapped_key_required", fmt.Sprintf("item %s has no wrapped key", item.ID))
+		}
+		if item.Kind != domain.RewrapKindShare && item.Kind != domain.RewrapKindFile {
+			return domain.Invalid("unknown_rewrap_kind", fmt.Sprintf("unknown rewrap kind %q", item.Kind))
+		}
+	}
+
+	return u.userKeyRepo.ApplyRewrap(ctx, userID, user.KeyVersion, items)
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/password"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLinkTTL = 7 * 24 * time.Hour
+	MaxLinkTTL     = 30 * 24 * time.Hour
+
+	// A link accepts linkAttemptsPerLink password guesses, and one client
+	// address linkAttemptsPerIP guesses across all links, per window.
+	linkAttemptWindow   = 15 * time.Minute
+	linkAttemptsPerLink = 5
+	linkAttemptsPerIP   = 20
+)
+
+type LinkUsecase interface {
+	Create(ctx context.Context, fileID, ownerID uuid.UUID, expiresAt time.Time, maxDownloads int, linkPassword string) (domain.LinkShare, string, error)
+	List(ctx context.Context, ownerID, fileID uuid.UUID) ([]domain.LinkShare, error)
+	Revoke(ctx context.Context, id, ownerID uuid.UUID) error
+	Open(ctx context.Context, token, linkPassword, clientIP string) (io.ReadCloser, domain.File, error)
+	PurgeAttempts(ctx context.Context, now time.Time) (int64, error)
+}
+
+type linkUsecase struct {
+	linkRepo repository.LinkShareRepository
+	fileRepo repository.FileRepository
+	storage  storage.Storage
+}
+
+func NewLinkUsecase(linkRepo repository.LinkShareRepository, fileRepo repository.FileRepository, storage storage.Storage) LinkUsecase {
+	return &linkUsecase{linkRepo: linkRepo, fileRepo: fileRepo, storage: storage}
+}
+
+func hashLinkToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// Create issues a new link for a file the caller owns and returns the
+// plaintext token, which is not stored and cannot be recovered later. A zero
+// expiresAt means DefaultLinkTTL from now.
+func (u *linkUsecase) Create(ctx context.Context, fileID, ownerID uuid.UUID, expiresAt time.Time, maxDownloads int, linkPassword string) (domain.LinkShare, string, error) {
+	file, err := u.fileRepo.FindByID(ctx, fileID)
+	if err != nil || file.Trashed() {
+		return domain.LinkShare{}, "", domain.NotFound("file")
+	}
+	if file.OwnerID != ownerID {
+		return domain.LinkShare{}, "", domain.ErrPermissionDenied
+	}
+
+	now := time.Now().UTC()
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(DefaultLinkTTL)
+	}
+	if !expiresAt.After(now) || expiresAt.Sub(now) > MaxLinkTTL {
+		return domain.LinkShare{}, "", domain.Invalid("invalid_expiry", "expiry must be in the future and at most 30 days away")
+	}
+	if maxDownloads < 0 {
+		return domain.LinkShare{}, "", domain.Invalid("invalid_max_downloads", "max downloads cannot be negative")
+	}
+
+	var passwordHash string
+	if linkPassword != "" {
+		passwordHash, err = password.Hash(linkPassword, password.DefaultParams)
+		if err != nil {
+			return domain.LinkShare{}, "", err
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return domain.LinkShare{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	link := domain.LinkShare{
+		ID:           uuid.New(),
+		FileID:       fileID,
+		OwnerID:      ownerID,
+		TokenHash:    hashLinkToken(token),
+		PasswordHash: passwordHash,
+		MaxDownloads: maxDownloads,
+		CreatedAt:    now,
+		ExpiresAt:    expiresAt.UTC(),
+	}
+	if err := u.linkRepo.Save(ctx, link); err != nil {
+		return domain.LinkShare{}, "", err
+	}
+
+	return link, token, nil
+}
+
+func (u *linkUsecase) List(ctx context.Context, ownerID, fileID uuid.UUID) ([]domain.LinkShare, error) {
+	return u.linkRepo.FindByOwner(ctx, ownerID, fileID)
+}
+
+func (u *linkUsecase) Revoke(ctx context.Context, id, ownerID uuid.UUID) error {
+	return u.linkRepo.Revoke(ctx, id, ownerID, time.Now().UTC())
+}
+
+// Open resolves a link token to the file's ciphertext. Password guesses
+// are counted per link and per clientIP before the hash is checked, so a
+// locked link costs no Argon2 work. The download is counted only once the
+// object is open, so a storage failure does not use up a limited link.
+func (u *linkUsecase) Open(ctx context.Context, token, linkPassword, clientIP string) (io.ReadCloser, domain.File, error) {
+	link, err := u.linkRepo.FindByTokenHash(ctx, hashLinkToken(token))
+	if err != nil {
+		return nil, domain.File{}, domain.ErrLinkUnavailable
+	}
+
+	now := time.Now().UTC()
+	if !link.Active(now) {
+		return nil, domain.File{}, domain.ErrLinkUnavailable
+	}
+
+	if link.PasswordHash != "" {
+		if err := u.checkPassword(ctx, link, linkPassword, clientIP, now); err != nil {
+			return nil, domain.File{}, err
+		}
+	}
+
+	file, err := u.fileRepo.FindByID(ctx, link.FileID)
+	if err != nil || file.Trashed() {
+		return nil, domain.File{}, domain.ErrLinkUnavailable
+	}
+
+	content, err := u.storage.Download(ctx, "files", file.ObjectName)
+	if err != nil {
+		return nil, domain.File{}, err
+	}
+
+	if err := u.linkRepo.ConsumeDownload(ctx, link.ID, now); err != nil {
+		content.Close()
+		return nil, domain.File{}, err
+	}
+
+	return content, file, nil
+}
+
+func (u *linkUsecase) checkPassword(ctx context.Context, link domain.LinkShare, linkPassword, clientIP string, now time.Time) error {
+	linkAttempts, ipAttempts, err := u.linkRepo.CountPasswordAttempt(ctx, link.ID, clientIP, now, now.Add(linkAttemptWindow))
+	if err != nil {
+		return err
+	}
+	if linkAttempts > linkAttemptsPerLink || ipAttempts > linkAttemptsPerIP {
+		return domain.ErrLinkLocked
+	}
+
+	ok, err := password.Verify(linkPassword, link.PasswordHash)
+	if err != nil || !ok {
+		return domain.ErrLinkPasswordRequired
+	}
+	return u.linkRepo.ResetPasswordAttempts(ctx, link.ID)
+}
+
+// PurgeAttempts drops per-address attempt counters whose window has ended.
+func (u *linkUsecase) PurgeAttempts(ctx context.Context, now time.Time) (int64, error) {
+	return u.linkRepo.DeleteStaleAttempts(ctx, now)
+}
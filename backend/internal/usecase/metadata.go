@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+)
+
+// MaxEncryptedMetadataSize keeps the metadata blob small enough to travel in
+// a response header next to the ciphertext.
+const MaxEncryptedMetadataSize = 4 << 10
+
+var (
+	errMetadataRequired = domain.Invalid("metadata_required", "encrypted metadata is required")
+	errMetadataTooLarge = domain.Invalid("metadata_too_large", fmt.Sprintf("encrypted metadata exceeds %d bytes", MaxEncryptedMetadataSize))
+)
+
+// MetadataMode decides whether uploads may still carry a plaintext filename
+// and MIME type.
+type MetadataMode int
+
+const (
+	// MetadataCompat accepts both encrypted metadata and the legacy
+	// plaintext fields while clients are being migrated.
+	MetadataCompat MetadataMode = iota
+	// MetadataEncrypted rejects uploads without encrypted metadata.
+	MetadataEncrypted
+)
+
+func ParseMetadataMode(s string) (MetadataMode, error) {
+	switch s {
+	case "", "compat":
+		return MetadataCompat, nil
+	case "encrypted":
+		return MetadataEncrypted, nil
+	default:
+		return 0, fmt.Errorf("unknown metadata mode %q", s)
+	}
+}
+
+// apply validates an upload's metadata under the mode. Whenever encrypted
+// metadata is present the plaintext fields are cleared so they never reach
+// the database.
+func (m MetadataMode) apply(encrypted []byte, filename, mimeType *string) error {
+	if len(encrypted) > MaxEncryptedMetadataSize {
+		return errMetadataTooLarge
+	}
+	if len(encrypted) > 0 {
+		*filename, *mimeType = "", ""
+		return nil
+	}
+	if m == MetadataEncrypted {
+		return errMetadataRequired
+	}
+	return nil
+}
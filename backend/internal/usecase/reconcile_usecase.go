@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+)
+
+const (
+	reconcileBatch = 100
+
+	maxOutboxBackoff = time.Hour
+)
+
+// ReconcileUsecase finishes what the upload and delete sagas left half done:
+// pending records whose process died are committed or rolled back depending
+// on whether their object made it to storage, and queued object deletions
+// are carried out.
+type ReconcileUsecase interface {
+	ResolvePending(ctx context.Context, before time.Time) (int, error)
+	DrainOutbox(ctx context.Context, now time.Time) (int, error)
+}
+
+type reconcileUsecase struct {
+	fileRepo   repository.FileRepository
+	outboxRepo repository.OutboxRepository
+	storage    storage.Storage
+}
+
+func NewReconcileUsecase(fileRepo repository.FileRepository, outboxRepo repository.OutboxRepository, storage storage.Storage) ReconcileUsecase {
+	return &reconcileUsecase{fileRepo: fileRepo, outboxRepo: outboxRepo, storage: storage}
+}
+
+// ResolvePending settles files and versions that have been pending since
+// before the cutoff, which should be well past the longest upload. It
+// returns how many were settled.
+func (u *reconcileUsecase) ResolvePending(ctx context.Context, before time.Time) (int, error) {
+	resolved := 0
+
+	files, err := u.fileRepo.FindPending(ctx, before, reconcileBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		stored, err := u.objectStored(ctx, f.ObjectName, f.Size)
+		if err != nil {
+			log.Printf("reconcile file %s: %v", f.ID, err)
+			continue
+		}
+		if stored {
+			err = u.fileRepo.Commit(ctx, f.ID)
+		} else {
+			err = u.fileRepo.Delete(ctx, f.ID)
+		}
+		if err != nil {
+			log.Printf("reconcile file %s: %v", f.ID, err)
+			continue
+		}
+		resolved++
+	}
+
+	versions, err := u.fileRepo.FindPendingVersions(ctx, before, reconcileBatch)
+	if err != nil {
+		return resolved, err
+	}
+	for _, v := range versions {
+		stored, err := u.objectStored(ctx, v.ObjectName, v.Size)
+		if err != nil {
+			log.Printf("reconcile version %d of file %s: %v", v.Version, v.FileID, err)
+			continue
+		}
+		if stored {
+			err = u.fileRepo.CommitVersion(ctx, v.FileID, v.Version)
+		} else {
+			err = u.fileRepo.AbortVersion(ctx, v.FileID, v.Version)
+		}
+		if err != nil {
+			log.Printf("reconcile version %d of file %s: %v", v.Version, v.FileID, err)
+			continue
+		}
+		resolved++
+	}
+
+	return resolved, nil
+}
+
+// objectStored reports whether the object exists with the expected size. A
+// size mismatch counts as missing so the record is rolled back.
+func (u *reconcileUsecase) objectStored(ctx context.Context, objectName string, size int64) (bool, error) {
+	info, err := u.storage.Stat(ctx, "files", objectName)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.Size == size, nil
+}
+
+// DrainOutbox performs due storage operations, rescheduling failures with
+// exponential backoff. It returns how many completed.
+func (u *reconcileUsecase) DrainOutbox(ctx context.Context, now time.Time) (int, error) {
+	ops, err := u.outboxRepo.FindDue(ctx, now, reconcileBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, op := range ops {
+		if err := u.apply(ctx, op); err != nil {
+			backoff := time.Duration(1<<min(op.Attempts, 6)) * time.Minute
+			if backoff > maxOutboxBackoff {
+				backoff = maxOutboxBackoff
+			}
+			if rErr := u.outboxRepo.Retry(ctx, op.ID, err.Error(), now.Add(backoff)); rErr != nil {
+				log.Printf("reschedule outbox entry %d: %v", op.ID, rErr)
+			}
+			continue
+		}
+		if err := u.outboxRepo.Complete(ctx, op.ID); err != nil {
+			log.Printf("complete outbox entry %d: %v", op.ID, err)
+			continue
+		}
+		done++
+	}
+
+	return done, nil
+}
+
+func (u *reconcileUsecase) apply(ctx context.Context, op domain.StorageOp) error {
+	switch op.Op {
+	case domain.StorageOpDelete:
+		return u.storage.Delete(ctx, op.Bucket, op.ObjectName)
+	default:
+		return errors.New("unknown storage operation " + op.Op)
+	}
+}
@@ -0,0 +1,380 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/memory"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+	"github.com/google/uuid"
+)
+
+// failingStorage fails uploads and deletes with the configured errors.
+type failingStorage struct {
+	storage.Storage
+
+	uploadErr error
+	deleteErr error
+}
+
+func (s *failingStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, objectSize int64, contentType string) error {
+	if s.uploadErr != nil {
+		return s.uploadErr
+	}
+	return s.Storage.Upload(ctx, bucket, objectName, reader, objectSize, contentType)
+}
+
+func (s *failingStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+	return s.Storage.Delete(ctx, bucket, objectName)
+}
+
+// failingFiles fails the saga's saves and commits with the configured
+// errors, as a lost database connection would.
+type failingFiles struct {
+	repository.FileRepository
+
+	saveErr   error
+	commitErr error
+}
+
+func (r *failingFiles) Save(ctx context.Context, file domain.File) error {
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+	return r.FileRepository.Save(ctx, file)
+}
+
+func (r *failingFiles) AddVersion(ctx context.Context, ownerID uuid.UUID, version domain.FileVersion) (domain.FileVersion, error) {
+	if r.saveErr != nil {
+		return domain.FileVersion{}, r.saveErr
+	}
+	return r.FileRepository.AddVersion(ctx, ownerID, version)
+}
+
+func (r *failingFiles) Commit(ctx context.Context, id uuid.UUID) error {
+	if r.commitErr != nil {
+		return r.commitErr
+	}
+	return r.FileRepository.Commit(ctx, id)
+}
+
+func (r *failingFiles) CommitVersion(ctx context.Context, fileID uuid.UUID, version int) error {
+	if r.commitErr != nil {
+		return r.commitErr
+	}
+	return r.FileRepository.CommitVersion(ctx, fileID, version)
+}
+
+type sagaEnv struct {
+	store   *memory.Store
+	repos   repotest.Repositories
+	usage   repository.UsageRepository
+	storage *failingStorage
+	files   *failingFiles
+
+	fileUsecase      FileUsecase
+	reconcileUsecase ReconcileUsecase
+}
+
+func newSagaEnv() *sagaEnv {
+	s := memory.NewStore()
+	env := &sagaEnv{
+		store:   s,
+		usage:   memory.NewUsageRepository(s),
+		storage: &failingStorage{Storage: storage.NewMemoryStorage()},
+		files:   &failingFiles{FileRepository: memory.NewFileRepository(s)},
+	}
+	env.repos = repotest.Repositories{Users: memory.NewUserRepository(s), Files: env.files}
+	env.fileUsecase = NewFileUsecase(env.files, nil, nil, repotest.NoFolders{}, env.storage, MetadataCompat)
+	env.reconcileUsecase = NewReconcileUsecase(env.files, memory.NewOutboxRepository(s), env.storage)
+	return env
+}
+
+func (env *sagaEnv) owner(t *testing.T) uuid.UUID {
+	t.Helper()
+	return repotest.NewUser(t, env.repos, "saga-").ID
+}
+
+func (env *sagaEnv) upload(t *testing.T, ownerID uuid.UUID, data []byte) domain.File {
+	t.Helper()
+	file, err := env.fileUsecase.Upload(context.Background(), domain.File{OwnerID: ownerID, Size: int64(len(data))}, body(data))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	return file
+}
+
+// pending returns every pending file record.
+func (env *sagaEnv) pending(t *testing.T) []domain.File {
+	t.Helper()
+	files, err := env.files.FindPending(context.Background(), time.Now().Add(time.Hour), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func (env *sagaEnv) used(t *testing.T, userID uuid.UUID) int64 {
+	t.Helper()
+	usage, err := env.usage.Get(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return usage.UsedBytes
+}
+
+func (env *sagaEnv) stored(objectName string) bool {
+	_, err := env.storage.Stat(context.Background(), "files", objectName)
+	return err == nil
+}
+
+func body(data []byte) io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(data))
+}
+
+func TestUploadCommitsAfterObjectIsStored(t *testing.T) {
+	env := newSagaEnv()
+	ownerID := env.owner(t)
+
+	file := env.upload(t, ownerID, []byte("ciphertext"))
+
+	if _, err := env.files.FindByID(context.Background(), file.ID); err != nil {
+		t.Fatalf("committed file not found: %v", err)
+	}
+	if !env.stored(file.ObjectName) {
+		t.Fatal("object was not stored")
+	}
+	if got := env.used(t, ownerID); got != file.Size {
+		t.Fatalf("used = %d, want %d", got, file.Size)
+	}
+}
+
+func TestUploadRollsBackWhenStorageFails(t *testing.T) {
+	env := newSagaEnv()
+	env.storage.uploadErr = errors.New("minio unavailable")
+	ownerID := env.owner(t)
+
+	_, err := env.fileUsecase.Upload(context.Background(), domain.File{OwnerID: ownerID, Size: 4}, body([]byte("data")))
+	if err == nil {
+		t.Fatal("expected upload to fail")
+	}
+
+	if files := env.pending(t); len(files) != 0 {
+		t.Fatalf("file record left behind: %+v", files)
+	}
+	if got := env.used(t, ownerID); got != 0 {
+		t.Fatalf("used = %d, want 0", got)
+	}
+	if ops := env.store.Outbox(); len(ops) != 1 {
+		t.Fatalf("outbox has %d entries, want the partial object queued for deletion", len(ops))
+	}
+}
+
+func TestUploadReservesNothingWhenSaveFails(t *testing.T) {
+	env := newSagaEnv()
+	env.files.saveErr = errors.New("connection reset")
+	ownerID := env.owner(t)
+	id := uuid.New()
+
+	_, err := env.fileUsecase.Upload(context.Background(), domain.File{ID: id, OwnerID: ownerID, Size: 4}, body([]byte("data")))
+	if err == nil {
+		t.Fatal("expected upload to fail")
+	}
+
+	if env.stored(id.String()) {
+		t.Fatal("object stored without a record")
+	}
+	if got := env.used(t, ownerID); got != 0 {
+		t.Fatalf("used = %d, want 0", got)
+	}
+}
+
+func TestUploadLeavesPendingRecordWhenCommitFails(t *testing.T) {
+	env := newSagaEnv()
+	env.files.commitErr = errors.New("connection reset")
+	ownerID := env.owner(t)
+	id := uuid.New()
+
+	_, err := env.fileUsecase.Upload(context.Background(), domain.File{ID: id, OwnerID: ownerID, Size: 4}, body([]byte("data")))
+	if err == nil {
+		t.Fatal("expected upload to fail")
+	}
+
+	if files := env.pending(t); len(files) != 1 || files[0].ID != id {
+		t.Fatalf("pending records = %+v, want the failed upload", files)
+	}
+
+	// Once the database is back, the reconciler finds the object and
+	// finishes the upload.
+	env.files.commitErr = nil
+	resolved, err := env.reconcileUsecase.ResolvePending(context.Background(), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved != 1 {
+		t.Fatalf("resolved = %d, want 1", resolved)
+	}
+	if _, err := env.files.FindByID(context.Background(), id); err != nil {
+		t.Fatalf("resolved file not committed: %v", err)
+	}
+}
+
+func TestResolvePendingRollsBackMissingObject(t *testing.T) {
+	env := newSagaEnv()
+	ownerID := env.owner(t)
+	file := repotest.NewFile(t, env.repos, ownerID, time.Now().Add(-2*time.Hour), true)
+
+	resolved, err := env.reconcileUsecase.ResolvePending(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved != 1 {
+		t.Fatalf("resolved = %d, want 1", resolved)
+	}
+	if files := env.pending(t); len(files) != 0 {
+		t.Fatalf("pending record without an object was not rolled back: %+v", files)
+	}
+	if got := env.used(t, ownerID); got != 0 {
+		t.Fatalf("used = %d, want 0", got)
+	}
+	if ops := env.store.Outbox(); len(ops) != 1 || ops[0].ObjectName != file.ObjectName {
+		t.Fatalf("outbox = %+v, want the missing object queued", ops)
+	}
+}
+
+func TestResolvePendingSkipsRecentUploads(t *testing.T) {
+	env := newSagaEnv()
+	repotest.NewFile(t, env.repos, env.owner(t), time.Now(), true)
+
+	resolved, err := env.reconcileUsecase.ResolvePending(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved != 0 {
+		t.Fatalf("resolved = %d, want an in-flight upload left alone", resolved)
+	}
+}
+
+func TestUploadVersionAbortsWhenStorageFails(t *testing.T) {
+	env := newSagaEnv()
+	ownerID := env.owner(t)
+	file := env.upload(t, ownerID, []byte("v1"))
+	ctx := context.Background()
+
+	env.storage.uploadErr = errors.New("minio unavailable")
+	_, err := env.fileUsecase.UploadVersion(ctx, file.ID, ownerID, domain.FileVersion{Size: 2, IV: []byte("iv")}, body([]byte("v2")))
+	if err == nil {
+		t.Fatal("expected version upload to fail")
+	}
+
+	current, err := env.files.FindByID(ctx, file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != 1 {
+		t.Fatalf("current version = %d, want 1", current.Version)
+	}
+	if _, err := env.files.FindVersion(ctx, file.ID, 2); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("find aborted version: err = %v, want ErrNotFound", err)
+	}
+	if got := env.used(t, ownerID); got != file.Size {
+		t.Fatalf("used = %d, want %d", got, file.Size)
+	}
+}
+
+func TestUploadVersionBecomesCurrentOnCommit(t *testing.T) {
+	env := newSagaEnv()
+	ownerID := env.owner(t)
+	file := env.upload(t, ownerID, []byte("v1"))
+	ctx := context.Background()
+
+	saved, err := env.fileUsecase.UploadVersion(ctx, file.ID, ownerID, domain.FileVersion{Size: 3, IV: []byte("iv")}, body([]byte("v2!")))
+	if err != nil {
+		t.Fatalf("upload version: %v", err)
+	}
+
+	current, err := env.files.FindByID(ctx, file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != saved.Version || current.ObjectName != saved.ObjectName {
+		t.Fatalf("current = v%d %s, want v%d %s", current.Version, current.ObjectName, saved.Version, saved.ObjectName)
+	}
+}
+
+// deleteForever trashes and purges a freshly uploaded file, leaving its
+// object queued in the outbox.
+func (env *sagaEnv) deleteForever(t *testing.T) domain.File {
+	t.Helper()
+	ctx := context.Background()
+	ownerID := env.owner(t)
+	file := env.upload(t, ownerID, []byte("data"))
+	if err := env.fileUsecase.Delete(ctx, file.ID, ownerID); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if err := env.fileUsecase.DeleteForever(ctx, file.ID, ownerID); err != nil {
+		t.Fatalf("delete forever: %v", err)
+	}
+	return file
+}
+
+func TestDeleteForeverQueuesObjectsUntilDrained(t *testing.T) {
+	env := newSagaEnv()
+	file := env.deleteForever(t)
+
+	if !env.stored(file.ObjectName) {
+		t.Fatal("object deleted before the outbox was drained")
+	}
+
+	done, err := env.reconcileUsecase.DrainOutbox(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if left := len(env.store.Outbox()); done != 1 || left != 0 {
+		t.Fatalf("done = %d with %d entries left, want 1 and 0", done, left)
+	}
+	if env.stored(file.ObjectName) {
+		t.Fatal("object still stored after drain")
+	}
+}
+
+func TestDrainOutboxRetriesWithBackoff(t *testing.T) {
+	env := newSagaEnv()
+	file := env.deleteForever(t)
+	env.storage.deleteErr = errors.New("minio unavailable")
+	ctx := context.Background()
+	now := time.Now()
+
+	done, err := env.reconcileUsecase.DrainOutbox(ctx, now)
+	if err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if done != 0 {
+		t.Fatalf("done = %d, want 0", done)
+	}
+	op := env.store.Outbox()[0]
+	if op.Attempts != 1 || op.LastError == "" || !op.NextAttemptAt.After(now) {
+		t.Fatalf("entry not rescheduled: %+v", op)
+	}
+
+	env.storage.deleteErr = nil
+	if done, _ := env.reconcileUsecase.DrainOutbox(ctx, now); done != 0 {
+		t.Fatal("entry retried before its backoff elapsed")
+	}
+	if done, _ := env.reconcileUsecase.DrainOutbox(ctx, op.NextAttemptAt); done != 1 {
+		t.Fatal("entry not retried after its backoff elapsed")
+	}
+	if env.stored(file.ObjectName) {
+		t.Fatal("object still stored after retry")
+	}
+}
@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/auth"
+	"github.com/google/uuid"
+)
+
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      domain.Session
+}
+
+type SessionUsecase interface {
+	Create(ctx context.Context, user domain.User, userAgent, ipAddress string) (SessionTokens, error)
+	Refresh(ctx context.Context, refreshToken string) (SessionTokens, error)
+	Validate(ctx context.Context, sessionID uuid.UUID) error
+	List(ctx context.Context, userID uuid.UUID) ([]domain.Session, error)
+	Revoke(ctx context.Context, sessionID, userID uuid.UUID) error
+	RevokeByRefreshToken(ctx context.Context, refreshToken string) error
+	RevokeOthers(ctx context.Context, userID, currentSessionID uuid.UUID) error
+	ReapExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type sessionUsecase struct {
+	sessionRepo repository.SessionRepository
+	userRepo    repository.UserRepository
+	tokens      *auth.TokenSigner
+}
+
+func NewSessionUsecase(sessionRepo repository.SessionRepository, userRepo repository.UserRepository, tokens *auth.TokenSigner) SessionUsecase {
+	return &sessionUsecase{sessionRepo: sessionRepo, userRepo: userRepo, tokens: tokens}
+}
+
+func (u *sessionUsecase) Create(ctx context.Context, user domain.User, userAgent, ipAddress string) (SessionTokens, error) {
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	now := time.Now().UTC()
+	session := domain.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(auth.RefreshTokenTTL),
+	}
+
+	if err := u.sessionRepo.Save(ctx, session, domain.RefreshToken{TokenHash: hash, SessionID: session.ID, CreatedAt: now}); err != nil {
+		return SessionTokens{}, err
+	}
+
+	accessToken, err := u.tokens.GenerateToken(user.ID.String(), user.Username, session.ID.String())
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	return SessionTokens{AccessToken: accessToken, RefreshToken: refreshToken, Session: session}, nil
+}
+
+func (u *sessionUsecase) Refresh(ctx context.Context, refreshToken string) (SessionTokens, error) {
+	nextToken, nextHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	now := time.Now().UTC()
+	session, err := u.sessionRepo.Rotate(ctx, auth.HashRefreshToken(refreshToken), domain.RefreshToken{TokenHash: nextHash, CreatedAt: now})
+	if errors.Is(err, domain.ErrRefreshTokenReused) {
+		// Someone replayed a rotated token, so either the legitimate client
+		// or an attacker holds a stale copy. Kill the whole session.
+		log.Printf("refresh token reuse detected for session %s, revoking", session.ID)
+		if err := u.sessionRepo.Revoke(ctx, session.ID, session.UserID, now); err != nil {
+			log.Printf("failed to revoke session %s: %v", session.ID, err)
+		}
+		return SessionTokens{}, domain.ErrRefreshTokenReused
+	}
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	user, err := u.userRepo.FindByID(ctx, session.UserID)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	accessToken, err := u.tokens.GenerateToken(user.ID.String(), user.Username, session.ID.String())
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	return SessionTokens{AccessToken: accessToken, RefreshToken: nextToken, Session: session}, nil
+}
+
+func (u *sessionUsecase) Validate(ctx context.Context, sessionID uuid.UUID) error {
+	session, err := u.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return domain.ErrSessionRevoked
+	}
+	if !session.Active(time.Now()) {
+		return domain.ErrSessionRevoked
+	}
+	return nil
+}
+
+func (u *sessionUsecase) List(ctx context.Context, userID uuid.UUID) ([]domain.Session, error) {
+	return u.sessionRepo.FindActiveByUser(ctx, userID, time.Now().UTC())
+}
+
+func (u *sessionUsecase) Revoke(ctx context.Context, sessionID, userID uuid.UUID) error {
+	return u.sessionRepo.Revoke(ctx, sessionID, userID, time.Now().UTC())
+}
+
+func (u *sessionUsecase) RevokeByRefreshToken(ctx context.Context, refreshToken string) error {
+	session, err := u.sessionRepo.FindByRefreshToken(ctx, auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		return domain.ErrInvalidRefreshToken
+	}
+	return u.sessionRepo.Revoke(ctx, session.ID, session.UserID, time.Now().UTC())
+}
+
+func (u *sessionUsecase) RevokeOthers(ctx context.Context, userID, currentSessionID uuid.UUID) error {
+	return u.sessionRepo.RevokeAllForUser(ctx, userID, currentSessionID, time.Now().UTC())
+}
+
+func (u *sessionUsecase) ReapExpired(ctx context.Context, now time.Time) (int64, error) {
+	return u.sessionRepo.DeleteExpired(ctx, now)
+}
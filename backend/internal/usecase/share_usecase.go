@@ -2,7 +2,7 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"slices"
 	"time"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
@@ -10,11 +10,21 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	errGrantExceedsOwn    = domain.Unprocessable("grant_exceeds_own", "cannot grant permissions you do not hold")
+	errShareNotManageable = domain.Conflict("share_not_manageable", "cannot change your own share or one granted above you")
+)
+
 type ShareUsecase interface {
-	ShareFile(ctx context.Context, fileID, ownerID, recipientID uuid.UUID, wrappedKey []byte) error
+	ShareFile(ctx context.Context, grantorID uuid.UUID, share domain.Share) (domain.Share, error)
 	GetSharesForRecipient(ctx context.Context, recipientID uuid.UUID) ([]domain.Share, error)
 	GetShare(ctx context.Context, fileID, recipientID uuid.UUID) (domain.Share, error)
-	Unshare(ctx context.Context, shareID, ownerID uuid.UUID) error
+	ListOutgoing(ctx context.Context, ownerID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error)
+	ListForFile(ctx context.Context, fileID, actorID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error)
+	Unshare(ctx context.Context, shareID, actorID uuid.UUID) error
+	UpdateExpiry(ctx context.Context, shareID, actorID uuid.UUID, expiresAt *time.Time) (domain.Share, error)
+	UpdatePermissions(ctx context.Context, shareID, actorID uuid.UUID, perms domain.SharePermission) (domain.Share, error)
+	ReapExpired(ctx context.Context, now time.Time) (int64, error)
 }
 
 type shareUsecase struct {
@@ -26,49 +36,212 @@ func NewShareUsecase(shareRepo repository.ShareRepository, fileRepo repository.F
 	return &shareUsecase{shareRepo: shareRepo, fileRepo: fileRepo}
 }
 
-func (u *shareUsecase) ShareFile(ctx context.Context, fileID, ownerID, recipientID uuid.UUID, wrappedKey []byte) error {
-	file, err := u.fileRepo.FindByID(ctx, fileID)
+// ShareFile grants share.RecipientID access to share.FileID on behalf of
+// grantorID, who is either the owner or a recipient holding PermReshare. A
+// reshare can only pass on permissions the grantor holds and never outlives
+// the grantor's own access. A zero KeyVersion means the recipient's current
+// key version, zero Permissions means domain.PermDefault and a nil ExpiresAt
+// grants access until the share is revoked.
+func (u *shareUsecase) ShareFile(ctx context.Context, grantorID uuid.UUID, share domain.Share) (domain.Share, error) {
+	file, err := u.fileRepo.FindByID(ctx, share.FileID)
 	if err != nil {
-		return err
+		return domain.Share{}, err
+	}
+	if file.Trashed() {
+		return domain.Share{}, domain.ErrFileUnavailable
+	}
+
+	if share.RecipientID == file.OwnerID || share.RecipientID == grantorID {
+		return domain.Share{}, domain.Invalid("share_with_self", "cannot share a file with its owner or yourself")
 	}
 
-	if(file.OwnerID != ownerID) {
-		return errors.New("unauthorized action")
+	if err := validateShareExpiry(share.ExpiresAt); err != nil {
+		return domain.Share{}, err
 	}
 
-	share := domain.Share{
-		ID:				uuid.New(),
-		FileID:			fileID,
-		RecipientID: 	recipientID,
-		WrappedKey: 	wrappedKey,
-		CreatedAt:		time.Now().UTC(),
+	share.Permissions = share.Permissions.Normalize()
+	if share.Permissions == 0 {
+		share.Permissions = domain.PermDefault
 	}
 
-	return u.shareRepo.Save(ctx, share)
+	chain := []uuid.UUID{file.OwnerID}
+	if file.OwnerID != grantorID {
+		grant, err := u.activeShare(ctx, file.ID, grantorID, domain.PermReshare)
+		if err != nil {
+			return domain.Share{}, err
+		}
+		if !grant.Permissions.Has(share.Permissions) {
+			return domain.Share{}, errGrantExceedsOwn
+		}
+		if grant.ExpiresAt != nil && (share.ExpiresAt == nil || share.ExpiresAt.After(*grant.ExpiresAt)) {
+			share.ExpiresAt = grant.ExpiresAt
+		}
+		chain = append(append([]uuid.UUID{}, grant.GrantorChain...), grantorID)
+	}
+
+	share.ID = uuid.New()
+	share.GrantedBy = grantorID
+	share.GrantorChain = chain
+	share.CreatedAt = time.Now().UTC()
+
+	if err := u.shareRepo.Save(ctx, share); err != nil {
+		return domain.Share{}, err
+	}
+	return share, nil
 }
 
 func (u *shareUsecase) GetSharesForRecipient(ctx context.Context, recipientID uuid.UUID) ([]domain.Share, error) {
-	return u.shareRepo.FindByRecipient(ctx, recipientID)
+	return u.shareRepo.FindByRecipient(ctx, recipientID, time.Now().UTC())
 }
 
 func (u *shareUsecase) GetShare(ctx context.Context, fileID, recipientID uuid.UUID) (domain.Share, error) {
-	return u.shareRepo.FindByFileAndRecipient(ctx, fileID, recipientID)
+	share, err := u.shareRepo.FindByFileAndRecipient(ctx, fileID, recipientID)
+	if err != nil {
+		return domain.Share{}, err
+	}
+	if share.Expired(time.Now()) {
+		return domain.Share{}, domain.ErrShareExpired
+	}
+	return share, nil
 }
 
-func (u *shareUsecase) Unshare(ctx context.Context, shareID, ownerID uuid.UUID) error {
-	share, err := u.shareRepo.FindByID(ctx, shareID)
+// ShareListingLimit clamps a requested page size for share listings.
+func ShareListingLimit(limit int) int {
+	if limit <= 0 || limit > 100 {
+		return 20
+	}
+	return limit
+}
+
+func (u *shareUsecase) ListOutgoing(ctx context.Context, ownerID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error) {
+	return u.shareRepo.FindOutgoingByOwner(ctx, ownerID, after, ShareListingLimit(limit))
+}
+
+// ListForFile lists who has access to a file. Only the owner and recipients
+// with PermManage may see it.
+func (u *shareUsecase) ListForFile(ctx context.Context, fileID, actorID uuid.UUID, after domain.ShareCursor, limit int) ([]domain.ShareListing, error) {
+	file, err := u.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.OwnerID != actorID {
+		if _, err := u.activeShare(ctx, file.ID, actorID, domain.PermManage); err != nil {
+			return nil, err
+		}
+	}
+
+	return u.shareRepo.FindListingsByFile(ctx, fileID, after, ShareListingLimit(limit))
+}
+
+// Unshare revokes a share along with every reshare its recipient passed on.
+func (u *shareUsecase) Unshare(ctx context.Context, shareID, actorID uuid.UUID) error {
+	share, _, err := u.managedShare(ctx, shareID, actorID)
 	if err != nil {
 		return err
 	}
 
+	return u.shareRepo.Delete(ctx, share.ID)
+}
+
+// activeShare returns the unexpired share through which userID reaches
+// fileID, failing with domain.ErrPermissionDenied unless it carries need.
+func (u *shareUsecase) activeShare(ctx context.Context, fileID, userID uuid.UUID, need domain.SharePermission) (domain.Share, error) {
+	share, err := u.shareRepo.FindByFileAndRecipient(ctx, fileID, userID)
+	if err != nil || share.Expired(time.Now()) || !share.Permissions.Has(need) {
+		return domain.Share{}, domain.ErrPermissionDenied
+	}
+	return share, nil
+}
+
+// managedShare loads a share that actorID may modify: they must own the file
+// or hold PermManage on it through a share of their own. A manager cannot
+// modify their own share or the share of any grantor above them, and a share
+// the actor cannot manage at all is reported as not found. It also returns
+// the actor's own grant, which for the owner is PermAll without an expiry.
+func (u *shareUsecase) managedShare(ctx context.Context, shareID, actorID uuid.UUID) (domain.Share, domain.Share, error) {
+	share, err := u.shareRepo.FindByID(ctx, shareID)
+	if err != nil {
+		return domain.Share{}, domain.Share{}, err
+	}
+
 	file, err := u.fileRepo.FindByID(ctx, share.FileID)
 	if err != nil {
-		return err
+		return domain.Share{}, domain.Share{}, err
+	}
+
+	if file.OwnerID == actorID {
+		return share, domain.Share{Permissions: domain.PermAll}, nil
+	}
+
+	own, err := u.activeShare(ctx, file.ID, actorID, domain.PermManage)
+	if err != nil {
+		return domain.Share{}, domain.Share{}, domain.NotFound("share")
+	}
+	if share.RecipientID == actorID || slices.Contains(own.GrantorChain, share.RecipientID) {
+		return domain.Share{}, domain.Share{}, errShareNotManageable
+	}
+	return share, own, nil
+}
+
+func validateShareExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return domain.Invalid("invalid_expiry", "expiry must be in the future")
+	}
+	return nil
+}
+
+// UpdateExpiry lets the file owner, or a recipient with PermManage, extend,
+// shorten or clear (nil) a share's expiry. A manager's own expiry caps the
+// new one, as it does for a reshare, and reshares below the share are
+// shortened to match.
+func (u *shareUsecase) UpdateExpiry(ctx context.Context, shareID, actorID uuid.UUID, expiresAt *time.Time) (domain.Share, error) {
+	share, own, err := u.managedShare(ctx, shareID, actorID)
+	if err != nil {
+		return domain.Share{}, err
 	}
 
-	if file.OwnerID != ownerID {
-		return errors.New("unauthorized action")
+	if err := validateShareExpiry(expiresAt); err != nil {
+		return domain.Share{}, err
+	}
+	if own.ExpiresAt != nil && (expiresAt == nil || expiresAt.After(*own.ExpiresAt)) {
+		expiresAt = own.ExpiresAt
+	}
+
+	if err := u.shareRepo.UpdateExpiry(ctx, shareID, expiresAt); err != nil {
+		return domain.Share{}, err
+	}
+
+	share.ExpiresAt = expiresAt
+	return share, nil
+}
+
+// UpdatePermissions replaces a share's permission set. Managers other than
+// the owner cannot grant permissions they do not hold themselves. Reshares
+// granted below the recipient lose whatever the share lost but are never
+// widened.
+func (u *shareUsecase) UpdatePermissions(ctx context.Context, shareID, actorID uuid.UUID, perms domain.SharePermission) (domain.Share, error) {
+	share, own, err := u.managedShare(ctx, shareID, actorID)
+	if err != nil {
+		return domain.Share{}, err
 	}
 
-	return u.shareRepo.Delete(ctx, shareID)
-}
\ No newline at end of file
+	perms = perms.Normalize()
+	if perms == 0 {
+		return domain.Share{}, domain.Invalid("no_permissions", "a share needs at least one permission")
+	}
+	if !own.Permissions.Has(perms) {
+		return domain.Share{}, errGrantExceedsOwn
+	}
+
+	if err := u.shareRepo.UpdatePermissions(ctx, shareID, perms); err != nil {
+		return domain.Share{}, err
+	}
+
+	share.Permissions = perms
+	return share, nil
+}
+
+func (u *shareUsecase) ReapExpired(ctx context.Context, now time.Time) (int64, error) {
+	return u.shareRepo.DeleteExpired(ctx, now)
+}
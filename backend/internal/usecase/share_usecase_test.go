@@ -0,0 +1,269 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository/repotest"
+	"github.com/google/uuid"
+)
+
+func TestShareFile(t *testing.T) {
+	ctx := context.Background()
+	soon := time.Now().Add(time.Hour)
+	later := time.Now().Add(48 * time.Hour)
+
+	tests := []struct {
+		name string
+		// grant, if set, is the share the grantor holds; otherwise the
+		// owner shares.
+		grant      *domain.SharePermission
+		grantUntil *time.Time
+		trashed    bool
+		toOwner    bool
+		share      domain.Share
+
+		wantErr     error
+		wantAnyErr  bool
+		wantPerms   domain.SharePermission
+		wantExpires *time.Time
+		wantChain   int
+	}{
+		{
+			name:      "owner with default permissions",
+			share:     domain.Share{},
+			wantPerms: domain.PermDefault,
+			wantChain: 1,
+		},
+		{
+			name:      "owner normalizes permissions",
+			share:     domain.Share{Permissions: domain.PermDownload},
+			wantPerms: domain.PermView | domain.PermDownload,
+			wantChain: 1,
+		},
+		{
+			name:       "expiry in the past",
+			share:      domain.Share{ExpiresAt: ptr(time.Now().Add(-time.Minute))},
+			wantAnyErr: true,
+		},
+		{
+			name:       "with the owner",
+			toOwner:    true,
+			wantAnyErr: true,
+		},
+		{
+			name:    "trashed file",
+			trashed: true,
+			wantErr: domain.ErrFileUnavailable,
+		},
+		{
+			name:    "recipient without reshare",
+			grant:   ptr(domain.PermDefault),
+			wantErr: domain.ErrPermissionDenied,
+		},
+		{
+			name:      "reshare of held permissions",
+			grant:     ptr(domain.PermView | domain.PermDownload | domain.PermReshare),
+			share:     domain.Share{Permissions: domain.PermView},
+			wantPerms: domain.PermView,
+			wantChain: 2,
+		},
+		{
+			name:       "reshare of permissions not held",
+			grant:      ptr(domain.PermView | domain.PermReshare),
+			share:      domain.Share{Permissions: domain.PermDownload},
+			wantAnyErr: true,
+		},
+		{
+			name:        "reshare capped at the grantor's expiry",
+			grant:       ptr(domain.PermView | domain.PermReshare),
+			grantUntil:  &soon,
+			share:       domain.Share{Permissions: domain.PermView, ExpiresAt: &later},
+			wantPerms:   domain.PermView,
+			wantExpires: &soon,
+			wantChain:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			owner := f.user(t)
+			recipient := f.user(t)
+			file := f.file(t, owner.ID)
+
+			grantorID := owner.ID
+			if tt.grant != nil {
+				grantor := f.user(t)
+				repotest.NewShare(t, f.repos, file, grantor.ID, *tt.grant, time.Now(), tt.grantUntil)
+				grantorID = grantor.ID
+			}
+			if tt.trashed {
+				if err := f.files.Delete(ctx, file.ID, owner.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			req := tt.share
+			req.FileID = file.ID
+			req.RecipientID = recipient.ID
+			req.WrappedKey = []byte("wrapped")
+			if tt.toOwner {
+				req.RecipientID = owner.ID
+			}
+
+			share, err := f.shares.ShareFile(ctx, grantorID, req)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("share: %v", err)
+			}
+
+			stored, err := f.repos.Shares.FindByID(ctx, share.ID)
+			if err != nil {
+				t.Fatalf("find share: %v", err)
+			}
+			if stored.Permissions != tt.wantPerms {
+				t.Errorf("permissions = %v, want %v", stored.Permissions.Strings(), tt.wantPerms.Strings())
+			}
+			if len(stored.GrantorChain) != tt.wantChain || stored.GrantorChain[0] != owner.ID || stored.GrantedBy != grantorID {
+				t.Errorf("granted by %s with chain %v", stored.GrantedBy, stored.GrantorChain)
+			}
+			if (stored.ExpiresAt == nil) != (tt.wantExpires == nil) ||
+				stored.ExpiresAt != nil && !stored.ExpiresAt.Equal(*tt.wantExpires) {
+				t.Errorf("expires at %v, want %v", stored.ExpiresAt, tt.wantExpires)
+			}
+		})
+	}
+}
+
+func TestUpdatePermissions(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// actor is "owner", "manager" or "stranger".
+		actor   string
+		perms   domain.SharePermission
+		wantErr error
+	}{
+		{name: "owner grants everything", actor: "owner", perms: domain.PermAll},
+		{name: "manager grants what they hold", actor: "manager", perms: domain.PermView | domain.PermManage},
+		{name: "manager cannot escalate", actor: "manager", perms: domain.PermReshare, wantErr: errGrantExceedsOwn},
+		{name: "stranger", actor: "stranger", perms: domain.PermView, wantErr: domain.ErrNotFound},
+		{name: "no permissions", actor: "owner", perms: 0, wantErr: domain.ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			owner := f.user(t)
+			manager := f.user(t)
+			file := f.file(t, owner.ID)
+			repotest.NewShare(t, f.repos, file, manager.ID, domain.PermView|domain.PermDownload|domain.PermManage, time.Now(), nil)
+			target := repotest.NewShare(t, f.repos, file, f.user(t).ID, domain.PermView, time.Now(), nil)
+
+			actors := map[string]uuid.UUID{"owner": owner.ID, "manager": manager.ID, "stranger": f.user(t).ID}
+			_, err := f.shares.UpdatePermissions(ctx, target.ID, actors[tt.actor], tt.perms)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("update: %v", err)
+			}
+
+			stored, err := f.repos.Shares.FindByID(ctx, target.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Permissions != tt.perms.Normalize() {
+				t.Fatalf("permissions = %v, want %v", stored.Permissions.Strings(), tt.perms.Normalize().Strings())
+			}
+		})
+	}
+}
+
+// TestManagerLimits covers a manager two grants below the owner: the chain
+// is owner -> grantor -> manager, with a sibling share the manager may edit.
+func TestManagerLimits(t *testing.T) {
+	ctx := context.Background()
+	managerUntil := time.Now().Add(time.Hour).UTC()
+	later := managerUntil.Add(time.Hour)
+
+	setup := func(t *testing.T) (f *fixture, grantor, manager, sibling domain.Share) {
+		f = newFixture(t)
+		owner := f.user(t)
+		file := f.file(t, owner.ID)
+		grantor = repotest.NewShare(t, f.repos, file, f.user(t).ID, domain.PermAll, time.Now(), nil)
+		manager = domain.Share{
+			FileID:      file.ID,
+			RecipientID: f.user(t).ID,
+			WrappedKey:  []byte("wrapped"),
+			ExpiresAt:   &managerUntil,
+			Permissions: domain.PermView | domain.PermManage | domain.PermReshare,
+		}
+		manager, err := f.shares.ShareFile(ctx, grantor.RecipientID, manager)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sibling = repotest.NewShare(t, f.repos, file, f.user(t).ID, domain.PermView, time.Now(), nil)
+		return f, grantor, manager, sibling
+	}
+
+	t.Run("own and ancestor shares", func(t *testing.T) {
+		f, grantor, manager, _ := setup(t)
+		for name, target := range map[string]domain.Share{"own": manager, "grantor": grantor} {
+			if _, err := f.shares.UpdateExpiry(ctx, target.ID, manager.RecipientID, nil); !errors.Is(err, errShareNotManageable) {
+				t.Errorf("%s expiry: err = %v, want errShareNotManageable", name, err)
+			}
+			if _, err := f.shares.UpdatePermissions(ctx, target.ID, manager.RecipientID, domain.PermView); !errors.Is(err, errShareNotManageable) {
+				t.Errorf("%s permissions: err = %v, want errShareNotManageable", name, err)
+			}
+			if err := f.shares.Unshare(ctx, target.ID, manager.RecipientID); !errors.Is(err, errShareNotManageable) {
+				t.Errorf("%s unshare: err = %v, want errShareNotManageable", name, err)
+			}
+		}
+	})
+
+	t.Run("expiry capped at the manager's", func(t *testing.T) {
+		f, _, manager, sibling := setup(t)
+		for name, expiresAt := range map[string]*time.Time{"clear": nil, "extend": &later} {
+			share, err := f.shares.UpdateExpiry(ctx, sibling.ID, manager.RecipientID, expiresAt)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			stored, _ := f.repos.Shares.FindByID(ctx, sibling.ID)
+			if share.ExpiresAt == nil || !share.ExpiresAt.Equal(managerUntil) || stored.ExpiresAt == nil || !stored.ExpiresAt.Equal(managerUntil) {
+				t.Errorf("%s: expires at %v (stored %v), want %v", name, share.ExpiresAt, stored.ExpiresAt, managerUntil)
+			}
+		}
+	})
+
+	t.Run("revoke cascades", func(t *testing.T) {
+		f, grantor, manager, sibling := setup(t)
+		if err := f.shares.Unshare(ctx, grantor.ID, grantor.GrantorChain[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.repos.Shares.FindByID(ctx, manager.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("manager's reshare survived its grantor's revocation: err = %v", err)
+		}
+		if _, err := f.repos.Shares.FindByID(ctx, sibling.ID); err != nil {
+			t.Errorf("sibling share: %v", err)
+		}
+	})
+}
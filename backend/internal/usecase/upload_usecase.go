@@ -0,0 +1,309 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// MinChunkSize is the smallest non-final chunk the object store accepts
+	// as a multipart part.
+	MinChunkSize int64 = 5 << 20
+	MaxChunkSize int64 = 64 << 20
+	maxParts           = 10000
+
+	uploadSessionTTL = 24 * time.Hour
+	// uploadLeaseTTL bounds how long one request may hold a session, which
+	// covers uploading the largest chunk or completing the largest upload.
+	uploadLeaseTTL = 10 * time.Minute
+)
+
+type UploadUsecase interface {
+	CreateSession(ctx context.Context, session domain.UploadSession) (domain.UploadSession, error)
+	GetSession(ctx context.Context, id, ownerID uuid.UUID) (domain.UploadSession, error)
+	WriteChunk(ctx context.Context, id, ownerID uuid.UUID, offset int64, content io.Reader, length int64) (int64, error)
+	Finalize(ctx context.Context, id, ownerID uuid.UUID) (domain.File, error)
+	Abort(ctx context.Context, id, ownerID uuid.UUID) error
+	ExpireSessions(ctx context.Context, now time.Time) (int, error)
+}
+
+type uploadUsecase struct {
+	sessionRepo  repository.UploadSessionRepository
+	storage      storage.Storage
+	metadataMode MetadataMode
+}
+
+func NewUploadUsecase(sessionRepo repository.UploadSessionRepository, storage storage.Storage, metadataMode MetadataMode) UploadUsecase {
+	return &uploadUsecase{sessionRepo: sessionRepo, storage: storage, metadataMode: metadataMode}
+}
+
+func (u *uploadUsecase) CreateSession(ctx context.Context, session domain.UploadSession) (domain.UploadSession, error) {
+	if session.Size <= 0 {
+		return domain.UploadSession{}, domain.Invalid("invalid_upload_size", "upload size must be positive")
+	}
+	if session.Size > maxParts*MaxChunkSize {
+		return domain.UploadSession{}, domain.Invalid("upload_too_large", "upload size exceeds the maximum supported size")
+	}
+
+	if err := validateFormat(session.FormatVersion, session.ChunkSize, session.Size); err != nil {
+		return domain.UploadSession{}, err
+	}
+	if err := u.metadataMode.apply(session.EncryptedMetadata, &session.Filename, &session.MimeType); err != nil {
+		return domain.UploadSession{}, err
+	}
+	if session.FormatVersion == domain.FormatLegacy {
+		session.ChunkSize = 0
+	}
+
+	now := time.Now().UTC()
+	session.ID = uuid.New()
+	session.FileID = uuid.New()
+	session.Offset = 0
+	session.PartCount = 0
+	session.CreatedAt = now
+	session.ExpiresAt = now.Add(uploadSessionTTL)
+
+	uploadID, err := u.storage.CreateMultipartUpload(ctx, "files", session.FileID.String(), session.MimeType)
+	if err != nil {
+		return domain.UploadSession{}, err
+	}
+	session.StorageUploadID = uploadID
+
+	// Save reserves the whole upload against the owner's quota; finalizing
+	// hands the bytes to the file and discarding the session gives them back.
+	if err := u.sessionRepo.Save(ctx, session); err != nil {
+		if abortErr := u.storage.AbortMultipartUpload(ctx, "files", session.FileID.String(), uploadID); abortErr != nil {
+			log.Printf("failed to abort multipart upload for file %s: %v", session.FileID, abortErr)
+		}
+		return domain.UploadSession{}, err
+	}
+
+	return session, nil
+}
+
+func (u *uploadUsecase) GetSession(ctx context.Context, id, ownerID uuid.UUID) (domain.UploadSession, error) {
+	session, err := u.sessionRepo.FindByID(ctx, id)
+	if err != nil {
+		return domain.UploadSession{}, err
+	}
+	if session.OwnerID != ownerID {
+		return domain.UploadSession{}, domain.NotFound("upload session")
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return domain.UploadSession{}, domain.ErrUploadSessionExpired
+	}
+	return session, nil
+}
+
+func (u *uploadUsecase) WriteChunk(ctx context.Context, id, ownerID uuid.UUID, offset int64, content io.Reader, length int64) (int64, error) {
+	session, err := u.GetSession(ctx, id, ownerID)
+	if err != nil {
+		return 0, err
+	}
+
+	if offset != session.Offset {
+		return session.Offset, domain.ErrUploadOffsetMismatch
+	}
+	if length <= 0 || length > MaxChunkSize {
+		return session.Offset, domain.Invalid("invalid_chunk_length", "invalid chunk length")
+	}
+	if offset+length > session.Size {
+		return session.Offset, domain.Invalid("chunk_exceeds_size", "chunk exceeds declared upload size")
+	}
+	if length < MinChunkSize && offset+length != session.Size {
+		return session.Offset, domain.Invalid("chunk_too_small", "only the final chunk may be smaller than the minimum chunk size")
+	}
+
+	// The lease keeps a concurrent PATCH at the same offset from uploading
+	// the same part number; the offset is checked again under it.
+	lease := uuid.New()
+	until := time.Now().UTC().Add(uploadLeaseTTL)
+	session, err = u.sessionRepo.Lease(ctx, session.ID, lease, time.Now().UTC(), until)
+	if err != nil {
+		return offset, err
+	}
+	newOffset, err := u.writePart(ctx, session, lease, until, offset, content, length)
+	if err != nil {
+		u.releaseLease(ctx, session.ID, lease)
+		return newOffset, err
+	}
+	return newOffset, nil
+}
+
+func (u *uploadUsecase) writePart(ctx context.Context, session domain.UploadSession, lease uuid.UUID, until time.Time, offset int64, content io.Reader, length int64) (int64, error) {
+	if offset != session.Offset {
+		return session.Offset, domain.ErrUploadOffsetMismatch
+	}
+
+	if offset == 0 && session.FormatVersion == domain.FormatChunkedV1 {
+		header, r, err := inspectContainer(content, session.Size)
+		if err != nil {
+			return session.Offset, err
+		}
+		if int(header.ChunkSize) != session.ChunkSize {
+			return session.Offset, domain.Invalid("chunk_size_mismatch", "container chunk size does not match upload session")
+		}
+		content = r
+	}
+
+	partNumber := session.PartCount + 1
+	if partNumber > maxParts {
+		return session.Offset, domain.Invalid("too_many_chunks", "too many chunks")
+	}
+
+	// Past the lease another request may take the session over, so the
+	// part must not land after it.
+	partCtx, cancel := context.WithDeadline(ctx, until)
+	defer cancel()
+	part, err := u.storage.UploadPart(partCtx, "files", session.FileID.String(), session.StorageUploadID, partNumber, content, length)
+	if err != nil {
+		return session.Offset, err
+	}
+
+	err = u.sessionRepo.AddPart(ctx, domain.UploadPart{
+		SessionID:  session.ID,
+		PartNumber: partNumber,
+		ETag:       part.ETag,
+		Size:       length,
+	}, lease, time.Now().UTC().Add(uploadSessionTTL))
+	if err != nil {
+		return session.Offset, err
+	}
+
+	return offset + length, nil
+}
+
+// Finalize completes the object and then swaps the session for its file in
+// one transaction. Every step can be retried: a session whose upload was
+// already completed by an earlier attempt is recognised by its object.
+func (u *uploadUsecase) Finalize(ctx context.Context, id, ownerID uuid.UUID) (domain.File, error) {
+	session, err := u.GetSession(ctx, id, ownerID)
+	if err != nil {
+		return domain.File{}, err
+	}
+
+	lease := uuid.New()
+	session, err = u.sessionRepo.Lease(ctx, session.ID, lease, time.Now().UTC(), time.Now().UTC().Add(uploadLeaseTTL))
+	if err != nil {
+		return domain.File{}, err
+	}
+	file, err := u.finalize(ctx, session, lease)
+	if err != nil {
+		u.releaseLease(ctx, session.ID, lease)
+		return domain.File{}, err
+	}
+	return file, nil
+}
+
+func (u *uploadUsecase) finalize(ctx context.Context, session domain.UploadSession, lease uuid.UUID) (domain.File, error) {
+	if session.Offset != session.Size {
+		return domain.File{}, domain.Conflict("upload_incomplete", "upload is incomplete")
+	}
+
+	parts, err := u.sessionRepo.ListParts(ctx, session.ID)
+	if err != nil {
+		return domain.File{}, err
+	}
+
+	storageParts := make([]storage.Part, 0, len(parts))
+	for _, p := range parts {
+		storageParts = append(storageParts, storage.Part{Number: p.PartNumber, ETag: p.ETag, Size: p.Size})
+	}
+
+	objectName := session.FileID.String()
+	if err := u.storage.CompleteMultipartUpload(ctx, "files", objectName, session.StorageUploadID, storageParts); err != nil {
+		// An earlier attempt may have completed the upload and failed
+		// afterwards, in which case the object store no longer knows it.
+		info, statErr := u.storage.Stat(ctx, "files", objectName)
+		if statErr != nil || info.Size != session.Size {
+			return domain.File{}, err
+		}
+	}
+
+	file := domain.File{
+		ID:                session.FileID,
+		OwnerID:           session.OwnerID,
+		Filename:          session.Filename,
+		MimeType:          session.MimeType,
+		Size:              session.Size,
+		EncryptedMetadata: session.EncryptedMetadata,
+		IV:                session.IV,
+		EncryptedKey:      session.EncryptedKey,
+		FormatVersion:     session.FormatVersion,
+		ChunkSize:         session.ChunkSize,
+		Version:           1,
+		ObjectName:        objectName,
+		CreatedAt:         time.Now().UTC(),
+	}
+	if err := u.sessionRepo.Finish(ctx, session.ID, lease, file); err != nil {
+		return domain.File{}, err
+	}
+	file.State = domain.FileStateCommitted
+
+	return file, nil
+}
+
+func (u *uploadUsecase) Abort(ctx context.Context, id, ownerID uuid.UUID) error {
+	session, err := u.sessionRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.OwnerID != ownerID {
+		return domain.NotFound("upload session")
+	}
+
+	return u.discard(ctx, session)
+}
+
+func (u *uploadUsecase) ExpireSessions(ctx context.Context, now time.Time) (int, error) {
+	sessions, err := u.sessionRepo.FindExpired(ctx, now, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, session := range sessions {
+		if err := u.discard(ctx, session); err != nil {
+			log.Printf("failed to expire upload session %s: %v", session.ID, err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// discard leases the session so no chunk or finalize is in flight, then
+// drops the upload, including an object an interrupted finalize completed,
+// and gives the reserved usage back.
+func (u *uploadUsecase) discard(ctx context.Context, session domain.UploadSession) error {
+	lease := uuid.New()
+	now := time.Now().UTC()
+	if _, err := u.sessionRepo.Lease(ctx, session.ID, lease, now, now.Add(uploadLeaseTTL)); err != nil {
+		return err
+	}
+
+	objectName := session.FileID.String()
+	if err := u.storage.AbortMultipartUpload(ctx, "files", objectName, session.StorageUploadID); err != nil {
+		u.releaseLease(ctx, session.ID, lease)
+		return err
+	}
+	if err := u.storage.Delete(ctx, "files", objectName); err != nil {
+		u.releaseLease(ctx, session.ID, lease)
+		return err
+	}
+	return u.sessionRepo.Delete(ctx, session.ID, lease)
+}
+
+func (u *uploadUsecase) releaseLease(ctx context.Context, id, lease uuid.UUID) {
+	if err := u.sessionRepo.ReleaseLease(ctx, id, lease); err != nil {
+		log.Printf("failed to release lease on upload session %s: %v", id, err)
+	}
+}
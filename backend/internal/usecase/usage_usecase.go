@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/google/uuid"
+)
+
+type UsageUsecase interface {
+	Get(ctx context.Context, userID uuid.UUID) (domain.Usage, error)
+	Recompute(ctx context.Context) (int, error)
+}
+
+type usageUsecase struct {
+	usageRepo repository.UsageRepository
+}
+
+func NewUsageUsecase(usageRepo repository.UsageRepository) UsageUsecase {
+	return &usageUsecase{usageRepo: usageRepo}
+}
+
+func (u *usageUsecase) Get(ctx context.Context, userID uuid.UUID) (domain.Usage, error) {
+	return u.usageRepo.Get(ctx, userID)
+}
+
+// Recompute corrects drift between the tracked usage and the stored files,
+// returning how many users were off.
+func (u *usageUsecase) Recompute(ctx context.Context) (int, error) {
+	return u.usageRepo.Recompute(ctx)
+}
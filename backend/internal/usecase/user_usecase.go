@@ -2,13 +2,13 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"log"
 	"time"
 
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/domain"
 	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/repository"
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/password"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/argon2"
 )
 
 type UserUsecase interface {
@@ -16,37 +16,49 @@ type UserUsecase interface {
 	Login(ctx context.Context, username, password string) (domain.User, []byte, error)
 	GetByID(ctx context.Context, id uuid.UUID) (domain.User, error)
 	GetByUsername(ctx context.Context, username string) (domain.User, error)
+	SearchByUsernamePrefix(ctx context.Context, prefix, after string, limit int) ([]domain.User, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string, keys []domain.UserKey) error
 }
 
 type userUsecase struct {
 	userRepo repository.UserRepository
+	keyLog   KeyLogUsecase
 }
 
-func NewUserUsecase(userRepo repository.UserRepository) UserUsecase {
-	return &userUsecase{userRepo: userRepo}
+func NewUserUsecase(userRepo repository.UserRepository, keyLog KeyLogUsecase) UserUsecase {
+	return &userUsecase{userRepo: userRepo, keyLog: keyLog}
 }
 
-func hashPassword(password string) []byte {
-	salt := []byte("static-salt")
-	hash := argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
-	return hash
-}
+// dummyHash is verified against when a username does not exist so that
+// failed logins take the same time whether or not the account is real.
+var dummyHash, _ = password.Hash("dummy-password", password.DefaultParams)
+
+func (uc *userUsecase) Register(ctx context.Context, username, pass string, publicKey, encryptedPrivateKey []byte) (domain.User, error) {
+	if _, err := uc.userRepo.FindByUsername(ctx, username); err == nil {
+		return domain.User{}, domain.ErrUsernameTaken
+	}
 
-func (uc *userUsecase) Register(ctx context.Context, username, password string, publicKey, encryptedPrivateKey []byte) (domain.User, error) {
-	_, err := uc.userRepo.FindByUsername(ctx, username)
+	hash, err := password.Hash(pass, password.DefaultParams)
 	if err != nil {
-		return domain.User{}, errors.New("username already exists")
+		return domain.User{}, err
 	}
 
 	user := domain.User{
 		ID:                  uuid.New(),
 		Username:            username,
-		PasswordHash:        string(hashPassword(password)),
+		PasswordHash:        hash,
 		PublicKey:           string(publicKey),
 		EncryptedPrivateKey: encryptedPrivateKey,
+		KeyVersion:          1,
 		CreatedAt:           time.Now(),
 	}
 
+	// Log the binding first: an entry for an account that then fails to be
+	// created is harmless, but an account missing from the log is not.
+	if err := uc.keyLog.Append(ctx, user.ID, publicKey); err != nil {
+		return domain.User{}, err
+	}
+
 	err = uc.userRepo.Save(ctx, user)
 	if err != nil {
 		return domain.User{}, err
@@ -55,15 +67,26 @@ func (uc *userUsecase) Register(ctx context.Context, username, password string,
 	return user, nil
 }
 
-func (uc *userUsecase) Login(ctx context.Context, username, password string) (domain.User, []byte, error) {
+func (uc *userUsecase) Login(ctx context.Context, username, pass string) (domain.User, []byte, error) {
 	user, err := uc.userRepo.FindByUsername(ctx, username)
 	if err != nil {
-		return domain.User{}, nil, errors.New("invalid credentials")
+		_, _ = password.Verify(pass, dummyHash)
+		return domain.User{}, nil, domain.ErrInvalidCredentials
+	}
+
+	ok, err := password.Verify(pass, user.PasswordHash)
+	if err != nil || !ok {
+		return domain.User{}, nil, domain.ErrInvalidCredentials
 	}
 
-	hash := string(hashPassword(password))
-	if hash != user.PasswordHash {
-		return domain.User{}, nil, errors.New("invalid credentials")
+	if password.NeedsRehash(user.PasswordHash, password.DefaultParams) {
+		if hash, err := password.Hash(pass, password.DefaultParams); err == nil {
+			if err := uc.userRepo.UpdatePasswordHash(ctx, user.ID, hash); err != nil {
+				log.Printf("failed to upgrade password hash for user %s: %v", user.ID, err)
+			} else {
+				user.PasswordHash = hash
+			}
+		}
 	}
 
 	return user, user.EncryptedPrivateKey, nil
@@ -76,3 +99,65 @@ func (uc *userUsecase) GetByID(ctx context.Context, id uuid.UUID) (domain.User,
 func (uc *userUsecase) GetByUsername(ctx context.Context, username string) (domain.User, error) {
 	return uc.userRepo.FindByUsername(ctx, username)
 }
+
+func (uc *userUsecase) SearchByUsernamePrefix(ctx context.Context, prefix, after string, limit int) ([]domain.User, error) {
+	if len(prefix) < 2 {
+		return nil, domain.Invalid("prefix_too_short", "prefix must be at least 2 characters")
+	}
+	return uc.userRepo.SearchByUsernamePrefix(ctx, prefix, after, UserSearchLimit(limit))
+}
+
+// UserSearchLimit clamps a requested page size for username search.
+func UserSearchLimit(limit int) int {
+	if limit <= 0 || limit > 50 {
+		return 20
+	}
+	return limit
+}
+
+// ChangePassword verifies oldPassword and stores newPassword together with
+// the private keys the client re-encrypted under it, exactly one for every
+// stored key version. The blobs are opaque to the server, so it cannot tell
+// whether one really holds the matching private key; each is submitted with
+// its version's public key only so that a client mixing up versions is
+// caught before it overwrites the wrong one.
+func (uc *userUsecase) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string, keys []domain.UserKey) error {
+	if newPassword == "" {
+		return domain.Invalid("password_required", "new password is required")
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return domain.NotFound("user")
+	}
+
+	ok, err := password.Verify(oldPassword, user.PasswordHash)
+	if err != nil || !ok {
+		return domain.ErrInvalidCredentials
+	}
+
+	var current *domain.UserKey
+	seen := make(map[int]bool, len(keys))
+	for i := range keys {
+		if seen[keys[i].Version] {
+			return domain.ErrDuplicateKeyVersion
+		}
+		seen[keys[i].Version] = true
+		if keys[i].Version == user.KeyVersion {
+			current = &keys[i]
+		}
+	}
+	if current == nil {
+		return domain.ErrKeyVersionMissing
+	}
+	if current.PublicKey != user.PublicKey {
+		return domain.ErrPublicKeyMismatch
+	}
+
+	hash, err := password.Hash(newPassword, password.DefaultParams)
+	if err != nil {
+		return err
+	}
+
+	return uc.userRepo.UpdateCredentials(ctx, userID, hash, keys)
+}
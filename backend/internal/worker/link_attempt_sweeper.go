@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+)
+
+type LinkAttemptSweeper struct {
+	linkUsecase usecase.LinkUsecase
+	interval    time.Duration
+}
+
+func NewLinkAttemptSweeper(linkUsecase usecase.LinkUsecase, interval time.Duration) *LinkAttemptSweeper {
+	return &LinkAttemptSweeper{linkUsecase: linkUsecase, interval: interval}
+}
+
+func (s *LinkAttemptSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.linkUsecase.PurgeAttempts(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("link attempt sweeper: %v", err)
+		} else if n > 0 {
+			log.Printf("link attempt sweeper: removed %d stale attempt counters", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+)
+
+// Reconciler settles uploads left pending for longer than grace and drains
+// the storage outbox.
+type Reconciler struct {
+	reconcileUsecase usecase.ReconcileUsecase
+	grace            time.Duration
+	interval         time.Duration
+}
+
+func NewReconciler(reconcileUsecase usecase.ReconcileUsecase, grace, interval time.Duration) *Reconciler {
+	return &Reconciler{reconcileUsecase: reconcileUsecase, grace: grace, interval: interval}
+}
+
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().UTC()
+
+		n, err := r.reconcileUsecase.ResolvePending(ctx, now.Add(-r.grace))
+		if err != nil {
+			log.Printf("reconciler: %v", err)
+		} else if n > 0 {
+			log.Printf("reconciler: resolved %d pending uploads", n)
+		}
+
+		n, err = r.reconcileUsecase.DrainOutbox(ctx, now)
+		if err != nil {
+			log.Printf("reconciler: %v", err)
+		} else if n > 0 {
+			log.Printf("reconciler: completed %d storage operations", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
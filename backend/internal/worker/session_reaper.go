@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+)
+
+type SessionReaper struct {
+	sessionUsecase usecase.SessionUsecase
+	interval       time.Duration
+}
+
+func NewSessionReaper(sessionUsecase usecase.SessionUsecase, interval time.Duration) *SessionReaper {
+	return &SessionReaper{sessionUsecase: sessionUsecase, interval: interval}
+}
+
+func (r *SessionReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.sessionUsecase.ReapExpired(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("session reaper: %v", err)
+		} else if n > 0 {
+			log.Printf("session reaper: removed %d expired sessions", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
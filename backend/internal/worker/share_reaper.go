@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+)
+
+type ShareReaper struct {
+	shareUsecase usecase.ShareUsecase
+	interval     time.Duration
+}
+
+func NewShareReaper(shareUsecase usecase.ShareUsecase, interval time.Duration) *ShareReaper {
+	return &ShareReaper{shareUsecase: shareUsecase, interval: interval}
+}
+
+func (r *ShareReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.shareUsecase.ReapExpired(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("share reaper: %v", err)
+		} else if n > 0 {
+			log.Printf("share reaper: removed %d expired shares", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+)
+
+// TrashPurger permanently deletes files that have sat in the trash for
+// longer than retention.
+type TrashPurger struct {
+	fileUsecase usecase.FileUsecase
+	retention   time.Duration
+	interval    time.Duration
+}
+
+func NewTrashPurger(fileUsecase usecase.FileUsecase, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{fileUsecase: fileUsecase, retention: retention, interval: interval}
+}
+
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := p.fileUsecase.PurgeTrash(ctx, time.Now().UTC().Add(-p.retention))
+		if err != nil {
+			log.Printf("trash purger: %v", err)
+		} else if n > 0 {
+			log.Printf("trash purger: purged %d files", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
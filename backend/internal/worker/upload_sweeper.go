@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/usecase"
+)
+
+type UploadSweeper struct {
+	uploadUsecase usecase.UploadUsecase
+	interval      time.Duration
+}
+
+func NewUploadSweeper(uploadUsecase usecase.UploadUsecase, interval time.Duration) *UploadSweeper {
+	return &UploadSweeper{uploadUsecase: uploadUsecase, interval: interval}
+}
+
+func (s *UploadSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.uploadUsecase.ExpireSessions(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("upload sweeper: %v", err)
+		} else if n > 0 {
+			log.Printf("upload sweeper: expired %d abandoned upload sessions", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS upload_session_parts;
+DROP TABLE IF EXISTS upload_sessions;
\ No newline at end of file
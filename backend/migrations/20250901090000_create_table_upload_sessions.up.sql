@@ -0,0 +1,25 @@
+CREATE TABLE upload_sessions (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_id UUID NOT NULL UNIQUE,
+    filename TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    part_count INTEGER NOT NULL DEFAULT 0,
+    iv BYTEA NOT NULL,
+    encrypted_key BYTEA NOT NULL,
+    storage_upload_id TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_upload_sessions_expires_at ON upload_sessions (expires_at);
+
+CREATE TABLE upload_session_parts (
+    session_id UUID NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    part_number INTEGER NOT NULL,
+    etag TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    PRIMARY KEY (session_id, part_number)
+);
\ No newline at end of file
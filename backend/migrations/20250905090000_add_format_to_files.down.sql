@@ -0,0 +1,7 @@
+ALTER TABLE upload_sessions
+    DROP COLUMN IF EXISTS chunk_size,
+    DROP COLUMN IF EXISTS format_version;
+
+ALTER TABLE files
+    DROP COLUMN IF EXISTS chunk_size,
+    DROP COLUMN IF EXISTS format_version;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE files
+    ADD COLUMN format_version SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN chunk_size INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE upload_sessions
+    ADD COLUMN format_version SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN chunk_size INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- Only hashes still carrying the legacy static salt can be converted back;
+-- rows already rehashed under a per-user salt are left as they are.
+UPDATE users
+SET password_hash = convert_from(
+        decode(
+            split_part(password_hash, '$', 6)
+                || repeat('=', (4 - length(split_part(password_hash, '$', 6)) % 4) % 4),
+            'base64'),
+        'UTF8')
+WHERE password_hash LIKE '$argon2id$v=19$m=65536,t=1,p=4$c3RhdGljLXNhbHQ$%';
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- Hashes written before per-user salts were raw Argon2id output
+-- (t=1, m=65536, p=4, 32-byte key) over the constant salt "static-salt",
+-- stored as text. Re-encode them as PHC strings carrying those parameters
+-- so they verify unchanged; the server rehashes them with a random salt on
+-- the user's next login.
+UPDATE users
+SET password_hash = '$argon2id$v=19$m=65536,t=1,p=4$c3RhdGljLXNhbHQ$'
+    || rtrim(replace(encode(convert_to(password_hash, 'UTF8'), 'base64'), E'\n', ''), '=')
+WHERE password_hash NOT LIKE '$argon2id$%';
\ No newline at end of file
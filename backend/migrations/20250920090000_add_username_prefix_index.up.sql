@@ -0,0 +1 @@
+CREATE INDEX idx_users_username_prefix ON users (username text_pattern_ops);
\ No newline at end of file
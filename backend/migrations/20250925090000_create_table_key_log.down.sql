@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS key_log_tree_heads;
+DROP TABLE IF EXISTS key_log_entries;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- Entries are never updated or deleted and deliberately do not reference
+-- users, so the log outlives the accounts it describes.
+CREATE TABLE key_log_entries (
+    idx BIGINT PRIMARY KEY CHECK (idx >= 0),
+    user_id UUID NOT NULL,
+    public_key BYTEA NOT NULL,
+    leaf_data BYTEA NOT NULL,
+    leaf_hash BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_key_log_entries_user_id ON key_log_entries (user_id, idx DESC);
+
+CREATE TABLE key_log_tree_heads (
+    tree_size BIGINT PRIMARY KEY,
+    root_hash BYTEA NOT NULL,
+    timestamp_ms BIGINT NOT NULL,
+    signature BYTEA NOT NULL
+);
\ No newline at end of file
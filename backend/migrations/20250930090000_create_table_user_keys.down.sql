@@ -0,0 +1,4 @@
+ALTER TABLE shares DROP COLUMN IF EXISTS key_version;
+ALTER TABLE files DROP COLUMN IF EXISTS key_version;
+DROP TABLE IF EXISTS user_keys;
+ALTER TABLE users DROP COLUMN IF EXISTS key_version;
\ No newline at end of file
@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN key_version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE user_keys (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0),
+    public_key TEXT NOT NULL,
+    encrypted_private_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, version)
+);
+
+INSERT INTO user_keys (user_id, version, public_key, encrypted_private_key, created_at)
+SELECT id, 1, public_key, encrypted_private_key, created_at FROM users;
+
+ALTER TABLE files ADD COLUMN key_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE shares ADD COLUMN key_version INTEGER NOT NULL DEFAULT 1;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS link_shares;
\ No newline at end of file
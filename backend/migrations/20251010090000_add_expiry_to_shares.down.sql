@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS share_events;
+DROP INDEX IF EXISTS idx_shares_expires_at;
+ALTER TABLE shares DROP COLUMN IF EXISTS expires_at;
\ No newline at end of file
@@ -0,0 +1,14 @@
+ALTER TABLE shares ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_shares_expires_at ON shares (expires_at) WHERE expires_at IS NOT NULL;
+
+CREATE TABLE share_events (
+    id BIGSERIAL PRIMARY KEY,
+    share_id UUID NOT NULL,
+    file_id UUID NOT NULL,
+    recipient_id UUID NOT NULL,
+    event TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_share_events_file_id ON share_events (file_id);
\ No newline at end of file
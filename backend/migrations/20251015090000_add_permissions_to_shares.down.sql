@@ -0,0 +1,3 @@
+ALTER TABLE shares DROP COLUMN IF EXISTS grantor_chain;
+ALTER TABLE shares DROP COLUMN IF EXISTS granted_by;
+ALTER TABLE shares DROP COLUMN IF EXISTS permissions;
\ No newline at end of file
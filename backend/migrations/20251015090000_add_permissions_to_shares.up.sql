@@ -0,0 +1,10 @@
+ALTER TABLE shares ADD COLUMN permissions INTEGER NOT NULL DEFAULT 3;
+ALTER TABLE shares ADD COLUMN granted_by UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE shares ADD COLUMN grantor_chain UUID[] NOT NULL DEFAULT '{}';
+
+UPDATE shares s
+SET granted_by = f.owner_id, grantor_chain = ARRAY[f.owner_id]
+FROM files f
+WHERE f.id = s.file_id;
+
+ALTER TABLE shares ALTER COLUMN granted_by SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS group_shares;
+DROP TABLE IF EXISTS group_member_keys;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS group_keys;
+DROP TABLE IF EXISTS groups;
\ No newline at end of file
@@ -0,0 +1,54 @@
+CREATE TABLE groups (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key_version INTEGER NOT NULL DEFAULT 1,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_groups_owner_id ON groups (owner_id);
+
+CREATE TABLE group_keys (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0),
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at TIMESTAMPTZ,
+    PRIMARY KEY (group_id, version)
+);
+
+CREATE TABLE group_members (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_group_members_user_id ON group_members (user_id);
+
+CREATE TABLE group_member_keys (
+    group_id UUID NOT NULL,
+    version INTEGER NOT NULL,
+    user_id UUID NOT NULL,
+    wrapped_private_key BYTEA NOT NULL,
+    user_key_version INTEGER NOT NULL,
+    PRIMARY KEY (group_id, version, user_id),
+    FOREIGN KEY (group_id, version) REFERENCES group_keys (group_id, version) ON DELETE CASCADE,
+    FOREIGN KEY (group_id, user_id) REFERENCES group_members (group_id, user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE group_shares (
+    id UUID PRIMARY KEY,
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    wrapped_key BYTEA NOT NULL,
+    key_version INTEGER NOT NULL,
+    permissions INTEGER NOT NULL DEFAULT 3,
+    granted_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    UNIQUE (file_id, group_id)
+);
+
+CREATE INDEX idx_group_shares_group_id ON group_shares (group_id);
\ No newline at end of file
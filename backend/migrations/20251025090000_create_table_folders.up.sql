@@ -0,0 +1,34 @@
+CREATE TABLE folders (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    encrypted_name BYTEA NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    key_version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (parent_id IS DISTINCT FROM id)
+);
+
+CREATE INDEX idx_folders_owner_parent ON folders (owner_id, parent_id);
+CREATE INDEX idx_folders_parent_id ON folders (parent_id);
+
+ALTER TABLE files ADD COLUMN folder_id UUID REFERENCES folders(id) ON DELETE CASCADE;
+ALTER TABLE files ADD COLUMN folder_wrapped_key BYTEA;
+
+CREATE INDEX idx_files_folder_id ON files (folder_id);
+
+CREATE TABLE folder_shares (
+    id UUID PRIMARY KEY,
+    folder_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    recipient_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    wrapped_key BYTEA NOT NULL,
+    key_version INTEGER NOT NULL,
+    permissions INTEGER NOT NULL DEFAULT 3,
+    granted_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    UNIQUE (folder_id, recipient_id)
+);
+
+CREATE INDEX idx_folder_shares_recipient_id ON folder_shares (recipient_id);
\ No newline at end of file
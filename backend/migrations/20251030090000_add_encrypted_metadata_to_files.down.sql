@@ -0,0 +1,6 @@
+ALTER TABLE upload_sessions ALTER COLUMN mime_type DROP DEFAULT;
+ALTER TABLE upload_sessions ALTER COLUMN filename DROP DEFAULT;
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS encrypted_metadata;
+ALTER TABLE files ALTER COLUMN mime_type DROP DEFAULT;
+ALTER TABLE files ALTER COLUMN filename DROP DEFAULT;
+ALTER TABLE files DROP COLUMN IF EXISTS encrypted_metadata;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- Plaintext filename and mime_type stay for rows written before clients
+-- encrypted their metadata; new rows with encrypted_metadata leave them empty.
+ALTER TABLE files ADD COLUMN encrypted_metadata BYTEA;
+ALTER TABLE files ALTER COLUMN filename SET DEFAULT '';
+ALTER TABLE files ALTER COLUMN mime_type SET DEFAULT '';
+
+ALTER TABLE upload_sessions ADD COLUMN encrypted_metadata BYTEA;
+ALTER TABLE upload_sessions ALTER COLUMN filename SET DEFAULT '';
+ALTER TABLE upload_sessions ALTER COLUMN mime_type SET DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS file_versions;
+ALTER TABLE files DROP COLUMN IF EXISTS object_name;
+ALTER TABLE files DROP COLUMN IF EXISTS version_key;
+ALTER TABLE files DROP COLUMN IF EXISTS version;
\ No newline at end of file
@@ -0,0 +1,23 @@
+ALTER TABLE files ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE files ADD COLUMN version_key BYTEA;
+ALTER TABLE files ADD COLUMN object_name TEXT;
+
+UPDATE files SET object_name = id::text;
+
+ALTER TABLE files ALTER COLUMN object_name SET NOT NULL;
+
+CREATE TABLE file_versions (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    object_name TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    iv BYTEA NOT NULL,
+    version_key BYTEA,
+    format_version INTEGER NOT NULL DEFAULT 0,
+    chunk_size INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (file_id, version)
+);
+
+INSERT INTO file_versions (file_id, version, object_name, size, iv, format_version, chunk_size, created_at)
+SELECT id, 1, object_name, size, iv, format_version, chunk_size, created_at FROM files;
\ No newline at end of file
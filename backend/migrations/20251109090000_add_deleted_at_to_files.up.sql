@@ -0,0 +1,3 @@
+ALTER TABLE files ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_files_deleted_at ON files (deleted_at) WHERE deleted_at IS NOT NULL;
\ No newline at end of file
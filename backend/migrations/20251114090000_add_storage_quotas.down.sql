@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN IF EXISTS used_bytes;
+ALTER TABLE users DROP COLUMN IF EXISTS quota_bytes;
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
+DROP TABLE IF EXISTS plans;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE plans (
+    name TEXT PRIMARY KEY,
+    quota_bytes BIGINT NOT NULL CHECK (quota_bytes >= 0)
+);
+
+INSERT INTO plans (name, quota_bytes) VALUES
+    ('free', 5368709120),
+    ('pro', 107374182400);
+
+ALTER TABLE users ADD COLUMN plan TEXT NOT NULL DEFAULT 'free' REFERENCES plans(name);
+ALTER TABLE users ADD COLUMN quota_bytes BIGINT CHECK (quota_bytes >= 0);
+ALTER TABLE users ADD COLUMN used_bytes BIGINT NOT NULL DEFAULT 0;
+
+UPDATE users u SET used_bytes =
+    COALESCE((SELECT SUM(v.size) FROM file_versions v JOIN files f ON f.id = v.file_id WHERE f.owner_id = u.id), 0)
+    + COALESCE((SELECT SUM(s.size) FROM upload_sessions s WHERE s.owner_id = u.id), 0);
\ No newline at end of file
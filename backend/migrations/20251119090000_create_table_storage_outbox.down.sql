@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS storage_outbox;
+DROP INDEX IF EXISTS idx_file_versions_pending;
+DROP INDEX IF EXISTS idx_files_pending;
+ALTER TABLE file_versions DROP COLUMN IF EXISTS state;
+ALTER TABLE files DROP COLUMN IF EXISTS state;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE files ADD COLUMN state TEXT NOT NULL DEFAULT 'committed' CHECK (state IN ('pending', 'committed'));
+ALTER TABLE file_versions ADD COLUMN state TEXT NOT NULL DEFAULT 'committed' CHECK (state IN ('pending', 'committed'));
+
+CREATE INDEX idx_files_pending ON files (created_at) WHERE state = 'pending';
+CREATE INDEX idx_file_versions_pending ON file_versions (created_at) WHERE state = 'pending';
+
+CREATE TABLE storage_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    op TEXT NOT NULL CHECK (op IN ('delete')),
+    bucket TEXT NOT NULL,
+    object_name TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_storage_outbox_next_attempt_at ON storage_outbox (next_attempt_at);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS write_lease_until;
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS write_lease;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE upload_sessions ADD COLUMN write_lease UUID;
+ALTER TABLE upload_sessions ADD COLUMN write_lease_until TIMESTAMPTZ;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS key_log_nodes;
\ No newline at end of file
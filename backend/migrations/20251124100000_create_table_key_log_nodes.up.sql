@@ -0,0 +1,30 @@
+-- Roots of the complete subtrees of the key log, so tree heads and proofs
+-- read O(log n) hashes instead of every leaf. Level 0 is the leaf hash in
+-- key_log_entries; node (level, idx) covers leaves [idx << level, (idx + 1) << level).
+CREATE TABLE key_log_nodes (
+    level SMALLINT NOT NULL CHECK (level > 0),
+    idx BIGINT NOT NULL CHECK (idx >= 0),
+    hash BYTEA NOT NULL,
+    PRIMARY KEY (level, idx)
+);
+
+INSERT INTO key_log_nodes (level, idx, hash)
+SELECT 1, l.idx / 2, sha256('\x01'::bytea || l.leaf_hash || r.leaf_hash)
+FROM key_log_entries l
+JOIN key_log_entries r ON r.idx = l.idx + 1
+WHERE l.idx % 2 = 0;
+
+DO $$
+DECLARE
+    lvl SMALLINT := 1;
+BEGIN
+    LOOP
+        INSERT INTO key_log_nodes (level, idx, hash)
+        SELECT lvl + 1, l.idx / 2, sha256('\x01'::bytea || l.hash || r.hash)
+        FROM key_log_nodes l
+        JOIN key_log_nodes r ON r.level = l.level AND r.idx = l.idx + 1
+        WHERE l.level = lvl AND l.idx % 2 = 0;
+        EXIT WHEN NOT FOUND;
+        lvl := lvl + 1;
+    END LOOP;
+END $$;
\ No newline at end of file
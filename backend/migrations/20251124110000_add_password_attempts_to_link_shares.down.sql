@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS link_password_attempts;
+
+ALTER TABLE link_shares
+    DROP COLUMN IF EXISTS attempts_reset_at,
+    DROP COLUMN IF EXISTS password_attempts;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE link_shares
+    ADD COLUMN password_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN attempts_reset_at TIMESTAMPTZ;
+
+CREATE TABLE link_password_attempts (
+    client_ip TEXT PRIMARY KEY,
+    attempts INTEGER NOT NULL,
+    reset_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_link_password_attempts_reset_at ON link_password_attempts (reset_at);
\ No newline at end of file
@@ -0,0 +1,5 @@
+DELETE FROM share_events WHERE recipient_id IS NULL;
+
+ALTER TABLE share_events
+    ALTER COLUMN recipient_id SET NOT NULL,
+    DROP COLUMN IF EXISTS group_id;
\ No newline at end of file
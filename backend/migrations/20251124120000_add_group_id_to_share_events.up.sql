@@ -0,0 +1,3 @@
+ALTER TABLE share_events
+    ADD COLUMN group_id UUID,
+    ALTER COLUMN recipient_id DROP NOT NULL;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE folders DROP CONSTRAINT folders_parent_id_fkey;
+ALTER TABLE folders ADD CONSTRAINT folders_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES folders(id) ON DELETE CASCADE;
+ALTER TABLE files DROP CONSTRAINT files_folder_id_fkey;
+ALTER TABLE files ADD CONSTRAINT files_folder_id_fkey FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_folders_deleted_at;
+ALTER TABLE folders DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
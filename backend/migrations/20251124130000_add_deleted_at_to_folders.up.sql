@@ -0,0 +1,10 @@
+ALTER TABLE folders ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_folders_deleted_at ON folders (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Deleting a folder moves it and its files to the trash; rows go only when
+-- the trash is purged, and never take a file the purge did not delete.
+ALTER TABLE files DROP CONSTRAINT files_folder_id_fkey;
+ALTER TABLE files ADD CONSTRAINT files_folder_id_fkey FOREIGN KEY (folder_id) REFERENCES folders(id);
+ALTER TABLE folders DROP CONSTRAINT folders_parent_id_fkey;
+ALTER TABLE folders ADD CONSTRAINT folders_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES folders(id);
\ No newline at end of file
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("test-secret")
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	s := NewTokenSigner(testSecret)
+	token, err := s.GenerateToken("user-id", "alice", "session-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken = %v", err)
+	}
+	if claims.UserID != "user-id" || claims.Username != "alice" || claims.SessionID != "session-id" {
+		t.Fatalf("claims = %+v", claims)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl <= 0 || ttl > AccessTokenTTL {
+		t.Fatalf("token expires in %v, want within %v", ttl, AccessTokenTTL)
+	}
+}
+
+func TestValidateTokenRejects(t *testing.T) {
+	s := NewTokenSigner(testSecret)
+	valid := func(expiresAt time.Time) Claims {
+		return Claims{
+			UserID:    "user-id",
+			Username:  "alice",
+			SessionID: "session-id",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				IssuedAt:  jwt.NewNumericDate(expiresAt.Add(-AccessTokenTTL)),
+			},
+		}
+	}
+	live := valid(time.Now().Add(AccessTokenTTL))
+	other, err := NewTokenSigner([]byte("other-secret")).GenerateToken("user-id", "alice", "session-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Swap the first signature character, which always changes the decoded
+	// signature.
+	good := sign(t, jwt.SigningMethodHS256, testSecret, live)
+	dot := strings.LastIndex(good, ".") + 1
+	flipped := "A"
+	if good[dot] == 'A' {
+		flipped = "B"
+	}
+	tampered := good[:dot] + flipped + good[dot+1:]
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", sign(t, jwt.SigningMethodHS256, testSecret, valid(time.Now().Add(-time.Minute)))},
+		{"wrong secret", other},
+		{"wrong signing method", sign(t, jwt.SigningMethodHS512, testSecret, live)},
+		{"unsigned", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, live)},
+		{"tampered signature", tampered},
+		{"malformed", "not-a-token"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := s.ValidateToken(tt.token); err == nil {
+				t.Fatalf("ValidateToken = %+v, want an error", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := base64.RawURLEncoding.DecodeString(token); err != nil || len(raw) != 32 {
+		t.Fatalf("token %q decodes to %d bytes, %v; want 32", token, len(raw), err)
+	}
+	if !bytes.Equal(HashRefreshToken(token), hash) {
+		t.Fatal("stored hash does not match the token")
+	}
+
+	tampered := []byte(token)
+	tampered[0] ^= 1
+	if bytes.Equal(HashRefreshToken(string(tampered)), hash) {
+		t.Fatal("tampered token matches the stored hash")
+	}
+
+	next, nextHash, err := NewRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == token || bytes.Equal(nextHash, hash) {
+		t.Fatal("two refresh tokens are equal")
+	}
+}
@@ -0,0 +1,82 @@
+package chunkcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+type sealer struct {
+	aead   cipher.AEAD
+	header []byte
+	prefix [NoncePrefixSize]byte
+}
+
+func newSealer(h Header, key []byte) (*sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("chunkcrypt: key must be %d bytes", KeySize)
+	}
+
+	var aead cipher.AEAD
+	switch h.Algorithm {
+	case AlgAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	case AlgChaCha20Poly1305:
+		var err error
+		aead, err = chacha20poly1305.New(key)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("chunkcrypt: unsupported algorithm")
+	}
+
+	encoded, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead, header: encoded, prefix: h.NoncePrefix}, nil
+}
+
+func (s *sealer) nonce(index uint32, final bool) []byte {
+	n := make([]byte, s.aead.NonceSize())
+	copy(n, s.prefix[:])
+	binary.BigEndian.PutUint32(n[NoncePrefixSize:], index)
+	if final {
+		n[len(n)-1] = 1
+	}
+	return n
+}
+
+func (s *sealer) seal(dst, plaintext []byte, index uint32, final bool) []byte {
+	return s.aead.Seal(dst, s.nonce(index, final), plaintext, s.header)
+}
+
+func (s *sealer) open(dst, segment []byte, index uint32, final bool) ([]byte, error) {
+	out, err := s.aead.Open(dst, s.nonce(index, final), segment, s.header)
+	if err != nil {
+		return nil, ErrAuth
+	}
+	return out, nil
+}
+
+// DecryptSegment opens a single segment read from SegmentOffset(index).
+// Callers doing random access must pass final=true for the last segment.
+func DecryptSegment(h Header, key, segment []byte, index uint32, final bool) ([]byte, error) {
+	s, err := newSealer(h, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(nil, segment, index, final)
+}
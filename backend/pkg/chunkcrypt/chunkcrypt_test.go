@@ -0,0 +1,204 @@
+package chunkcrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+const testChunkSize = MinChunkSize
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encrypt(t *testing.T, key, plain []byte, algorithm uint8) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, key, algorithm, testChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Odd-sized writes exercise segments filled across several calls.
+	for rest := plain; len(rest) > 0; {
+		n := min(len(rest), 1000)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(key, container []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(container), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1, 3*testChunkSize + 17}
+	algorithms := map[string]uint8{"aes-gcm": AlgAES256GCM, "chacha20-poly1305": AlgChaCha20Poly1305}
+
+	for name, alg := range algorithms {
+		for _, size := range sizes {
+			plain := make([]byte, size)
+			rand.Read(plain)
+			key := testKey(t)
+
+			container := encrypt(t, key, plain, alg)
+
+			h, err := ParseHeader(container)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", name, size, err)
+			}
+			if got := h.EncryptedSize(int64(size)); got != int64(len(container)) {
+				t.Errorf("%s/%d: EncryptedSize = %d, container is %d bytes", name, size, got, len(container))
+			}
+			if got, err := h.PlaintextSize(int64(len(container))); err != nil || got != int64(size) {
+				t.Errorf("%s/%d: PlaintextSize = %d, %v", name, size, got, err)
+			}
+
+			got, err := decrypt(key, container)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", name, size, err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("%s/%d: plaintext differs after round trip", name, size)
+			}
+		}
+	}
+}
+
+func TestDecryptSegment(t *testing.T) {
+	key := testKey(t)
+	plain := make([]byte, 2*testChunkSize+5)
+	rand.Read(plain)
+	container := encrypt(t, key, plain, AlgAES256GCM)
+
+	h, err := ParseHeader(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := h.SegmentCount(int64(len(plain))) - 1
+	segment := container[h.SegmentOffset(last):]
+
+	got, err := DecryptSegment(h, key, segment, uint32(last), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain[last*testChunkSize:]) {
+		t.Fatal("last segment decrypted to the wrong plaintext")
+	}
+	if _, err := DecryptSegment(h, key, segment, uint32(last), false); !errors.Is(err, ErrAuth) {
+		t.Fatalf("opening the final segment as non-final: err = %v, want ErrAuth", err)
+	}
+}
+
+func TestTruncatedStream(t *testing.T) {
+	key := testKey(t)
+	plain := make([]byte, 3*testChunkSize+100)
+	rand.Read(plain)
+	container := encrypt(t, key, plain, AlgAES256GCM)
+	h, _ := ParseHeader(container)
+
+	tests := []struct {
+		name    string
+		length  int64
+		wantErr error
+	}{
+		// Dropping whole segments leaves a non-final segment at the end,
+		// which fails to open as the final one.
+		{"at segment boundary", h.SegmentOffset(2), ErrAuth},
+		{"mid segment", h.SegmentOffset(1) + 100, ErrAuth},
+		{"inside a tag", h.SegmentOffset(3) + TagSize - 1, ErrTruncated},
+		{"last byte missing", int64(len(container) - 1), ErrAuth},
+		{"header only", HeaderSize, ErrTruncated},
+		{"short header", HeaderSize - 1, ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decrypt(key, container[:tt.length]); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReorderedSegments(t *testing.T) {
+	key := testKey(t)
+	plain := make([]byte, 3*testChunkSize+100)
+	rand.Read(plain)
+	container := encrypt(t, key, plain, AlgChaCha20Poly1305)
+	h, _ := ParseHeader(container)
+
+	seg := func(i int64) []byte {
+		return container[h.SegmentOffset(i):h.SegmentOffset(i+1)]
+	}
+	var swapped []byte
+	swapped = append(swapped, container[:HeaderSize]...)
+	swapped = append(swapped, seg(1)...)
+	swapped = append(swapped, seg(0)...)
+	swapped = append(swapped, container[h.SegmentOffset(2):]...)
+
+	if _, err := decrypt(key, swapped); !errors.Is(err, ErrAuth) {
+		t.Fatalf("err = %v, want ErrAuth", err)
+	}
+}
+
+func TestTamperedContainer(t *testing.T) {
+	key := testKey(t)
+	plain := make([]byte, 2*testChunkSize)
+	rand.Read(plain)
+	container := encrypt(t, key, plain, AlgAES256GCM)
+	h, _ := ParseHeader(container)
+
+	tests := []struct {
+		name    string
+		offset  int64
+		wantErr error
+	}{
+		{"tag", h.SegmentOffset(1) - 1, ErrAuth},
+		{"ciphertext", h.SegmentOffset(1) + 10, ErrAuth},
+		{"nonce prefix", 12, ErrAuth},
+		{"magic", 0, ErrInvalidHeader},
+		{"reserved", 6, ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := bytes.Clone(container)
+			tampered[tt.offset] ^= 0x01
+			if _, err := decrypt(key, tampered); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := decrypt(testKey(t), container); !errors.Is(err, ErrAuth) {
+		t.Fatalf("wrong key: err = %v, want ErrAuth", err)
+	}
+}
+
+func TestPlaintextSizeRejectsImpossibleSizes(t *testing.T) {
+	h := Header{Version: Version1, Algorithm: AlgAES256GCM, ChunkSize: testChunkSize}
+
+	for _, size := range []int64{0, HeaderSize, HeaderSize + TagSize - 1, h.SegmentOffset(1) + TagSize - 1} {
+		if _, err := h.PlaintextSize(size); !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("PlaintextSize(%d) err = %v, want ErrInvalidSize", size, err)
+		}
+	}
+}
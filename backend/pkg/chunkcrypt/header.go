@@ -0,0 +1,159 @@
+// Package chunkcrypt implements the chunked AEAD container used for file
+// content. A container is a fixed-size header followed by a sequence of
+// segments, each holding up to ChunkSize bytes of plaintext sealed with the
+// AEAD named in the header. Segment i is sealed with the nonce
+//
+//	nonce_prefix (7 bytes) || uint32_be(i) || final_flag (1 byte)
+//
+// and the encoded header as additional data, so segments cannot be
+// reordered, truncated or moved between files without detection.
+package chunkcrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	Version1 uint8 = 1
+
+	AlgAES256GCM        uint8 = 1
+	AlgChaCha20Poly1305 uint8 = 2
+
+	HeaderSize      = 19
+	NoncePrefixSize = 7
+	TagSize         = 16
+	KeySize         = 32
+
+	MinChunkSize     = 4 << 10
+	MaxChunkSize     = 16 << 20
+	DefaultChunkSize = 64 << 10
+)
+
+var magic = [4]byte{'E', '2', 'E', 'F'}
+
+var (
+	ErrInvalidHeader = errors.New("chunkcrypt: invalid header")
+	ErrInvalidSize   = errors.New("chunkcrypt: ciphertext size does not match header")
+	ErrTruncated     = errors.New("chunkcrypt: ciphertext truncated")
+	ErrAuth          = errors.New("chunkcrypt: message authentication failed")
+)
+
+type Header struct {
+	Version     uint8
+	Algorithm   uint8
+	ChunkSize   uint32
+	NoncePrefix [NoncePrefixSize]byte
+}
+
+func (h Header) Validate() error {
+	if h.Version != Version1 {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, h.Version)
+	}
+	if h.Algorithm != AlgAES256GCM && h.Algorithm != AlgChaCha20Poly1305 {
+		return fmt.Errorf("%w: unsupported algorithm %d", ErrInvalidHeader, h.Algorithm)
+	}
+	if h.ChunkSize < MinChunkSize || h.ChunkSize > MaxChunkSize {
+		return fmt.Errorf("%w: chunk size %d out of range", ErrInvalidHeader, h.ChunkSize)
+	}
+	return nil
+}
+
+func (h Header) MarshalBinary() ([]byte, error) {
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, HeaderSize)
+	copy(b[0:4], magic[:])
+	b[4] = h.Version
+	b[5] = h.Algorithm
+	// b[6:8] is reserved and must be zero.
+	binary.BigEndian.PutUint32(b[8:12], h.ChunkSize)
+	copy(b[12:], h.NoncePrefix[:])
+	return b, nil
+}
+
+func ParseHeader(b []byte) (Header, error) {
+	if len(b) < HeaderSize {
+		return Header{}, fmt.Errorf("%w: short header", ErrInvalidHeader)
+	}
+	if !bytes.Equal(b[0:4], magic[:]) {
+		return Header{}, fmt.Errorf("%w: bad magic", ErrInvalidHeader)
+	}
+	if b[6] != 0 || b[7] != 0 {
+		return Header{}, fmt.Errorf("%w: reserved bytes set", ErrInvalidHeader)
+	}
+
+	h := Header{
+		Version:   b[4],
+		Algorithm: b[5],
+		ChunkSize: binary.BigEndian.Uint32(b[8:12]),
+	}
+	copy(h.NoncePrefix[:], b[12:HeaderSize])
+
+	if err := h.Validate(); err != nil {
+		return Header{}, err
+	}
+	return h, nil
+}
+
+func ReadHeader(r io.Reader) (Header, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Header{}, fmt.Errorf("%w: short header", ErrInvalidHeader)
+		}
+		return Header{}, err
+	}
+	return ParseHeader(b)
+}
+
+func (h Header) segmentSize() int64 {
+	return int64(h.ChunkSize) + TagSize
+}
+
+// SegmentCount returns how many segments hold plainSize bytes. Empty input
+// still produces a single, empty final segment.
+func (h Header) SegmentCount(plainSize int64) int64 {
+	if plainSize <= 0 {
+		return 1
+	}
+	return (plainSize + int64(h.ChunkSize) - 1) / int64(h.ChunkSize)
+}
+
+func (h Header) EncryptedSize(plainSize int64) int64 {
+	return HeaderSize + plainSize + h.SegmentCount(plainSize)*TagSize
+}
+
+// SegmentOffset returns the byte offset of segment index within the
+// container, which lets clients issue Range requests for random access.
+func (h Header) SegmentOffset(index int64) int64 {
+	return HeaderSize + index*h.segmentSize()
+}
+
+// PlaintextSize derives the plaintext length from a container's total size
+// and reports ErrInvalidSize when no valid container could have that size.
+// It needs only the header, so servers can check uploads without the key.
+func (h Header) PlaintextSize(encryptedSize int64) (int64, error) {
+	body := encryptedSize - HeaderSize
+	if body < TagSize {
+		return 0, ErrInvalidSize
+	}
+
+	full := body / h.segmentSize()
+	rem := body % h.segmentSize()
+	if rem == 0 {
+		return full * int64(h.ChunkSize), nil
+	}
+	if rem < TagSize {
+		return 0, ErrInvalidSize
+	}
+	if full+1 > 1<<32 {
+		return 0, ErrInvalidSize
+	}
+	return full*int64(h.ChunkSize) + rem - TagSize, nil
+}
@@ -0,0 +1,210 @@
+package chunkcrypt
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math"
+)
+
+var errClosed = errors.New("chunkcrypt: write to closed writer")
+
+// Writer encrypts everything written to it into a container. Close must be
+// called to emit the final segment; it does not close the underlying writer.
+type Writer struct {
+	w      io.Writer
+	h      Header
+	s      *sealer
+	buf    []byte
+	out    []byte
+	index  uint32
+	header bool
+	closed bool
+	err    error
+}
+
+func NewWriter(w io.Writer, key []byte, algorithm uint8, chunkSize int) (*Writer, error) {
+	if chunkSize < MinChunkSize || chunkSize > MaxChunkSize {
+		return nil, ErrInvalidHeader
+	}
+
+	h := Header{Version: Version1, Algorithm: algorithm, ChunkSize: uint32(chunkSize)}
+	if _, err := rand.Read(h.NoncePrefix[:]); err != nil {
+		return nil, err
+	}
+
+	s, err := newSealer(h, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:   w,
+		h:   h,
+		s:   s,
+		buf: make([]byte, 0, chunkSize),
+		out: make([]byte, 0, chunkSize+TagSize),
+	}, nil
+}
+
+func (w *Writer) Header() Header {
+	return w.h
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only flushed once more data arrives, so the
+		// last segment is always known to be final when Close runs.
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush(true)
+}
+
+func (w *Writer) flush(final bool) error {
+	if !w.header {
+		if _, err := w.w.Write(w.s.header); err != nil {
+			w.err = err
+			return err
+		}
+		w.header = true
+	}
+
+	if !final && w.index == math.MaxUint32 {
+		w.err = errors.New("chunkcrypt: too many segments")
+		return w.err
+	}
+
+	w.out = w.s.seal(w.out[:0], w.buf, w.index, final)
+	if _, err := w.w.Write(w.out); err != nil {
+		w.err = err
+		return err
+	}
+
+	w.buf = w.buf[:0]
+	w.index++
+	return nil
+}
+
+// Reader decrypts a container, returning ErrAuth if any segment has been
+// modified and ErrTruncated if the stream ends before the final segment.
+type Reader struct {
+	r     io.Reader
+	h     Header
+	s     *sealer
+	buf   []byte
+	out   []byte
+	carry int
+	plain []byte
+	index uint32
+	done  bool
+	err   error
+}
+
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	h, err := ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newSealer(h, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		r:   r,
+		h:   h,
+		s:   s,
+		buf: make([]byte, int(h.ChunkSize)+TagSize+1),
+		out: make([]byte, 0, h.ChunkSize),
+	}, nil
+}
+
+func (r *Reader) Header() Header {
+	return r.h
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *Reader) next() error {
+	segSize := int(r.h.ChunkSize) + TagSize
+
+	// Reading one byte past the segment tells us whether it is the last.
+	m, err := io.ReadFull(r.r, r.buf[r.carry:segSize+1])
+	n := r.carry + m
+	final := false
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		final = true
+	case err != nil:
+		return err
+	}
+
+	if final {
+		if n < TagSize {
+			return ErrTruncated
+		}
+		plain, err := r.s.open(r.out[:0], r.buf[:n], r.index, true)
+		if err != nil {
+			return err
+		}
+		r.plain = plain
+		r.done = true
+		return nil
+	}
+
+	if r.index == math.MaxUint32 {
+		return errors.New("chunkcrypt: too many segments")
+	}
+
+	plain, err := r.s.open(r.out[:0], r.buf[:segSize], r.index, false)
+	if err != nil {
+		return err
+	}
+	r.plain = plain
+	r.buf[0] = r.buf[segSize]
+	r.carry = 1
+	r.index++
+	return nil
+}
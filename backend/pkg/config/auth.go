@@ -0,0 +1,25 @@
+package config
+
+import (
+	"crypto/rand"
+	"log"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/auth"
+)
+
+const minJWTSecretLen = 32
+
+// NewTokenSigner signs access tokens with the configured secret. Without
+// one a random secret is generated, so every access token stops verifying
+// on the next restart; production configs never get this far without one.
+func NewTokenSigner(cfg AuthConfig) *auth.TokenSigner {
+	if cfg.JWTSecret == "" {
+		log.Println("WARNING: JWT_SECRET not set, using an ephemeral access token secret; access tokens stop verifying on restart")
+		secret := make([]byte, minJWTSecretLen)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Unable to generate access token secret: %v", err)
+		}
+		return auth.NewTokenSigner(secret)
+	}
+	return auth.NewTokenSigner([]byte(cfg.JWTSecret.Value()))
+}
@@ -0,0 +1,390 @@
+// Package config loads the server's settings. Values are layered: built-in
+// defaults, then an optional JSON file, then environment variables, then
+// command-line flags. Any secret can instead be read from a file named by
+// the same variable with a _FILE suffix, which is how container secrets are
+// usually mounted.
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+type Config struct {
+	Env     string        `json:"env"`
+	Port    int           `json:"port"`
+	DB      DBConfig      `json:"db"`
+	Auth    AuthConfig    `json:"auth"`
+	Storage StorageConfig `json:"storage"`
+	KeyLog  KeyLogConfig  `json:"key_log"`
+	Files   FilesConfig   `json:"files"`
+
+	// TrustedProxies lists the addresses or CIDRs whose X-Forwarded-For
+	// header is believed. Empty trusts none, so the client address is the
+	// peer's.
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+type DBConfig struct {
+	URL             Secret   `json:"url"`
+	MaxConns        int32    `json:"max_conns"`
+	MinConns        int32    `json:"min_conns"`
+	MaxConnLifetime Duration `json:"max_conn_lifetime"`
+	ConnectTimeout  Duration `json:"connect_timeout"`
+	// RequireCurrentSchema refuses to start while embedded migrations are
+	// pending instead of only logging a warning.
+	RequireCurrentSchema bool `json:"require_current_schema"`
+}
+
+type AuthConfig struct {
+	JWTSecret Secret `json:"jwt_secret"`
+}
+
+type StorageConfig struct {
+	// Backend is "minio" or "local", which keeps objects under Root.
+	Backend string      `json:"backend"`
+	Root    string      `json:"root"`
+	Minio   MinioConfig `json:"minio"`
+}
+
+type MinioConfig struct {
+	Endpoint  string `json:"endpoint"`
+	AccessKey string `json:"access_key"`
+	SecretKey Secret `json:"secret_key"`
+	UseSSL    bool   `json:"use_ssl"`
+}
+
+type KeyLogConfig struct {
+	// SigningKey is a base64 Ed25519 seed.
+	SigningKey Secret `json:"signing_key"`
+}
+
+type FilesConfig struct {
+	// MetadataMode is "compat" or "encrypted".
+	MetadataMode   string   `json:"metadata_mode"`
+	TrashRetention Duration `json:"trash_retention"`
+}
+
+func Default() Config {
+	return Config{
+		Env:  EnvDevelopment,
+		Port: 3000,
+		DB: DBConfig{
+			MaxConns:        10,
+			MinConns:        2,
+			MaxConnLifetime: Duration(time.Hour),
+			ConnectTimeout:  Duration(5 * time.Second),
+		},
+		Storage: StorageConfig{
+			Backend: "minio",
+			Minio:   MinioConfig{Endpoint: "localhost:9000"},
+		},
+		Files: FilesConfig{
+			MetadataMode:   "compat",
+			TrashRetention: Duration(30 * 24 * time.Hour),
+		},
+	}
+}
+
+func (c Config) Production() bool {
+	return c.Env == EnvProduction
+}
+
+// Load registers the configuration flags on fs, parses args and returns the
+// validated configuration. A .env file in the working directory, when
+// present, is read into the environment first.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env found.")
+	}
+	return load(fs, args, os.LookupEnv)
+}
+
+func load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	var overrides []func(*Config)
+	configFile := fs.String("config", "", "path to a JSON config file (env CONFIG_FILE)")
+	fs.Func("env", `"development" or "production" (env APP_ENV)`, func(v string) error {
+		overrides = append(overrides, func(c *Config) { c.Env = v })
+		return nil
+	})
+	fs.Func("port", "HTTP listen port (env PORT)", func(v string) error {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) { c.Port = port })
+		return nil
+	})
+	fs.Func("storage-backend", `"minio" or "local" (env STORAGE_BACKEND)`, func(v string) error {
+		overrides = append(overrides, func(c *Config) { c.Storage.Backend = v })
+		return nil
+	})
+	fs.Func("metadata-mode", `"compat" or "encrypted" (env METADATA_MODE)`, func(v string) error {
+		overrides = append(overrides, func(c *Config) { c.Files.MetadataMode = v })
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	env := envReader{lookup: lookupEnv}
+	env.string(&cfg.Env, "APP_ENV")
+	env.int(&cfg.Port, "PORT")
+	env.list(&cfg.TrustedProxies, "TRUSTED_PROXIES")
+	env.secret(&cfg.DB.URL, "DB_URL")
+	env.int32(&cfg.DB.MaxConns, "DB_MAX_CONNS")
+	env.int32(&cfg.DB.MinConns, "DB_MIN_CONNS")
+	env.text(&cfg.DB.MaxConnLifetime, "DB_MAX_CONN_LIFETIME")
+	env.text(&cfg.DB.ConnectTimeout, "DB_CONNECT_TIMEOUT")
+	env.bool(&cfg.DB.RequireCurrentSchema, "DB_REQUIRE_CURRENT_SCHEMA")
+	env.secret(&cfg.Auth.JWTSecret, "JWT_SECRET")
+	env.string(&cfg.Storage.Backend, "STORAGE_BACKEND")
+	env.string(&cfg.Storage.Root, "STORAGE_ROOT")
+	env.string(&cfg.Storage.Minio.Endpoint, "MINIO_ENDPOINT")
+	env.string(&cfg.Storage.Minio.AccessKey, "MINIO_ROOT_USER")
+	env.secret(&cfg.Storage.Minio.SecretKey, "MINIO_ROOT_PASSWORD")
+	env.bool(&cfg.Storage.Minio.UseSSL, "MINIO_USE_SSL")
+	env.secret(&cfg.KeyLog.SigningKey, "KEY_LOG_SIGNING_KEY")
+	env.string(&cfg.Files.MetadataMode, "METADATA_MODE")
+	env.text(&cfg.Files.TrashRetention, "TRASH_RETENTION")
+	if err := errors.Join(env.errs...); err != nil {
+		return Config{}, err
+	}
+
+	for _, override := range overrides {
+		override(&cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once. In production it also
+// refuses the defaults that are only tolerable on a developer machine.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Env == EnvDevelopment || c.Env == EnvProduction, "env must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env)
+	check(c.Port > 0 && c.Port <= 65535, "port %d is out of range", c.Port)
+	check(c.DB.MaxConns > 0, "db.max_conns must be positive")
+	check(c.DB.MinConns >= 0 && c.DB.MinConns <= c.DB.MaxConns, "db.min_conns must be between 0 and db.max_conns")
+	check(c.DB.MaxConnLifetime > 0, "db.max_conn_lifetime must be positive")
+	check(c.DB.ConnectTimeout > 0, "db.connect_timeout must be positive")
+	check(c.Files.MetadataMode == "compat" || c.Files.MetadataMode == "encrypted", "unknown files.metadata_mode %q", c.Files.MetadataMode)
+	check(c.Files.TrashRetention > 0, "files.trash_retention must be positive")
+
+	switch c.Storage.Backend {
+	case "minio":
+		check(c.Storage.Minio.Endpoint != "", "storage.minio.endpoint is required")
+	case "local":
+		check(c.Storage.Root != "", "storage.root is required for the local backend")
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage.backend %q", c.Storage.Backend))
+	}
+
+	if c.KeyLog.SigningKey != "" {
+		if _, err := decodeSigningKey(c.KeyLog.SigningKey); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.Production() {
+		check(c.DB.URL != "", "db.url is required in production")
+		check(len(c.Auth.JWTSecret) >= minJWTSecretLen, "auth.jwt_secret must be at least %d bytes in production", minJWTSecretLen)
+		check(c.KeyLog.SigningKey != "", "key_log.signing_key is required in production")
+		if c.Storage.Backend == "minio" {
+			m := c.Storage.Minio
+			check(m.AccessKey != "" && m.AccessKey != "minioadmin", "storage.minio.access_key must be set to a non-default value in production")
+			check(m.SecretKey != "" && m.SecretKey != "minioadmin", "storage.minio.secret_key must be set to a non-default value in production")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the configuration as JSON with every secret redacted.
+func (c Config) Print(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// Secret is a setting that must not end up in logs or config dumps. Its
+// String and JSON forms are redacted; Value returns the real thing.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Duration is a time.Duration written as "90s" or "720h" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// envReader applies environment variables over a config, collecting parse
+// errors instead of stopping at the first one. Empty variables are ignored.
+type envReader struct {
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+func (r *envReader) get(key string) (string, bool) {
+	v, ok := r.lookup(key)
+	return v, ok && v != ""
+}
+
+func (r *envReader) fail(key string, err error) {
+	r.errs = append(r.errs, fmt.Errorf("%s: %w", key, err))
+}
+
+func (r *envReader) string(dst *string, key string) {
+	if v, ok := r.get(key); ok {
+		*dst = v
+	}
+}
+
+func (r *envReader) int(dst *int, key string) {
+	if v, ok := r.get(key); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			r.fail(key, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) int32(dst *int32, key string) {
+	if v, ok := r.get(key); ok {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			r.fail(key, err)
+			return
+		}
+		*dst = int32(n)
+	}
+}
+
+func (r *envReader) bool(dst *bool, key string) {
+	if v, ok := r.get(key); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			r.fail(key, err)
+			return
+		}
+		*dst = b
+	}
+}
+
+// list reads a comma-separated list, dropping empty items.
+func (r *envReader) list(dst *[]string, key string) {
+	if v, ok := r.get(key); ok {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
+
+func (r *envReader) text(dst encoding.TextUnmarshaler, key string) {
+	if v, ok := r.get(key); ok {
+		if err := dst.UnmarshalText([]byte(v)); err != nil {
+			r.fail(key, err)
+		}
+	}
+}
+
+// secret reads key, or the file named by key_FILE. Setting both is an
+// error rather than a silent preference.
+func (r *envReader) secret(dst *Secret, key string) {
+	v, inline := r.get(key)
+	path, fromFile := r.get(key + "_FILE")
+	switch {
+	case inline && fromFile:
+		r.fail(key, fmt.Errorf("set either %s or %s_FILE, not both", key, key))
+	case fromFile:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			r.fail(key+"_FILE", err)
+			return
+		}
+		*dst = Secret(strings.TrimRight(string(data), "\r\n"))
+	case inline:
+		*dst = Secret(v)
+	}
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadWith(t *testing.T, env map[string]string, args ...string) (Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(new(bytes.Buffer))
+	return load(fs, args, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := loadWith(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadLayersFileEnvAndFlags(t *testing.T) {
+	path := writeFile(t, "config.json", `{
+		"port": 4000,
+		"storage": {"backend": "local", "root": "/srv/objects"},
+		"files": {"metadata_mode": "encrypted", "trash_retention": "48h"},
+		"db": {"max_conns": 20}
+	}`)
+
+	cfg, err := loadWith(t, map[string]string{
+		"CONFIG_FILE":     path,
+		"PORT":            "5000",
+		"DB_MIN_CONNS":    "4",
+		"STORAGE_BACKEND": "",
+		"TRUSTED_PROXIES": "10.0.0.0/8, ,192.168.1.1",
+	}, "-storage-backend", "minio")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Port != 5000 {
+		t.Errorf("port = %d, want the env value over the file", cfg.Port)
+	}
+	if cfg.Storage.Backend != "minio" || cfg.Storage.Root != "/srv/objects" {
+		t.Errorf("storage = %+v, want the flag backend and the file root", cfg.Storage)
+	}
+	if cfg.DB.MaxConns != 20 || cfg.DB.MinConns != 4 || cfg.DB.MaxConnLifetime != Duration(time.Hour) {
+		t.Errorf("db = %+v, want file, env and default values merged", cfg.DB)
+	}
+	if want := []string{"10.0.0.0/8", "192.168.1.1"}; !reflect.DeepEqual(cfg.TrustedProxies, want) {
+		t.Errorf("trusted proxies = %q, want %q", cfg.TrustedProxies, want)
+	}
+	if cfg.Files.MetadataMode != "encrypted" || cfg.Files.TrashRetention != Duration(48*time.Hour) {
+		t.Errorf("files = %+v", cfg.Files)
+	}
+}
+
+func TestLoadRejectsInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{"unknown file field", `{"prot": 80}`, nil, nil, `unknown field "prot"`},
+		{"bad env number", "", map[string]string{"DB_MAX_CONNS": "many"}, nil, "DB_MAX_CONNS"},
+		{"bad env duration", "", map[string]string{"TRASH_RETENTION": "a week"}, nil, "TRASH_RETENTION"},
+		{"bad flag", "", nil, []string{"-port", "eighty"}, "-port"},
+		{"unknown metadata mode", "", nil, []string{"-metadata-mode", "plain"}, `unknown files.metadata_mode "plain"`},
+		{"unknown backend", "", map[string]string{"STORAGE_BACKEND": "s3"}, nil, `unknown storage.backend "s3"`},
+		{"local without root", "", map[string]string{"STORAGE_BACKEND": "local"}, nil, "storage.root is required"},
+		{"min above max", `{"db": {"max_conns": 2, "min_conns": 3}}`, nil, nil, "db.min_conns"},
+		{"bad signing key", "", map[string]string{"KEY_LOG_SIGNING_KEY": "c2hvcnQ="}, nil, "32-byte seed"},
+		{"unknown env", "", map[string]string{"APP_ENV": "staging"}, nil, `got "staging"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			if tt.file != "" {
+				env["CONFIG_FILE"] = writeFile(t, "config.json", tt.file)
+			}
+			_, err := loadWith(t, env, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFromFile(t *testing.T) {
+	path := writeFile(t, "jwt_secret", "from-a-mounted-secret\n")
+
+	cfg, err := loadWith(t, map[string]string{"JWT_SECRET_FILE": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Auth.JWTSecret.Value(); got != "from-a-mounted-secret" {
+		t.Fatalf("secret = %q", got)
+	}
+
+	_, err = loadWith(t, map[string]string{"JWT_SECRET_FILE": path, "JWT_SECRET": "inline"})
+	if err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Fatalf("err = %v, want a conflict between JWT_SECRET and JWT_SECRET_FILE", err)
+	}
+}
+
+func productionEnv() map[string]string {
+	return map[string]string{
+		"APP_ENV":             EnvProduction,
+		"DB_URL":              "postgres://app:hunter2@db/files",
+		"JWT_SECRET":          strings.Repeat("s", minJWTSecretLen),
+		"KEY_LOG_SIGNING_KEY": base64.StdEncoding.EncodeToString(make([]byte, 32)),
+		"MINIO_ROOT_USER":     "files",
+		"MINIO_ROOT_PASSWORD": "correct horse battery staple",
+	}
+}
+
+func TestProductionRefusesInsecureDefaults(t *testing.T) {
+	if _, err := loadWith(t, productionEnv()); err != nil {
+		t.Fatalf("a complete production config was refused: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr string
+	}{
+		{"no database url", "DB_URL", "", "db.url"},
+		{"no jwt secret", "JWT_SECRET", "", "auth.jwt_secret"},
+		{"short jwt secret", "JWT_SECRET", "super_secret", "auth.jwt_secret"},
+		{"ephemeral signing key", "KEY_LOG_SIGNING_KEY", "", "key_log.signing_key"},
+		{"default minio user", "MINIO_ROOT_USER", "minioadmin", "storage.minio.access_key"},
+		{"default minio password", "MINIO_ROOT_PASSWORD", "minioadmin", "storage.minio.secret_key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := productionEnv()
+			env[tt.key] = tt.value
+			_, err := loadWith(t, env)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+
+			env["APP_ENV"] = EnvDevelopment
+			if _, err := loadWith(t, env); err != nil {
+				t.Fatalf("development config refused: %v", err)
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, err := loadWith(t, productionEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, v := range productionEnv() {
+		if key == "APP_ENV" || key == "MINIO_ROOT_USER" {
+			continue
+		}
+		if strings.Contains(out.String(), v) {
+			t.Errorf("%s leaked into the dump:\n%s", key, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), `"jwt_secret": "[REDACTED]"`) {
+		t.Errorf("dump does not mark the secret as redacted:\n%s", out.String())
+	}
+}
@@ -3,25 +3,22 @@ package config
 import (
 	"context"
 	"log"
-	"os"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPostgresPool() *pgxpool.Pool {
-	dsn := os.Getenv("DB_URL")
-
-	config, err := pgxpool.ParseConfig(dsn)
+func NewPostgresPool(cfg DBConfig) *pgxpool.Pool {
+	config, err := pgxpool.ParseConfig(cfg.URL.Value())
 	if err != nil {
 		log.Fatalf("Unable to parse DB_URL: %v", err)
 	}
 
-	config.MaxConns = 10
-	config.MinConns = 2
-	config.MaxConnLifetime = time.Hour
+	config.MaxConns = cfg.MaxConns
+	config.MinConns = cfg.MinConns
+	config.MaxConnLifetime = time.Duration(cfg.MaxConnLifetime)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ConnectTimeout))
 	defer cancel()
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"log"
+)
+
+// LoadKeyLogSigningKey decodes the configured Ed25519 seed. Without one a
+// throwaway key is generated, which invalidates every tree head signed
+// before the next restart.
+func LoadKeyLogSigningKey(cfg KeyLogConfig) ed25519.PrivateKey {
+	if cfg.SigningKey == "" {
+		pub, key, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			log.Fatalf("Unable to generate key log signing key: %v", err)
+		}
+		log.Printf("WARNING: KEY_LOG_SIGNING_KEY not set, signing the key log with the ephemeral public key %s; "+
+			"clients that pinned it will reject every tree head after a restart", base64.StdEncoding.EncodeToString(pub))
+		return key
+	}
+
+	key, err := decodeSigningKey(cfg.SigningKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+func decodeSigningKey(s Secret) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(s.Value())
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("key_log.signing_key must be a base64 %d-byte seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
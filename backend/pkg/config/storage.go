@@ -0,0 +1,34 @@
+package config
+
+import (
+	"log"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/internal/storage"
+)
+
+// NewStorage opens the object store named by cfg.Backend: "minio" or
+// "local", which keeps objects under cfg.Root.
+func NewStorage(cfg StorageConfig) storage.Storage {
+	switch cfg.Backend {
+	case "minio":
+		s, err := storage.NewMinioStorage(
+			cfg.Minio.Endpoint,
+			cfg.Minio.AccessKey,
+			cfg.Minio.SecretKey.Value(),
+			cfg.Minio.UseSSL,
+		)
+		if err != nil {
+			log.Fatalf("Unable to init minio: %v", err)
+		}
+		return s
+	case "local":
+		s, err := storage.NewLocalStorage(cfg.Root)
+		if err != nil {
+			log.Fatalf("Unable to init local storage: %v", err)
+		}
+		return s
+	default:
+		log.Fatalf("Unknown storage backend %q", cfg.Backend)
+		return nil
+	}
+}
@@ -0,0 +1,119 @@
+// Package keylog defines the entries and signed tree heads of the public
+// key transparency log, and lets clients verify that a key served by the
+// directory is the one the log committed to.
+package keylog
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/merkle"
+	"github.com/google/uuid"
+)
+
+const entryVersion = 1
+
+var (
+	ErrInvalidEntry     = errors.New("keylog: invalid entry encoding")
+	ErrInvalidSignature = errors.New("keylog: invalid tree head signature")
+	ErrKeyMismatch      = errors.New("keylog: logged key does not match")
+)
+
+// Entry binds a user to a public key at a point in time. Timestamp is in
+// milliseconds since the Unix epoch.
+type Entry struct {
+	UserID    uuid.UUID `json:"user_id"`
+	PublicKey []byte    `json:"public_key"`
+	Timestamp int64     `json:"timestamp"`
+}
+
+// Encode returns the leaf data hashed into the tree:
+//
+//	version (1) || user_id (16) || uint64_be(timestamp) || uint32_be(len) || public_key
+func (e Entry) Encode() []byte {
+	b := make([]byte, 0, 1+16+8+4+len(e.PublicKey))
+	b = append(b, entryVersion)
+	b = append(b, e.UserID[:]...)
+	b = binary.BigEndian.AppendUint64(b, uint64(e.Timestamp))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(e.PublicKey)))
+	return append(b, e.PublicKey...)
+}
+
+func DecodeEntry(b []byte) (Entry, error) {
+	if len(b) < 29 || b[0] != entryVersion {
+		return Entry{}, ErrInvalidEntry
+	}
+
+	var e Entry
+	copy(e.UserID[:], b[1:17])
+	e.Timestamp = int64(binary.BigEndian.Uint64(b[17:25]))
+	n := binary.BigEndian.Uint32(b[25:29])
+	if uint32(len(b)-29) != n {
+		return Entry{}, ErrInvalidEntry
+	}
+	e.PublicKey = append([]byte(nil), b[29:]...)
+	return e, nil
+}
+
+func (e Entry) LeafHash() []byte {
+	return merkle.LeafHash(e.Encode())
+}
+
+type TreeHead struct {
+	TreeSize  uint64 `json:"tree_size"`
+	Timestamp int64  `json:"timestamp"`
+	RootHash  []byte `json:"root_hash"`
+	Signature []byte `json:"signature"`
+}
+
+func (h TreeHead) signedData() []byte {
+	b := []byte("e2ee-keylog-sth-v1")
+	b = binary.BigEndian.AppendUint64(b, h.TreeSize)
+	b = binary.BigEndian.AppendUint64(b, uint64(h.Timestamp))
+	return append(b, h.RootHash...)
+}
+
+func SignTreeHead(h TreeHead, key ed25519.PrivateKey) TreeHead {
+	h.Signature = ed25519.Sign(key, h.signedData())
+	return h
+}
+
+func VerifyTreeHead(h TreeHead, key ed25519.PublicKey) error {
+	if len(h.RootHash) != merkle.HashSize || !ed25519.Verify(key, h.signedData(), h.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyKey checks that entry sits at index in the tree described by head,
+// that head was signed by the log, and that entry binds userID to
+// publicKey. Clients should call it before wrapping a file key for a
+// recipient, and should also check head is consistent with the last head
+// they saw using VerifyConsistency.
+func VerifyKey(logKey ed25519.PublicKey, head TreeHead, entry Entry, index uint64, proof [][]byte, userID uuid.UUID, publicKey []byte) error {
+	if entry.UserID != userID || string(entry.PublicKey) != string(publicKey) {
+		return ErrKeyMismatch
+	}
+	if err := VerifyTreeHead(head, logKey); err != nil {
+		return err
+	}
+	if err := merkle.VerifyInclusion(entry.LeafHash(), index, head.TreeSize, proof, head.RootHash); err != nil {
+		return fmt.Errorf("keylog: inclusion: %w", err)
+	}
+	return nil
+}
+
+func VerifyConsistency(logKey ed25519.PublicKey, older, newer TreeHead, proof [][]byte) error {
+	if err := VerifyTreeHead(older, logKey); err != nil {
+		return err
+	}
+	if err := VerifyTreeHead(newer, logKey); err != nil {
+		return err
+	}
+	if err := merkle.VerifyConsistency(older.TreeSize, newer.TreeSize, older.RootHash, newer.RootHash, proof); err != nil {
+		return fmt.Errorf("keylog: consistency: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package keylog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/pkg/merkle"
+	"github.com/google/uuid"
+)
+
+func TestEntryEncoding(t *testing.T) {
+	e := Entry{UserID: uuid.New(), PublicKey: []byte("public key"), Timestamp: 1700000000123}
+
+	got, err := DecodeEntry(e.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != e.UserID || !bytes.Equal(got.PublicKey, e.PublicKey) || got.Timestamp != e.Timestamp {
+		t.Fatalf("decoded %+v, want %+v", got, e)
+	}
+
+	encoded := e.Encode()
+	bad := map[string][]byte{
+		"empty":         nil,
+		"short":         encoded[:28],
+		"version":       append([]byte{2}, encoded[1:]...),
+		"length short":  encoded[:len(encoded)-1],
+		"trailing data": append(bytes.Clone(encoded), 0),
+	}
+	for name, b := range bad {
+		if _, err := DecodeEntry(b); !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("%s: err = %v, want ErrInvalidEntry", name, err)
+		}
+	}
+}
+
+// testLog builds a signed log of entries for n fresh users.
+func testLog(t *testing.T, n int) (ed25519.PublicKey, []Entry, [][]byte, TreeHead, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []Entry
+	var leaves [][]byte
+	for i := 0; i < n; i++ {
+		e := Entry{UserID: uuid.New(), PublicKey: []byte{byte(i)}, Timestamp: int64(i)}
+		entries = append(entries, e)
+		leaves = append(leaves, e.LeafHash())
+	}
+	head := SignTreeHead(TreeHead{TreeSize: uint64(n), Timestamp: 42, RootHash: merkle.Root(leaves)}, priv)
+	return pub, entries, leaves, head, priv
+}
+
+func TestVerifyKey(t *testing.T) {
+	pub, entries, leaves, head, _ := testLog(t, 5)
+	proof, err := merkle.InclusionProof(leaves, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := entries[3]
+
+	if err := VerifyKey(pub, head, e, 3, proof, e.UserID, e.PublicKey); err != nil {
+		t.Fatalf("VerifyKey: %v", err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	forged := head
+	forged.RootHash = bytes.Clone(head.RootHash)
+	forged.RootHash[0] ^= 1
+
+	tests := []struct {
+		name      string
+		logKey    ed25519.PublicKey
+		head      TreeHead
+		index     uint64
+		userID    uuid.UUID
+		publicKey []byte
+		wantErr   error
+	}{
+		{"other user", pub, head, 3, entries[2].UserID, e.PublicKey, ErrKeyMismatch},
+		{"other key", pub, head, 3, e.UserID, []byte("substituted"), ErrKeyMismatch},
+		{"other log", otherPub, head, 3, e.UserID, e.PublicKey, ErrInvalidSignature},
+		{"altered head", pub, forged, 3, e.UserID, e.PublicKey, ErrInvalidSignature},
+		{"wrong index", pub, head, 2, e.UserID, e.PublicKey, merkle.ErrInvalidProof},
+	}
+	for _, tt := range tests {
+		if err := VerifyKey(tt.logKey, tt.head, e, tt.index, proof, tt.userID, tt.publicKey); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	pub, _, leaves, newer, priv := testLog(t, 7)
+	older := SignTreeHead(TreeHead{TreeSize: 4, Timestamp: 41, RootHash: merkle.Root(leaves[:4])}, priv)
+	proof, err := merkle.ConsistencyProof(leaves, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyConsistency(pub, older, newer, proof); err != nil {
+		t.Fatalf("VerifyConsistency: %v", err)
+	}
+
+	// A log that rewrote history signs a head the old one is no prefix of.
+	rewritten := append([][]byte{merkle.LeafHash([]byte("rewritten"))}, leaves[1:]...)
+	forked := SignTreeHead(TreeHead{TreeSize: 7, Timestamp: 43, RootHash: merkle.Root(rewritten)}, priv)
+	if err := VerifyConsistency(pub, older, forked, proof); !errors.Is(err, merkle.ErrInvalidProof) {
+		t.Fatalf("forked log: err = %v, want ErrInvalidProof", err)
+	}
+}
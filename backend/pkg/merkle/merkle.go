@@ -0,0 +1,164 @@
+// Package merkle implements the Merkle tree hashing, audit paths and
+// consistency proofs of RFC 6962, with the verification algorithms from
+// RFC 9162 section 2.1. All functions operate on leaf hashes, so callers
+// hash their entries once with LeafHash.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+const HashSize = sha256.Size
+
+var ErrInvalidProof = errors.New("merkle: invalid proof")
+
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func NodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func EmptyRoot() []byte {
+	sum := sha256.Sum256(nil)
+	return sum[:]
+}
+
+func Root(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return EmptyRoot()
+	case 1:
+		return leaves[0]
+	}
+	k := splitSize(uint64(len(leaves)))
+	return NodeHash(Root(leaves[:k]), Root(leaves[k:]))
+}
+
+// InclusionProof returns the audit path for leaves[index] in the tree made
+// of all of leaves.
+func InclusionProof(leaves [][]byte, index int) ([][]byte, error) {
+	ranges, err := InclusionRanges(uint64(index), uint64(len(leaves)))
+	if err != nil {
+		return nil, err
+	}
+	return rangeRoots(leaves, ranges), nil
+}
+
+// ConsistencyProof proves that the tree of the first size leaves is a
+// prefix of the tree made of all of leaves.
+func ConsistencyProof(leaves [][]byte, size int) ([][]byte, error) {
+	ranges, err := ConsistencyRanges(uint64(size), uint64(len(leaves)))
+	if err != nil {
+		return nil, err
+	}
+	return rangeRoots(leaves, ranges), nil
+}
+
+func rangeRoots(leaves [][]byte, ranges []Range) [][]byte {
+	if len(ranges) == 0 {
+		return nil
+	}
+	hashes := make([][]byte, len(ranges))
+	for i, r := range ranges {
+		hashes[i] = Root(leaves[r.Start:r.End])
+	}
+	return hashes
+}
+
+func VerifyInclusion(leafHash []byte, index, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return ErrInvalidProof
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = NodeHash(p, r)
+			if fn&1 == 0 {
+				for fn&1 == 0 && fn != 0 {
+					fn >>= 1
+					sn >>= 1
+				}
+			}
+		} else {
+			r = NodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+func VerifyConsistency(size1, size2 uint64, root1, root2 []byte, proof [][]byte) error {
+	switch {
+	case size1 > size2:
+		return ErrInvalidProof
+	case size1 == size2:
+		if len(proof) != 0 || !bytes.Equal(root1, root2) {
+			return ErrInvalidProof
+		}
+		return nil
+	case size1 == 0:
+		if len(proof) != 0 {
+			return ErrInvalidProof
+		}
+		return nil
+	case len(proof) == 0:
+		return ErrInvalidProof
+	}
+
+	if size1&(size1-1) == 0 {
+		proof = append([][]byte{root1}, proof...)
+	}
+
+	fn, sn := size1-1, size2-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = NodeHash(c, fr)
+			sr = NodeHash(c, sr)
+			if fn&1 == 0 {
+				for fn&1 == 0 && fn != 0 {
+					fn >>= 1
+					sn >>= 1
+				}
+			}
+		} else {
+			sr = NodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, root1) || !bytes.Equal(sr, root2) {
+		return ErrInvalidProof
+	}
+	return nil
+}
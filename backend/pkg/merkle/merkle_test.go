@@ -0,0 +1,282 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// The vectors below are the reference tree used by the RFC 6962 test suite
+// of certificate-transparency and RFC 9162 implementations: eight leaves,
+// the roots of each prefix, and a selection of audit paths and consistency
+// proofs. Leaf and tree sizes in the proof tables are 1-based, as there.
+
+var vectorLeaves = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+var vectorRoots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+var vectorInclusion = []struct {
+	leaf, size int
+	proof      []string
+}{
+	{1, 1, nil},
+	{1, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+	}},
+	{6, 8, []string{
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	}},
+	{3, 3, []string{
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	}},
+	{2, 5, []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+	}},
+}
+
+var vectorConsistency = []struct {
+	size1, size2 int
+	proof        []string
+}{
+	{1, 1, nil},
+	{1, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+	}},
+	{6, 8, []string{
+		"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	}},
+	{2, 5, []string{
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+	}},
+}
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func unhexAll(t *testing.T, ss []string) [][]byte {
+	t.Helper()
+	var bs [][]byte
+	for _, s := range ss {
+		bs = append(bs, unhex(t, s))
+	}
+	return bs
+}
+
+func vectorLeafHashes(t *testing.T) [][]byte {
+	t.Helper()
+	var hashes [][]byte
+	for _, l := range vectorLeaves {
+		hashes = append(hashes, LeafHash(unhex(t, l)))
+	}
+	return hashes
+}
+
+func equalHashes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEmptyRoot(t *testing.T) {
+	want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := hex.EncodeToString(Root(nil)); got != want {
+		t.Fatalf("Root(nil) = %s, want %s", got, want)
+	}
+}
+
+func TestRootVectors(t *testing.T) {
+	leaves := vectorLeafHashes(t)
+	for size := 1; size <= len(leaves); size++ {
+		if got := hex.EncodeToString(Root(leaves[:size])); got != vectorRoots[size-1] {
+			t.Errorf("root of %d leaves = %s, want %s", size, got, vectorRoots[size-1])
+		}
+	}
+}
+
+func TestInclusionVectors(t *testing.T) {
+	leaves := vectorLeafHashes(t)
+	for _, v := range vectorInclusion {
+		index := v.leaf - 1
+		want := unhexAll(t, v.proof)
+
+		got, err := InclusionProof(leaves[:v.size], index)
+		if err != nil {
+			t.Fatalf("leaf %d of %d: %v", v.leaf, v.size, err)
+		}
+		if !equalHashes(got, want) {
+			t.Errorf("leaf %d of %d: proof = %x, want %x", v.leaf, v.size, got, want)
+		}
+
+		root := unhex(t, vectorRoots[v.size-1])
+		if err := VerifyInclusion(leaves[index], uint64(index), uint64(v.size), want, root); err != nil {
+			t.Errorf("leaf %d of %d: verify: %v", v.leaf, v.size, err)
+		}
+	}
+}
+
+func TestConsistencyVectors(t *testing.T) {
+	leaves := vectorLeafHashes(t)
+	for _, v := range vectorConsistency {
+		want := unhexAll(t, v.proof)
+
+		got, err := ConsistencyProof(leaves[:v.size2], v.size1)
+		if err != nil {
+			t.Fatalf("%d to %d: %v", v.size1, v.size2, err)
+		}
+		if !equalHashes(got, want) {
+			t.Errorf("%d to %d: proof = %x, want %x", v.size1, v.size2, got, want)
+		}
+
+		root1, root2 := unhex(t, vectorRoots[v.size1-1]), unhex(t, vectorRoots[v.size2-1])
+		if err := VerifyConsistency(uint64(v.size1), uint64(v.size2), root1, root2, want); err != nil {
+			t.Errorf("%d to %d: verify: %v", v.size1, v.size2, err)
+		}
+	}
+}
+
+func TestVerifyRejectsBadProofs(t *testing.T) {
+	leaves := vectorLeafHashes(t)
+	root := unhex(t, vectorRoots[7])
+	proof, _ := InclusionProof(leaves, 5)
+
+	tampered := unhexAll(t, vectorInclusion[2].proof)
+	tampered[1][0] ^= 1
+
+	tests := []struct {
+		name  string
+		leaf  []byte
+		index uint64
+		size  uint64
+		proof [][]byte
+	}{
+		{"wrong leaf", leaves[4], 5, 8, proof},
+		{"wrong index", leaves[5], 4, 8, proof},
+		{"wrong size", leaves[5], 5, 6, proof},
+		{"index past size", leaves[5], 8, 8, proof},
+		{"tampered node", leaves[5], 5, 8, tampered},
+		{"truncated", leaves[5], 5, 8, proof[:2]},
+		{"extended", leaves[5], 5, 8, append(append([][]byte{}, proof...), root)},
+	}
+	for _, tt := range tests {
+		if err := VerifyInclusion(tt.leaf, tt.index, tt.size, tt.proof, root); !errors.Is(err, ErrInvalidProof) {
+			t.Errorf("%s: err = %v, want ErrInvalidProof", tt.name, err)
+		}
+	}
+
+	consistency, _ := ConsistencyProof(leaves, 6)
+	root6 := unhex(t, vectorRoots[5])
+	if err := VerifyConsistency(6, 8, root6, root, consistency[1:]); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("truncated consistency proof: err = %v, want ErrInvalidProof", err)
+	}
+	if err := VerifyConsistency(5, 8, root6, root, consistency); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("consistency proof for the wrong size: err = %v, want ErrInvalidProof", err)
+	}
+}
+
+// TestStoredNodes checks that roots and proofs assembled from the nodes
+// CompletedBy produces match the ones computed from every leaf.
+func TestStoredNodes(t *testing.T) {
+	const n = 70
+
+	var leaves [][]byte
+	nodes := map[NodeID][]byte{}
+	for i := uint64(0); i < n; i++ {
+		leaf := LeafHash([]byte{byte(i)})
+		leaves = append(leaves, leaf)
+		nodes[NodeID{0, i}] = leaf
+
+		h := leaf
+		for _, id := range CompletedBy(i) {
+			left, right := id.Children()
+			if !bytes.Equal(nodes[right], h) {
+				t.Fatalf("node %+v: right child %+v is not the node completed before it", id, right)
+			}
+			h = NodeHash(nodes[left], h)
+			nodes[id] = h
+		}
+	}
+
+	resolve := func(ranges []Range) [][]byte {
+		var hashes [][]byte
+		for _, r := range ranges {
+			var parts [][]byte
+			for _, id := range r.Nodes() {
+				h, ok := nodes[id]
+				if !ok {
+					t.Fatalf("range %+v needs node %+v, which was never completed", r, id)
+				}
+				parts = append(parts, h)
+			}
+			hashes = append(hashes, Fold(parts))
+		}
+		return hashes
+	}
+
+	for size := uint64(1); size <= n; size++ {
+		if got := resolve([]Range{{0, size}})[0]; !bytes.Equal(got, Root(leaves[:size])) {
+			t.Fatalf("root of %d leaves differs", size)
+		}
+		for index := uint64(0); index < size; index++ {
+			ranges, err := InclusionRanges(index, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := InclusionProof(leaves[:size], int(index))
+			if !equalHashes(resolve(ranges), want) {
+				t.Fatalf("inclusion proof for %d of %d differs", index, size)
+			}
+		}
+		for first := uint64(1); first <= size; first++ {
+			ranges, err := ConsistencyRanges(first, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := ConsistencyProof(leaves[:size], int(first))
+			if !equalHashes(resolve(ranges), want) {
+				t.Fatalf("consistency proof from %d to %d differs", first, size)
+			}
+		}
+	}
+}
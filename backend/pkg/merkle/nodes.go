@@ -0,0 +1,115 @@
+package merkle
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// A log that stores the root of every complete subtree once, as leaves are
+// appended, can compute any root or proof from O(log n) stored hashes
+// instead of rehashing every leaf. NodeID names those subtrees, Range the
+// spans the proofs are made of, and Fold turns a span's subtrees into its
+// root.
+
+// NodeID names the root of the perfect subtree over leaves
+// [Index<<Level, (Index+1)<<Level). Level 0 nodes are the leaf hashes.
+type NodeID struct {
+	Level uint8
+	Index uint64
+}
+
+func (n NodeID) Children() (left, right NodeID) {
+	return NodeID{n.Level - 1, n.Index << 1}, NodeID{n.Level - 1, n.Index<<1 | 1}
+}
+
+// CompletedBy returns the interior nodes that appending leaf index
+// completes, lowest first. Each one's right child is the node before it in
+// the list, or the leaf itself for the first.
+func CompletedBy(index uint64) []NodeID {
+	var ids []NodeID
+	for level := uint8(1); level < 64 && (index+1)%(1<<level) == 0; level++ {
+		ids = append(ids, NodeID{level, (index+1)>>level - 1})
+	}
+	return ids
+}
+
+// Range is the span of leaves [Start, End) under one node of the tree.
+type Range struct {
+	Start, End uint64
+}
+
+// Nodes returns the perfect subtrees that make up r, left to right. It
+// relies on the alignment every span of an RFC 6962 tree has: Start is a
+// multiple of the largest power of two not above End-Start.
+func (r Range) Nodes() []NodeID {
+	var ids []NodeID
+	for start := r.Start; start < r.End; {
+		level := uint8(bits.Len64(r.End-start) - 1)
+		ids = append(ids, NodeID{level, start >> level})
+		start += 1 << level
+	}
+	return ids
+}
+
+// Fold returns the root of a span from the hashes of its Nodes.
+func Fold(hashes [][]byte) []byte {
+	if len(hashes) == 0 {
+		return EmptyRoot()
+	}
+	root := hashes[len(hashes)-1]
+	for i := len(hashes) - 2; i >= 0; i-- {
+		root = NodeHash(hashes[i], root)
+	}
+	return root
+}
+
+// InclusionRanges returns the spans whose roots form the audit path for
+// leaf index in a tree of size leaves.
+func InclusionRanges(index, size uint64) ([]Range, error) {
+	if index >= size {
+		return nil, fmt.Errorf("merkle: index %d out of range for tree of size %d", index, size)
+	}
+	return pathRanges(0, size, index), nil
+}
+
+func pathRanges(start, end, m uint64) []Range {
+	if end-start <= 1 {
+		return nil
+	}
+	k := splitSize(end - start)
+	if m < k {
+		return append(pathRanges(start, start+k, m), Range{start + k, end})
+	}
+	return append(pathRanges(start+k, end, m-k), Range{start, start + k})
+}
+
+// ConsistencyRanges returns the spans whose roots prove the tree of size1
+// leaves is a prefix of the tree of size2 leaves.
+func ConsistencyRanges(size1, size2 uint64) ([]Range, error) {
+	if size1 == 0 || size1 > size2 {
+		return nil, fmt.Errorf("merkle: size %d out of range for tree of size %d", size1, size2)
+	}
+	if size1 == size2 {
+		return nil, nil
+	}
+	return subproofRanges(0, size2, size1, true), nil
+}
+
+func subproofRanges(start, end, m uint64, complete bool) []Range {
+	if m == end-start {
+		if complete {
+			return nil
+		}
+		return []Range{{start, end}}
+	}
+	k := splitSize(end - start)
+	if m <= k {
+		return append(subproofRanges(start, start+k, m, complete), Range{start + k, end})
+	}
+	return append(subproofRanges(start+k, end, m-k, false), Range{start, start + k})
+}
+
+// splitSize returns the largest power of two strictly less than n.
+func splitSize(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
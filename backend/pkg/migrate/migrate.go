@@ -0,0 +1,382 @@
+// Package migrate applies versioned SQL migrations and records each applied
+// version, with a checksum of its up script, in the schema_versions table.
+// Migrations are files named <version>_<name>.up.sql and .down.sql.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey identifies the session advisory lock held while migrating, so
+// replicas starting together apply each migration exactly once.
+const lockKey int64 = 0x6532656d6967
+
+var (
+	ErrSchemaOutOfDate  = errors.New("migrate: database schema is out of date")
+	ErrChecksumMismatch = errors.New("migrate: applied migration differs from the embedded one")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// Parse reads the migrations at the root of fsys, ordered by version. Every
+// version needs both an up and a down script.
+func Parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrate: unexpected file %s", e.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: bad version in %s", e.Name())
+		}
+		script, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: %s needs both an up and a down script", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+func New(db *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest is the newest embedded version, or 0 when there are none.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the newest steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("migrate: cannot roll back %d steps", steps)
+	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn, done map[int64]applied) error {
+		var versions []int64
+		for _, mg := range m.migrations {
+			if _, ok := done[mg.Version]; ok {
+				versions = append(versions, mg.Version)
+			}
+		}
+		if steps > len(versions) {
+			return fmt.Errorf("migrate: only %d migrations are applied", len(versions))
+		}
+		var target int64
+		if i := len(versions) - steps - 1; i >= 0 {
+			target = versions[i]
+		}
+		return m.migrate(ctx, conn, done, target)
+	})
+}
+
+// To applies or rolls back migrations until version is the newest applied
+// one. Version 0 rolls back everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("migrate: unknown version %d", version)
+	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn, done map[int64]applied) error {
+		return m.migrate(ctx, conn, done, version)
+	})
+}
+
+// Status lists every embedded migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := readApplied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := Status{Migration: mg}
+		if a, ok := done[mg.Version]; ok {
+			s.AppliedAt = &a.appliedAt
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// Check reports ErrSchemaOutOfDate when an embedded migration has not been
+// applied, and ErrChecksumMismatch when one was applied from a different
+// script. Versions applied by a newer build are not an error.
+func (m *Migrator) Check(ctx context.Context) error {
+	done, err := readApplied(ctx, m.db)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, mg := range m.migrations {
+		a, ok := done[mg.Version]
+		if !ok {
+			pending++
+			continue
+		}
+		if a.checksum != mg.Checksum() {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, mg)
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d of %d migrations pending", ErrSchemaOutOfDate, pending, len(m.migrations))
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *pgxpool.Conn, done map[int64]applied, target int64) error {
+	for v, a := range done {
+		mg := m.find(v)
+		if mg == nil {
+			return fmt.Errorf("migrate: version %d is applied but not known to this build", v)
+		}
+		if a.checksum != mg.Checksum() {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, mg)
+		}
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if _, ok := done[mg.Version]; !ok || mg.Version <= target {
+			continue
+		}
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mg.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_versions WHERE version = $1`, mg.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrate: roll back %s: %w", mg, err)
+		}
+		log.Printf("rolled back migration %s", mg)
+	}
+
+	for _, mg := range m.migrations {
+		if _, ok := done[mg.Version]; ok || mg.Version > target {
+			continue
+		}
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mg.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx,
+				`INSERT INTO schema_versions (version, name, checksum) VALUES ($1, $2, $3)`,
+				mg.Version, mg.Name, mg.Checksum())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrate: apply %s: %w", mg, err)
+		}
+		log.Printf("applied migration %s", mg)
+	}
+	return nil
+}
+
+// withLock runs fn on one connection holding the advisory lock, with the
+// history table in place and the applied versions read under the lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(*pgxpool.Conn, map[int64]applied) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("migrate: take lock: %w", err)
+	}
+	defer func() {
+		// The lock belongs to the session, so a connection that cannot give
+		// it back must not return to the pool.
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_versions (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+		return fmt.Errorf("migrate: create schema_versions: %w", err)
+	}
+	if err := m.adoptLegacy(ctx, conn); err != nil {
+		return err
+	}
+
+	done, err := readApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, done)
+}
+
+// adoptLegacy seeds an empty history from the schema_migrations table the
+// external migrate tool kept, so databases it set up are not migrated twice.
+func (m *Migrator) adoptLegacy(ctx context.Context, conn *pgxpool.Conn) error {
+	var legacy, seeded bool
+	err := conn.QueryRow(ctx, `
+		SELECT to_regclass('schema_migrations') IS NOT NULL,
+		       EXISTS (SELECT 1 FROM schema_versions)`).Scan(&legacy, &seeded)
+	if err != nil || !legacy || seeded {
+		return err
+	}
+
+	var version int64
+	var dirty bool
+	err = conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: read schema_migrations: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migrate: schema_migrations marks version %d as dirty; repair it by hand first", version)
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		for _, mg := range m.migrations {
+			if mg.Version > version {
+				break
+			}
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO schema_versions (version, name, checksum) VALUES ($1, $2, $3)`,
+				mg.Version, mg.Name, mg.Checksum()); err != nil {
+				return err
+			}
+		}
+		log.Printf("adopted schema version %d from schema_migrations", version)
+		return nil
+	})
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// readApplied returns nothing applied when the history table does not exist
+// yet, so status and checks never create it.
+func readApplied(ctx context.Context, q querier) (map[int64]applied, error) {
+	done := map[int64]applied{}
+	rows, err := q.Query(ctx, `SELECT version, checksum, applied_at FROM schema_versions`)
+	if err == nil {
+		for rows.Next() {
+			var v int64
+			var a applied
+			if err := rows.Scan(&v, &a.checksum, &a.appliedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			done[v] = a
+		}
+		err = rows.Err()
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		return map[int64]applied{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("migrate: read schema_versions: %w", err)
+	}
+	return done, nil
+}
@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/1sh-repalto/e2ee-file-sharing-platform/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func script(s string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(s)}
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse(fstest.MapFS{
+		"20250102000000_add_b.up.sql":      script("ALTER TABLE a ADD COLUMN b INT;"),
+		"20250102000000_add_b.down.sql":    script("ALTER TABLE a DROP COLUMN b;"),
+		"20250101000000_create_a.up.sql":   script("CREATE TABLE a (id INT);"),
+		"20250101000000_create_a.down.sql": script("DROP TABLE a;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 20250101000000, Name: "create_a", Up: "CREATE TABLE a (id INT);", Down: "DROP TABLE a;"},
+		{Version: 20250102000000, Name: "add_b", Up: "ALTER TABLE a ADD COLUMN b INT;", Down: "ALTER TABLE a DROP COLUMN b;"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got[0].Checksum() == got[1].Checksum() {
+		t.Error("different scripts share a checksum")
+	}
+}
+
+func TestParseRejectsMalformedSets(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr string
+	}{
+		{"missing down", fstest.MapFS{
+			"1_create_a.up.sql": script("CREATE TABLE a (id INT);"),
+		}, "both an up and a down"},
+		{"version reused", fstest.MapFS{
+			"1_create_a.up.sql":   script("CREATE TABLE a (id INT);"),
+			"1_create_a.down.sql": script("DROP TABLE a;"),
+			"1_create_b.up.sql":   script("CREATE TABLE b (id INT);"),
+			"1_create_b.down.sql": script("DROP TABLE b;"),
+		}, "used by both"},
+		{"stray file", fstest.MapFS{
+			"1_create_a.sql": script("CREATE TABLE a (id INT);"),
+		}, "unexpected file"},
+		{"zero version", fstest.MapFS{
+			"0_create_a.up.sql":   script("CREATE TABLE a (id INT);"),
+			"0_create_a.down.sql": script("DROP TABLE a;"),
+		}, "bad version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsParse(t *testing.T) {
+	ms, err := Parse(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+}
+
+// TestRoundTrip applies, rolls back and reapplies the embedded migrations
+// against MIGRATE_TEST_DB_URL. It drops every table it created, so point it
+// at an empty database.
+func TestRoundTrip(t *testing.T) {
+	dsn := os.Getenv("MIGRATE_TEST_DB_URL")
+	if dsn == "" {
+		t.Skip("MIGRATE_TEST_DB_URL not set")
+	}
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaOutOfDate) {
+		t.Fatalf("Check on an empty database = %v, want ErrSchemaOutOfDate", err)
+	}
+
+	// Replicas starting together must not apply anything twice.
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = m.Up(ctx)
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatalf("concurrent Up: %v", err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range statuses {
+		if applied, wantApplied := s.AppliedAt != nil, i < len(statuses)-2; applied != wantApplied {
+			t.Errorf("%s applied = %v, want %v", s.Migration, applied, wantApplied)
+		}
+	}
+
+	if err := m.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// testParams keeps the round trips fast; the encoding is the same for any
+// parameters.
+var testParams = Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestRoundTrip(t *testing.T) {
+	encoded, err := Hash("correct horse battery staple", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Fatalf("encoded = %q, want PHC format with the given parameters", encoded)
+	}
+
+	ok, err := Verify("correct horse battery staple", encoded)
+	if err != nil || !ok {
+		t.Fatalf("Verify(right password) = %v, %v", ok, err)
+	}
+	ok, err = Verify("correct horse battery stapler", encoded)
+	if err != nil || ok {
+		t.Fatalf("Verify(wrong password) = %v, %v", ok, err)
+	}
+
+	again, _ := Hash("correct horse battery staple", testParams)
+	if again == encoded {
+		t.Fatal("two hashes of one password share a salt")
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"too few segments", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA"},
+		{"too many segments", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$a2V5$extra"},
+		{"no leading dollar", "argon2id$v=19$m=65536,t=3,p=4$c2FsdA$a2V5$"},
+		{"wrong algorithm", "$argon2i$v=19$m=65536,t=3,p=4$c2FsdA$a2V5"},
+		{"bcrypt", "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{"bad version", "$argon2id$v=nineteen$m=65536,t=3,p=4$c2FsdA$a2V5"},
+		{"unsupported version", "$argon2id$v=16$m=65536,t=3,p=4$c2FsdA$a2V5"},
+		{"bad params", "$argon2id$v=19$m=lots,t=3,p=4$c2FsdA$a2V5"},
+		{"missing param", "$argon2id$v=19$m=65536,t=3$c2FsdA$a2V5"},
+		{"zero memory", "$argon2id$v=19$m=0,t=3,p=4$c2FsdA$a2V5"},
+		{"zero iterations", "$argon2id$v=19$m=65536,t=0,p=4$c2FsdA$a2V5"},
+		{"zero parallelism", "$argon2id$v=19$m=65536,t=3,p=0$c2FsdA$a2V5"},
+		{"bad salt base64", "$argon2id$v=19$m=65536,t=3,p=4$c2Fs!A$a2V5"},
+		{"padded salt", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA==$a2V5"},
+		{"empty salt", "$argon2id$v=19$m=65536,t=3,p=4$$a2V5"},
+		{"bad hash base64", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$a2V*"},
+		{"empty hash", "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := Verify("password", tt.encoded)
+			if ok || !errors.Is(err, ErrInvalidHash) {
+				t.Fatalf("Verify = %v, %v, want ErrInvalidHash", ok, err)
+			}
+			if !NeedsRehash(tt.encoded, DefaultParams) {
+				t.Fatal("NeedsRehash = false for an unreadable hash")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current, err := Hash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRehash(current, testParams) {
+		t.Fatal("NeedsRehash = true for the current parameters")
+	}
+
+	stronger := []struct {
+		name string
+		edit func(*Params)
+	}{
+		{"memory", func(p *Params) { p.Memory *= 2 }},
+		{"iterations", func(p *Params) { p.Iterations++ }},
+		{"parallelism", func(p *Params) { p.Parallelism++ }},
+		{"salt length", func(p *Params) { p.SaltLength++ }},
+		{"key length", func(p *Params) { p.KeyLength++ }},
+	}
+	for _, tt := range stronger {
+		p := testParams
+		tt.edit(&p)
+		if !NeedsRehash(current, p) {
+			t.Errorf("NeedsRehash = false after raising %s", tt.name)
+		}
+	}
+}
+
+// legacyHash is what 20250910090000_encode_password_hashes_as_phc turns a
+// pre-PHC hash of "correct horse battery staple" into: the raw Argon2id key
+// over the constant salt "static-salt", base64 encoded without padding
+// behind the fixed legacy prefix.
+const legacyHash = "$argon2id$v=19$m=65536,t=1,p=4$c3RhdGljLXNhbHQ$0j+K1xi2mSSw+AsXEdH4LPV6iijiSN4zR46ZG+hzO88"
+
+func TestVerifyMigratedLegacyHash(t *testing.T) {
+	// Rebuild the vector the way the migration does, so a change to either
+	// side shows up here.
+	key := argon2.IDKey([]byte("correct horse battery staple"), []byte("static-salt"), 1, 64*1024, 4, 32)
+	migrated := "$argon2id$v=19$m=65536,t=1,p=4$c3RhdGljLXNhbHQ$" + strings.TrimRight(base64.StdEncoding.EncodeToString(key), "=")
+	if migrated != legacyHash {
+		t.Fatalf("migrated hash = %q, want %q", migrated, legacyHash)
+	}
+
+	ok, err := Verify("correct horse battery staple", legacyHash)
+	if err != nil || !ok {
+		t.Fatalf("Verify(legacy hash) = %v, %v", ok, err)
+	}
+	if ok, _ := Verify("Correct horse battery staple", legacyHash); ok {
+		t.Fatal("legacy hash accepted a wrong password")
+	}
+	if !NeedsRehash(legacyHash, DefaultParams) {
+		t.Fatal("legacy hash does not need a rehash")
+	}
+}
@@ -0,0 +1,42 @@
+// Package pubkey describes the SPKI-encoded public keys users register so
+// clients can show and compare them before wrapping file keys.
+package pubkey
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+)
+
+// Fingerprint is the lowercase hex SHA-256 of the DER-encoded key.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// Algorithm names the key type as clients should use it. Keys that cannot
+// be parsed are reported as "unknown" rather than failing the lookup.
+func Algorithm(der []byte) string {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return "unknown"
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-OAEP-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "EC-" + k.Curve.Params().Name
+	case *ecdh.PublicKey:
+		return fmt.Sprintf("ECDH-%v", k.Curve())
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
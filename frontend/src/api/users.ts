@@ -1,6 +1,6 @@
 import axios from "axios";
 
 export async function getPublicKeyBase64(recipientId: string): Promise<string> {
-    const res = await axios.get(`api/users/${recipientId}/publicKey`);
-    return res.data.publicKeyBase64;
+    const res = await axios.get(`api/users/${recipientId}/public-key`);
+    return res.data.public_key;
 }
\ No newline at end of file